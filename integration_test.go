@@ -1,21 +1,39 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
 	"database/sql"
 	"embed"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/big"
 	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"internal-transfers/internal/config"
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/fx"
+	"internal-transfers/internal/handler"
 	"internal-transfers/internal/server"
 
 	"github.com/google/uuid"
@@ -29,10 +47,38 @@ import (
 //go:embed migrations/*.sql
 var migrationsFS embed.FS
 
+// testAdminKey is the admin API key the suite's client authenticates with
+const testAdminKey = "test-admin-key"
+
+// The suite's JWTs are issued by a local keypair, published in a JWKS file
+const (
+	testJWTIssuer   = "https://idp.test"
+	testJWTAudience = "internal-transfers"
+)
+
+// bearerTransport authenticates requests with a key unless they carry credentials of their own
+type bearerTransport struct {
+	key string
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+t.key)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
 type IntegrationTestSuite struct {
 	suite.Suite
 	postgresContainer testcontainers.Container
 	serverInstance    *server.Server
+	rateServer        *httptest.Server // Stub rate service behind FX conversions
+	eventSink         *eventSink
+	eventServer       *httptest.Server // Webhook receiving outbox events
+	jwtKey            *rsa.PrivateKey  // Signs the suite's JWTs as key "test-rsa"
+	jwksFile          string
+	config            *config.Config // Configuration the server was started with
 	serverPort        string
 	baseURL           string
 	client            *http.Client
@@ -91,7 +137,8 @@ func (suite *IntegrationTestSuite) SetupSuite() {
 	}
 
 	suite.client = &http.Client{
-		Timeout: 30 * time.Second,
+		Transport: &bearerTransport{key: testAdminKey},
+		Timeout:   30 * time.Second,
 	}
 }
 
@@ -147,7 +194,62 @@ func (suite *IntegrationTestSuite) startApplicationServer() error {
 		DBPassword: "password",
 		DBName:     "internal_transfers",
 		ServerPort: "0", // Let OS choose a free port
+
+		SchedulerInterval: 200 * time.Millisecond,
+
+		LimitPerTransfer:  decimal.NewFromInt(1_000_000_000),
+		MaxInitialBalance: decimal.NewFromInt(10_000_000_000),
+
+		FeeAccountID: 1901,
+
+		AuthEnabled: true,
+		AdminAPIKey: testAdminKey,
+	}
+
+	// Accept JWTs signed by a local keypair instead of a live identity provider
+	jwtKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
 	}
+	suite.jwtKey = jwtKey
+	suite.jwksFile = filepath.Join(suite.T().TempDir(), "jwks.json")
+	if err := writeJWKS(suite.jwksFile, map[string]crypto.PublicKey{"test-rsa": &jwtKey.PublicKey}); err != nil {
+		return err
+	}
+	cfg.JWTJWKSFile = suite.jwksFile
+	cfg.JWTIssuer = testJWTIssuer
+	cfg.JWTAudience = testJWTAudience
+
+	// Serve exchange rates from a local stub of the rate service
+	rates, err := fx.NewStaticRateProvider(map[string]decimal.Decimal{
+		"EUR/JPY": decimal.RequireFromString("160.5"),
+		"USD/EUR": decimal.RequireFromString("0.92"),
+	})
+	if err != nil {
+		return err
+	}
+	suite.rateServer = httptest.NewServer(fx.StubHandler(rates))
+	cfg.FXRateURL = suite.rateServer.URL
+	cfg.FXRateTimeout = 2 * time.Second
+	cfg.FXQuoteTTL = time.Minute
+
+	// Publish outbox events to a local webhook
+	suite.eventSink = newEventSink()
+	suite.eventServer = httptest.NewServer(suite.eventSink)
+	cfg.EventPublisher = "webhook"
+	cfg.EventWebhookURL = suite.eventServer.URL
+	cfg.EventWebhookTimeout = 2 * time.Second
+	cfg.OutboxPollInterval = 100 * time.Millisecond
+	cfg.OutboxRetryBaseDelay = 200 * time.Millisecond
+	cfg.OutboxRetryMaxBackoff = time.Second
+	cfg.OutboxMaxAttempts = 3
+
+	// Dispatch webhook subscriptions quickly, dead-lettering after a few attempts
+	cfg.WebhookDispatchInterval = 100 * time.Millisecond
+	cfg.WebhookTimeout = 2 * time.Second
+	cfg.WebhookMaxAttempts = 3
+	cfg.WebhookRetryBaseDelay = 100 * time.Millisecond
+	cfg.WebhookRetryMaxBackoff = 200 * time.Millisecond
 
 	// Get the actual port from the container
 	ctx := context.Background()
@@ -162,6 +264,7 @@ func (suite *IntegrationTestSuite) startApplicationServer() error {
 	if err != nil {
 		return err
 	}
+	suite.config = cfg
 
 	suite.serverInstance = serverInstance
 	suite.serverPort = port
@@ -171,6 +274,201 @@ func (suite *IntegrationTestSuite) startApplicationServer() error {
 	return suite.waitForServerReady()
 }
 
+// writeJWKS publishes public keys by key ID as a JWKS file
+func writeJWKS(path string, keys map[string]crypto.PublicKey) error {
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+	var set []map[string]string
+	for kid, key := range keys {
+		switch public := key.(type) {
+		case *rsa.PublicKey:
+			set = append(set, map[string]string{
+				"kty": "RSA", "kid": kid, "use": "sig", "alg": "RS256",
+				"n": encode(public.N.Bytes()), "e": encode(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			point, err := public.Bytes()
+			if err != nil {
+				return err
+			}
+			set = append(set, map[string]string{
+				"kty": "EC", "kid": kid, "use": "sig", "crv": "P-256",
+				"x": encode(point[1:33]), "y": encode(point[33:]),
+			})
+		default:
+			return fmt.Errorf("unsupported key type %T", key)
+		}
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"keys": set})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// signJWT issues a token signed with an RSA (RS256) or P-256 (ES256) key
+func signJWT(key crypto.Signer, kid string, claims map[string]interface{}) string {
+	alg := "RS256"
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		alg = "ES256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, _ := ecdsa.Sign(rand.Reader, k, digest[:])
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// eventSink records the events delivered by the outbox relay. The first delivery of each event
+// concerning an account registered with failFirst is rejected, to exercise retries.
+type eventSink struct {
+	mu         sync.Mutex
+	events     []domain.Event
+	failFirst  map[int64]bool
+	failAlways map[int64]bool
+	rejected   map[uuid.UUID]bool
+}
+
+func newEventSink() *eventSink {
+	return &eventSink{failFirst: make(map[int64]bool), failAlways: make(map[int64]bool), rejected: make(map[uuid.UUID]bool)}
+}
+
+func (s *eventSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var event domain.Event
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range event.AccountIDs {
+		if s.failAlways[id] {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if s.failFirst[id] && !s.rejected[event.ID] {
+			s.rejected[event.ID] = true
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	s.events = append(s.events, event)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// eventsFor returns the events received for an account in delivery order, without redeliveries
+func (s *eventSink) eventsFor(accountID int64) []domain.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[uuid.UUID]bool)
+	var events []domain.Event
+	for _, event := range s.events {
+		for _, id := range event.AccountIDs {
+			if id == accountID && !seen[event.ID] {
+				seen[event.ID] = true
+				events = append(events, event)
+			}
+		}
+	}
+	return events
+}
+
+// webhookReceiver records the requests made to a webhook subscription and answers with status
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []webhookRequest
+}
+
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+	event  domain.Event
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var event domain.Event
+	json.Unmarshal(body, &event)
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	rcv.requests = append(rcv.requests, webhookRequest{header: r.Header.Clone(), body: body, event: event})
+	w.WriteHeader(rcv.status)
+}
+
+// received returns the requests carrying events of a type that concern an account
+func (rcv *webhookReceiver) received(eventType string, accountID int64) []webhookRequest {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+
+	var requests []webhookRequest
+	for _, req := range rcv.requests {
+		for _, id := range req.event.AccountIDs {
+			if req.event.Type == eventType && id == accountID {
+				requests = append(requests, req)
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// sseEvent is one Server-Sent Event read from an account stream
+type sseEvent struct {
+	id    string
+	event string
+	data  map[string]interface{}
+}
+
+// sseStream reads the events of an open account stream, skipping comments
+type sseStream struct {
+	resp   *http.Response
+	reader *bufio.Reader
+	cancel context.CancelFunc
+}
+
+func (s *sseStream) next() (sseEvent, error) {
+	var event sseEvent
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return event, err
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && event.event != "":
+			return event, nil
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.data); err != nil {
+				return event, err
+			}
+		}
+	}
+}
+
+func (s *sseStream) close() {
+	s.cancel()
+	s.resp.Body.Close()
+}
+
 func (suite *IntegrationTestSuite) waitForServerReady() error {
 	timeout := 30 * time.Second
 	start := time.Now()
@@ -197,6 +495,14 @@ func (suite *IntegrationTestSuite) TearDownSuite() {
 		suite.serverInstance.Stop(ctx)
 	}
 
+	if suite.rateServer != nil {
+		suite.rateServer.Close()
+	}
+
+	if suite.eventServer != nil {
+		suite.eventServer.Close()
+	}
+
 	if suite.postgresContainer != nil {
 		suite.postgresContainer.Terminate(ctx)
 	}
@@ -245,6 +551,23 @@ func (suite *IntegrationTestSuite) getAccount(accountID int64) (*http.Response,
 	return newResp, string(respBody), nil
 }
 
+func (suite *IntegrationTestSuite) get(path string) (*http.Response, string, error) {
+	resp, err := suite.client.Get(suite.baseURL + path)
+	if err != nil {
+		return resp, "", err
+	}
+
+	respBody, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	newResp := &http.Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+	}
+
+	return newResp, string(respBody), nil
+}
+
 func (suite *IntegrationTestSuite) transfer(sourceID, destID int64, amount string, idempotencyKey ...string) (*http.Response, string, error) {
 	reqBody := map[string]interface{}{
 		"source_account_id":      sourceID,
@@ -274,6 +597,63 @@ func (suite *IntegrationTestSuite) transfer(sourceID, destID int64, amount strin
 	return newResp, string(respBody), nil
 }
 
+func (suite *IntegrationTestSuite) post(path string, payload interface{}, headers map[string]string) (*http.Response, string, error) {
+	body, _ := json.Marshal(payload)
+
+	req, err := http.NewRequest(http.MethodPost, suite.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := suite.client.Do(req)
+	if err != nil {
+		return resp, "", err
+	}
+
+	respBody, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	newResp := &http.Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+	}
+
+	return newResp, string(respBody), nil
+}
+
+func (suite *IntegrationTestSuite) request(method, path string, payload interface{}) (*http.Response, string, error) {
+	var body io.Reader
+	if payload != nil {
+		encoded, _ := json.Marshal(payload)
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, suite.baseURL+path, body)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := suite.client.Do(req)
+	if err != nil {
+		return resp, "", err
+	}
+
+	respBody, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	newResp := &http.Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+	}
+
+	return newResp, string(respBody), nil
+}
+
 // Helper to parse response and log errors
 func (suite *IntegrationTestSuite) parseResponse(body string) (map[string]interface{}, error) {
 	var response map[string]interface{}
@@ -407,6 +787,7 @@ func (suite *IntegrationTestSuite) stepIdempotentTransfer() {
 	assert.NoError(suite.T(), err)
 	suite.T().Logf("First Transfer Response: %s", body)
 	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	assert.Empty(suite.T(), resp.Header.Get("Idempotent-Replayed"))
 
 	response, err := suite.parseResponse(body)
 	assert.NoError(suite.T(), err)
@@ -421,11 +802,12 @@ func (suite *IntegrationTestSuite) stepIdempotentTransfer() {
 		assert.NotEmpty(suite.T(), firstTransactionID)
 	}
 
-	// Second transfer with same idempotency key
-	resp, body, err = suite.transfer(123, 456, "100.00", idempotencyKey)
+	// Second transfer with same idempotency key (amount formatted differently)
+	resp, body, err = suite.transfer(123, 456, "100", idempotencyKey)
 	assert.NoError(suite.T(), err)
 	suite.T().Logf("Second Transfer Response: %s", body)
 	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	assert.Equal(suite.T(), "true", resp.Header.Get("Idempotent-Replayed"))
 
 	response, err = suite.parseResponse(body)
 	assert.NoError(suite.T(), err)
@@ -440,6 +822,18 @@ func (suite *IntegrationTestSuite) stepIdempotentTransfer() {
 		assert.Equal(suite.T(), "completed", transferData["status"])
 	}
 
+	// Same key with a different payload is rejected
+	resp, body, err = suite.transfer(123, 456, "99.00", idempotencyKey)
+	assert.NoError(suite.T(), err)
+	suite.T().Logf("Reused Key Response: %s", body)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	if errorData, hasError := response["error"]; assert.True(suite.T(), hasError) {
+		assert.Equal(suite.T(), "idempotency_key_reused", errorData.(map[string]interface{})["code"])
+	}
+
 	// Verify balance only changed once
 	_, body, err = suite.getAccount(123)
 	assert.NoError(suite.T(), err)
@@ -512,6 +906,2822 @@ func (suite *IntegrationTestSuite) stepInsufficientBalance() {
 	}
 }
 
+func (suite *IntegrationTestSuite) stepFailedTransferReplay() {
+	idempotencyKey := uuid.New().String()
+
+	// First attempt is declined but persisted as failed
+	resp, body, err := suite.transfer(123, 456, "10000.00", idempotencyKey)
+	assert.NoError(suite.T(), err)
+	suite.T().Logf("Failed Transfer Response: %s", body)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+
+	response, err := suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+
+	var firstDetails string
+	if errorData, hasError := response["error"]; assert.True(suite.T(), hasError) {
+		errorInfo := errorData.(map[string]interface{})
+		assert.Equal(suite.T(), "insufficient_balance", errorInfo["code"])
+		firstDetails, _ = errorInfo["details"].(string)
+		assert.Contains(suite.T(), firstDetails, "transaction_id")
+	}
+
+	// Replay returns the original failure for the same transaction
+	resp, body, err = suite.transfer(123, 456, "10000.00", idempotencyKey)
+	assert.NoError(suite.T(), err)
+	suite.T().Logf("Failed Transfer Replay Response: %s", body)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+
+	if errorData, hasError := response["error"]; assert.True(suite.T(), hasError) {
+		errorInfo := errorData.(map[string]interface{})
+		assert.Equal(suite.T(), "insufficient_balance", errorInfo["code"])
+		assert.Equal(suite.T(), firstDetails, errorInfo["details"])
+	}
+
+	// Verify the failed row was committed with its reason
+	db, err := sql.Open("postgres", suite.dbConnStr)
+	assert.NoError(suite.T(), err)
+	defer db.Close()
+
+	var status, reason string
+	err = db.QueryRow(`SELECT status, failure_reason FROM transactions WHERE idempotency_key = $1`, idempotencyKey).
+		Scan(&status, &reason)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "failed", status)
+	assert.Equal(suite.T(), "insufficient_balance", reason)
+}
+
+func (suite *IntegrationTestSuite) stepLedgerAudit() {
+	for _, accountID := range []int64{123, 456} {
+		resp, body, err := suite.get(fmt.Sprintf("/accounts/%d/ledger", accountID))
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("Ledger Response: %s", body)
+		assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+
+		data, hasData := response["data"]
+		assert.True(suite.T(), hasData, "Response should have 'data' field")
+
+		if hasData {
+			ledgerData := data.(map[string]interface{})
+			assert.Equal(suite.T(), true, ledgerData["balanced"])
+			suite.assertDecimalEqual(ledgerData["balance"].(string), ledgerData["ledger_balance"].(string))
+
+			// Opening credit plus one entry per completed transfer
+			entries := ledgerData["entries"].([]interface{})
+			assert.Len(suite.T(), entries, 5)
+		}
+	}
+}
+
+func (suite *IntegrationTestSuite) listTransactions(path string) ([]interface{}, string) {
+	resp, body, err := suite.get(path)
+	assert.NoError(suite.T(), err)
+	suite.T().Logf("Statement Response: %s", body)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+	response, err := suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+
+	data, hasData := response["data"]
+	if !assert.True(suite.T(), hasData, "Response should have 'data' field") {
+		return nil, ""
+	}
+
+	statement := data.(map[string]interface{})
+	cursor, _ := statement["next_cursor"].(string)
+	return statement["transactions"].([]interface{}), cursor
+}
+
+func (suite *IntegrationTestSuite) stepAccountStatement() {
+	// Walk the full statement two rows at a time
+	var all []interface{}
+	path := "/accounts/123/transactions?limit=2"
+	for {
+		page, cursor := suite.listTransactions(path)
+		assert.LessOrEqual(suite.T(), len(page), 2)
+		all = append(all, page...)
+		if cursor == "" {
+			break
+		}
+		path = "/accounts/123/transactions?limit=2&cursor=" + cursor
+	}
+
+	// Four completed transfers and two declined ones, all debits for account 123
+	assert.Len(suite.T(), all, 6)
+	seen := map[string]bool{}
+	for _, item := range all {
+		entry := item.(map[string]interface{})
+		assert.Equal(suite.T(), "debit", entry["direction"])
+		seen[entry["transaction_id"].(string)] = true
+	}
+	assert.Len(suite.T(), seen, 6, "pages should not overlap")
+
+	failed, _ := suite.listTransactions("/accounts/123/transactions?status=failed")
+	assert.Len(suite.T(), failed, 2)
+
+	credits, _ := suite.listTransactions("/accounts/123/transactions?direction=credit")
+	assert.Len(suite.T(), credits, 0)
+
+	credits, _ = suite.listTransactions("/accounts/456/transactions?direction=credit&status=completed")
+	assert.Len(suite.T(), credits, 4)
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	later, _ := suite.listTransactions("/accounts/456/transactions?from=" + future)
+	assert.Len(suite.T(), later, 0)
+
+	resp, _, err := suite.get("/accounts/123/transactions?direction=sideways")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+}
+
+func (suite *IntegrationTestSuite) stepTransactionLookup() {
+	resp, _, err := suite.createAccount(701, "100.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	resp, _, err = suite.createAccount(702, "0")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+	idempotencyKey := uuid.New().String()
+	resp, body, err := suite.transfer(701, 702, "25.00", idempotencyKey)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+	response, err := suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	transactionID := response["data"].(map[string]interface{})["transaction_id"].(string)
+
+	for _, path := range []string{
+		"/transactions/" + transactionID,
+		"/transactions?idempotency_key=" + idempotencyKey,
+	} {
+		resp, body, err := suite.get(path)
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("Transaction Lookup Response: %s", body)
+		assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+
+		if data, hasData := response["data"]; assert.True(suite.T(), hasData) {
+			tx := data.(map[string]interface{})
+			assert.Equal(suite.T(), transactionID, tx["transaction_id"])
+			assert.Equal(suite.T(), float64(701), tx["source_account_id"])
+			assert.Equal(suite.T(), float64(702), tx["destination_account_id"])
+			suite.assertDecimalEqual("25.00", tx["amount"].(string))
+			assert.Equal(suite.T(), "completed", tx["status"])
+			assert.Equal(suite.T(), idempotencyKey, tx["idempotency_key"])
+			assert.NotEmpty(suite.T(), tx["created_at"])
+			assert.NotEmpty(suite.T(), tx["updated_at"])
+		}
+	}
+
+	for _, path := range []string{
+		"/transactions/" + uuid.New().String(),
+		"/transactions?idempotency_key=" + uuid.New().String(),
+	} {
+		resp, body, err := suite.get(path)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusNotFound, resp.StatusCode)
+
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		if errorData, hasError := response["error"]; assert.True(suite.T(), hasError) {
+			assert.Equal(suite.T(), "transaction_not_found", errorData.(map[string]interface{})["code"])
+		}
+	}
+
+	resp, _, err = suite.get("/transactions/not-a-uuid")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+}
+
+func (suite *IntegrationTestSuite) stepIdempotencyKeyHeader() {
+	// Keys are scoped per client, which is the principal of the credentials
+	keyA, _ := suite.createAPIKey("client a", domain.ScopeAccountsRead, domain.ScopeAccountsWrite, domain.ScopeTransfersWrite)
+	keyB, keyBID := suite.createAPIKey("client b", domain.ScopeAccountsRead, domain.ScopeTransfersWrite)
+
+	clientA := map[string]string{"Idempotency-Key": "create-801", "Authorization": "Bearer " + keyA}
+	account := map[string]interface{}{"account_id": 801, "initial_balance": "50.00"}
+
+	resp, body, err := suite.post("/accounts", account, clientA)
+	assert.NoError(suite.T(), err)
+	suite.T().Logf("Create Account With Key Response: %s", body)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	assert.Empty(suite.T(), resp.Header.Get("Idempotent-Replayed"))
+
+	// Replaying the creation returns the account instead of duplicate_account
+	resp, _, err = suite.post("/accounts", account, clientA)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	assert.Equal(suite.T(), "true", resp.Header.Get("Idempotent-Replayed"))
+
+	resp, _, err = suite.post("/accounts", map[string]interface{}{"account_id": 801, "initial_balance": "60.00"}, clientA)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+
+	// Opaque keys are scoped per client
+	transfer := map[string]interface{}{"source_account_id": 801, "destination_account_id": 702, "amount": "5.00"}
+	transferA := map[string]string{"Idempotency-Key": "order-42", "Authorization": "Bearer " + keyA}
+	transferB := map[string]string{"Idempotency-Key": "order-42", "Authorization": "Bearer " + keyB}
+
+	transactionIDs := make([]string, 0, 3)
+	for _, headers := range []map[string]string{transferA, transferA, transferB} {
+		resp, body, err := suite.post("/transactions", transfer, headers)
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("Transfer With Key Header Response: %s", body)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		transferData := response["data"].(map[string]interface{})
+		assert.Equal(suite.T(), "order-42", transferData["idempotency_key"])
+		transactionIDs = append(transactionIDs, transferData["transaction_id"].(string))
+	}
+
+	assert.Equal(suite.T(), transactionIDs[0], transactionIDs[1])
+	assert.NotEqual(suite.T(), transactionIDs[0], transactionIDs[2])
+
+	// Each client looks a key up among its own transactions only. The X-Client-ID header cannot
+	// claim another client's keys once authenticated.
+	for key, transactionID := range map[string]string{keyA: transactionIDs[0], keyB: transactionIDs[2]} {
+		resp, response := suite.requestWithKey(key, http.MethodGet, "/transactions?idempotency_key=order-42", nil)
+		assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+		assert.Equal(suite.T(), transactionID, response["data"].(map[string]interface{})["transaction_id"])
+	}
+	resp, body, err = suite.post("/transactions", transfer, map[string]string{"Idempotency-Key": "order-43", "Authorization": "Bearer " + keyB})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode, body)
+	resp, _ = suite.requestWithKey(keyA, http.MethodGet, "/transactions?idempotency_key=order-43", nil)
+	assert.Equal(suite.T(), http.StatusNotFound, resp.StatusCode)
+	resp, body, err = suite.request(http.MethodGet, "/transactions?idempotency_key=order-43", nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotFound, resp.StatusCode, body)
+	req, err := http.NewRequest(http.MethodGet, suite.baseURL+"/transactions?idempotency_key=order-43", nil)
+	assert.NoError(suite.T(), err)
+	req.Header.Set("Authorization", "Bearer "+keyA)
+	req.Header.Set("X-Client-ID", "api_key:"+keyBID)
+	resp, err = suite.client.Do(req)
+	if assert.NoError(suite.T(), err) {
+		resp.Body.Close()
+		assert.Equal(suite.T(), http.StatusNotFound, resp.StatusCode)
+	}
+
+	_, body, err = suite.getAccount(801)
+	assert.NoError(suite.T(), err)
+	response, err := suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	suite.assertDecimalEqual("35.00", response["data"].(map[string]interface{})["balance"].(string))
+
+	// Header and body keys must agree
+	transfer["idempotency_key"] = "something-else"
+	resp, _, err = suite.post("/transactions", transfer, transferA)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+}
+
+func (suite *IntegrationTestSuite) stepBatchTransfer() {
+	for _, account := range []struct {
+		id      int64
+		balance string
+	}{{901, "100.00"}, {902, "0.00"}, {903, "0.00"}} {
+		resp, _, err := suite.createAccount(account.id, account.balance)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	}
+
+	overdrawn := []map[string]interface{}{
+		{"source_account_id": 901, "destination_account_id": 902, "amount": "60.00"},
+		{"source_account_id": 901, "destination_account_id": 903, "amount": "60.00"},
+	}
+
+	// A batch idempotency key is required
+	resp, _, err := suite.post("/transactions/batch", map[string]interface{}{"transfers": overdrawn}, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+
+	// Atomic: the second item would overdraw 901, so nothing is posted
+	atomic := map[string]interface{}{"mode": "atomic", "idempotency_key": "batch-atomic-1", "transfers": overdrawn}
+	resp, body, err := suite.post("/transactions/batch", atomic, nil)
+	assert.NoError(suite.T(), err)
+	suite.T().Logf("Atomic Batch Response: %s", body)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+
+	response, err := suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	if errorData, hasError := response["error"]; assert.True(suite.T(), hasError) {
+		errorInfo := errorData.(map[string]interface{})
+		assert.Equal(suite.T(), "insufficient_balance", errorInfo["code"])
+		assert.Contains(suite.T(), errorInfo["details"], "item: 1")
+	}
+
+	_, body, err = suite.getAccount(901)
+	assert.NoError(suite.T(), err)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	suite.assertDecimalEqual("100.00", response["data"].(map[string]interface{})["balance"].(string))
+
+	// Best effort: the first item commits and the second is reported as failed
+	bestEffort := map[string]interface{}{"mode": "best_effort", "idempotency_key": "batch-best-effort-1", "transfers": overdrawn}
+	var batchID string
+	for attempt := 0; attempt < 2; attempt++ {
+		resp, body, err := suite.post("/transactions/batch", bestEffort, nil)
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("Best Effort Batch Response: %s", body)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		batchData := response["data"].(map[string]interface{})
+		assert.Equal(suite.T(), "partially_completed", batchData["status"])
+
+		results := batchData["results"].([]interface{})
+		if assert.Len(suite.T(), results, 2) {
+			assert.Equal(suite.T(), "completed", results[0].(map[string]interface{})["status"])
+			failed := results[1].(map[string]interface{})
+			assert.Equal(suite.T(), "failed", failed["status"])
+			assert.Equal(suite.T(), "insufficient_balance", failed["error"].(map[string]interface{})["code"])
+		}
+
+		if attempt == 0 {
+			batchID = batchData["batch_id"].(string)
+			assert.Empty(suite.T(), resp.Header.Get("Idempotent-Replayed"))
+		} else {
+			assert.Equal(suite.T(), batchID, batchData["batch_id"])
+			assert.Equal(suite.T(), "true", resp.Header.Get("Idempotent-Replayed"))
+		}
+	}
+
+	// Atomic success moves every item together
+	resp, body, err = suite.post("/transactions/batch", map[string]interface{}{
+		"transfers": []map[string]interface{}{
+			{"source_account_id": 902, "destination_account_id": 903, "amount": "10.00"},
+			{"source_account_id": 901, "destination_account_id": 903, "amount": "10.00"},
+		},
+	}, map[string]string{"Idempotency-Key": "batch-atomic-2"})
+	assert.NoError(suite.T(), err)
+	suite.T().Logf("Atomic Batch Success Response: %s", body)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "completed", response["data"].(map[string]interface{})["status"])
+
+	for id, expected := range map[int64]string{901: "30.00", 902: "50.00", 903: "20.00"} {
+		_, body, err := suite.getAccount(id)
+		assert.NoError(suite.T(), err)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		suite.assertDecimalEqual(expected, response["data"].(map[string]interface{})["balance"].(string), "account %d", id)
+	}
+}
+
+func (suite *IntegrationTestSuite) stepTransferReversal() {
+	for _, account := range []struct {
+		id      int64
+		balance string
+	}{{1001, "100.00"}, {1002, "0.00"}} {
+		resp, _, err := suite.createAccount(account.id, account.balance)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	}
+
+	_, body, err := suite.transfer(1001, 1002, "80.00")
+	assert.NoError(suite.T(), err)
+	response, err := suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	originalID := response["data"].(map[string]interface{})["transaction_id"].(string)
+	reversePath := "/transactions/" + originalID + "/reverse"
+
+	// Partial reversal, replayed with the same key
+	var reversalID string
+	for attempt := 0; attempt < 2; attempt++ {
+		resp, body, err := suite.post(reversePath, map[string]interface{}{"amount": "30.00"}, map[string]string{"Idempotency-Key": "reverse-1001-1"})
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("Partial Reversal Response: %s", body)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		reversal := response["data"].(map[string]interface{})
+		assert.Equal(suite.T(), originalID, reversal["reversal_of"])
+		assert.Equal(suite.T(), float64(1002), reversal["source_account_id"])
+		assert.Equal(suite.T(), "completed", reversal["status"])
+
+		if attempt == 0 {
+			reversalID = reversal["transaction_id"].(string)
+		} else {
+			assert.Equal(suite.T(), reversalID, reversal["transaction_id"])
+			assert.Equal(suite.T(), "true", resp.Header.Get("Idempotent-Replayed"))
+		}
+	}
+
+	_, body, err = suite.get("/transactions/" + originalID)
+	assert.NoError(suite.T(), err)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	original := response["data"].(map[string]interface{})
+	assert.Equal(suite.T(), "partially_reversed", original["status"])
+	suite.assertDecimalEqual("30.00", original["reversed_amount"].(string))
+
+	// Only 50.00 is left to reverse
+	resp, body, err := suite.post(reversePath, map[string]interface{}{"amount": "60.00"}, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "reversal_exceeds_amount", response["error"].(map[string]interface{})["code"])
+
+	// Without an amount the remainder is reversed
+	resp, body, err = suite.post(reversePath, nil, nil)
+	assert.NoError(suite.T(), err)
+	suite.T().Logf("Full Reversal Response: %s", body)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	suite.assertDecimalEqual("50.00", response["data"].(map[string]interface{})["amount"].(string))
+
+	_, body, err = suite.get("/transactions/" + originalID)
+	assert.NoError(suite.T(), err)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "reversed", response["data"].(map[string]interface{})["status"])
+
+	// Fully reversed transfers and reversals themselves cannot be reversed
+	for _, path := range []string{reversePath, "/transactions/" + reversalID + "/reverse"} {
+		resp, body, err := suite.post(path, nil, nil)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), "transaction_not_reversible", response["error"].(map[string]interface{})["code"])
+	}
+
+	for id, expected := range map[int64]string{1001: "100.00", 1002: "0.00"} {
+		_, body, err := suite.getAccount(id)
+		assert.NoError(suite.T(), err)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		suite.assertDecimalEqual(expected, response["data"].(map[string]interface{})["balance"].(string), "account %d", id)
+	}
+}
+
+func (suite *IntegrationTestSuite) accountBalances(accountID int64) (string, string) {
+	_, body, err := suite.getAccount(accountID)
+	assert.NoError(suite.T(), err)
+	response, err := suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	data := response["data"].(map[string]interface{})
+	return data["balance"].(string), data["available_balance"].(string)
+}
+
+func (suite *IntegrationTestSuite) stepHolds() {
+	for _, account := range []struct {
+		id      int64
+		balance string
+	}{{1101, "100.00"}, {1102, "0.00"}} {
+		resp, _, err := suite.createAccount(account.id, account.balance)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	}
+
+	hold := map[string]interface{}{"source_account_id": 1101, "destination_account_id": 1102, "amount": "60.00"}
+	holdKey := map[string]string{"Idempotency-Key": "hold-1101-1"}
+
+	resp, body, err := suite.post("/holds", hold, holdKey)
+	assert.NoError(suite.T(), err)
+	suite.T().Logf("Create Hold Response: %s", body)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	response, err := suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	holdData := response["data"].(map[string]interface{})
+	assert.Equal(suite.T(), "active", holdData["status"])
+	holdID := holdData["hold_id"].(string)
+
+	balance, available := suite.accountBalances(1101)
+	suite.assertDecimalEqual("100.00", balance)
+	suite.assertDecimalEqual("40.00", available)
+
+	// Replaying the key returns the same hold without reserving again
+	resp, body, err = suite.post("/holds", hold, holdKey)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	assert.Equal(suite.T(), "true", resp.Header.Get("Idempotent-Replayed"))
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), holdID, response["data"].(map[string]interface{})["hold_id"])
+
+	// Transfers only see the available balance
+	resp, _, err = suite.transfer(1101, 1102, "50.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+
+	// Partial capture becomes a normal transfer and releases the rest of the hold
+	capturePath := "/holds/" + holdID + "/capture"
+	var transactionID string
+	for attempt := 0; attempt < 2; attempt++ {
+		resp, body, err := suite.post(capturePath, map[string]interface{}{"amount": "30.00"}, nil)
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("Capture Hold Response: %s", body)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		transaction := response["data"].(map[string]interface{})
+		assert.Equal(suite.T(), "completed", transaction["status"])
+
+		if attempt == 0 {
+			transactionID = transaction["transaction_id"].(string)
+		} else {
+			assert.Equal(suite.T(), transactionID, transaction["transaction_id"])
+			assert.Equal(suite.T(), "true", resp.Header.Get("Idempotent-Replayed"))
+		}
+	}
+
+	resp, _, err = suite.post(capturePath, map[string]interface{}{"amount": "10.00"}, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+
+	_, body, err = suite.get("/holds/" + holdID)
+	assert.NoError(suite.T(), err)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	holdData = response["data"].(map[string]interface{})
+	assert.Equal(suite.T(), "captured", holdData["status"])
+	assert.Equal(suite.T(), transactionID, holdData["transaction_id"])
+
+	balance, available = suite.accountBalances(1101)
+	suite.assertDecimalEqual("70.00", balance)
+	suite.assertDecimalEqual("70.00", available)
+
+	// Void releases the reservation
+	resp, body, err = suite.post("/holds", map[string]interface{}{"source_account_id": 1101, "destination_account_id": 1102, "amount": "20.00"}, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	voidPath := "/holds/" + response["data"].(map[string]interface{})["hold_id"].(string) + "/void"
+
+	resp, body, err = suite.post(voidPath, nil, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "voided", response["data"].(map[string]interface{})["status"])
+
+	_, available = suite.accountBalances(1101)
+	suite.assertDecimalEqual("70.00", available)
+
+	// An overdue hold cannot be captured and its funds are released
+	resp, body, err = suite.post("/holds", map[string]interface{}{
+		"source_account_id":      1101,
+		"destination_account_id": 1102,
+		"amount":                 "25.00",
+		"expires_at":             time.Now().Add(time.Second).UTC().Format(time.RFC3339Nano),
+	}, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	expiringID := response["data"].(map[string]interface{})["hold_id"].(string)
+
+	_, available = suite.accountBalances(1101)
+	suite.assertDecimalEqual("45.00", available)
+
+	time.Sleep(1500 * time.Millisecond)
+
+	resp, body, err = suite.post("/holds/"+expiringID+"/capture", nil, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "hold_not_active", response["error"].(map[string]interface{})["code"])
+
+	_, available = suite.accountBalances(1101)
+	suite.assertDecimalEqual("70.00", available)
+}
+
+// waitForScheduledTransfer polls a scheduled transfer until it leaves the scheduled and running states
+func (suite *IntegrationTestSuite) waitForScheduledTransfer(id string) map[string]interface{} {
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		_, body, err := suite.get("/scheduled-transfers/" + id)
+		assert.NoError(suite.T(), err)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		data := response["data"].(map[string]interface{})
+
+		status := data["status"]
+		if (status != "scheduled" && status != "running") || time.Now().After(deadline) {
+			return data
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func (suite *IntegrationTestSuite) stepScheduledTransfers() {
+	for _, account := range []struct {
+		id      int64
+		balance string
+	}{{1201, "100.00"}, {1202, "0.00"}} {
+		resp, _, err := suite.createAccount(account.id, account.balance)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	}
+
+	schedule := func(amount string, executeAt time.Time, headers map[string]string) (*http.Response, map[string]interface{}) {
+		resp, body, err := suite.post("/transactions", map[string]interface{}{
+			"source_account_id":      1201,
+			"destination_account_id": 1202,
+			"amount":                 amount,
+			"execute_at":             executeAt.UTC().Format(time.RFC3339Nano),
+		}, headers)
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("Schedule Transfer Response: %s", body)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		return resp, response
+	}
+
+	// execute_at must be in the future
+	resp, _ := schedule("10.00", time.Now().Add(-time.Minute), nil)
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+
+	executeAt := time.Now().Add(time.Second)
+	key := map[string]string{"Idempotency-Key": "schedule-1201-1"}
+	resp, response := schedule("40.00", executeAt, key)
+	assert.Equal(suite.T(), http.StatusAccepted, resp.StatusCode)
+	scheduled := response["data"].(map[string]interface{})
+	assert.Equal(suite.T(), "scheduled", scheduled["status"])
+	assert.Equal(suite.T(), "USD", scheduled["currency"])
+	dueID := scheduled["scheduled_transfer_id"].(string)
+
+	resp, response = schedule("40.00", executeAt, key)
+	assert.Equal(suite.T(), http.StatusAccepted, resp.StatusCode)
+	assert.Equal(suite.T(), "true", resp.Header.Get("Idempotent-Replayed"))
+	assert.Equal(suite.T(), dueID, response["data"].(map[string]interface{})["scheduled_transfer_id"])
+
+	// The requested currency is part of the request, so naming one reuses the key with a different payload
+	resp, body, err := suite.post("/transactions", map[string]interface{}{
+		"source_account_id":      1201,
+		"destination_account_id": 1202,
+		"amount":                 "40.00",
+		"currency":               "USD",
+		"execute_at":             executeAt.UTC().Format(time.RFC3339Nano),
+	}, key)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	if errorData, hasError := response["error"]; assert.True(suite.T(), hasError) {
+		assert.Equal(suite.T(), "idempotency_key_reused", errorData.(map[string]interface{})["code"])
+	}
+
+	// Nothing moves before execute_at
+	balance, _ := suite.accountBalances(1201)
+	suite.assertDecimalEqual("100.00", balance)
+
+	_, response = schedule("500.00", executeAt, nil)
+	overdrawnID := response["data"].(map[string]interface{})["scheduled_transfer_id"].(string)
+
+	// A transfer can be cancelled until the scheduler picks it up
+	_, response = schedule("30.00", time.Now().Add(time.Hour), nil)
+	cancelPath := "/scheduled-transfers/" + response["data"].(map[string]interface{})["scheduled_transfer_id"].(string) + "/cancel"
+	for attempt := 0; attempt < 2; attempt++ {
+		resp, body, err := suite.post(cancelPath, nil, nil)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), "cancelled", response["data"].(map[string]interface{})["status"])
+	}
+
+	completed := suite.waitForScheduledTransfer(dueID)
+	assert.Equal(suite.T(), "completed", completed["status"])
+	if transactionID, ok := completed["transaction_id"].(string); assert.True(suite.T(), ok) {
+		_, body, err := suite.get("/transactions/" + transactionID)
+		assert.NoError(suite.T(), err)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), "completed", response["data"].(map[string]interface{})["status"])
+		assert.Equal(suite.T(), "USD", response["data"].(map[string]interface{})["currency"])
+	}
+
+	failed := suite.waitForScheduledTransfer(overdrawnID)
+	assert.Equal(suite.T(), "failed", failed["status"])
+	assert.Equal(suite.T(), "insufficient_balance", failed["failure_reason"])
+
+	// A transfer left running by a worker from before scheduled transfers kept their currency is
+	// claimed again once its lease runs out, and replays the transaction fingerprinted without one
+	db, err := sql.Open("postgres", suite.dbConnStr)
+	assert.NoError(suite.T(), err)
+	defer db.Close()
+
+	legacyHash := sha256.Sum256([]byte("transfer|1201|1202|40"))
+	_, err = db.Exec(`UPDATE transactions SET request_hash = $1 WHERE id = $2`, hex.EncodeToString(legacyHash[:]), completed["transaction_id"])
+	assert.NoError(suite.T(), err)
+
+	tx, err := db.Begin()
+	assert.NoError(suite.T(), err)
+	// Keeps the updated_at trigger from renewing the lease
+	_, err = tx.Exec(`SET LOCAL session_replication_role = replica`)
+	assert.NoError(suite.T(), err)
+	_, err = tx.Exec(`UPDATE scheduled_transfers SET status = 'running', transaction_id = NULL, updated_at = NOW() - INTERVAL '10 minutes' WHERE id = $1`, dueID)
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), tx.Commit())
+
+	rerun := suite.waitForScheduledTransfer(dueID)
+	assert.Equal(suite.T(), "completed", rerun["status"])
+	assert.Equal(suite.T(), completed["transaction_id"], rerun["transaction_id"])
+
+	// Completed transfers can no longer be cancelled
+	resp, _, err = suite.post("/scheduled-transfers/"+dueID+"/cancel", nil, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+
+	balance, _ = suite.accountBalances(1201)
+	suite.assertDecimalEqual("60.00", balance)
+	balance, _ = suite.accountBalances(1202)
+	suite.assertDecimalEqual("40.00", balance)
+}
+
+// waitForStandingOrder polls a standing order until it reaches the given status
+func (suite *IntegrationTestSuite) waitForStandingOrder(id, status string) map[string]interface{} {
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		_, body, err := suite.get("/standing-orders/" + id)
+		assert.NoError(suite.T(), err)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		data := response["data"].(map[string]interface{})
+
+		if data["status"] == status || time.Now().After(deadline) {
+			return data
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func (suite *IntegrationTestSuite) stepStandingOrders() {
+	for _, account := range []struct {
+		id      int64
+		balance string
+	}{{1301, "100.00"}, {1302, "0.00"}} {
+		resp, _, err := suite.createAccount(account.id, account.balance)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	}
+
+	create := func(payload map[string]interface{}, headers map[string]string) (*http.Response, map[string]interface{}) {
+		payload["source_account_id"] = 1301
+		payload["destination_account_id"] = 1302
+		resp, body, err := suite.post("/standing-orders", payload, headers)
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("Create Standing Order Response: %s", body)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		return resp, response
+	}
+
+	// Exactly one of cron and interval, and only valid cron expressions
+	resp, _ := create(map[string]interface{}{"amount": "10.00", "cron": "0 9 1 * *", "interval": "24h"}, nil)
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+	resp, _ = create(map[string]interface{}{"amount": "10.00", "cron": "0 25 * * *"}, nil)
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+
+	key := map[string]string{"Idempotency-Key": "standing-order-1301-1"}
+	resp, response := create(map[string]interface{}{"amount": "10.00", "interval": "1s", "max_runs": 3}, key)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	order := response["data"].(map[string]interface{})
+	assert.Equal(suite.T(), "active", order["status"])
+	orderID := order["standing_order_id"].(string)
+
+	resp, response = create(map[string]interface{}{"amount": "10.00", "interval": "1s", "max_runs": 3}, key)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	assert.Equal(suite.T(), "true", resp.Header.Get("Idempotent-Replayed"))
+	assert.Equal(suite.T(), orderID, response["data"].(map[string]interface{})["standing_order_id"])
+
+	// The order stops by itself after max_runs periods, each paid exactly once
+	completed := suite.waitForStandingOrder(orderID, "completed")
+	assert.Equal(suite.T(), "completed", completed["status"])
+	assert.Equal(suite.T(), float64(3), completed["run_count"])
+	assert.Nil(suite.T(), completed["next_run_at"])
+
+	_, body, err := suite.get("/standing-orders/" + orderID + "/runs")
+	assert.NoError(suite.T(), err)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	runs := response["data"].(map[string]interface{})["runs"].([]interface{})
+	assert.Len(suite.T(), runs, 3)
+	periods := map[interface{}]bool{}
+	for _, run := range runs {
+		run := run.(map[string]interface{})
+		assert.Equal(suite.T(), "completed", run["status"])
+		assert.NotEmpty(suite.T(), run["transaction_id"])
+		periods[run["period"]] = true
+	}
+	assert.Len(suite.T(), periods, 3)
+
+	balance, _ := suite.accountBalances(1301)
+	suite.assertDecimalEqual("70.00", balance)
+	balance, _ = suite.accountBalances(1302)
+	suite.assertDecimalEqual("30.00", balance)
+
+	// A declined period is recorded as a failed run and the order moves on
+	_, response = create(map[string]interface{}{"amount": "500.00", "interval": "1s", "max_runs": 1}, nil)
+	overdrawnID := response["data"].(map[string]interface{})["standing_order_id"].(string)
+	suite.waitForStandingOrder(overdrawnID, "completed")
+	_, body, err = suite.get("/standing-orders/" + overdrawnID + "/runs")
+	assert.NoError(suite.T(), err)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	runs = response["data"].(map[string]interface{})["runs"].([]interface{})
+	if assert.Len(suite.T(), runs, 1) {
+		assert.Equal(suite.T(), "failed", runs[0].(map[string]interface{})["status"])
+		assert.Equal(suite.T(), "insufficient_balance", runs[0].(map[string]interface{})["failure_reason"])
+	}
+
+	// Monthly on the 1st: pause, resume, edit and cancel
+	_, response = create(map[string]interface{}{"amount": "5.00", "cron": "0 9 1 * *"}, nil)
+	monthly := response["data"].(map[string]interface{})
+	assert.Equal(suite.T(), "active", monthly["status"])
+	assert.NotNil(suite.T(), monthly["next_run_at"])
+	monthlyPath := "/standing-orders/" + monthly["standing_order_id"].(string)
+
+	for _, step := range []struct {
+		method, path string
+		payload      interface{}
+		status       int
+		orderStatus  string
+	}{
+		{http.MethodPost, monthlyPath + "/pause", nil, http.StatusOK, "paused"},
+		{http.MethodPost, monthlyPath + "/pause", nil, http.StatusOK, "paused"},
+		{http.MethodPost, monthlyPath + "/resume", nil, http.StatusOK, "active"},
+		{http.MethodPatch, monthlyPath, map[string]interface{}{"amount": "7.50", "max_runs": 12}, http.StatusOK, "active"},
+		{http.MethodDelete, monthlyPath, nil, http.StatusOK, "cancelled"},
+		{http.MethodPost, monthlyPath + "/resume", nil, http.StatusUnprocessableEntity, ""},
+	} {
+		resp, body, err := suite.request(step.method, step.path, step.payload)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), step.status, resp.StatusCode, "%s %s: %s", step.method, step.path, body)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		if step.orderStatus != "" {
+			assert.Equal(suite.T(), step.orderStatus, response["data"].(map[string]interface{})["status"])
+		} else {
+			assert.Equal(suite.T(), "standing_order_finished", response["error"].(map[string]interface{})["code"])
+		}
+	}
+
+	_, body, err = suite.get(monthlyPath)
+	assert.NoError(suite.T(), err)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	monthly = response["data"].(map[string]interface{})
+	suite.assertDecimalEqual("7.50", monthly["amount"].(string))
+	assert.Equal(suite.T(), float64(12), monthly["max_runs"])
+	assert.Nil(suite.T(), monthly["next_run_at"])
+
+	_, body, err = suite.get("/accounts/1301/standing-orders")
+	assert.NoError(suite.T(), err)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), response["data"].(map[string]interface{})["standing_orders"], 3)
+
+	resp, _, err = suite.get("/standing-orders/not-a-uuid")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+}
+
+func (suite *IntegrationTestSuite) stepCurrencies() {
+	errorCode := func(body string) interface{} {
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		return response["error"].(map[string]interface{})["code"]
+	}
+
+	for _, account := range []struct {
+		id       int64
+		balance  string
+		currency string
+	}{{1401, "100.00", "eur"}, {1402, "0", "EUR"}, {1403, "5000", "JPY"}, {1404, "0", "JPY"}} {
+		resp, body, err := suite.post("/accounts", map[string]interface{}{
+			"account_id":      account.id,
+			"initial_balance": account.balance,
+			"currency":        account.currency,
+		}, nil)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode, body)
+	}
+
+	// Codes are normalised to upper case; accounts created without one default to USD
+	_, body, err := suite.getAccount(1401)
+	assert.NoError(suite.T(), err)
+	response, err := suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "EUR", response["data"].(map[string]interface{})["currency"])
+
+	_, body, err = suite.getAccount(123)
+	assert.NoError(suite.T(), err)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "USD", response["data"].(map[string]interface{})["currency"])
+
+	resp, body, err := suite.post("/accounts", map[string]interface{}{
+		"account_id": 1405, "initial_balance": "10", "currency": "XYZ",
+	}, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(suite.T(), "invalid_input", errorCode(body))
+
+	// Amounts finer than the currency's minor unit are rejected
+	resp, body, err = suite.post("/accounts", map[string]interface{}{
+		"account_id": 1405, "initial_balance": "10.5", "currency": "JPY",
+	}, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(suite.T(), "invalid_amount", errorCode(body))
+
+	transfer := func(sourceID, destID int64, amount, currency string) (*http.Response, string) {
+		payload := map[string]interface{}{
+			"source_account_id":      sourceID,
+			"destination_account_id": destID,
+			"amount":                 amount,
+		}
+		if currency != "" {
+			payload["currency"] = currency
+		}
+		resp, body, err := suite.post("/transactions", payload, nil)
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("Currency Transfer Response: %s", body)
+		return resp, body
+	}
+
+	resp, body = transfer(1401, 1403, "10.00", "")
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(suite.T(), "currency_mismatch", errorCode(body))
+
+	resp, body = transfer(1401, 1402, "10.00", "USD")
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(suite.T(), "currency_mismatch", errorCode(body))
+
+	resp, body = transfer(1403, 1404, "1.5", "")
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(suite.T(), "invalid_amount", errorCode(body))
+
+	resp, body = transfer(1401, 1402, "0.001", "")
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(suite.T(), "invalid_amount", errorCode(body))
+
+	resp, body = transfer(1401, 1402, "25.50", "eur")
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	transactionID := response["data"].(map[string]interface{})["transaction_id"].(string)
+
+	_, body, err = suite.get("/transactions/" + transactionID)
+	assert.NoError(suite.T(), err)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "EUR", response["data"].(map[string]interface{})["currency"])
+
+	balance, _ := suite.accountBalances(1402)
+	suite.assertDecimalEqual("25.50", balance)
+}
+
+func (suite *IntegrationTestSuite) stepFXTransfers() {
+	for _, account := range []struct {
+		id       int64
+		balance  string
+		currency string
+	}{{1501, "100.00", "EUR"}, {1502, "0", "JPY"}, {1503, "0", "GBP"}} {
+		resp, body, err := suite.post("/accounts", map[string]interface{}{
+			"account_id":      account.id,
+			"initial_balance": account.balance,
+			"currency":        account.currency,
+		}, nil)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode, body)
+	}
+
+	transfer := func(payload map[string]interface{}) (*http.Response, map[string]interface{}) {
+		payload["source_account_id"] = 1501
+		if _, ok := payload["destination_account_id"]; !ok {
+			payload["destination_account_id"] = 1502
+		}
+		resp, body, err := suite.post("/transactions", payload, nil)
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("FX Transfer Response: %s", body)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		return resp, response
+	}
+	errorCode := func(response map[string]interface{}) interface{} {
+		return response["error"].(map[string]interface{})["code"]
+	}
+	quote := func(payload map[string]interface{}) (*http.Response, map[string]interface{}) {
+		resp, body, err := suite.post("/fx/quotes", payload, nil)
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("FX Quote Response: %s", body)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		return resp, response
+	}
+
+	// Without an explicit conversion, currencies must still match
+	resp, response := transfer(map[string]interface{}{"amount": "10.00"})
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(suite.T(), "currency_mismatch", errorCode(response))
+
+	// 10.00 EUR at 160.5 credits 1605 JPY
+	resp, response = transfer(map[string]interface{}{"amount": "10.00", "convert": true})
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	data := response["data"].(map[string]interface{})
+	suite.assertDecimalEqual("1605", data["destination_amount"].(string))
+	assert.Equal(suite.T(), "JPY", data["destination_currency"])
+	suite.assertDecimalEqual("160.5", data["fx_rate"].(string))
+	marketTransferID := data["transaction_id"].(string)
+
+	// Yen are rounded down: 0.33 EUR is 52.965 JPY, credited as 52
+	resp, response = transfer(map[string]interface{}{"amount": "0.33", "convert": true})
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	suite.assertDecimalEqual("52", response["data"].(map[string]interface{})["destination_amount"].(string))
+
+	resp, response = transfer(map[string]interface{}{"amount": "1.00", "convert": true, "destination_account_id": 1503})
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(suite.T(), "fx_rate_unavailable", errorCode(response))
+
+	// A quote locks the rate and previews the conversion
+	resp, response = quote(map[string]interface{}{"source_currency": "EUR", "destination_currency": "JPY", "amount": "20.00"})
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	data = response["data"].(map[string]interface{})
+	suite.assertDecimalEqual("160.5", data["rate"].(string))
+	suite.assertDecimalEqual("3210", data["destination_amount"].(string))
+	quoteID := data["quote_id"].(string)
+
+	resp, response = transfer(map[string]interface{}{"amount": "20.00", "quote_id": quoteID})
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	quotedTransferID := response["data"].(map[string]interface{})["transaction_id"].(string)
+
+	_, body, err := suite.get("/transactions/" + quotedTransferID)
+	assert.NoError(suite.T(), err)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	data = response["data"].(map[string]interface{})
+	assert.Equal(suite.T(), quoteID, data["fx_quote_id"])
+	assert.Equal(suite.T(), "EUR", data["currency"])
+	suite.assertDecimalEqual("3210", data["destination_amount"].(string))
+
+	_, body, err = suite.get("/fx/quotes/" + quoteID)
+	assert.NoError(suite.T(), err)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), quotedTransferID, response["data"].(map[string]interface{})["transaction_id"])
+
+	// A quote converts one transfer only
+	resp, response = transfer(map[string]interface{}{"amount": "20.00", "quote_id": quoteID})
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(suite.T(), "fx_quote_used", errorCode(response))
+
+	// The quote's pair must match the accounts
+	resp, response = quote(map[string]interface{}{"source_currency": "JPY", "destination_currency": "EUR"})
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	resp, response = transfer(map[string]interface{}{"amount": "1.00", "quote_id": response["data"].(map[string]interface{})["quote_id"]})
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(suite.T(), "currency_mismatch", errorCode(response))
+
+	resp, response = quote(map[string]interface{}{"source_currency": "EUR", "destination_currency": "JPY", "ttl": "1s"})
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	expiringID := response["data"].(map[string]interface{})["quote_id"]
+	time.Sleep(1500 * time.Millisecond)
+	resp, response = transfer(map[string]interface{}{"amount": "1.00", "quote_id": expiringID})
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(suite.T(), "fx_quote_expired", errorCode(response))
+
+	resp, _ = quote(map[string]interface{}{"source_currency": "EUR", "destination_currency": "GBP"})
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+
+	// The destination's statement shows the credits in yen
+	entries, _ := suite.listTransactions("/accounts/1502/transactions")
+	if assert.Len(suite.T(), entries, 3) {
+		entry := entries[0].(map[string]interface{})
+		assert.Equal(suite.T(), "credit", entry["direction"])
+		assert.Equal(suite.T(), "JPY", entry["currency"])
+		suite.assertDecimalEqual("3210", entry["amount"].(string))
+	}
+
+	// Reversals convert back at the original rate, and partial ones add up to the credited amount
+	for _, step := range []struct {
+		amount string
+		debit  string
+	}{{"5.00", "802"}, {"5.00", "803"}} {
+		resp, body, err := suite.post("/transactions/"+marketTransferID+"/reverse", map[string]interface{}{"amount": step.amount}, nil)
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("FX Reversal Response: %s", body)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		data := response["data"].(map[string]interface{})
+		assert.Equal(suite.T(), "JPY", data["currency"])
+		suite.assertDecimalEqual(step.debit, data["amount"].(string))
+		suite.assertDecimalEqual(step.amount, data["destination_amount"].(string))
+	}
+
+	balance, _ := suite.accountBalances(1501)
+	suite.assertDecimalEqual("79.67", balance)
+	balance, _ = suite.accountBalances(1502)
+	suite.assertDecimalEqual("3262", balance)
+}
+
+func (suite *IntegrationTestSuite) stepAccountLifecycle() {
+	errorCode := func(body string) interface{} {
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		return response["error"].(map[string]interface{})["code"]
+	}
+	changeStatus := func(accountID int64, action string, payload map[string]interface{}) (*http.Response, map[string]interface{}) {
+		resp, body, err := suite.post(fmt.Sprintf("/accounts/%d/%s", accountID, action), payload, nil)
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("%s Account Response: %s", action, body)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		return resp, response
+	}
+
+	for _, account := range []struct {
+		id      int64
+		balance string
+	}{{1601, "100.00"}, {1602, "0.00"}, {1603, "50.00"}} {
+		resp, _, err := suite.createAccount(account.id, account.balance)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	}
+
+	_, body, err := suite.getAccount(1601)
+	assert.NoError(suite.T(), err)
+	response, err := suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "active", response["data"].(map[string]interface{})["status"])
+
+	// A reason is required for every change
+	resp, _ := changeStatus(1601, "freeze", map[string]interface{}{"reason": "  "})
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+
+	resp, response = changeStatus(1601, "freeze", map[string]interface{}{"reason": "compliance review"})
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	data := response["data"].(map[string]interface{})
+	assert.Equal(suite.T(), "frozen", data["status"])
+	assert.Equal(suite.T(), "active", data["change"].(map[string]interface{})["from_status"])
+
+	resp, response = changeStatus(1601, "freeze", map[string]interface{}{"reason": "again"})
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(suite.T(), "invalid_account_status", response["error"].(map[string]interface{})["code"])
+
+	// A frozen account cannot send funds, but can still receive them
+	resp, body, err = suite.transfer(1601, 1602, "10.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(suite.T(), "account_frozen", errorCode(body))
+
+	resp, _, err = suite.transfer(1603, 1601, "10.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+	resp, _ = changeStatus(1601, "unfreeze", map[string]interface{}{"reason": "review cleared"})
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+	resp, _, err = suite.transfer(1601, 1602, "10.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+	// A non-zero balance must be swept to another account to close
+	resp, response = changeStatus(1602, "close", map[string]interface{}{"reason": "customer request"})
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(suite.T(), "account_not_empty", response["error"].(map[string]interface{})["code"])
+
+	resp, response = changeStatus(1601, "close", map[string]interface{}{"reason": "customer request", "sweep_to_account_id": "1603"})
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	data = response["data"].(map[string]interface{})
+	assert.Equal(suite.T(), "closed", data["status"])
+	suite.assertDecimalEqual("0", data["balance"].(string))
+	sweepID, ok := data["change"].(map[string]interface{})["sweep_transaction_id"].(string)
+	assert.True(suite.T(), ok, "closing with a balance should record the sweep transfer")
+
+	_, body, err = suite.get("/transactions/" + sweepID)
+	assert.NoError(suite.T(), err)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	sweep := response["data"].(map[string]interface{})
+	assert.Equal(suite.T(), "completed", sweep["status"])
+	suite.assertDecimalEqual("100.00", sweep["amount"].(string))
+
+	balance, _ := suite.accountBalances(1603)
+	suite.assertDecimalEqual("140.00", balance)
+
+	// Nothing moves into or out of a closed account, and closing is final
+	resp, body, err = suite.transfer(1603, 1601, "1.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(suite.T(), "account_closed", errorCode(body))
+
+	resp, response = changeStatus(1602, "close", map[string]interface{}{"reason": "customer request", "sweep_to_account_id": "1601"})
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(suite.T(), "account_closed", response["error"].(map[string]interface{})["code"])
+
+	resp, response = changeStatus(1601, "unfreeze", map[string]interface{}{"reason": "reopen"})
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(suite.T(), "account_closed", response["error"].(map[string]interface{})["code"])
+
+	// Every change is in the audit trail, oldest first
+	resp, body, err = suite.get("/accounts/1601/status-history")
+	assert.NoError(suite.T(), err)
+	suite.T().Logf("Status History Response: %s", body)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	changes := response["data"].(map[string]interface{})["changes"].([]interface{})
+	if assert.Len(suite.T(), changes, 3) {
+		for i, expected := range []struct{ from, to, reason string }{
+			{"active", "frozen", "compliance review"},
+			{"frozen", "active", "review cleared"},
+			{"active", "closed", "customer request"},
+		} {
+			change := changes[i].(map[string]interface{})
+			assert.Equal(suite.T(), expected.from, change["from_status"])
+			assert.Equal(suite.T(), expected.to, change["to_status"])
+			assert.Equal(suite.T(), expected.reason, change["reason"])
+		}
+		assert.Equal(suite.T(), sweepID, changes[2].(map[string]interface{})["sweep_transaction_id"])
+	}
+}
+
+func (suite *IntegrationTestSuite) stepTransferLimits() {
+	errorInfo := func(body string) map[string]interface{} {
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		return response["error"].(map[string]interface{})
+	}
+	setLimit := func(payload map[string]interface{}) map[string]interface{} {
+		resp, body, err := suite.request(http.MethodPut, "/limits", payload)
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("Set Limit Response: %s", body)
+		assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		return response["data"].(map[string]interface{})
+	}
+
+	resp, body, err := suite.post("/accounts", map[string]interface{}{
+		"account_id":      1701,
+		"initial_balance": "10000.00",
+		"tier":            "Gold",
+	}, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode, body)
+	response, err := suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "gold", response["data"].(map[string]interface{})["tier"])
+
+	resp, _, err = suite.createAccount(1702, "0.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+	resp, _, err = suite.post("/accounts", map[string]interface{}{"account_id": 1703, "initial_balance": "1", "tier": "not a tier"}, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+
+	// Account limits are always in the account's currency
+	resp, _, err = suite.request(http.MethodPut, "/limits", map[string]interface{}{
+		"scope": "account", "account_id": 1701, "limit_type": "daily", "currency": "EUR", "amount": "100",
+	})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+
+	// A tier limit applies to every account of the tier
+	setLimit(map[string]interface{}{"scope": "tier", "tier": "gold", "limit_type": "daily", "amount": "500.00"})
+
+	resp, _, err = suite.transfer(1701, 1702, "300.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+	resp, body, err = suite.transfer(1701, 1702, "250.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	info := errorInfo(body)
+	assert.Equal(suite.T(), "limit_exceeded", info["code"])
+	assert.Contains(suite.T(), info["message"], "daily")
+	assert.Contains(suite.T(), info["details"], "scope: tier")
+	assert.Contains(suite.T(), info["details"], "remaining: 200")
+
+	resp, body, err = suite.get("/accounts/1701/limits")
+	assert.NoError(suite.T(), err)
+	suite.T().Logf("Account Limits Response: %s", body)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	limits := map[string]map[string]interface{}{}
+	for _, limit := range response["data"].(map[string]interface{})["limits"].([]interface{}) {
+		limits[limit.(map[string]interface{})["limit_type"].(string)] = limit.(map[string]interface{})
+	}
+	if assert.Contains(suite.T(), limits, "daily") {
+		assert.Equal(suite.T(), "tier", limits["daily"]["scope"])
+		suite.assertDecimalEqual("300.00", limits["daily"]["used"].(string))
+		suite.assertDecimalEqual("200.00", limits["daily"]["remaining"].(string))
+	}
+	if assert.Contains(suite.T(), limits, "per_transfer") {
+		assert.Equal(suite.T(), "default", limits["per_transfer"]["scope"])
+	}
+	assert.NotContains(suite.T(), limits, "monthly")
+
+	// An account override takes precedence over its tier
+	override := setLimit(map[string]interface{}{"scope": "account", "account_id": 1701, "limit_type": "daily", "amount": "1000.00"})
+	resp, _, err = suite.transfer(1701, 1702, "250.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+	setLimit(map[string]interface{}{"scope": "tier", "tier": "gold", "limit_type": "per_transfer", "amount": "100.00"})
+	resp, body, err = suite.transfer(1701, 1702, "150.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Contains(suite.T(), errorInfo(body)["message"], "per_transfer")
+
+	// Held funds count toward the daily limit: 550 sent plus 90 held leaves 360
+	resp, _, err = suite.post("/holds", map[string]interface{}{"source_account_id": 1701, "destination_account_id": 1702, "amount": "90.00"}, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+	items := make([]map[string]interface{}, 5)
+	for i := range items {
+		items[i] = map[string]interface{}{"source_account_id": 1701, "destination_account_id": 1702, "amount": "80.00"}
+	}
+	resp, body, err = suite.post("/transactions/batch", map[string]interface{}{
+		"mode": "best_effort", "idempotency_key": "batch-limits-1", "transfers": items,
+	}, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	info = errorInfo(body)
+	assert.Equal(suite.T(), "limit_exceeded", info["code"])
+	assert.Contains(suite.T(), info["details"], "item: 4")
+
+	// Removing the override falls back to the tier limit, which is used up
+	resp, _, err = suite.request(http.MethodDelete, "/limits/"+override["limit_id"].(string), nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+	resp, _, err = suite.request(http.MethodDelete, "/limits/"+override["limit_id"].(string), nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotFound, resp.StatusCode)
+
+	resp, body, err = suite.transfer(1701, 1702, "10.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(suite.T(), "limit_exceeded", errorInfo(body)["code"])
+
+	// Moving the account to another tier moves it to that tier's limits
+	resp, body, err = suite.request(http.MethodPut, "/accounts/1701/tier", map[string]interface{}{"tier": "standard"})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode, body)
+
+	resp, _, err = suite.transfer(1701, 1702, "10.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+	// The configured default still caps a single transfer
+	resp, body, err = suite.transfer(1701, 1702, "1000000001")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Contains(suite.T(), errorInfo(body)["details"], "scope: default")
+
+	resp, body, err = suite.get("/limits")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Len(suite.T(), response["data"].(map[string]interface{})["limits"], 2)
+}
+
+// stepLimitsAfterReversal checks that reversing a transfer does not free the daily limit it used up
+func (suite *IntegrationTestSuite) stepLimitsAfterReversal() {
+	for _, account := range []struct {
+		id      int64
+		balance string
+	}{{1711, "1000.00"}, {1712, "0.00"}} {
+		resp, _, err := suite.createAccount(account.id, account.balance)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	}
+
+	resp, body, err := suite.request(http.MethodPut, "/limits", map[string]interface{}{
+		"scope": "account", "account_id": 1711, "limit_type": "daily", "amount": "100.00",
+	})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode, body)
+
+	resp, body, err = suite.transfer(1711, 1712, "100.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	response, err := suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	originalID := response["data"].(map[string]interface{})["transaction_id"].(string)
+
+	assertLimited := func() {
+		resp, body, err := suite.transfer(1711, 1712, "1.00")
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		if errorData, hasError := response["error"]; assert.True(suite.T(), hasError) {
+			assert.Equal(suite.T(), "limit_exceeded", errorData.(map[string]interface{})["code"])
+		}
+	}
+	assertLimited()
+
+	// Neither a partial nor a full reversal gives the limit back
+	for _, step := range []struct{ amount, status string }{{"0.01", "partially_reversed"}, {"99.99", "reversed"}} {
+		resp, body, err := suite.post("/transactions/"+originalID+"/reverse", map[string]interface{}{"amount": step.amount}, nil)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode, body)
+
+		_, body, err = suite.get("/transactions/" + originalID)
+		assert.NoError(suite.T(), err)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), step.status, response["data"].(map[string]interface{})["status"])
+		assertLimited()
+	}
+
+	balance, _ := suite.accountBalances(1711)
+	suite.assertDecimalEqual("1000.00", balance)
+}
+
+func (suite *IntegrationTestSuite) stepOverdraft() {
+	accountData := func(accountID int64) map[string]interface{} {
+		_, body, err := suite.getAccount(accountID)
+		assert.NoError(suite.T(), err)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		return response["data"].(map[string]interface{})
+	}
+	setOverdraft := func(accountID int64, limit string) (*http.Response, string) {
+		resp, body, err := suite.request(http.MethodPut, fmt.Sprintf("/accounts/%d/overdraft", accountID), map[string]interface{}{"overdraft_limit": limit})
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("Set Overdraft Response: %s", body)
+		return resp, body
+	}
+
+	for _, id := range []int64{1801, 1802} {
+		resp, _, err := suite.createAccount(id, "100.00")
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	}
+
+	account := accountData(1801)
+	suite.assertDecimalEqual("0", account["overdraft_limit"].(string))
+	suite.assertDecimalEqual("0", account["available_credit"].(string))
+
+	resp, _ := setOverdraft(1801, "-1")
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+
+	resp, body := setOverdraft(1801, "500.00")
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	response, err := suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	suite.assertDecimalEqual("500.00", response["data"].(map[string]interface{})["available_credit"].(string))
+
+	// The balance can go negative down to minus the overdraft limit
+	resp, body, err = suite.transfer(1801, 1802, "400.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode, body)
+
+	account = accountData(1801)
+	suite.assertDecimalEqual("-300.00", account["balance"].(string))
+	suite.assertDecimalEqual("-300.00", account["available_balance"].(string))
+	suite.assertDecimalEqual("200.00", account["available_credit"].(string))
+
+	resp, _, err = suite.transfer(1801, 1802, "200.01")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+
+	// Holds draw on the overdraft as well
+	resp, body, err = suite.post("/holds", map[string]interface{}{"source_account_id": 1801, "destination_account_id": 1802, "amount": "150.00"}, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	holdID := response["data"].(map[string]interface{})["hold_id"].(string)
+	suite.assertDecimalEqual("50.00", accountData(1801)["available_credit"].(string))
+
+	// The limit cannot be lowered below what is already drawn
+	resp, body = setOverdraft(1801, "400.00")
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(suite.T(), body, "drawn: 450")
+
+	// Accounts without an overdraft still stop at zero
+	resp, _, err = suite.transfer(1802, 1801, "500.01")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+
+	// An overdrawn account cannot be closed
+	resp, _, err = suite.post("/holds/"+holdID+"/void", nil, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+	resp, body, err = suite.post("/accounts/1801/close", map[string]interface{}{"reason": "overdraft test"}, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Contains(suite.T(), body, "account is overdrawn")
+
+	// The ledger still balances with a negative balance
+	resp, body, err = suite.get("/accounts/1801/ledger")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), true, response["data"].(map[string]interface{})["balanced"])
+}
+
+func (suite *IntegrationTestSuite) stepTransferFees() {
+	balance := func(accountID int64) string {
+		_, body, err := suite.getAccount(accountID)
+		assert.NoError(suite.T(), err)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		return response["data"].(map[string]interface{})["balance"].(string)
+	}
+	setSchedule := func(payload map[string]interface{}) map[string]interface{} {
+		resp, body, err := suite.request(http.MethodPut, "/fee-schedules", payload)
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("Set Fee Schedule Response: %s", body)
+		assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		return response["data"].(map[string]interface{})
+	}
+	// transferWithFee sends a transfer and checks its gross, fee and net amounts
+	transferWithFee := func(amount, fee, net string) string {
+		resp, body, err := suite.transfer(1902, 1903, amount)
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("Transfer With Fee Response: %s", body)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		data := response["data"].(map[string]interface{})
+		suite.assertDecimalEqual(amount, data["amount"].(string))
+		suite.assertDecimalEqual(fee, data["fee"].(string))
+		suite.assertDecimalEqual(net, data["net_amount"].(string))
+		return data["transaction_id"].(string)
+	}
+
+	for _, account := range []struct {
+		id      int64
+		balance string
+	}{{1901, "0.00"}, {1902, "1000.00"}, {1903, "0.00"}} {
+		resp, _, err := suite.createAccount(account.id, account.balance)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	}
+
+	resp, _, err := suite.request(http.MethodPut, "/fee-schedules", map[string]interface{}{
+		"scope": "account", "account_id": 1902, "fee_type": "flat", "percentage": "1",
+	})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+
+	// A flat fee is collected into the configured fee account
+	schedule := setSchedule(map[string]interface{}{"scope": "account", "account_id": 1902, "fee_type": "flat", "amount": "1.50"})
+	assert.Len(suite.T(), schedule["tiers"], 1)
+
+	transactionID := transferWithFee("100.00", "1.50", "98.50")
+	suite.assertDecimalEqual("900.00", balance(1902))
+	suite.assertDecimalEqual("98.50", balance(1903))
+	suite.assertDecimalEqual("1.50", balance(1901))
+
+	resp, body, err := suite.get("/transactions/" + transactionID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	response, err := suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	detail := response["data"].(map[string]interface{})
+	suite.assertDecimalEqual("1.50", detail["fee"].(string))
+	suite.assertDecimalEqual("98.50", detail["net_amount"].(string))
+	assert.Equal(suite.T(), float64(1901), detail["fee_account_id"])
+
+	// Setting a schedule again replaces it; a percentage fee is held to its minimum
+	setSchedule(map[string]interface{}{"scope": "account", "account_id": 1902, "fee_type": "percentage", "percentage": "1.5", "min_fee": "5.00"})
+	transferWithFee("100.00", "5.00", "95.00")
+	transferWithFee("400.00", "6.00", "394.00")
+
+	// Tiered fees: 1.00 up to 100, then 0.50 plus 0.5%
+	schedule = setSchedule(map[string]interface{}{
+		"scope": "account", "account_id": 1902, "fee_type": "tiered",
+		"tiers": []map[string]interface{}{
+			{"up_to": "100.00", "amount": "1.00"},
+			{"amount": "0.50", "percentage": "0.5"},
+		},
+	})
+	transferWithFee("50.00", "1.00", "49.00")
+	reversedID := transferWithFee("200.00", "1.50", "198.50")
+	suite.assertDecimalEqual("150.00", balance(1902))
+	suite.assertDecimalEqual("835.00", balance(1903))
+	suite.assertDecimalEqual("15.00", balance(1901))
+
+	// The amount must cover the fee
+	resp, body, err = suite.transfer(1902, 1903, "1.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(suite.T(), body, "does not cover the transfer fee")
+
+	// Reversals refund the fee in proportion to the amount they reverse
+	resp, body, err = suite.post("/transactions/"+reversedID+"/reverse", map[string]interface{}{"amount": "100.00"}, nil)
+	assert.NoError(suite.T(), err)
+	suite.T().Logf("Fee Reversal Response: %s", body)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	reversal := response["data"].(map[string]interface{})
+	suite.assertDecimalEqual("99.25", reversal["amount"].(string))
+	suite.assertDecimalEqual("0.75", reversal["fee"].(string))
+	suite.assertDecimalEqual("14.25", balance(1901))
+
+	resp, _, err = suite.post("/transactions/"+reversedID+"/reverse", nil, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	suite.assertDecimalEqual("350.00", balance(1902))
+	suite.assertDecimalEqual("636.50", balance(1903))
+	suite.assertDecimalEqual("13.50", balance(1901))
+
+	// Batch items pay the fee of each transfer
+	resp, body, err = suite.post("/transactions/batch", map[string]interface{}{
+		"idempotency_key": "fee-batch-1",
+		"transfers": []map[string]interface{}{
+			{"source_account_id": 1902, "destination_account_id": 1903, "amount": "50.00"},
+			{"source_account_id": 1902, "destination_account_id": 1903, "amount": "50.00"},
+		},
+	}, nil)
+	assert.NoError(suite.T(), err)
+	suite.T().Logf("Batch With Fee Response: %s", body)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	for _, result := range response["data"].(map[string]interface{})["results"].([]interface{}) {
+		resp, body, err := suite.get("/transactions/" + result.(map[string]interface{})["transaction_id"].(string))
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		item := response["data"].(map[string]interface{})
+		suite.assertDecimalEqual("1.00", item["fee"].(string))
+		suite.assertDecimalEqual("49.00", item["net_amount"].(string))
+		assert.Equal(suite.T(), float64(1901), item["fee_account_id"])
+	}
+	suite.assertDecimalEqual("250.00", balance(1902))
+	suite.assertDecimalEqual("734.50", balance(1903))
+	suite.assertDecimalEqual("15.50", balance(1901))
+
+	// A hold capture pays the fee of the captured amount
+	resp, body, err = suite.post("/holds", map[string]interface{}{"source_account_id": 1902, "destination_account_id": 1903, "amount": "100.00"}, nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	holdID := response["data"].(map[string]interface{})["hold_id"].(string)
+
+	resp, body, err = suite.post("/holds/"+holdID+"/capture", map[string]interface{}{"amount": "80.00"}, nil)
+	assert.NoError(suite.T(), err)
+	suite.T().Logf("Capture With Fee Response: %s", body)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	captured := response["data"].(map[string]interface{})
+	suite.assertDecimalEqual("80.00", captured["amount"].(string))
+	suite.assertDecimalEqual("1.00", captured["fee"].(string))
+	suite.assertDecimalEqual("79.00", captured["net_amount"].(string))
+	suite.assertDecimalEqual("170.00", balance(1902))
+	suite.assertDecimalEqual("813.50", balance(1903))
+	suite.assertDecimalEqual("16.50", balance(1901))
+
+	// Deleting the schedule stops the fees
+	resp, body, err = suite.get("/fee-schedules")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	assert.Contains(suite.T(), body, schedule["schedule_id"].(string))
+
+	resp, _, err = suite.request(http.MethodDelete, "/fee-schedules/"+schedule["schedule_id"].(string), nil)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	transferWithFee("10.00", "0", "10.00")
+
+	// Fees move money between accounts, so the ledger still balances
+	resp, body, err = suite.get("/accounts/1901/ledger")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), true, response["data"].(map[string]interface{})["balanced"])
+}
+
+func (suite *IntegrationTestSuite) stepOutboxEvents() {
+	eventTypes := func(events []domain.Event) []string {
+		types := make([]string, len(events))
+		for i, event := range events {
+			types[i] = event.Type
+		}
+		return types
+	}
+
+	// Every event of account 2001 is rejected once, so its events are retried in order
+	suite.eventSink.mu.Lock()
+	suite.eventSink.failFirst[2001] = true
+	suite.eventSink.mu.Unlock()
+
+	resp, _, err := suite.createAccount(2001, "100.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	resp, _, err = suite.createAccount(2002, "0.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+	resp, _, err = suite.transfer(2001, 2002, "60.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	resp, _, err = suite.transfer(2001, 2002, "60.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+
+	expected := []string{domain.EventAccountCreated, domain.EventTransferCompleted, domain.EventTransferFailed}
+	for _, accountID := range []int64{2001, 2002} {
+		assert.Eventually(suite.T(), func() bool {
+			return len(suite.eventSink.eventsFor(accountID)) == len(expected)
+		}, 10*time.Second, 100*time.Millisecond, "events of account %d", accountID)
+
+		events := suite.eventSink.eventsFor(accountID)
+		assert.Equal(suite.T(), expected, eventTypes(events), "events of account %d", accountID)
+		for i := 1; i < len(events); i++ {
+			assert.Less(suite.T(), events[i-1].Sequence, events[i].Sequence)
+		}
+	}
+
+	events := suite.eventSink.eventsFor(2001)
+	if len(events) != len(expected) {
+		return
+	}
+
+	var account map[string]interface{}
+	assert.NoError(suite.T(), json.Unmarshal(events[0].Data, &account))
+	assert.Equal(suite.T(), float64(2001), account["account_id"])
+	suite.assertDecimalEqual("100.00", account["balance"].(string))
+
+	var transfer map[string]interface{}
+	assert.NoError(suite.T(), json.Unmarshal(events[1].Data, &transfer))
+	assert.Equal(suite.T(), "completed", transfer["status"])
+	suite.assertDecimalEqual("60.00", transfer["amount"].(string))
+	suite.assertDecimalEqual("60.00", transfer["net_amount"].(string))
+
+	assert.NoError(suite.T(), json.Unmarshal(events[2].Data, &transfer))
+	assert.Equal(suite.T(), "failed", transfer["status"])
+	assert.Equal(suite.T(), "insufficient_balance", transfer["failure_reason"])
+
+	// An event the publisher keeps rejecting is dead-lettered after OUTBOX_MAX_ATTEMPTS, and no
+	// longer holds up the later events of its account
+	suite.eventSink.mu.Lock()
+	suite.eventSink.failAlways[2003] = true
+	suite.eventSink.mu.Unlock()
+
+	resp, _, err = suite.createAccount(2003, "100.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+	db, err := sql.Open("postgres", suite.dbConnStr)
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	defer db.Close()
+	assert.Eventually(suite.T(), func() bool {
+		var attempts int
+		var deadLettered bool
+		err := db.QueryRow(`SELECT attempts, dead_lettered_at IS NOT NULL FROM outbox
+			WHERE event_type = $1 AND account_ids = ARRAY[2003::bigint]`, domain.EventAccountCreated).Scan(&attempts, &deadLettered)
+		return err == nil && deadLettered && attempts == 3
+	}, 10*time.Second, 100*time.Millisecond)
+
+	suite.eventSink.mu.Lock()
+	suite.eventSink.failAlways[2003] = false
+	suite.eventSink.mu.Unlock()
+
+	resp, _, err = suite.transfer(2003, 2002, "10.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	assert.Eventually(suite.T(), func() bool {
+		return len(suite.eventSink.eventsFor(2003)) == 1
+	}, 10*time.Second, 100*time.Millisecond)
+	if events := suite.eventSink.eventsFor(2003); len(events) == 1 {
+		assert.Equal(suite.T(), domain.EventTransferCompleted, events[0].Type)
+	}
+}
+
+func (suite *IntegrationTestSuite) stepWebhooks() {
+	good := &webhookReceiver{status: http.StatusOK}
+	goodServer := httptest.NewServer(good)
+	defer goodServer.Close()
+	bad := &webhookReceiver{status: http.StatusInternalServerError}
+	badServer := httptest.NewServer(bad)
+	defer badServer.Close()
+
+	createWebhook := func(payload map[string]interface{}) map[string]interface{} {
+		resp, body, err := suite.post("/webhooks", payload, nil)
+		assert.NoError(suite.T(), err)
+		suite.T().Logf("Create Webhook Response: %s", body)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		webhook := response["data"].(map[string]interface{})
+		assert.True(suite.T(), strings.HasPrefix(webhook["secret"].(string), "whsec_"))
+		return webhook
+	}
+	// verify checks the signature of a request with the secret of the webhook it was sent to
+	verify := func(req webhookRequest, secret string) {
+		header := req.header.Get("X-Webhook-Signature")
+		t, v1, found := strings.Cut(strings.TrimPrefix(header, "t="), ",v1=")
+		assert.True(suite.T(), found, "signature header %q", header)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(t + "."))
+		mac.Write(req.body)
+		assert.Equal(suite.T(), hex.EncodeToString(mac.Sum(nil)), v1)
+		assert.Equal(suite.T(), req.event.ID.String(), req.header.Get("X-Event-ID"))
+		assert.Equal(suite.T(), req.event.Type, req.header.Get("X-Event-Type"))
+	}
+	deliveries := func(webhookID, status string) []interface{} {
+		resp, body, err := suite.get("/webhooks/" + webhookID + "/deliveries?status=" + status)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+		response, err := suite.parseResponse(body)
+		assert.NoError(suite.T(), err)
+		return response["data"].(map[string]interface{})["deliveries"].([]interface{})
+	}
+
+	// Unknown event types and non-HTTP URLs are rejected
+	for _, payload := range []map[string]interface{}{
+		{"url": goodServer.URL, "event_types": []string{"transfer.exploded"}},
+		{"url": "ftp://example.com/hook", "event_types": []string{"transfer.completed"}},
+		{"url": goodServer.URL, "event_types": []string{}},
+	} {
+		resp, _, err := suite.post("/webhooks", payload, nil)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+	}
+
+	// A webhook for account.created of every account; accounts cannot be subscribed to before they exist
+	created := createWebhook(map[string]interface{}{"url": goodServer.URL, "event_types": []string{"account.created"}})
+	for _, account := range []struct {
+		id      int64
+		balance string
+	}{{2101, "100.00"}, {2102, "0.00"}} {
+		resp, _, err := suite.createAccount(account.id, account.balance)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	}
+
+	transfers := createWebhook(map[string]interface{}{
+		"url":         goodServer.URL,
+		"event_types": []string{"transfer.completed", "transfer.failed", "transfer.completed"},
+		"account_id":  2101,
+	})
+	assert.Equal(suite.T(), []interface{}{"transfer.completed", "transfer.failed"}, transfers["event_types"])
+	failing := createWebhook(map[string]interface{}{
+		"url":         badServer.URL,
+		"event_types": []string{"transfer.completed"},
+		"account_id":  2102,
+	})
+
+	resp, _, err := suite.transfer(2101, 2102, "40.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+	// Signed deliveries reach the working endpoint
+	for _, accountID := range []int64{2101, 2102} {
+		assert.Eventually(suite.T(), func() bool {
+			return len(good.received("account.created", accountID)) == 1
+		}, 10*time.Second, 100*time.Millisecond, "account.created of %d", accountID)
+	}
+	for _, req := range good.received("account.created", 2101) {
+		verify(req, created["secret"].(string))
+	}
+	assert.Eventually(suite.T(), func() bool {
+		return len(good.received("transfer.completed", 2101)) == 1
+	}, 10*time.Second, 100*time.Millisecond)
+	completed := good.received("transfer.completed", 2101)
+	if len(completed) == 1 {
+		verify(completed[0], transfers["secret"].(string))
+	}
+
+	// The failing endpoint is retried until the delivery is dead-lettered
+	failingID := failing["webhook_id"].(string)
+	assert.Eventually(suite.T(), func() bool {
+		return len(deliveries(failingID, "dead_letter")) == 1
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.Len(suite.T(), bad.received("transfer.completed", 2102), 3)
+
+	dead := deliveries(failingID, "dead_letter")
+	if len(dead) != 1 {
+		return
+	}
+	delivery := dead[0].(map[string]interface{})
+	assert.Equal(suite.T(), float64(3), delivery["attempts"])
+	attempts := delivery["attempt_log"].([]interface{})
+	assert.Len(suite.T(), attempts, 3)
+	for _, attempt := range attempts {
+		assert.Equal(suite.T(), float64(500), attempt.(map[string]interface{})["status_code"])
+	}
+	assert.Equal(suite.T(), "transfer.completed", delivery["event"].(map[string]interface{})["type"])
+
+	// Once the endpoint is fixed, a redelivery succeeds with the same event
+	resp, body, err := suite.request(http.MethodPatch, "/webhooks/"+failingID, map[string]interface{}{"url": goodServer.URL})
+	assert.NoError(suite.T(), err)
+	suite.T().Logf("Update Webhook Response: %s", body)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+	deliveryID := delivery["delivery_id"].(string)
+	resp, body, err = suite.post("/webhooks/"+failingID+"/deliveries/"+deliveryID+"/redeliver", nil, nil)
+	assert.NoError(suite.T(), err)
+	suite.T().Logf("Redeliver Response: %s", body)
+	assert.Equal(suite.T(), http.StatusAccepted, resp.StatusCode)
+	response, err := suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "pending", response["data"].(map[string]interface{})["status"])
+
+	var redelivered map[string]interface{}
+	assert.Eventually(suite.T(), func() bool {
+		_, body, err := suite.get("/webhooks/" + failingID + "/deliveries/" + deliveryID)
+		if err != nil {
+			return false
+		}
+		response, err := suite.parseResponse(body)
+		if err != nil {
+			return false
+		}
+		redelivered = response["data"].(map[string]interface{})
+		return redelivered["status"] == "succeeded"
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.Equal(suite.T(), float64(1), redelivered["attempts"])
+	assert.Len(suite.T(), redelivered["attempt_log"], 4)
+	assert.NotNil(suite.T(), redelivered["delivered_at"])
+
+	received := good.received("transfer.completed", 2101)
+	assert.Len(suite.T(), received, 2)
+	if len(received) == 2 {
+		assert.Equal(suite.T(), received[0].event.ID, received[1].event.ID)
+	}
+
+	// Secrets are only shown on creation
+	resp, body, err = suite.get("/webhooks?account_id=2101")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	listed := response["data"].(map[string]interface{})["webhooks"].([]interface{})
+	assert.Len(suite.T(), listed, 1)
+	if len(listed) == 1 {
+		assert.NotContains(suite.T(), listed[0], "secret")
+	}
+
+	for _, webhook := range []map[string]interface{}{created, transfers, failing} {
+		id := webhook["webhook_id"].(string)
+		resp, _, err := suite.request(http.MethodDelete, "/webhooks/"+id, nil)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+		resp, _, err = suite.get("/webhooks/" + id)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+// openStream connects to an account stream, resuming after lastEventID unless it is empty
+func (suite *IntegrationTestSuite) openStream(accountID int64, lastEventID string) (*sseStream, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/accounts/%d/stream", suite.baseURL, accountID), nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := suite.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &sseStream{resp: resp, reader: bufio.NewReader(resp.Body), cancel: cancel}, nil
+}
+
+func (suite *IntegrationTestSuite) stepStreams() {
+	for _, account := range []struct {
+		id      int64
+		balance string
+	}{{2201, "100.00"}, {2202, "0.00"}} {
+		resp, _, err := suite.createAccount(account.id, account.balance)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	}
+	assertBalance := func(event sseEvent, expected string) {
+		balance, ok := event.data["balance"].(string)
+		if assert.True(suite.T(), ok, "event %s has no balance", event.event) {
+			assert.True(suite.T(), decimal.RequireFromString(expected).Equal(decimal.RequireFromString(balance)),
+				"balance %s, expected %s", balance, expected)
+		}
+	}
+
+	// Unknown accounts and malformed ids are rejected before the stream opens
+	stream, err := suite.openStream(2299, "")
+	if assert.NoError(suite.T(), err) {
+		assert.Equal(suite.T(), http.StatusNotFound, stream.resp.StatusCode)
+		stream.close()
+	}
+	stream, err = suite.openStream(2201, "latest")
+	if assert.NoError(suite.T(), err) {
+		assert.Equal(suite.T(), http.StatusBadRequest, stream.resp.StatusCode)
+		stream.close()
+	}
+
+	// A new stream opens with a snapshot, then pushes transfers as they commit
+	stream, err = suite.openStream(2201, "")
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	assert.Equal(suite.T(), http.StatusOK, stream.resp.StatusCode)
+	assert.Equal(suite.T(), "text/event-stream", stream.resp.Header.Get("Content-Type"))
+
+	snapshot, err := stream.next()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "snapshot", snapshot.event)
+	assertBalance(snapshot, "100.00")
+	assert.Equal(suite.T(), snapshot.id, fmt.Sprint(snapshot.data["sequence"]))
+
+	resp, body, err := suite.transfer(2201, 2202, "30.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	response, err := suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	transactionID := response["data"].(map[string]interface{})["transaction_id"]
+
+	completed, err := stream.next()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "transfer.completed", completed.event)
+	assertBalance(completed, "70.00")
+	if transaction, ok := completed.data["transaction"].(map[string]interface{}); assert.True(suite.T(), ok) {
+		assert.Equal(suite.T(), transactionID, transaction["id"])
+	}
+	stream.close()
+
+	// Events committed while disconnected are replayed after the Last-Event-ID, without a snapshot
+	resp, _, err = suite.transfer(2201, 2202, "20.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	resp, _, err = suite.transfer(2201, 2202, "500.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnprocessableEntity, resp.StatusCode)
+
+	stream, err = suite.openStream(2201, completed.id)
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	event, err := stream.next()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "transfer.completed", event.event)
+	assertBalance(event, "50.00")
+	event, err = stream.next()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "transfer.failed", event.event)
+	assert.NotContains(suite.T(), event.data, "balance")
+	stream.close()
+
+	// Resuming from the start replays the account's whole history
+	stream, err = suite.openStream(2202, "0")
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	defer stream.close()
+	for _, expected := range []struct {
+		event   string
+		balance string
+	}{{"account.created", "0.00"}, {"transfer.completed", "30.00"}, {"transfer.completed", "50.00"}} {
+		event, err := stream.next()
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), expected.event, event.event)
+		assertBalance(event, expected.balance)
+	}
+}
+
+// createAPIKey creates an API key with scopes and returns it with its ID
+func (suite *IntegrationTestSuite) createAPIKey(name string, scopes ...string) (string, string) {
+	resp, response := suite.requestWithKey(testAdminKey, http.MethodPost, "/api-keys", map[string]interface{}{
+		"name":   name,
+		"scopes": scopes,
+	})
+	if !assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode) {
+		suite.T().FailNow()
+	}
+	key := response["data"].(map[string]interface{})
+	return key["key"].(string), key["api_key_id"].(string)
+}
+
+// requestWithKey sends a request authenticated with an API key; an empty key sends no credentials
+func (suite *IntegrationTestSuite) requestWithKey(key, method, path string, payload interface{}) (*http.Response, map[string]interface{}) {
+	return suite.requestServer(suite.baseURL, key, method, path, payload)
+}
+
+// requestServer is requestWithKey against the server at a base URL
+func (suite *IntegrationTestSuite) requestServer(baseURL, key, method, path string, payload interface{}) (*http.Response, map[string]interface{}) {
+	var body io.Reader
+	if payload != nil {
+		encoded, _ := json.Marshal(payload)
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, baseURL+path, body)
+	assert.NoError(suite.T(), err)
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: 30 * time.Second}
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+		client = suite.client
+	}
+
+	resp, err := client.Do(req)
+	if !assert.NoError(suite.T(), err) {
+		return &http.Response{}, nil
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	response, err := suite.parseResponse(string(respBody))
+	assert.NoError(suite.T(), err)
+	return resp, response
+}
+
+func (suite *IntegrationTestSuite) stepAPIKeys() {
+	for _, id := range []int64{2301, 2302, 2303} {
+		resp, _, err := suite.createAccount(id, "100.00")
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	}
+	errorCode := func(response map[string]interface{}) interface{} {
+		if errorData, ok := response["error"].(map[string]interface{}); ok {
+			return errorData["code"]
+		}
+		return nil
+	}
+	transferWith := func(key string, sourceID, destID int64) (*http.Response, map[string]interface{}) {
+		return suite.requestWithKey(key, http.MethodPost, "/transactions", map[string]interface{}{
+			"source_account_id":      sourceID,
+			"destination_account_id": destID,
+			"amount":                 "10.00",
+		})
+	}
+	createKey := func(payload map[string]interface{}) (string, map[string]interface{}) {
+		resp, response := suite.requestWithKey(testAdminKey, http.MethodPost, "/api-keys", payload)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+		key := response["data"].(map[string]interface{})
+		plain := key["key"].(string)
+		assert.True(suite.T(), strings.HasPrefix(plain, "itk_"))
+		assert.True(suite.T(), strings.HasPrefix(plain, key["prefix"].(string)))
+		return plain, key
+	}
+
+	// Requests without credentials, with another scheme or with an unknown key are rejected
+	resp, response := suite.requestWithKey("", http.MethodGet, "/accounts/2301", nil)
+	assert.Equal(suite.T(), http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(suite.T(), "unauthorized", errorCode(response))
+	assert.Contains(suite.T(), resp.Header.Get("WWW-Authenticate"), "Bearer")
+	resp, _, err := suite.post("/accounts", map[string]interface{}{"account_id": 2399, "initial_balance": "1"}, map[string]string{"Authorization": "Basic " + testAdminKey})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnauthorized, resp.StatusCode)
+	resp, response = suite.requestWithKey("itk_unknown", http.MethodGet, "/accounts/2301", nil)
+	assert.Equal(suite.T(), http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(suite.T(), "unauthorized", errorCode(response))
+
+	// The health check stays open
+	health, err := http.Get(suite.baseURL + "/health")
+	assert.NoError(suite.T(), err)
+	health.Body.Close()
+	assert.Equal(suite.T(), http.StatusOK, health.StatusCode)
+
+	// Keys need a name and known scopes, and may only debit real account IDs
+	for _, payload := range []map[string]interface{}{
+		{"name": "", "scopes": []string{"accounts:read"}},
+		{"name": "bad scope", "scopes": []string{"accounts:delete"}},
+		{"name": "no scopes", "scopes": []string{}},
+		{"name": "empty allowlist", "scopes": []string{"transfers:write"}, "debit_account_ids": []int64{}},
+		{"name": "bad allowlist", "scopes": []string{"transfers:write"}, "debit_account_ids": []int64{0}},
+	} {
+		resp, response := suite.requestWithKey(testAdminKey, http.MethodPost, "/api-keys", payload)
+		assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode, "payload %v", payload)
+		assert.Equal(suite.T(), "invalid_input", errorCode(response))
+	}
+
+	// A read-only key reads accounts but cannot move funds or manage keys
+	reader, _ := createKey(map[string]interface{}{"name": "dashboard", "scopes": []string{"accounts:read", "accounts:read"}})
+	resp, response = suite.requestWithKey(reader, http.MethodGet, "/accounts/2301", nil)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	resp, response = transferWith(reader, 2301, 2302)
+	assert.Equal(suite.T(), http.StatusForbidden, resp.StatusCode)
+	assert.Equal(suite.T(), "forbidden", errorCode(response))
+	resp, _ = suite.requestWithKey(reader, http.MethodGet, "/api-keys", nil)
+	assert.Equal(suite.T(), http.StatusForbidden, resp.StatusCode)
+
+	// An accounts:write key opens accounts, but changing an account's status, tier or overdraft
+	// loosens what it may send, so it takes admin
+	opener, _ := createKey(map[string]interface{}{"name": "onboarding", "scopes": []string{"accounts:write"}, "debit_account_ids": []int64{2304}})
+	resp, _ = suite.requestWithKey(opener, http.MethodPost, "/accounts", map[string]interface{}{"account_id": 2304, "initial_balance": "10.00"})
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	for _, change := range []struct {
+		method, path string
+		payload      map[string]interface{}
+	}{
+		{http.MethodPost, "/accounts/2304/freeze", map[string]interface{}{"reason": "review"}},
+		{http.MethodPost, "/accounts/2304/unfreeze", map[string]interface{}{"reason": "review"}},
+		{http.MethodPost, "/accounts/2304/close", map[string]interface{}{"reason": "review", "sweep_to_account_id": "2301"}},
+		{http.MethodPut, "/accounts/2304/tier", map[string]interface{}{"tier": "premium"}},
+		{http.MethodPut, "/accounts/2304/overdraft", map[string]interface{}{"overdraft_limit": "1000000"}},
+	} {
+		resp, response = suite.requestWithKey(opener, change.method, change.path, change.payload)
+		assert.Equal(suite.T(), http.StatusForbidden, resp.StatusCode, change.path)
+		assert.Equal(suite.T(), "forbidden", errorCode(response), change.path)
+	}
+	resp, response = suite.requestWithKey(testAdminKey, http.MethodGet, "/accounts/2304", nil)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	assert.Equal(suite.T(), "active", response["data"].(map[string]interface{})["status"])
+
+	// A transfer key limited to debiting 2301 cannot debit other accounts, directly or otherwise
+	payer, payerKey := createKey(map[string]interface{}{
+		"name":              "payroll",
+		"scopes":            []string{"transfers:write", "accounts:read"},
+		"debit_account_ids": []int64{2301},
+	})
+	assert.Equal(suite.T(), []interface{}{float64(2301)}, payerKey["debit_account_ids"])
+	resp, response = transferWith(payer, 2301, 2302)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	resp, response = transferWith(payer, 2302, 2301)
+	assert.Equal(suite.T(), http.StatusForbidden, resp.StatusCode)
+	assert.Equal(suite.T(), "forbidden", errorCode(response))
+	resp, _ = suite.requestWithKey(payer, http.MethodPost, "/holds", map[string]interface{}{
+		"source_account_id":      2303,
+		"destination_account_id": 2301,
+		"amount":                 "5.00",
+	})
+	assert.Equal(suite.T(), http.StatusForbidden, resp.StatusCode)
+	resp, response = suite.requestWithKey(payer, http.MethodPost, "/transactions/batch", map[string]interface{}{
+		"mode":            "best_effort",
+		"idempotency_key": "batch-api-key-1",
+		"transfers": []map[string]interface{}{
+			{"source_account_id": 2301, "destination_account_id": 2303, "amount": "1.00"},
+			{"source_account_id": 2303, "destination_account_id": 2301, "amount": "1.00"},
+		},
+	})
+	assert.Equal(suite.T(), http.StatusForbidden, resp.StatusCode)
+	if errorData, ok := response["error"].(map[string]interface{}); assert.True(suite.T(), ok) {
+		assert.Contains(suite.T(), errorData["details"], "item: 1")
+	}
+	resp, _ = suite.requestWithKey(payer, http.MethodPost, "/accounts/2301/freeze", nil)
+	assert.Equal(suite.T(), http.StatusForbidden, resp.StatusCode)
+
+	// Nor can it void holds, cancel scheduled transfers or pause, resume or cancel standing
+	// orders debiting other accounts
+	later := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	resp, response = suite.requestWithKey(testAdminKey, http.MethodPost, "/holds", map[string]interface{}{
+		"source_account_id": 2303, "destination_account_id": 2301, "amount": "5.00",
+	})
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	holdPath := "/holds/" + response["data"].(map[string]interface{})["hold_id"].(string)
+	resp, response = suite.requestWithKey(testAdminKey, http.MethodPost, "/transactions", map[string]interface{}{
+		"source_account_id": 2303, "destination_account_id": 2301, "amount": "5.00", "execute_at": later,
+	})
+	assert.Equal(suite.T(), http.StatusAccepted, resp.StatusCode)
+	scheduledPath := "/scheduled-transfers/" + response["data"].(map[string]interface{})["scheduled_transfer_id"].(string)
+	resp, response = suite.requestWithKey(testAdminKey, http.MethodPost, "/standing-orders", map[string]interface{}{
+		"source_account_id": 2303, "destination_account_id": 2301, "amount": "5.00", "interval": "24h", "start_at": later,
+	})
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	orderPath := "/standing-orders/" + response["data"].(map[string]interface{})["standing_order_id"].(string)
+	resp, _ = suite.requestWithKey(testAdminKey, http.MethodPost, orderPath+"/pause", nil)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	for _, change := range []struct{ method, path string }{
+		{http.MethodPost, holdPath + "/void"},
+		{http.MethodPost, scheduledPath + "/cancel"},
+		{http.MethodPost, orderPath + "/resume"},
+		{http.MethodPost, orderPath + "/pause"},
+		{http.MethodDelete, orderPath},
+	} {
+		resp, response = suite.requestWithKey(payer, change.method, change.path, nil)
+		assert.Equal(suite.T(), http.StatusForbidden, resp.StatusCode, change.path)
+		assert.Equal(suite.T(), "forbidden", errorCode(response), change.path)
+	}
+	for path, status := range map[string]string{holdPath: "active", scheduledPath: "scheduled", orderPath: "paused"} {
+		resp, response = suite.requestWithKey(testAdminKey, http.MethodGet, path, nil)
+		assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+		assert.Equal(suite.T(), status, response["data"].(map[string]interface{})["status"], path)
+	}
+	resp, _ = suite.requestWithKey(testAdminKey, http.MethodPost, holdPath+"/void", nil)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	resp, _ = suite.requestWithKey(testAdminKey, http.MethodPost, scheduledPath+"/cancel", nil)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	resp, _ = suite.requestWithKey(testAdminKey, http.MethodDelete, orderPath, nil)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+	balance, _ := suite.accountBalances(2301)
+	suite.assertDecimalEqual("90.00", balance)
+	balance, _ = suite.accountBalances(2303)
+	suite.assertDecimalEqual("100.00", balance)
+
+	// Keys are shown once: lists and lookups only carry the prefix
+	resp, response = suite.requestWithKey(testAdminKey, http.MethodGet, "/api-keys", nil)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	keys := response["data"].(map[string]interface{})["api_keys"].([]interface{})
+	assert.GreaterOrEqual(suite.T(), len(keys), 2)
+	for _, key := range keys {
+		assert.NotContains(suite.T(), key.(map[string]interface{}), "key")
+	}
+	keyID := payerKey["api_key_id"].(string)
+	resp, response = suite.requestWithKey(testAdminKey, http.MethodGet, "/api-keys/"+keyID, nil)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	assert.NotContains(suite.T(), response["data"].(map[string]interface{}), "key")
+	assert.Equal(suite.T(), "payroll", response["data"].(map[string]interface{})["name"])
+
+	// A revoked key stops authenticating
+	resp, response = suite.requestWithKey(testAdminKey, http.MethodPost, "/api-keys/"+keyID+"/revoke", nil)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	assert.NotNil(suite.T(), response["data"].(map[string]interface{})["revoked_at"])
+	resp, response = transferWith(payer, 2301, 2302)
+	assert.Equal(suite.T(), http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(suite.T(), "unauthorized", errorCode(response))
+
+	resp, response = suite.requestWithKey(testAdminKey, http.MethodGet, "/api-keys/"+uuid.New().String(), nil)
+	assert.Equal(suite.T(), http.StatusNotFound, resp.StatusCode)
+	assert.Equal(suite.T(), "api_key_not_found", errorCode(response))
+	resp, _ = suite.requestWithKey(testAdminKey, http.MethodPost, "/api-keys/not-a-uuid/revoke", nil)
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+}
+
+func (suite *IntegrationTestSuite) stepJWTs() {
+	for _, id := range []int64{2401, 2402} {
+		resp, _, err := suite.createAccount(id, "100.00")
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	}
+	claims := func(subject, scope string, overrides map[string]interface{}) map[string]interface{} {
+		now := time.Now()
+		c := map[string]interface{}{
+			"iss":   testJWTIssuer,
+			"aud":   []string{"other-service", testJWTAudience},
+			"sub":   subject,
+			"scope": scope,
+			"iat":   now.Unix(),
+			"exp":   now.Add(5 * time.Minute).Unix(),
+		}
+		for name, value := range overrides {
+			c[name] = value
+		}
+		return c
+	}
+	transferWith := func(token string, amount string) (*http.Response, map[string]interface{}) {
+		return suite.requestWithKey(token, http.MethodPost, "/transactions", map[string]interface{}{
+			"source_account_id":      2401,
+			"destination_account_id": 2402,
+			"amount":                 amount,
+		})
+	}
+
+	// A token's subject becomes the principal recorded on the transactions it creates, and
+	// scopes the service does not know are ignored
+	payer := signJWT(suite.jwtKey, "test-rsa", claims("alice", "openid transfers:write accounts:read", nil))
+	resp, response := transferWith(payer, "10.00")
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	transfer := response["data"].(map[string]interface{})
+	assert.Equal(suite.T(), "jwt:alice", transfer["initiated_by"])
+	resp, response = suite.requestWithKey(payer, http.MethodGet, "/transactions/"+transfer["transaction_id"].(string), nil)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	assert.Equal(suite.T(), "jwt:alice", response["data"].(map[string]interface{})["initiated_by"])
+
+	// Other credentials are recorded too
+	resp, body, err := suite.transfer(2401, 2402, "1.00")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	response, err = suite.parseResponse(body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "admin", response["data"].(map[string]interface{})["initiated_by"])
+
+	// Scheduled transfers run for the principal that scheduled them
+	resp, response = suite.requestWithKey(payer, http.MethodPost, "/transactions", map[string]interface{}{
+		"source_account_id":      2401,
+		"destination_account_id": 2402,
+		"amount":                 "2.00",
+		"execute_at":             time.Now().Add(500 * time.Millisecond).UTC().Format(time.RFC3339Nano),
+	})
+	assert.Equal(suite.T(), http.StatusAccepted, resp.StatusCode)
+	scheduled := response["data"].(map[string]interface{})
+	assert.Equal(suite.T(), "jwt:alice", scheduled["initiated_by"])
+	ran := suite.waitForScheduledTransfer(scheduled["scheduled_transfer_id"].(string))
+	if assert.Equal(suite.T(), "completed", ran["status"]) {
+		resp, response = suite.requestWithKey(payer, http.MethodGet, "/transactions/"+ran["transaction_id"].(string), nil)
+		assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+		assert.Equal(suite.T(), "jwt:alice", response["data"].(map[string]interface{})["initiated_by"])
+	}
+
+	// Scopes may also be an array; a token without transfers:write cannot move funds
+	reader := signJWT(suite.jwtKey, "test-rsa", claims("bob", "", map[string]interface{}{"scope": []string{"accounts:read"}}))
+	resp, _ = suite.requestWithKey(reader, http.MethodGet, "/accounts/2401", nil)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	resp, response = transferWith(reader, "1.00")
+	assert.Equal(suite.T(), http.StatusForbidden, resp.StatusCode)
+
+	// Expired, premature, foreign, subjectless and forged tokens are rejected
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(suite.T(), err)
+	header, _ := json.Marshal(map[string]string{"alg": "none", "kid": "test-rsa"})
+	payload, _ := json.Marshal(claims("mallory", "transfers:write", nil))
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+	for name, token := range map[string]string{
+		"expired":      signJWT(suite.jwtKey, "test-rsa", claims("alice", "transfers:write", map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})),
+		"not yet":      signJWT(suite.jwtKey, "test-rsa", claims("alice", "transfers:write", map[string]interface{}{"nbf": time.Now().Add(time.Hour).Unix()})),
+		"issuer":       signJWT(suite.jwtKey, "test-rsa", claims("alice", "transfers:write", map[string]interface{}{"iss": "https://evil.test"})),
+		"audience":     signJWT(suite.jwtKey, "test-rsa", claims("alice", "transfers:write", map[string]interface{}{"aud": "other-service"})),
+		"no issuer":    signJWT(suite.jwtKey, "test-rsa", claims("alice", "transfers:write", map[string]interface{}{"iss": nil})),
+		"no audience":  signJWT(suite.jwtKey, "test-rsa", claims("alice", "transfers:write", map[string]interface{}{"aud": nil})),
+		"no subject":   signJWT(suite.jwtKey, "test-rsa", claims("", "transfers:write", nil)),
+		"no expiry":    signJWT(suite.jwtKey, "test-rsa", claims("alice", "transfers:write", map[string]interface{}{"exp": nil})),
+		"forged":       signJWT(otherKey, "test-rsa", claims("alice", "transfers:write", nil)),
+		"unknown key":  signJWT(otherKey, "other", claims("alice", "transfers:write", nil)),
+		"unsigned":     unsigned,
+		"tampered":     payer[:strings.LastIndex(payer, ".")] + "." + base64.RawURLEncoding.EncodeToString([]byte("signature")),
+		"not base64":   "a.b.c",
+		"wrong format": "a.b",
+	} {
+		resp, response := transferWith(token, "1.00")
+		assert.Equal(suite.T(), http.StatusUnauthorized, resp.StatusCode, name)
+		if errorData, ok := response["error"].(map[string]interface{}); assert.True(suite.T(), ok, name) {
+			assert.Equal(suite.T(), "unauthorized", errorData["code"], name)
+		}
+	}
+
+	// A key added to the key set is picked up as soon as a token uses it, and the key it
+	// replaces keeps working until the set is reloaded without it
+	rotated, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(suite.T(), err)
+	assert.NoError(suite.T(), writeJWKS(suite.jwksFile, map[string]crypto.PublicKey{
+		"test-rsa": &suite.jwtKey.PublicKey,
+		"test-ec":  &rotated.PublicKey,
+	}))
+	resp, response = transferWith(signJWT(rotated, "test-ec", claims("carol", "transfers:write", nil)), "1.00")
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	assert.Equal(suite.T(), "jwt:carol", response["data"].(map[string]interface{})["initiated_by"])
+	resp, _ = transferWith(payer, "1.00")
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+	balance, _ := suite.accountBalances(2401)
+	suite.assertDecimalEqual("85.00", balance)
+
+	// A key set without an issuer or an audience to check tokens against fails startup
+	for name, unset := range map[string]func(*config.Config){
+		"issuer":   func(cfg *config.Config) { cfg.JWTIssuer = "" },
+		"audience": func(cfg *config.Config) { cfg.JWTAudience = "" },
+	} {
+		cfg := *suite.config
+		unset(&cfg)
+		_, _, err := server.StartServer(&cfg)
+		if assert.Error(suite.T(), err, name) {
+			assert.Contains(suite.T(), err.Error(), "JWT_ISSUER and JWT_AUDIENCE are required", name)
+		}
+	}
+}
+
+func (suite *IntegrationTestSuite) stepRateLimits() {
+	for _, id := range []int64{2501, 2502, 2503, 2504} {
+		resp, _, err := suite.createAccount(id, "100.00")
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	}
+	resp, response := suite.requestWithKey(testAdminKey, http.MethodPost, "/api-keys", map[string]interface{}{
+		"name":   "batch job",
+		"scopes": []string{"accounts:read", "transfers:write"},
+	})
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	batchJob := response["data"].(map[string]interface{})["key"].(string)
+
+	// Run a second server on the same database with tight limits, leaving the suite's server
+	// unlimited, and without the background workers the suite's server already runs
+	cfg := *suite.config
+	cfg.SchedulerInterval = 0
+	cfg.OutboxPollInterval = 0
+	cfg.WebhookDispatchInterval = 0
+	cfg.RateLimits = "GET /accounts/{account_id}=client:2/m; POST /transactions=client:100/m,account:3/m;" +
+		"POST /transactions/batch=client:2/m,account:1/m"
+	cfg.MaxInFlightTransfersPerAccount = 2
+	limited, port, err := server.StartServer(&cfg)
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	defer limited.Stop(context.Background())
+	send := func(key, method, path string, payload interface{}) (*http.Response, map[string]interface{}) {
+		return suite.requestServer("http://localhost:"+port, key, method, path, payload)
+	}
+	transfer := func(key string, sourceID, destID int64) (*http.Response, map[string]interface{}) {
+		return send(key, http.MethodPost, "/transactions", map[string]interface{}{
+			"source_account_id":      sourceID,
+			"destination_account_id": destID,
+			"amount":                 "1.00",
+		})
+	}
+	assertRateLimited := func(resp *http.Response, response map[string]interface{}, message string) {
+		assert.Equal(suite.T(), http.StatusTooManyRequests, resp.StatusCode)
+		retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		assert.NoError(suite.T(), err)
+		assert.GreaterOrEqual(suite.T(), retryAfter, 1)
+		if errorData, ok := response["error"].(map[string]interface{}); assert.True(suite.T(), ok) {
+			assert.Equal(suite.T(), "rate_limited", errorData["code"])
+			assert.Contains(suite.T(), errorData["message"], message)
+		}
+	}
+
+	// Each client has its own bucket per route, and the headers count down what is left
+	for _, remaining := range []string{"1", "0"} {
+		resp, _ = send(batchJob, http.MethodGet, "/accounts/2501", nil)
+		assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+		assert.Equal(suite.T(), "2", resp.Header.Get("RateLimit-Limit"))
+		assert.Equal(suite.T(), remaining, resp.Header.Get("RateLimit-Remaining"))
+		assert.NotEmpty(suite.T(), resp.Header.Get("RateLimit-Reset"))
+	}
+	resp, response = send(batchJob, http.MethodGet, "/accounts/2501", nil)
+	assertRateLimited(resp, response, "these credentials")
+	assert.Equal(suite.T(), "0", resp.Header.Get("RateLimit-Remaining"))
+	resp, _ = send(testAdminKey, http.MethodGet, "/accounts/2501", nil)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+	// Routes without a rule are not limited, and the suite's server has no limits at all
+	resp, _ = send(batchJob, http.MethodGet, "/accounts/2501/ledger", nil)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	assert.Empty(suite.T(), resp.Header.Get("RateLimit-Limit"))
+	resp, _ = suite.requestWithKey(batchJob, http.MethodGet, "/accounts/2501", nil)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+	// The client bucket is charged before credentials are checked, keyed by address until they
+	// are verified, so guessing keys is limited too. Once an address has used up the bucket,
+	// valid credentials from it are turned away as well, so a right guess is not given away.
+	for range 2 {
+		resp, _ = send("not-a-key", http.MethodGet, "/accounts/2501", nil)
+		assert.Equal(suite.T(), http.StatusUnauthorized, resp.StatusCode)
+	}
+	resp, response = send("not-a-key", http.MethodGet, "/accounts/2501", nil)
+	assertRateLimited(resp, response, "this client")
+	resp, response = send(testAdminKey, http.MethodGet, "/accounts/2501", nil)
+	assertRateLimited(resp, response, "this client")
+
+	// The bucket of a source account is shared by every client debiting it, and reported when
+	// it is the one closest to running out
+	for _, remaining := range []string{"2", "1", "0"} {
+		resp, _ = transfer(testAdminKey, 2501, 2502)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+		assert.Equal(suite.T(), "3", resp.Header.Get("RateLimit-Limit"))
+		assert.Equal(suite.T(), remaining, resp.Header.Get("RateLimit-Remaining"))
+	}
+	resp, response = transfer(batchJob, 2501, 2502)
+	assertRateLimited(resp, response, "account 2501")
+	resp, _ = transfer(batchJob, 2503, 2502)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+	// A request turned away by one bucket gives back the tokens it took from the others
+	batch := func(key, idempotencyKey string, sources ...int64) (*http.Response, map[string]interface{}) {
+		var transfers []map[string]interface{}
+		for _, source := range sources {
+			dest := int64(2502)
+			if source == dest {
+				dest = 2501
+			}
+			transfers = append(transfers, map[string]interface{}{
+				"source_account_id": source, "destination_account_id": dest, "amount": "1.00",
+			})
+		}
+		return send(key, http.MethodPost, "/transactions/batch", map[string]interface{}{
+			"idempotency_key": idempotencyKey, "transfers": transfers,
+		})
+	}
+	resp, _ = batch(testAdminKey, "limits-batch-1", 2501)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	resp, response = batch(batchJob, "limits-batch-2", 2503, 2501)
+	assertRateLimited(resp, response, "account 2501")
+	resp, _ = batch(batchJob, "limits-batch-3", 2503)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	resp, _ = batch(batchJob, "limits-batch-4", 2502)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	resp, response = batch(batchJob, "limits-batch-5", 2504)
+	assertRateLimited(resp, response, "these credentials")
+
+	// Bodies are read for their source accounts only up to the size the handlers accept
+	resp, response = send(testAdminKey, http.MethodPost, "/transactions", map[string]interface{}{
+		"source_account_id":      2503,
+		"destination_account_id": 2502,
+		"amount":                 "1.00",
+		"padding":                strings.Repeat("x", handler.MaxRequestBodySize),
+	})
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+	if errorData, ok := response["error"].(map[string]interface{}); assert.True(suite.T(), ok) {
+		assert.Equal(suite.T(), "invalid request body", errorData["message"])
+		assert.Contains(suite.T(), errorData["details"], "request body too large")
+	}
+
+	// Hold the source account's row lock so transfers from it stay in flight, queued behind it.
+	// They credit another account than the transfers made meanwhile, which would queue too.
+	db, err := sql.Open("postgres", suite.dbConnStr)
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	defer db.Close()
+	lock, err := db.Begin()
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	_, err = lock.Exec(`SELECT id FROM accounts WHERE id = 2504 FOR UPDATE`)
+	assert.NoError(suite.T(), err)
+
+	var wg sync.WaitGroup
+	statuses := make([]int, 2)
+	for i := range statuses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, _ := transfer(batchJob, 2504, 2501)
+			statuses[i] = resp.StatusCode
+		}()
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var waiting int
+		err := db.QueryRow(`SELECT count(*) FROM pg_stat_activity WHERE wait_event_type = 'Lock'`).Scan(&waiting)
+		assert.NoError(suite.T(), err)
+		if waiting >= 2 || time.Now().After(deadline) {
+			assert.Equal(suite.T(), 2, waiting)
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Beyond the cap, transfers from the account are turned away instead of queueing, while
+	// other accounts are unaffected
+	resp, response = transfer(batchJob, 2504, 2501)
+	assertRateLimited(resp, response, "too many transfers in flight from account 2504")
+	resp, _ = transfer(batchJob, 2503, 2502)
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+
+	assert.NoError(suite.T(), lock.Commit())
+	wg.Wait()
+	assert.Equal(suite.T(), []int{http.StatusCreated, http.StatusCreated}, statuses)
+
+	balance, _ := suite.accountBalances(2504)
+	suite.assertDecimalEqual("98.00", balance)
+}
+
+func (suite *IntegrationTestSuite) stepTransactionRetries() {
+	for _, id := range []int64{2601, 2602} {
+		resp, _, err := suite.createAccount(id, "100.00")
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	}
+
+	// Run a second server whose database sessions default to SERIALIZABLE, so a row updated
+	// after a transaction's snapshot fails it with a serialization failure
+	db, err := sql.Open("postgres", suite.dbConnStr)
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	defer db.Close()
+	for _, statement := range []string{
+		`CREATE ROLE serializable_client LOGIN SUPERUSER PASSWORD 'password'`,
+		`ALTER ROLE serializable_client SET default_transaction_isolation = 'serializable'`,
+	} {
+		_, err := db.Exec(statement)
+		assert.NoError(suite.T(), err)
+	}
+	cfg := *suite.config
+	cfg.DBUser = "serializable_client"
+	cfg.SchedulerInterval = 0
+	cfg.OutboxPollInterval = 0
+	cfg.WebhookDispatchInterval = 0
+	serializable, port, err := server.StartServer(&cfg)
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	defer serializable.Stop(context.Background())
+	baseURL := "http://localhost:" + port
+	retries := func() float64 {
+		resp, response := suite.requestServer(baseURL, "", http.MethodGet, "/health", nil)
+		assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+		return response["transactions"].(map[string]interface{})["retries"].(float64)
+	}
+	before := retries()
+
+	// Touch the source account in a transaction left open, so the transfer takes its snapshot
+	// and then queues on the row lock
+	update, err := db.Begin()
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	_, err = update.Exec(`UPDATE accounts SET updated_at = now() WHERE id = 2601`)
+	assert.NoError(suite.T(), err)
+
+	done := make(chan *http.Response, 1)
+	go func() {
+		resp, _ := suite.requestServer(baseURL, testAdminKey, http.MethodPost, "/transactions", map[string]interface{}{
+			"source_account_id":      2601,
+			"destination_account_id": 2602,
+			"amount":                 "30.00",
+		})
+		done <- resp
+	}()
+	assert.Eventually(suite.T(), func() bool {
+		var waiting int
+		err := db.QueryRow(`SELECT count(*) FROM pg_stat_activity
+			WHERE usename = 'serializable_client' AND wait_event_type = 'Lock'`).Scan(&waiting)
+		return err == nil && waiting == 1
+	}, 10*time.Second, 50*time.Millisecond)
+
+	// Committing the update fails the transfer's first attempt, and the retry goes through
+	assert.NoError(suite.T(), update.Commit())
+	resp := <-done
+	assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	assert.Greater(suite.T(), retries(), before)
+
+	balance, _ := suite.accountBalances(2601)
+	suite.assertDecimalEqual("70.00", balance)
+	balance, _ = suite.accountBalances(2602)
+	suite.assertDecimalEqual("130.00", balance)
+}
+
+func (suite *IntegrationTestSuite) stepRequestTimeout() {
+	for _, id := range []int64{2701, 2702} {
+		resp, _, err := suite.createAccount(id, "100.00")
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, resp.StatusCode)
+	}
+
+	cfg := *suite.config
+	cfg.RequestTimeout = 300 * time.Millisecond
+	cfg.SchedulerInterval = 0
+	cfg.OutboxPollInterval = 0
+	cfg.WebhookDispatchInterval = 0
+	bounded, port, err := server.StartServer(&cfg)
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	defer bounded.Stop(context.Background())
+
+	// Hold the source account's row lock past the request timeout
+	db, err := sql.Open("postgres", suite.dbConnStr)
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	defer db.Close()
+	lock, err := db.Begin()
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	defer lock.Rollback()
+	_, err = lock.Exec(`SELECT id FROM accounts WHERE id = 2701 FOR UPDATE`)
+	assert.NoError(suite.T(), err)
+
+	started := time.Now()
+	resp, response := suite.requestServer("http://localhost:"+port, testAdminKey, http.MethodPost, "/transactions", map[string]interface{}{
+		"source_account_id":      2701,
+		"destination_account_id": 2702,
+		"amount":                 "10.00",
+	})
+	assert.Equal(suite.T(), http.StatusGatewayTimeout, resp.StatusCode)
+	assert.Less(suite.T(), time.Since(started), 5*time.Second)
+	if errorData, ok := response["error"].(map[string]interface{}); assert.True(suite.T(), ok) {
+		assert.Equal(suite.T(), "request_timeout", errorData["code"])
+	}
+
+	// The deadline cancels the transfer's FOR UPDATE rather than leaving it queued on the lock
+	assert.Eventually(suite.T(), func() bool {
+		var waiting int
+		err := db.QueryRow(`SELECT count(*) FROM pg_stat_activity
+			WHERE wait_event_type = 'Lock' AND query LIKE '%FOR UPDATE%'`).Scan(&waiting)
+		return err == nil && waiting == 0
+	}, 5*time.Second, 50*time.Millisecond)
+
+	assert.NoError(suite.T(), lock.Commit())
+	var transfers int
+	err = db.QueryRow(`SELECT count(*) FROM transactions WHERE source_account_id = 2701`).Scan(&transfers)
+	assert.NoError(suite.T(), err)
+	assert.Zero(suite.T(), transfers)
+	balance, _ := suite.accountBalances(2701)
+	suite.assertDecimalEqual("100.00", balance)
+}
+
 func (suite *IntegrationTestSuite) stepSameAccountTransfer() {
 	// Try to transfer to same account
 	resp, body, err := suite.transfer(123, 123, "100.00")
@@ -618,6 +3828,31 @@ func (suite *IntegrationTestSuite) TestFlow() {
 	suite.stepIdempotentTransfer()
 	suite.stepNonIdempotentTransfer()
 	suite.stepInsufficientBalance()
+	suite.stepFailedTransferReplay()
+	suite.stepLedgerAudit()
+	suite.stepAccountStatement()
+	suite.stepTransactionLookup()
+	suite.stepIdempotencyKeyHeader()
+	suite.stepBatchTransfer()
+	suite.stepTransferReversal()
+	suite.stepHolds()
+	suite.stepScheduledTransfers()
+	suite.stepStandingOrders()
+	suite.stepCurrencies()
+	suite.stepFXTransfers()
+	suite.stepAccountLifecycle()
+	suite.stepTransferLimits()
+	suite.stepLimitsAfterReversal()
+	suite.stepOverdraft()
+	suite.stepTransferFees()
+	suite.stepOutboxEvents()
+	suite.stepWebhooks()
+	suite.stepStreams()
+	suite.stepAPIKeys()
+	suite.stepJWTs()
+	suite.stepRateLimits()
+	suite.stepTransactionRetries()
+	suite.stepRequestTimeout()
 	suite.stepSameAccountTransfer()
 	suite.stepInvalidAmount()
 	suite.stepZeroAmount()
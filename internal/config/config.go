@@ -3,6 +3,10 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 type Config struct {
@@ -12,6 +16,108 @@ type Config struct {
 	DBPassword string
 	DBName     string
 	ServerPort string
+
+	// RequestTimeout bounds each HTTP request, including its database work. Zero disables it.
+	RequestTimeout time.Duration
+
+	// Transactions failing with a serialization failure or deadlock are retried
+	// up to TxMaxAttempts times with exponential backoff between the two delays.
+	TxMaxAttempts     int
+	TxRetryBaseDelay  time.Duration
+	TxRetryMaxBackoff time.Duration
+
+	// Idempotency keys are released once older than the retention window.
+	// A zero retention or interval disables the sweeper.
+	IdempotencyKeyRetention  time.Duration
+	IdempotencySweepInterval time.Duration
+
+	// Holds expire after HoldDefaultTTL unless the request sets expires_at.
+	// Expired holds are released every HoldExpiryInterval; zero disables the expirer.
+	HoldDefaultTTL     time.Duration
+	HoldExpiryInterval time.Duration
+
+	// SchedulerInterval is how often due scheduled transfers are run. Zero disables the scheduler.
+	SchedulerInterval time.Duration
+
+	// Exchange rates come from FXRateURL when set, otherwise from the JSON file at FXRatesFile.
+	// Without either, transfers between currencies are rejected. FX quotes lock a rate for
+	// FXQuoteTTL unless the request sets its own ttl.
+	FXRateURL     string
+	FXRatesFile   string
+	FXRateTimeout time.Duration
+	FXQuoteTTL    time.Duration
+
+	// Transfer limits used when none is defined for an account, its tier or globally through the
+	// limits API. Zero disables a limit. Accounts cannot open with more than MaxInitialBalance.
+	LimitPerTransfer  decimal.Decimal
+	LimitDaily        decimal.Decimal
+	LimitMonthly      decimal.Decimal
+	MaxInitialBalance decimal.Decimal
+
+	// FeeAccountID collects the fees of schedules that do not name their own fee account.
+	// Zero leaves such schedules without an account, so they cannot be defined.
+	FeeAccountID int64
+
+	// Outbox events are published through EventPublisher: "jsonl" writes JSON lines to
+	// EventJSONLFile, or to stdout when it is empty; "webhook" POSTs them to EventWebhookURL.
+	// Without a publisher, events stay in the outbox.
+	EventPublisher      string
+	EventJSONLFile      string
+	EventWebhookURL     string
+	EventWebhookTimeout time.Duration
+
+	// The relay publishes pending events every OutboxPollInterval, OutboxBatchSize at a time;
+	// zero disables it. Failed deliveries are retried with exponential backoff between the two
+	// delays, and events are dead-lettered after OutboxMaxAttempts. Published events are deleted
+	// after OutboxRetention; zero keeps them.
+	OutboxPollInterval    time.Duration
+	OutboxBatchSize       int
+	OutboxRetryBaseDelay  time.Duration
+	OutboxRetryMaxBackoff time.Duration
+	OutboxMaxAttempts     int
+	OutboxRetention       time.Duration
+
+	// Webhook deliveries due are sent every WebhookDispatchInterval, WebhookBatchSize at a time;
+	// zero disables the dispatcher. Each attempt times out after WebhookTimeout. Failed attempts
+	// are retried with exponential backoff between the two delays, and a delivery is
+	// dead-lettered after WebhookMaxAttempts.
+	WebhookDispatchInterval time.Duration
+	WebhookBatchSize        int
+	WebhookTimeout          time.Duration
+	WebhookMaxAttempts      int
+	WebhookRetryBaseDelay   time.Duration
+	WebhookRetryMaxBackoff  time.Duration
+
+	// Idle account streams send a keep-alive comment every StreamHeartbeatInterval. A client not
+	// accepting a write within StreamWriteTimeout is disconnected.
+	StreamHeartbeatInterval time.Duration
+	StreamWriteTimeout      time.Duration
+
+	// With AuthEnabled, every route but /health needs an API key sent as a bearer token.
+	// AdminAPIKey is accepted with the admin scope, to create the first keys.
+	AuthEnabled bool
+	AdminAPIKey string
+
+	// JWTs from an identity provider are accepted besides API keys when JWTJWKSURL, or else
+	// JWTJWKSFile, holds the provider's signing keys. The key set is cached for
+	// JWTJWKSRefreshInterval and reloaded early for unknown key IDs. Tokens must be issued by
+	// JWTIssuer and for JWTAudience, both required with a key set. JWTSubjectClaim names the
+	// acting principal and JWTScopeClaim its scopes.
+	JWTJWKSURL             string
+	JWTJWKSFile            string
+	JWTJWKSTimeout         time.Duration
+	JWTJWKSRefreshInterval time.Duration
+	JWTIssuer              string
+	JWTAudience            string
+	JWTSubjectClaim        string
+	JWTScopeClaim          string
+	JWTClockSkew           time.Duration
+
+	// RateLimits throttles routes per client and per source account, as semicolon-separated rules
+	// such as "POST /transactions=client:100/s:200,account:20/s:40". Transfers in flight from an
+	// account are capped at MaxInFlightTransfersPerAccount; zero disables the cap.
+	RateLimits                     string
+	MaxInFlightTransfersPerAccount int
 }
 
 func Load() *Config {
@@ -22,6 +128,70 @@ func Load() *Config {
 		DBPassword: getEnv("DB_PASSWORD", "password"),
 		DBName:     getEnv("DB_NAME", "internal_transfers"),
 		ServerPort: getEnv("SERVER_PORT", "8080"),
+
+		RequestTimeout: getEnvDuration("REQUEST_TIMEOUT", 10*time.Second),
+
+		TxMaxAttempts:     getEnvInt("TX_MAX_ATTEMPTS", 3),
+		TxRetryBaseDelay:  getEnvDuration("TX_RETRY_BASE_DELAY", 10*time.Millisecond),
+		TxRetryMaxBackoff: getEnvDuration("TX_RETRY_MAX_BACKOFF", 250*time.Millisecond),
+
+		IdempotencyKeyRetention:  getEnvDuration("IDEMPOTENCY_KEY_RETENTION", 24*time.Hour),
+		IdempotencySweepInterval: getEnvDuration("IDEMPOTENCY_SWEEP_INTERVAL", time.Hour),
+
+		HoldDefaultTTL:     getEnvDuration("HOLD_DEFAULT_TTL", 7*24*time.Hour),
+		HoldExpiryInterval: getEnvDuration("HOLD_EXPIRY_INTERVAL", time.Minute),
+
+		SchedulerInterval: getEnvDuration("SCHEDULER_INTERVAL", 5*time.Second),
+
+		FXRateURL:     getEnv("FX_RATE_URL", ""),
+		FXRatesFile:   getEnv("FX_RATES_FILE", ""),
+		FXRateTimeout: getEnvDuration("FX_RATE_TIMEOUT", 2*time.Second),
+		FXQuoteTTL:    getEnvDuration("FX_QUOTE_TTL", time.Minute),
+
+		LimitPerTransfer:  getEnvDecimal("LIMIT_PER_TRANSFER", decimal.NewFromInt(1_000_000_000)),
+		LimitDaily:        getEnvDecimal("LIMIT_DAILY", decimal.Zero),
+		LimitMonthly:      getEnvDecimal("LIMIT_MONTHLY", decimal.Zero),
+		MaxInitialBalance: getEnvDecimal("MAX_INITIAL_BALANCE", decimal.NewFromInt(10_000_000_000)),
+
+		FeeAccountID: int64(getEnvInt("FEE_ACCOUNT_ID", 0)),
+
+		EventPublisher:      getEnv("EVENT_PUBLISHER", ""),
+		EventJSONLFile:      getEnv("EVENT_JSONL_FILE", ""),
+		EventWebhookURL:     getEnv("EVENT_WEBHOOK_URL", ""),
+		EventWebhookTimeout: getEnvDuration("EVENT_WEBHOOK_TIMEOUT", 5*time.Second),
+
+		OutboxPollInterval:    getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+		OutboxBatchSize:       getEnvInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxRetryBaseDelay:  getEnvDuration("OUTBOX_RETRY_BASE_DELAY", time.Second),
+		OutboxRetryMaxBackoff: getEnvDuration("OUTBOX_RETRY_MAX_BACKOFF", 5*time.Minute),
+		OutboxMaxAttempts:     getEnvInt("OUTBOX_MAX_ATTEMPTS", 20),
+		OutboxRetention:       getEnvDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+
+		WebhookDispatchInterval: getEnvDuration("WEBHOOK_DISPATCH_INTERVAL", time.Second),
+		WebhookBatchSize:        getEnvInt("WEBHOOK_BATCH_SIZE", 50),
+		WebhookTimeout:          getEnvDuration("WEBHOOK_TIMEOUT", 5*time.Second),
+		WebhookMaxAttempts:      getEnvInt("WEBHOOK_MAX_ATTEMPTS", 10),
+		WebhookRetryBaseDelay:   getEnvDuration("WEBHOOK_RETRY_BASE_DELAY", 5*time.Second),
+		WebhookRetryMaxBackoff:  getEnvDuration("WEBHOOK_RETRY_MAX_BACKOFF", time.Hour),
+
+		StreamHeartbeatInterval: getEnvDuration("STREAM_HEARTBEAT_INTERVAL", 15*time.Second),
+		StreamWriteTimeout:      getEnvDuration("STREAM_WRITE_TIMEOUT", 10*time.Second),
+
+		AuthEnabled: getEnvBool("AUTH_ENABLED", true),
+		AdminAPIKey: getEnv("ADMIN_API_KEY", ""),
+
+		JWTJWKSURL:             getEnv("JWT_JWKS_URL", ""),
+		JWTJWKSFile:            getEnv("JWT_JWKS_FILE", ""),
+		JWTJWKSTimeout:         getEnvDuration("JWT_JWKS_TIMEOUT", 5*time.Second),
+		JWTJWKSRefreshInterval: getEnvDuration("JWT_JWKS_REFRESH_INTERVAL", 15*time.Minute),
+		JWTIssuer:              getEnv("JWT_ISSUER", ""),
+		JWTAudience:            getEnv("JWT_AUDIENCE", ""),
+		JWTSubjectClaim:        getEnv("JWT_SUBJECT_CLAIM", "sub"),
+		JWTScopeClaim:          getEnv("JWT_SCOPE_CLAIM", "scope"),
+		JWTClockSkew:           getEnvDuration("JWT_CLOCK_SKEW", time.Minute),
+
+		RateLimits:                     getEnv("RATE_LIMITS", "POST /transactions=client:100/s:200,account:20/s:40;POST /transactions/batch=client:10/s:20,account:20/s:40"),
+		MaxInFlightTransfersPerAccount: getEnvInt("MAX_IN_FLIGHT_TRANSFERS_PER_ACCOUNT", 4),
 	}
 }
 
@@ -36,3 +206,39 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDecimal(key string, defaultValue decimal.Decimal) decimal.Decimal {
+	if value := os.Getenv(key); value != "" {
+		if d, err := decimal.NewFromString(value); err == nil && !d.IsNegative() {
+			return d
+		}
+	}
+	return defaultValue
+}
@@ -1,21 +1,82 @@
 package domain
 
 import (
+	"context"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
 
+const (
+	AccountStatusActive = "active"
+	AccountStatusFrozen = "frozen" // Can receive funds but not send them
+	AccountStatusClosed = "closed" // Final; no funds move in or out
+)
+
+// DefaultAccountTier is the tier of accounts created without one
+const DefaultAccountTier = "standard"
+
 type Account struct {
-	ID        int64           `json:"account_id"`
-	Balance   decimal.Decimal `json:"balance"`
-	CreatedAt time.Time       `json:"created_at"`
-	UpdatedAt time.Time       `json:"updated_at"`
+	ID             int64           `json:"account_id"`
+	Balance        decimal.Decimal `json:"balance"`
+	Currency       string          `json:"currency"`     // ISO 4217 code
+	HeldBalance    decimal.Decimal `json:"held_balance"` // Reserved by active holds
+	Status         string          `json:"status"`
+	Tier           string          `json:"tier"`            // Selects the tier's transfer limits
+	OverdraftLimit decimal.Decimal `json:"overdraft_limit"` // How far below zero the balance may go
+	ClientID       string          `json:"-"`
+	IdempotencyKey *string         `json:"-"` // Optional, unique per client
+	RequestHash    string          `json:"-"` // SHA-256 of the creation payload when a key was used
+	Replayed       bool            `json:"-"` // Set when returned for an idempotent replay; not persisted
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// AvailableBalance is the part of the balance not reserved by holds. It is negative while the
+// account is overdrawn.
+func (a *Account) AvailableBalance() decimal.Decimal {
+	return a.Balance.Sub(a.HeldBalance)
+}
+
+// SpendableBalance is what the account can still send or reserve: its available balance plus
+// its overdraft limit
+func (a *Account) SpendableBalance() decimal.Decimal {
+	return a.AvailableBalance().Add(a.OverdraftLimit)
+}
+
+// AvailableCredit is the part of the overdraft limit not yet drawn on
+func (a *Account) AvailableCredit() decimal.Decimal {
+	return decimal.Min(a.OverdraftLimit, decimal.Max(a.SpendableBalance(), decimal.Zero))
+}
+
+// AccountStatusChange is one entry of an account's status audit trail
+type AccountStatusChange struct {
+	ID                 uuid.UUID  `json:"id"`
+	AccountID          int64      `json:"account_id"`
+	FromStatus         string     `json:"from_status"`
+	ToStatus           string     `json:"to_status"`
+	Reason             string     `json:"reason"`
+	ClientID           string     `json:"client_id,omitempty"`
+	SweepTransactionID *uuid.UUID `json:"sweep_transaction_id,omitempty"` // Set when closing swept the balance away
+	CreatedAt          time.Time  `json:"created_at"`
 }
 
 type AccountRepository interface {
-	CreateAccount(account *Account) error
-	GetAccount(id int64) (*Account, error)
-	GetAccountForUpdate(id int64) (*Account, error)
-	UpdateAccountBalance(id int64, newBalance decimal.Decimal) error
+	CreateAccount(ctx context.Context, account *Account) error
+	GetAccount(ctx context.Context, id int64) (*Account, error)
+	GetAccountForUpdate(ctx context.Context, id int64) (*Account, error)
+	GetAccountByIdempotencyKey(ctx context.Context, clientID, key string) (*Account, error) // Nil when the key is unused
+	PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time, limit int) (int64, error)
+	PostLedgerEntry(ctx context.Context, entry *LedgerEntry) error // Applies the entry to the balance and records it
+	AdjustHeldBalance(ctx context.Context, accountID int64, delta decimal.Decimal) error
+	GetLedgerEntries(ctx context.Context, accountID int64) ([]*LedgerEntry, error)
+	GetLedgerBalance(ctx context.Context, accountID int64) (decimal.Decimal, error)
+	// GetBalancesAfter returns the account's balance right after each of the transactions that posted to it
+	GetBalancesAfter(ctx context.Context, accountID int64, transactionIDs []uuid.UUID) (map[uuid.UUID]decimal.Decimal, error)
+	UpdateAccountStatus(ctx context.Context, id int64, status string) error
+	UpdateAccountTier(ctx context.Context, id int64, tier string) error
+	UpdateOverdraftLimit(ctx context.Context, id int64, limit decimal.Decimal) error
+	CreateStatusChange(ctx context.Context, change *AccountStatusChange) error
+	ListStatusChanges(ctx context.Context, accountID int64) ([]*AccountStatusChange, error)
 }
@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	BatchModeAtomic     = "atomic"
+	BatchModeBestEffort = "best_effort"
+)
+
+type TransactionBatch struct {
+	ID             uuid.UUID `json:"id"`
+	ClientID       string    `json:"client_id,omitempty"`
+	IdempotencyKey *string   `json:"idempotency_key,omitempty"`
+	RequestHash    string    `json:"-"`
+	Mode           string    `json:"mode"`
+	Status         string    `json:"status"` // completed, partially_completed or failed
+	ItemCount      int       `json:"item_count"`
+	FailureReason  *string   `json:"failure_reason,omitempty"` // Atomic batches only
+	FailedItem     *int      `json:"failed_item,omitempty"`    // Atomic batches only
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type BatchRepository interface {
+	CreateBatch(ctx context.Context, batch *TransactionBatch) error
+	GetBatchByIdempotencyKey(ctx context.Context, clientID, key string) (*TransactionBatch, error) // Nil when the key is unused
+	ListBatchTransactions(ctx context.Context, batchID uuid.UUID) ([]*Transaction, error)
+	PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time, limit int) (int64, error)
+}
@@ -0,0 +1,71 @@
+package domain
+
+import "github.com/shopspring/decimal"
+
+// DefaultCurrency is used for accounts opened without a currency and for rows written before
+// currencies were introduced
+const DefaultCurrency = "USD"
+
+// Rounding is how a converted amount is brought to the minor unit of its currency
+type Rounding int
+
+const (
+	RoundHalfEven Rounding = iota // Ties go to the even digit, so conversions carry no systematic bias
+	RoundHalfUp                   // Ties go away from zero
+	RoundDown                     // Truncates towards zero
+)
+
+// currencyRule is the precision of a currency and how amounts converted into it are rounded
+type currencyRule struct {
+	minorUnits int32 // Decimal places of the minor unit
+	rounding   Rounding
+}
+
+// currencyRules lists every supported ISO 4217 code
+var currencyRules = map[string]currencyRule{
+	"AUD": {2, RoundHalfEven},
+	"BHD": {3, RoundHalfEven},
+	"CAD": {2, RoundHalfEven},
+	"CHF": {2, RoundHalfEven},
+	"CNY": {2, RoundHalfEven},
+	"DKK": {2, RoundHalfEven},
+	"EUR": {2, RoundHalfEven},
+	"GBP": {2, RoundHalfEven},
+	"HKD": {2, RoundHalfEven},
+	"INR": {2, RoundHalfEven},
+	"JPY": {0, RoundDown}, // Fractional yen are not credited
+	"KRW": {0, RoundDown}, // Fractional won are not credited
+	"KWD": {3, RoundHalfEven},
+	"MXN": {2, RoundHalfEven},
+	"NOK": {2, RoundHalfEven},
+	"NZD": {2, RoundHalfEven},
+	"OMR": {3, RoundHalfEven},
+	"SEK": {2, RoundHalfEven},
+	"SGD": {2, RoundHalfEven},
+	"USD": {2, RoundHalfEven},
+	"ZAR": {2, RoundHalfEven},
+}
+
+// MinorUnits returns the number of decimal places of a currency, and false when it is not supported
+func MinorUnits(currency string) (int32, bool) {
+	rule, ok := currencyRules[currency]
+	return rule.minorUnits, ok
+}
+
+// MinorUnit returns the smallest amount of a supported currency, such as 0.01 for USD or 1 for JPY
+func MinorUnit(currency string) decimal.Decimal {
+	return decimal.New(1, -currencyRules[currency].minorUnits)
+}
+
+// RoundAmount rounds an amount to the minor unit of a supported currency using its rounding rule
+func RoundAmount(amount decimal.Decimal, currency string) decimal.Decimal {
+	rule := currencyRules[currency]
+	switch rule.rounding {
+	case RoundHalfUp:
+		return amount.Round(rule.minorUnits)
+	case RoundDown:
+		return amount.Truncate(rule.minorUnits)
+	default:
+		return amount.RoundBank(rule.minorUnits)
+	}
+}
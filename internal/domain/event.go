@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventAccountCreated    = "account.created"    // Data is the account
+	EventTransferCompleted = "transfer.completed" // Data is the transaction, reversals included
+	EventTransferFailed    = "transfer.failed"    // Data is the declined transaction
+)
+
+// EventTypes lists every event type
+var EventTypes = []string{EventAccountCreated, EventTransferCompleted, EventTransferFailed}
+
+// Event is a change recorded in the outbox with the database transaction that made it. It is
+// published at least once, so consumers should deduplicate on ID.
+type Event struct {
+	ID             uuid.UUID       `json:"id"`
+	Sequence       int64           `json:"sequence"` // Increases with every event; events of an account are published in order
+	Type           string          `json:"type"`
+	AccountIDs     []int64         `json:"account_ids"`
+	Data           json.RawMessage `json:"data"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Attempts       int             `json:"-"` // Failed deliveries so far
+	NextAttemptAt  time.Time       `json:"-"`
+	LastError      *string         `json:"-"`
+	PublishedAt    *time.Time      `json:"-"`
+	DeadLetteredAt *time.Time      `json:"-"` // Set when the event ran out of attempts; it is no longer published
+}
+
+// EventsChannel is the Postgres notification channel announcing events committed to the outbox
+const EventsChannel = "outbox_events"
+
+// EventNotification is the payload of a notification on EventsChannel. It only announces the
+// event; listeners read it from the outbox.
+type EventNotification struct {
+	Sequence   int64   `json:"sequence"`
+	AccountIDs []int64 `json:"account_ids"`
+}
+
+// EventListener receives the payloads of the notifications sent on EventsChannel. Notifications
+// sent while its connection was down are lost, so an empty payload is delivered after every
+// reconnect: receivers should then catch up from the outbox.
+type EventListener interface {
+	Notifications() <-chan string // Closed by Close
+	Close() error
+}
+
+// Publisher delivers events to downstream consumers. An error leaves the event pending, and it
+// is delivered again later.
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+type OutboxRepository interface {
+	Enqueue(ctx context.Context, event *Event) error // Sets the event's sequence
+	// TryLockRelay takes the transaction-scoped lock that lets a single relay run at a time.
+	// It returns false when another relay holds it.
+	TryLockRelay(ctx context.Context) (bool, error)
+	// ListPending returns the events neither published nor dead-lettered after a sequence, in
+	// sequence order, whether due or not
+	ListPending(ctx context.Context, afterSequence int64, limit int) ([]*Event, error)
+	MarkPublished(ctx context.Context, sequence int64, publishedAt time.Time) error
+	MarkFailed(ctx context.Context, sequence int64, nextAttemptAt time.Time, lastError string) error
+	MarkDeadLettered(ctx context.Context, sequence int64, deadLetteredAt time.Time, lastError string) error
+	PurgePublished(ctx context.Context, publishedBefore time.Time, limit int) (int64, error)
+	// ListAccountEvents returns the events concerning an account after a sequence, published or
+	// not, in sequence order
+	ListAccountEvents(ctx context.Context, accountID, afterSequence int64, limit int) ([]*Event, error)
+	LatestAccountSequence(ctx context.Context, accountID int64) (int64, error) // Zero when the account has no events
+}
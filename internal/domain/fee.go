@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	FeeTypeFlat       = "flat"       // The same fee for every transfer
+	FeeTypePercentage = "percentage" // A share of the transfer amount
+	FeeTypeTiered     = "tiered"     // A flat fee and a share that depend on the amount
+)
+
+// FeeTypes lists every fee type
+var FeeTypes = []string{FeeTypeFlat, FeeTypePercentage, FeeTypeTiered}
+
+const (
+	FeeScopeGlobal  = "global"
+	FeeScopeAccount = "account"
+)
+
+// FeeTier prices transfers up to an amount. The fee is Amount plus Percentage of the whole
+// transfer amount.
+type FeeTier struct {
+	UpTo       *decimal.Decimal `json:"up_to,omitempty"` // Inclusive; nil on the last tier
+	Amount     decimal.Decimal  `json:"amount"`
+	Percentage decimal.Decimal  `json:"percentage"` // 0-100
+}
+
+// FeeSchedule prices the transfers sent from an account. Fees are in the currency of the source
+// account. An account's own schedule applies first, then the global schedule for its currency,
+// then the global schedule for every currency.
+type FeeSchedule struct {
+	ID           uuid.UUID        `json:"id"`
+	Scope        string           `json:"scope"`
+	AccountID    *int64           `json:"account_id,omitempty"` // Account scope only
+	Currency     *string          `json:"currency,omitempty"`   // Global scope; nil applies to every currency
+	FeeType      string           `json:"fee_type"`
+	Tiers        []FeeTier        `json:"tiers"` // Ascending by UpTo; flat and percentage schedules have one
+	MinFee       *decimal.Decimal `json:"min_fee,omitempty"`
+	MaxFee       *decimal.Decimal `json:"max_fee,omitempty"`
+	FeeAccountID *int64           `json:"fee_account_id,omitempty"` // Nil collects into the configured fee account
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+}
+
+// Rank orders schedules from most to least specific
+func (s *FeeSchedule) Rank() int {
+	switch {
+	case s.Scope == FeeScopeAccount:
+		return 0
+	case s.Currency != nil:
+		return 1
+	}
+	return 2
+}
+
+// Fee prices a transfer of amount, before rounding to the currency's minor unit
+func (s *FeeSchedule) Fee(amount decimal.Decimal) decimal.Decimal {
+	tier := s.Tiers[len(s.Tiers)-1]
+	for _, candidate := range s.Tiers {
+		if candidate.UpTo == nil || amount.LessThanOrEqual(*candidate.UpTo) {
+			tier = candidate
+			break
+		}
+	}
+
+	fee := tier.Amount.Add(amount.Mul(tier.Percentage).Div(decimal.NewFromInt(100)))
+	if s.MinFee != nil && fee.LessThan(*s.MinFee) {
+		fee = *s.MinFee
+	}
+	if s.MaxFee != nil && fee.GreaterThan(*s.MaxFee) {
+		fee = *s.MaxFee
+	}
+	return fee
+}
+
+type FeeRepository interface {
+	UpsertSchedule(ctx context.Context, schedule *FeeSchedule) error        // Replaces the schedule of the same target and currency
+	DeleteSchedule(ctx context.Context, id uuid.UUID) (*FeeSchedule, error) // Returns the deleted schedule; nil when not found
+	ListSchedules(ctx context.Context) ([]*FeeSchedule, error)
+	// GetApplicableSchedules returns every schedule that can apply to an account, whatever its rank
+	GetApplicableSchedules(ctx context.Context, accountID int64, currency string) ([]*FeeSchedule, error)
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ErrRateNotFound is returned by a RateProvider that has no rate for a currency pair
+var ErrRateNotFound = errors.New("no exchange rate for currency pair")
+
+// RateProvider supplies exchange rates: how many units of to one unit of from buys
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string) (decimal.Decimal, error)
+}
+
+// FXQuote locks an exchange rate for a currency pair until it expires. A quote is used by at most
+// one completed transfer.
+type FXQuote struct {
+	ID                  uuid.UUID       `json:"id"`
+	SourceCurrency      string          `json:"source_currency"`
+	DestinationCurrency string          `json:"destination_currency"`
+	Rate                decimal.Decimal `json:"rate"`
+	ClientID            string          `json:"client_id,omitempty"`
+	ExpiresAt           time.Time       `json:"expires_at"`
+	TransactionID       *uuid.UUID      `json:"transaction_id,omitempty"` // Set once a transfer has used the quote
+	CreatedAt           time.Time       `json:"created_at"`
+
+	// Preview of converting Amount at the quoted rate, when the request named one; not persisted
+	Amount            *decimal.Decimal `json:"-"`
+	DestinationAmount *decimal.Decimal `json:"-"`
+}
+
+// Expired reports whether the quote can no longer be used at now
+func (q *FXQuote) Expired(now time.Time) bool {
+	return !now.Before(q.ExpiresAt)
+}
+
+type FXQuoteRepository interface {
+	CreateQuote(ctx context.Context, quote *FXQuote) error
+	GetQuote(ctx context.Context, id uuid.UUID) (*FXQuote, error)          // Nil when not found
+	GetQuoteForUpdate(ctx context.Context, id uuid.UUID) (*FXQuote, error) // Nil when not found
+	MarkQuoteUsed(ctx context.Context, id, transactionID uuid.UUID) error
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	HoldStatusActive   = "active"
+	HoldStatusCaptured = "captured"
+	HoldStatusVoided   = "voided"
+	HoldStatusExpired  = "expired"
+)
+
+// Hold reserves funds on a source account until it is captured into a transfer, voided or expires
+type Hold struct {
+	ID                   uuid.UUID       `json:"id"`
+	SourceAccountID      int64           `json:"source_account_id"`
+	DestinationAccountID int64           `json:"destination_account_id"`
+	Amount               decimal.Decimal `json:"amount"`
+	Status               string          `json:"status"`
+	TransactionID        *uuid.UUID      `json:"transaction_id,omitempty"` // Set once captured
+	ClientID             string          `json:"client_id,omitempty"`
+	IdempotencyKey       *string         `json:"idempotency_key,omitempty"` // Optional, unique per client
+	RequestHash          string          `json:"-"`
+	Replayed             bool            `json:"-"` // Set when returned for an idempotent replay; not persisted
+	ExpiresAt            time.Time       `json:"expires_at"`
+	CreatedAt            time.Time       `json:"created_at"`
+	UpdatedAt            time.Time       `json:"updated_at"`
+}
+
+type HoldRepository interface {
+	CreateHold(ctx context.Context, hold *Hold) error
+	GetHold(ctx context.Context, id uuid.UUID) (*Hold, error)                         // Nil when not found
+	GetHoldForUpdate(ctx context.Context, id uuid.UUID) (*Hold, error)                // Nil when not found
+	GetHoldByIdempotencyKey(ctx context.Context, clientID, key string) (*Hold, error) // Nil when the key is unused
+	UpdateHoldStatus(ctx context.Context, id uuid.UUID, status string, transactionID *uuid.UUID) error
+	LockExpiredHolds(ctx context.Context, now time.Time, limit int) ([]*Hold, error) // Skips holds locked by others
+	PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time, limit int) (int64, error)
+}
@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	LedgerEntryDebit  = "debit"
+	LedgerEntryCredit = "credit"
+)
+
+type LedgerEntry struct {
+	ID            uuid.UUID       `json:"id"`
+	TransactionID *uuid.UUID      `json:"transaction_id,omitempty"` // Nil for opening balances
+	AccountID     int64           `json:"account_id"`
+	EntryType     string          `json:"entry_type"`
+	Amount        decimal.Decimal `json:"amount"`
+	BalanceAfter  decimal.Decimal `json:"balance_after"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	LimitPerTransfer = "per_transfer" // Largest single transfer
+	LimitDaily       = "daily"        // Outgoing total per UTC calendar day
+	LimitMonthly     = "monthly"      // Outgoing total per UTC calendar month
+)
+
+// LimitTypes lists every limit type, in the order limits are checked
+var LimitTypes = []string{LimitPerTransfer, LimitDaily, LimitMonthly}
+
+const (
+	LimitScopeGlobal  = "global"
+	LimitScopeTier    = "tier"
+	LimitScopeAccount = "account"
+	LimitScopeDefault = "default" // Configured fallback; never stored
+)
+
+// TransferLimit caps what an account can send. Amounts are in the currency of the source account.
+// The most specific limit of each type applies: an account override, then the account's tier,
+// then the global limit, preferring limits restricted to the account's currency at each level.
+type TransferLimit struct {
+	ID        uuid.UUID       `json:"id"`
+	Scope     string          `json:"scope"`
+	Tier      *string         `json:"tier,omitempty"`       // Tier scope only
+	AccountID *int64          `json:"account_id,omitempty"` // Account scope only
+	LimitType string          `json:"limit_type"`
+	Currency  *string         `json:"currency,omitempty"` // Global and tier scopes; nil applies to every currency
+	Amount    decimal.Decimal `json:"amount"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Rank orders limits of one type from most to least specific
+func (l *TransferLimit) Rank() int {
+	switch l.Scope {
+	case LimitScopeAccount:
+		return 0
+	case LimitScopeTier:
+		if l.Currency != nil {
+			return 1
+		}
+		return 2
+	case LimitScopeGlobal:
+		if l.Currency != nil {
+			return 3
+		}
+		return 4
+	}
+	return 5
+}
+
+type LimitRepository interface {
+	UpsertLimit(ctx context.Context, limit *TransferLimit) error           // Replaces the limit of the same target, type and currency
+	DeleteLimit(ctx context.Context, id uuid.UUID) (*TransferLimit, error) // Returns the deleted limit; nil when not found
+	ListLimits(ctx context.Context) ([]*TransferLimit, error)
+	// GetApplicableLimits returns every limit that can apply to an account, whatever its rank
+	GetApplicableLimits(ctx context.Context, accountID int64, tier, currency string) ([]*TransferLimit, error)
+	// GetOutgoingTotal sums the transfers an account has sent since a time, at their full amount
+	// even once reversed; the reversals themselves are excluded
+	GetOutgoingTotal(ctx context.Context, accountID int64, since time.Time) (decimal.Decimal, error)
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	ScheduledStatusScheduled = "scheduled"
+	ScheduledStatusRunning   = "running" // Claimed by a scheduler worker
+	ScheduledStatusCompleted = "completed"
+	ScheduledStatusFailed    = "failed"
+	ScheduledStatusCancelled = "cancelled"
+)
+
+// ScheduledTransfer is a transfer that runs through the normal Transfer path once execute_at is reached
+type ScheduledTransfer struct {
+	ID                   uuid.UUID       `json:"id"`
+	SourceAccountID      int64           `json:"source_account_id"`
+	DestinationAccountID int64           `json:"destination_account_id"`
+	Amount               decimal.Decimal `json:"amount"`
+	Currency             string          `json:"currency"` // Currency of the source account, in which the transfer runs
+	ExecuteAt            time.Time       `json:"execute_at"`
+	Status               string          `json:"status"`
+	TransactionID        *uuid.UUID      `json:"transaction_id,omitempty"` // Set once executed
+	FailureReason        *string         `json:"failure_reason,omitempty"`
+	ClientID             string          `json:"client_id,omitempty"`
+	IdempotencyKey       *string         `json:"idempotency_key,omitempty"` // Optional, unique per client
+	InitiatedBy          *string         `json:"initiated_by,omitempty"`    // Principal that scheduled it, recorded on its transaction
+	RequestHash          string          `json:"-"`
+	Replayed             bool            `json:"-"` // Set when returned for an idempotent replay; not persisted
+	CreatedAt            time.Time       `json:"created_at"`
+	UpdatedAt            time.Time       `json:"updated_at"`
+}
+
+type ScheduledTransferRepository interface {
+	CreateScheduledTransfer(ctx context.Context, transfer *ScheduledTransfer) error
+	GetScheduledTransfer(ctx context.Context, id uuid.UUID) (*ScheduledTransfer, error)                         // Nil when not found
+	GetScheduledTransferByIdempotencyKey(ctx context.Context, clientID, key string) (*ScheduledTransfer, error) // Nil when the key is unused
+	CancelScheduledTransfer(ctx context.Context, id uuid.UUID) (bool, error)                                    // False unless it was still scheduled
+	// ClaimDueScheduledTransfers marks up to limit due transfers as running and returns them. Transfers
+	// left running since before staleBefore, e.g. by a crashed worker, are claimed again.
+	ClaimDueScheduledTransfers(ctx context.Context, now, staleBefore time.Time, limit int) ([]*ScheduledTransfer, error)
+	FinishScheduledTransfer(ctx context.Context, id uuid.UUID, status string, transactionID *uuid.UUID, failureReason *string) error
+	PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time, limit int) (int64, error)
+}
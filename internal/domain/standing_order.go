@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	StandingOrderStatusActive    = "active"
+	StandingOrderStatusPaused    = "paused"
+	StandingOrderStatusCompleted = "completed" // End date or maximum run count reached
+	StandingOrderStatusCancelled = "cancelled"
+)
+
+// StandingOrder is a recurring transfer. Exactly one of CronExpression and Interval is set.
+type StandingOrder struct {
+	ID                   uuid.UUID       `json:"id"`
+	SourceAccountID      int64           `json:"source_account_id"`
+	DestinationAccountID int64           `json:"destination_account_id"`
+	Amount               decimal.Decimal `json:"amount"`
+	CronExpression       string          `json:"cron_expression,omitempty"` // Five fields, evaluated in UTC
+	Interval             time.Duration   `json:"interval,omitempty"`        // Periods are StartAt plus multiples of Interval
+	StartAt              time.Time       `json:"start_at"`
+	EndAt                *time.Time      `json:"end_at,omitempty"`
+	MaxRuns              *int            `json:"max_runs,omitempty"`
+	RunCount             int             `json:"run_count"`
+	NextRunAt            *time.Time      `json:"next_run_at,omitempty"` // Nil once completed or cancelled
+	Status               string          `json:"status"`
+	ClientID             string          `json:"client_id,omitempty"`
+	IdempotencyKey       *string         `json:"idempotency_key,omitempty"` // Optional, unique per client
+	InitiatedBy          *string         `json:"initiated_by,omitempty"`    // Principal that created it, recorded on its transactions
+	RequestHash          string          `json:"-"`
+	Replayed             bool            `json:"-"` // Set when returned for an idempotent replay; not persisted
+	CreatedAt            time.Time       `json:"created_at"`
+	UpdatedAt            time.Time       `json:"updated_at"`
+}
+
+// StandingOrderRun records the outcome of one period of a standing order
+type StandingOrderRun struct {
+	ID              uuid.UUID  `json:"id"`
+	StandingOrderID uuid.UUID  `json:"standing_order_id"`
+	Period          time.Time  `json:"period"` // The scheduled time the run belongs to
+	Status          string     `json:"status"` // completed or failed
+	TransactionID   *uuid.UUID `json:"transaction_id,omitempty"`
+	FailureReason   *string    `json:"failure_reason,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type StandingOrderRepository interface {
+	CreateStandingOrder(ctx context.Context, order *StandingOrder) error
+	GetStandingOrder(ctx context.Context, id uuid.UUID) (*StandingOrder, error)                         // Nil when not found
+	GetStandingOrderForUpdate(ctx context.Context, id uuid.UUID) (*StandingOrder, error)                // Nil when not found
+	GetStandingOrderByIdempotencyKey(ctx context.Context, clientID, key string) (*StandingOrder, error) // Nil when the key is unused
+	ListStandingOrdersByAccount(ctx context.Context, accountID int64) ([]*StandingOrder, error)         // Orders paying out of the account
+	// UpdateStandingOrder saves the editable fields, status and next run. A scheduler lease is left in place.
+	UpdateStandingOrder(ctx context.Context, order *StandingOrder) error
+	// ClaimDueStandingOrders leases up to limit active orders whose next run is due and returns them.
+	// Orders whose lease has run out, e.g. after a worker crashed, are claimed again.
+	ClaimDueStandingOrders(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*StandingOrder, error)
+	// AdvanceStandingOrder stores the outcome of a run and releases the lease
+	AdvanceStandingOrder(ctx context.Context, order *StandingOrder) error
+	CreateStandingOrderRun(ctx context.Context, run *StandingOrderRun) error
+	ListStandingOrderRuns(ctx context.Context, orderID uuid.UUID) ([]*StandingOrderRun, error) // Oldest period first
+	PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time, limit int) (int64, error)
+}
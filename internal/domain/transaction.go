@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"context"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,19 +9,96 @@ import (
 )
 
 type Transaction struct {
-	ID                   uuid.UUID       `json:"id"`
-	SourceAccountID      int64           `json:"source_account_id"`
-	DestinationAccountID int64           `json:"destination_account_id"`
-	Amount               decimal.Decimal `json:"amount"`
-	IdempotencyKey       *uuid.UUID      `json:"idempotency_key,omitempty"` // Now optional
-	Status               string          `json:"status"`
-	CreatedAt            time.Time       `json:"created_at"`
-	UpdatedAt            time.Time       `json:"updated_at"`
+	ID                   uuid.UUID        `json:"id"`
+	SourceAccountID      int64            `json:"source_account_id"`
+	DestinationAccountID int64            `json:"destination_account_id"`
+	Amount               decimal.Decimal  `json:"amount"`
+	Currency             string           `json:"currency"`                       // ISO 4217 code of the source account, and of Amount
+	Fee                  decimal.Decimal  `json:"fee"`                            // Charged by a transfer, or refunded by a reversal
+	FeeAccountID         *int64           `json:"fee_account_id,omitempty"`       // Account the fee is paid into or refunded from
+	DestinationAmount    *decimal.Decimal `json:"destination_amount,omitempty"`   // Credited amount of an FX transfer, in DestinationCurrency
+	DestinationCurrency  *string          `json:"destination_currency,omitempty"` // Set on FX transfers only
+	FXRate               *decimal.Decimal `json:"fx_rate,omitempty"`              // Destination units per source unit
+	FXQuoteID            *uuid.UUID       `json:"fx_quote_id,omitempty"`          // Quote the rate was locked with, if any
+	IdempotencyKey       *string          `json:"idempotency_key,omitempty"`      // Optional, unique per client
+	ClientID             string           `json:"client_id,omitempty"`
+	InitiatedBy          *string          `json:"initiated_by,omitempty"` // Principal that created it; nil without authentication
+	Status               string           `json:"status"`
+	FailureReason        *string          `json:"failure_reason,omitempty"`
+	BatchID              *uuid.UUID       `json:"batch_id,omitempty"`
+	BatchItem            *int             `json:"batch_item,omitempty"`  // Position within the batch
+	ReversalOf           *uuid.UUID       `json:"reversal_of,omitempty"` // Set on reversals to the transaction they undo
+	ReversedAmount       decimal.Decimal  `json:"reversed_amount"`       // Total reversed so far
+	RequestHash          string           `json:"-"`                     // SHA-256 of the transfer payload; empty for legacy rows
+	Replayed             bool             `json:"-"`                     // Set when returned for an idempotent replay; not persisted
+	CreatedAt            time.Time        `json:"created_at"`
+	UpdatedAt            time.Time        `json:"updated_at"`
+}
+
+// NetAmount is the gross amount less the fee, in the currency the original transfer was sent in.
+// A transfer credits it, converted if need be, to the destination. A reversal takes it back from
+// the destination and the refunded fee from the fee account.
+func (t *Transaction) NetAmount() decimal.Decimal {
+	switch {
+	case t.ReversalOf == nil:
+		return t.Amount.Sub(t.Fee)
+	case t.DestinationAmount != nil:
+		return t.DestinationAmount.Sub(t.Fee)
+	default:
+		return t.Amount
+	}
+}
+
+// CreditedAmount is what the destination account receives: the converted amount of an FX
+// transfer, the net amount of a transfer otherwise, and the net amount plus the refunded fee
+// for a reversal
+func (t *Transaction) CreditedAmount() decimal.Decimal {
+	switch {
+	case t.DestinationAmount != nil:
+		return *t.DestinationAmount
+	case t.ReversalOf != nil:
+		return t.Amount.Add(t.Fee)
+	default:
+		return t.Amount.Sub(t.Fee)
+	}
+}
+
+// Statuses a completed transaction moves to as it is reversed
+const (
+	StatusReversed          = "reversed"
+	StatusPartiallyReversed = "partially_reversed"
+)
+
+const (
+	DirectionDebit  = "debit"
+	DirectionCredit = "credit"
+)
+
+// TransactionCursor identifies the last row of a page in (created_at, id) order
+type TransactionCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// TransactionFilter selects the transactions of one account, newest first
+type TransactionFilter struct {
+	AccountID int64
+	Direction string // DirectionDebit, DirectionCredit or empty for both
+	Status    string
+	From      *time.Time // Inclusive
+	To        *time.Time // Exclusive
+	After     *TransactionCursor
+	Limit     int
 }
 
 type TransactionRepository interface {
-	CreateTransaction(tx *Transaction) error
-	GetTransactionByID(id uuid.UUID) (*Transaction, error)
-	GetTransactionByIDempotencyKey(key uuid.UUID) (*Transaction, error) // Still used when key is provided
-	UpdateTransactionStatus(id uuid.UUID, status string) error
+	CreateTransaction(ctx context.Context, tx *Transaction) error
+	GetTransactionByID(ctx context.Context, id uuid.UUID) (*Transaction, error)
+	GetTransactionByIDempotencyKey(ctx context.Context, clientID, key string) (*Transaction, error) // Still used when key is provided
+	UpdateTransactionStatus(ctx context.Context, id uuid.UUID, status string) error
+	MarkTransactionFailed(ctx context.Context, id uuid.UUID, reason string) error
+	GetTransactionForUpdate(ctx context.Context, id uuid.UUID) (*Transaction, error) // Nil when not found
+	RecordReversal(ctx context.Context, id uuid.UUID, amount decimal.Decimal, status string) error
+	ListTransactions(ctx context.Context, filter TransactionFilter) ([]*Transaction, error)
+	PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time, limit int) (int64, error)
 }
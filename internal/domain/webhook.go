@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	WebhookDeliveryPending    = "pending"
+	WebhookDeliverySucceeded  = "succeeded"
+	WebhookDeliveryDeadLetter = "dead_letter" // Ran out of attempts; only a redelivery sends it again
+)
+
+// WebhookDeliveryStatuses lists every delivery status
+var WebhookDeliveryStatuses = []string{WebhookDeliveryPending, WebhookDeliverySucceeded, WebhookDeliveryDeadLetter}
+
+// WebhookSubscription registers a URL for events of the listed types. Without an account it
+// receives the events of every account, otherwise only the events concerning that account.
+type WebhookSubscription struct {
+	ID         uuid.UUID `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"-"` // Signs deliveries; shown only when the subscription is created
+	EventTypes []string  `json:"event_types"`
+	AccountID  *int64    `json:"account_id,omitempty"`
+	Active     bool      `json:"active"` // Deliveries of inactive subscriptions wait until it is reactivated
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is one event to be POSTed to one subscription
+type WebhookDelivery struct {
+	ID             uuid.UUID         `json:"id"`
+	SubscriptionID uuid.UUID         `json:"subscription_id"`
+	EventID        uuid.UUID         `json:"event_id"`
+	EventType      string            `json:"event_type"`
+	Payload        json.RawMessage   `json:"payload"` // The event, as sent
+	Status         string            `json:"status"`
+	Attempts       int               `json:"attempts"` // Attempts since the delivery was last queued
+	NextAttemptAt  time.Time         `json:"next_attempt_at"`
+	LastStatusCode *int              `json:"last_status_code,omitempty"`
+	LastError      *string           `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time        `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	AttemptLog     []*WebhookAttempt `json:"attempt_log,omitempty"` // Oldest first; loaded on request
+}
+
+// WebhookAttempt records one attempt to send a delivery
+type WebhookAttempt struct {
+	ID          int64         `json:"id"`
+	DeliveryID  uuid.UUID     `json:"delivery_id"`
+	StatusCode  *int          `json:"status_code,omitempty"` // Nil when no response was received
+	Error       *string       `json:"error,omitempty"`       // Nil on success
+	Duration    time.Duration `json:"duration"`
+	AttemptedAt time.Time     `json:"attempted_at"`
+}
+
+// WebhookSender POSTs a delivery to a subscription, signed with its secret. It returns the
+// response status, zero when none was received, and an error unless the status is 2xx.
+type WebhookSender interface {
+	Send(ctx context.Context, subscription *WebhookSubscription, delivery *WebhookDelivery) (int, error)
+}
+
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, subscription *WebhookSubscription) error
+	GetSubscription(ctx context.Context, id uuid.UUID) (*WebhookSubscription, error)          // Nil when not found
+	GetSubscriptionForUpdate(ctx context.Context, id uuid.UUID) (*WebhookSubscription, error) // Nil when not found
+	ListSubscriptions(ctx context.Context, accountID *int64) ([]*WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, subscription *WebhookSubscription) error    // Saves the URL, event types and active flag
+	DeleteSubscription(ctx context.Context, id uuid.UUID) (*WebhookSubscription, error) // Returns the deleted subscription; nil when not found
+	// ListMatchingSubscriptions returns the subscriptions, active or not, that receive an event
+	// of the type concerning any of the accounts
+	ListMatchingSubscriptions(ctx context.Context, eventType string, accountIDs []int64) ([]*WebhookSubscription, error)
+
+	CreateDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	GetDelivery(ctx context.Context, subscriptionID, id uuid.UUID) (*WebhookDelivery, error) // Nil when not found
+	// ListDeliveries returns a subscription's deliveries newest first, optionally of one status
+	ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, status string, limit int) ([]*WebhookDelivery, error)
+	// ListAttempts returns the attempts of the deliveries, oldest first
+	ListAttempts(ctx context.Context, deliveryIDs []uuid.UUID) (map[uuid.UUID][]*WebhookAttempt, error)
+	// ClaimDueDeliveries leases up to limit due pending deliveries of active subscriptions by moving
+	// their next attempt to leaseUntil, and returns them. A delivery whose sender crashed is
+	// claimed again once the lease runs out.
+	ClaimDueDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*WebhookDelivery, error)
+	// RecordAttempt logs an attempt and saves the delivery's status, attempts, next attempt and
+	// outcome. It fails with ErrWebhookDeliveryNotFound once the delivery has been deleted.
+	RecordAttempt(ctx context.Context, delivery *WebhookDelivery, attempt *WebhookAttempt) error
+	// RequeueDelivery makes a delivery pending and due now with a fresh set of attempts
+	RequeueDelivery(ctx context.Context, delivery *WebhookDelivery) error
+}
@@ -8,27 +8,63 @@ import (
 type ErrorCode string
 
 const (
-	InvalidInput           ErrorCode = "invalid_input"
-	AccountNotFound        ErrorCode = "account_not_found"
-	InsufficientBalance    ErrorCode = "insufficient_balance"
-	DuplicateAccount       ErrorCode = "duplicate_account"
-	DuplicateTransaction   ErrorCode = "duplicate_transaction"
-	InvalidAmount          ErrorCode = "invalid_amount"
-	SameAccountTransfer    ErrorCode = "same_account_transfer"
-	InternalError          ErrorCode = "internal_error"
-	CannotBeginTransaction ErrorCode = "cannot_begin_transaction"
+	InvalidInput            ErrorCode = "invalid_input"
+	AccountNotFound         ErrorCode = "account_not_found"
+	TransactionNotFound     ErrorCode = "transaction_not_found"
+	HoldNotFound            ErrorCode = "hold_not_found"
+	ScheduledNotFound       ErrorCode = "scheduled_transfer_not_found"
+	NotCancellable          ErrorCode = "scheduled_transfer_not_cancellable"
+	StandingOrderNotFound   ErrorCode = "standing_order_not_found"
+	StandingOrderFinished   ErrorCode = "standing_order_finished"
+	HoldNotActive           ErrorCode = "hold_not_active"
+	CaptureExceedsHold      ErrorCode = "capture_exceeds_hold"
+	InsufficientBalance     ErrorCode = "insufficient_balance"
+	DuplicateAccount        ErrorCode = "duplicate_account"
+	DuplicateTransaction    ErrorCode = "duplicate_transaction"
+	IdempotencyKeyReused    ErrorCode = "idempotency_key_reused"
+	InvalidAmount           ErrorCode = "invalid_amount"
+	SameAccountTransfer     ErrorCode = "same_account_transfer"
+	CurrencyMismatch        ErrorCode = "currency_mismatch"
+	AccountFrozen           ErrorCode = "account_frozen"
+	AccountClosed           ErrorCode = "account_closed"
+	AccountNotEmpty         ErrorCode = "account_not_empty"
+	InvalidAccountStatus    ErrorCode = "invalid_account_status"
+	LimitExceeded           ErrorCode = "limit_exceeded"
+	LimitNotFound           ErrorCode = "limit_not_found"
+	FeeScheduleNotFound     ErrorCode = "fee_schedule_not_found"
+	WebhookNotFound         ErrorCode = "webhook_not_found"
+	WebhookDeliveryNotFound ErrorCode = "webhook_delivery_not_found"
+	APIKeyNotFound          ErrorCode = "api_key_not_found"
+	Unauthorized            ErrorCode = "unauthorized"
+	Forbidden               ErrorCode = "forbidden"
+	RateLimited             ErrorCode = "rate_limited"
+	FXRateUnavailable       ErrorCode = "fx_rate_unavailable"
+	FXProviderUnavailable   ErrorCode = "fx_provider_unavailable"
+	FXQuoteNotFound         ErrorCode = "fx_quote_not_found"
+	FXQuoteExpired          ErrorCode = "fx_quote_expired"
+	FXQuoteUsed             ErrorCode = "fx_quote_used"
+	NotReversible           ErrorCode = "transaction_not_reversible"
+	ReversalExceedsAmount   ErrorCode = "reversal_exceeds_amount"
+	InternalError           ErrorCode = "internal_error"
+	RequestTimeout          ErrorCode = "request_timeout"
+	CannotBeginTransaction  ErrorCode = "cannot_begin_transaction"
 )
 
 type AppError struct {
 	Code    ErrorCode `json:"code"`
 	Message string    `json:"message"`
 	Details string    `json:"details,omitempty"`
+	Cause   error     `json:"-"` // Underlying error, e.g. the *pq.Error from the driver
 }
 
 func (e AppError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Code, e.Message)
 }
 
+func (e *AppError) Unwrap() error {
+	return e.Cause
+}
+
 func NewAppError(code ErrorCode, message string) *AppError {
 	return &AppError{
 		Code:    code,
@@ -43,6 +79,16 @@ func NewAppErrorf(code ErrorCode, format string, args ...interface{}) *AppError
 	}
 }
 
+// Wrap builds an AppError around an underlying error, keeping it available to errors.As
+func Wrap(err error, code ErrorCode, message string) *AppError {
+	return &AppError{
+		Code:    code,
+		Message: message,
+		Details: err.Error(),
+		Cause:   err,
+	}
+}
+
 func (e *AppError) WithDetails(details string) *AppError {
 	e.Details = details
 	return e
@@ -53,12 +99,26 @@ func (e *AppError) HTTPStatus() int {
 	switch e.Code {
 	case InvalidInput, InvalidAmount, SameAccountTransfer:
 		return http.StatusBadRequest
-	case AccountNotFound:
+	case AccountNotFound, TransactionNotFound, HoldNotFound, ScheduledNotFound, StandingOrderNotFound, FXQuoteNotFound,
+		LimitNotFound, FeeScheduleNotFound, WebhookNotFound, WebhookDeliveryNotFound, APIKeyNotFound:
 		return http.StatusNotFound
-	case InsufficientBalance:
+	case Unauthorized:
+		return http.StatusUnauthorized
+	case Forbidden:
+		return http.StatusForbidden
+	case RateLimited:
+		return http.StatusTooManyRequests
+	case InsufficientBalance, IdempotencyKeyReused, NotReversible, ReversalExceedsAmount,
+		HoldNotActive, CaptureExceedsHold, NotCancellable, StandingOrderFinished, CurrencyMismatch,
+		FXRateUnavailable, FXQuoteExpired, FXQuoteUsed,
+		AccountFrozen, AccountClosed, AccountNotEmpty, InvalidAccountStatus, LimitExceeded:
 		return http.StatusUnprocessableEntity
+	case FXProviderUnavailable:
+		return http.StatusBadGateway
 	case DuplicateAccount, DuplicateTransaction:
 		return http.StatusConflict
+	case RequestTimeout:
+		return http.StatusGatewayTimeout
 	default:
 		return http.StatusInternalServerError
 	}
@@ -66,12 +126,49 @@ func (e *AppError) HTTPStatus() int {
 
 // Predefined errors for common cases
 var (
-	ErrInvalidAccountID       = NewAppError(InvalidInput, "invalid account ID")
-	ErrAccountNotFound        = NewAppError(AccountNotFound, "account not found")
-	ErrInsufficientBalance    = NewAppError(InsufficientBalance, "insufficient balance")
-	ErrDuplicateAccount       = NewAppError(DuplicateAccount, "account already exists")
-	ErrDuplicateTransaction   = NewAppError(DuplicateTransaction, "transaction already processed")
-	ErrInvalidAmount          = NewAppError(InvalidAmount, "invalid amount")
-	ErrSameAccountTransfer    = NewAppError(SameAccountTransfer, "source and destination accounts cannot be the same")
-	ErrCannotBeginTransaction = NewAppError(CannotBeginTransaction, "cannot begin transaction on non-db executor")
+	ErrInvalidAccountID         = NewAppError(InvalidInput, "invalid account ID")
+	ErrAccountNotFound          = NewAppError(AccountNotFound, "account not found")
+	ErrTransactionNotFound      = NewAppError(TransactionNotFound, "transaction not found")
+	ErrInvalidTransactionID     = NewAppError(InvalidInput, "invalid transaction ID")
+	ErrHoldNotFound             = NewAppError(HoldNotFound, "hold not found")
+	ErrInvalidHoldID            = NewAppError(InvalidInput, "invalid hold ID")
+	ErrHoldNotActive            = NewAppError(HoldNotActive, "hold is no longer active")
+	ErrCaptureExceedsHold       = NewAppError(CaptureExceedsHold, "capture amount exceeds the held amount")
+	ErrScheduledNotFound        = NewAppError(ScheduledNotFound, "scheduled transfer not found")
+	ErrInvalidScheduledID       = NewAppError(InvalidInput, "invalid scheduled transfer ID")
+	ErrNotCancellable           = NewAppError(NotCancellable, "only transfers that have not started running can be cancelled")
+	ErrStandingOrderNotFound    = NewAppError(StandingOrderNotFound, "standing order not found")
+	ErrInvalidStandingOrderID   = NewAppError(InvalidInput, "invalid standing order ID")
+	ErrStandingOrderFinished    = NewAppError(StandingOrderFinished, "standing order has already completed or been cancelled")
+	ErrInsufficientBalance      = NewAppError(InsufficientBalance, "insufficient balance")
+	ErrDuplicateAccount         = NewAppError(DuplicateAccount, "account already exists")
+	ErrDuplicateTransaction     = NewAppError(DuplicateTransaction, "transaction already processed")
+	ErrIdempotencyKeyReused     = NewAppError(IdempotencyKeyReused, "idempotency key was already used with a different request")
+	ErrInvalidAmount            = NewAppError(InvalidAmount, "invalid amount")
+	ErrSameAccountTransfer      = NewAppError(SameAccountTransfer, "source and destination accounts cannot be the same")
+	ErrCurrencyMismatch         = NewAppError(CurrencyMismatch, "source and destination accounts use different currencies")
+	ErrUnsupportedCurrency      = NewAppError(InvalidInput, "unsupported currency")
+	ErrAccountFrozen            = NewAppError(AccountFrozen, "account is frozen and cannot send funds")
+	ErrAccountClosed            = NewAppError(AccountClosed, "account is closed")
+	ErrAccountNotEmpty          = NewAppError(AccountNotEmpty, "account can only be closed at a zero balance or with a sweep account")
+	ErrLimitNotFound            = NewAppError(LimitNotFound, "transfer limit not found")
+	ErrInvalidLimitID           = NewAppError(InvalidInput, "invalid transfer limit ID")
+	ErrFeeScheduleNotFound      = NewAppError(FeeScheduleNotFound, "fee schedule not found")
+	ErrInvalidFeeScheduleID     = NewAppError(InvalidInput, "invalid fee schedule ID")
+	ErrWebhookNotFound          = NewAppError(WebhookNotFound, "webhook not found")
+	ErrInvalidWebhookID         = NewAppError(InvalidInput, "invalid webhook ID")
+	ErrWebhookDeliveryNotFound  = NewAppError(WebhookDeliveryNotFound, "webhook delivery not found")
+	ErrInvalidWebhookDeliveryID = NewAppError(InvalidInput, "invalid webhook delivery ID")
+	ErrAPIKeyNotFound           = NewAppError(APIKeyNotFound, "api key not found")
+	ErrInvalidAPIKeyID          = NewAppError(InvalidInput, "invalid api key ID")
+	ErrUnauthorized             = NewAppError(Unauthorized, "missing or invalid credentials")
+	ErrFXRateUnavailable        = NewAppError(FXRateUnavailable, "no exchange rate is available for the currency pair")
+	ErrFXQuoteNotFound          = NewAppError(FXQuoteNotFound, "fx quote not found")
+	ErrInvalidFXQuoteID         = NewAppError(InvalidInput, "invalid fx quote ID")
+	ErrFXQuoteExpired           = NewAppError(FXQuoteExpired, "fx quote has expired")
+	ErrFXQuoteUsed              = NewAppError(FXQuoteUsed, "fx quote has already been used")
+	ErrNotReversible            = NewAppError(NotReversible, "only completed transfers can be reversed")
+	ErrReversalExceedsAmount    = NewAppError(ReversalExceedsAmount, "reversal exceeds the amount left to reverse")
+	ErrCannotBeginTransaction   = NewAppError(CannotBeginTransaction, "cannot begin transaction on non-db executor")
+	ErrRequestTimeout           = NewAppError(RequestTimeout, "request timed out")
 )
@@ -0,0 +1,58 @@
+// Package events provides the publishers behind domain.Publisher and the signed sender behind
+// domain.WebhookSender
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"internal-transfers/internal/domain"
+)
+
+// JSONLPublisher writes each event as one line of JSON, for log shippers and local runs
+type JSONLPublisher struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer // Set when the publisher opened its own file
+}
+
+// NewJSONLPublisher writes events to w, such as os.Stdout
+func NewJSONLPublisher(w io.Writer) *JSONLPublisher {
+	return &JSONLPublisher{w: w}
+}
+
+// OpenJSONLFile appends events to the file at path, creating it when needed
+func OpenJSONLFile(path string) (*JSONLPublisher, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open event file: %w", err)
+	}
+	return &JSONLPublisher{w: f, closer: f}, nil
+}
+
+func (p *JSONLPublisher) Publish(_ context.Context, event *domain.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode event %s: %w", event.ID, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := p.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write event %s: %w", event.ID, err)
+	}
+	return nil
+}
+
+// Close closes the file opened by OpenJSONLFile; it is a no-op for other writers
+func (p *JSONLPublisher) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	return p.closer.Close()
+}
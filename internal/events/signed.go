@@ -0,0 +1,115 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"internal-transfers/internal/domain"
+)
+
+const (
+	// SignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256>" on subscription deliveries
+	SignatureHeader = "X-Webhook-Signature"
+	// DeliveryIDHeader identifies a delivery; it is the same on every attempt
+	DeliveryIDHeader = "X-Webhook-Delivery-ID"
+)
+
+// maxErrorBody bounds how much of a failed response is kept in the delivery log
+const maxErrorBody = 512
+
+// Sign computes the signature header of a body sent at a time. The HMAC-SHA256 with the
+// subscription's secret covers "<unix seconds>.<body>", so a captured delivery cannot be replayed
+// with a new timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks a signature header against a body, rejecting signatures made more than
+// tolerance away from now. Receivers written in Go can use it as is.
+func VerifySignature(secret, header string, body []byte, tolerance time.Duration) error {
+	var timestamp int64
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			t, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid signature timestamp: %w", err)
+			}
+			timestamp = t
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == 0 || len(signatures) == 0 {
+		return fmt.Errorf("malformed signature header")
+	}
+
+	signedAt := time.Unix(timestamp, 0)
+	if age := time.Since(signedAt); age > tolerance || age < -tolerance {
+		return fmt.Errorf("signature timestamp outside tolerance")
+	}
+
+	_, expected, _ := strings.Cut(Sign(secret, signedAt, body), ",v1=")
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature mismatch")
+}
+
+// SignedWebhookSender POSTs subscription deliveries, signing each attempt with the subscription's
+// secret at the time it is sent
+type SignedWebhookSender struct {
+	client *http.Client
+}
+
+func NewSignedWebhookSender(timeout time.Duration) *SignedWebhookSender {
+	return &SignedWebhookSender{
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *SignedWebhookSender) Send(ctx context.Context, subscription *domain.WebhookSubscription, delivery *domain.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", delivery.EventID.String())
+	req.Header.Set("X-Event-Type", delivery.EventType)
+	req.Header.Set(DeliveryIDHeader, delivery.ID.String())
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, time.Now(), delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if text := strings.TrimSpace(string(body)); text != "" {
+			return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, text)
+		}
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,96 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+)
+
+// rateResponse is the body of GET {base}/rates?from=EUR&to=USD
+type rateResponse struct {
+	From string          `json:"from"`
+	To   string          `json:"to"`
+	Rate decimal.Decimal `json:"rate"`
+}
+
+// HTTPRateProvider fetches rates from a rate service over HTTP. The service answers
+// GET {base}/rates?from=EUR&to=USD with {"from": "EUR", "to": "USD", "rate": "1.0850"},
+// and with 404 when it has no rate for the pair.
+type HTTPRateProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewHTTPRateProvider(baseURL string, timeout time.Duration) *HTTPRateProvider {
+	return &HTTPRateProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *HTTPRateProvider) Rate(ctx context.Context, from, to string) (decimal.Decimal, error) {
+	query := url.Values{"from": {from}, "to": {to}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/rates?"+query.Encode(), nil)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return decimal.Decimal{}, fmt.Errorf("fetch rate %s/%s: %w", from, to, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return decimal.Decimal{}, domain.ErrRateNotFound
+	default:
+		return decimal.Decimal{}, fmt.Errorf("fetch rate %s/%s: unexpected status %d", from, to, resp.StatusCode)
+	}
+
+	var body rateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return decimal.Decimal{}, fmt.Errorf("decode rate %s/%s: %w", from, to, err)
+	}
+	if !body.Rate.IsPositive() {
+		return decimal.Decimal{}, fmt.Errorf("rate %s/%s must be positive, got %s", from, to, body.Rate)
+	}
+
+	return body.Rate, nil
+}
+
+// StubHandler serves the rate service protocol from another provider. It stands in for a real
+// rate service in local runs and tests, e.g. httptest.NewServer(fx.StubHandler(static)).
+func StubHandler(rates domain.RateProvider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/rates" {
+			http.NotFound(w, r)
+			return
+		}
+
+		from := strings.ToUpper(r.URL.Query().Get("from"))
+		to := strings.ToUpper(r.URL.Query().Get("to"))
+
+		rate, err := rates.Rate(r.Context(), from, to)
+		if err == domain.ErrRateNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rateResponse{From: from, To: to, Rate: rate})
+	})
+}
@@ -0,0 +1,67 @@
+// Package fx provides the exchange rate sources behind domain.RateProvider
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+)
+
+// inverseRatePrecision is the number of decimal places kept when a rate is derived from its inverse
+const inverseRatePrecision = 12
+
+// StaticRateProvider serves a fixed table of rates keyed by "FROM/TO", such as "EUR/USD".
+// A pair missing from the table is answered with the inverse of the opposite pair when present.
+type StaticRateProvider struct {
+	rates map[string]decimal.Decimal
+}
+
+// NewStaticRateProvider builds a provider from a rate table. Pairs are upper-cased and every rate
+// must be positive.
+func NewStaticRateProvider(rates map[string]decimal.Decimal) (*StaticRateProvider, error) {
+	table := make(map[string]decimal.Decimal, len(rates))
+	for pair, rate := range rates {
+		from, to, ok := strings.Cut(strings.ToUpper(pair), "/")
+		if !ok || len(from) != 3 || len(to) != 3 {
+			return nil, fmt.Errorf("invalid currency pair %q, expected FROM/TO", pair)
+		}
+		if !rate.IsPositive() {
+			return nil, fmt.Errorf("rate for %s must be positive", pair)
+		}
+		table[from+"/"+to] = rate
+	}
+
+	return &StaticRateProvider{rates: table}, nil
+}
+
+// LoadRateFile reads a JSON object of "FROM/TO" pairs to decimal strings, for example
+// {"EUR/USD": "1.0850", "USD/JPY": "151.20"}
+func LoadRateFile(path string) (*StaticRateProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read rate file: %w", err)
+	}
+
+	var rates map[string]decimal.Decimal
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("parse rate file %s: %w", path, err)
+	}
+
+	return NewStaticRateProvider(rates)
+}
+
+func (p *StaticRateProvider) Rate(_ context.Context, from, to string) (decimal.Decimal, error) {
+	if rate, ok := p.rates[from+"/"+to]; ok {
+		return rate, nil
+	}
+	if inverse, ok := p.rates[to+"/"+from]; ok {
+		return decimal.NewFromInt(1).DivRound(inverse, inverseRatePrecision), nil
+	}
+	return decimal.Decimal{}, domain.ErrRateNotFound
+}
@@ -3,7 +3,9 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
+	"internal-transfers/internal/domain"
 	"internal-transfers/internal/errors"
 	"internal-transfers/internal/service"
 
@@ -24,11 +26,33 @@ func NewAccountHandler(accountService *service.AccountService) *AccountHandler {
 type CreateAccountRequest struct {
 	AccountID      int64  `json:"account_id"`
 	InitialBalance string `json:"initial_balance"`
+	Currency       string `json:"currency,omitempty"` // ISO 4217; defaults to USD
+	Tier           string `json:"tier,omitempty"`     // Limit tier; defaults to standard
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 type AccountResponse struct {
-	AccountID int64  `json:"account_id"`
-	Balance   string `json:"balance"`
+	AccountID        int64  `json:"account_id"`
+	Balance          string `json:"balance"`
+	AvailableBalance string `json:"available_balance"` // Balance minus funds reserved by holds
+	Currency         string `json:"currency"`
+	Status           string `json:"status"` // active, frozen or closed
+	Tier             string `json:"tier"`
+	OverdraftLimit   string `json:"overdraft_limit"`  // How far below zero the balance may go
+	AvailableCredit  string `json:"available_credit"` // Part of the overdraft limit not yet drawn on
+}
+
+func newAccountResponse(account *domain.Account) AccountResponse {
+	return AccountResponse{
+		AccountID:        account.ID,
+		Balance:          account.Balance.String(),
+		AvailableBalance: account.AvailableBalance().String(),
+		Currency:         account.Currency,
+		Status:           account.Status,
+		Tier:             account.Tier,
+		OverdraftLimit:   account.OverdraftLimit.String(),
+		AvailableCredit:  account.AvailableCredit().String(),
+	}
 }
 
 func (h *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
@@ -44,19 +68,29 @@ func (h *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	account, err := h.accountService.CreateAccount(req.AccountID, initialBalance)
+	key, appErr := idempotencyKey(r, req.IdempotencyKey)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	account, err := h.accountService.CreateAccount(r.Context(), &service.CreateAccountRequest{
+		AccountID:      req.AccountID,
+		InitialBalance: initialBalance,
+		Currency:       req.Currency,
+		Tier:           req.Tier,
+		IdempotencyKey: key,
+		ClientID:       clientID(r),
+	})
 	if err != nil {
-		if appErr, ok := err.(*errors.AppError); ok {
-			writeError(w, appErr)
-		} else {
-			writeError(w, errors.NewAppError(errors.InternalError, "an unexpected error occurred"))
-		}
+		writeServiceError(w, r, err)
 		return
 	}
 
-	response := AccountResponse{
-		AccountID: account.ID,
-		Balance:   account.Balance.String(),
+	response := newAccountResponse(account)
+
+	if account.Replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
 	}
 
 	writeJSON(w, http.StatusCreated, response)
@@ -66,19 +100,115 @@ func (h *AccountHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	accountID := vars["account_id"]
 
-	account, err := h.accountService.GetAccount(accountID)
+	account, err := h.accountService.GetAccount(r.Context(), accountID)
 	if err != nil {
-		if appErr, ok := err.(*errors.AppError); ok {
-			writeError(w, appErr)
-		} else {
-			writeError(w, errors.NewAppError(errors.InternalError, "an unexpected error occurred"))
-		}
+		writeServiceError(w, r, err)
+		return
+	}
+
+	response := newAccountResponse(account)
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+type UpdateTierRequest struct {
+	Tier string `json:"tier"`
+}
+
+// UpdateTier serves PUT /accounts/{account_id}/tier
+func (h *AccountHandler) UpdateTier(w http.ResponseWriter, r *http.Request) {
+	var req UpdateTierRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.NewAppError(errors.InvalidInput, "invalid request body"))
+		return
+	}
+
+	account, err := h.accountService.UpdateAccountTier(r.Context(), mux.Vars(r)["account_id"], req.Tier)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	response := newAccountResponse(account)
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+type UpdateOverdraftRequest struct {
+	OverdraftLimit string `json:"overdraft_limit"`
+}
+
+// UpdateOverdraft serves PUT /accounts/{account_id}/overdraft
+func (h *AccountHandler) UpdateOverdraft(w http.ResponseWriter, r *http.Request) {
+	var req UpdateOverdraftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.NewAppError(errors.InvalidInput, "invalid request body"))
+		return
+	}
+
+	limit, err := decimal.NewFromString(req.OverdraftLimit)
+	if err != nil {
+		writeError(w, errors.NewAppError(errors.InvalidAmount, "invalid overdraft_limit format"))
+		return
+	}
+
+	account, err := h.accountService.UpdateOverdraftLimit(r.Context(), mux.Vars(r)["account_id"], limit)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAccountResponse(account))
+}
+
+type LedgerEntryResponse struct {
+	EntryID       string  `json:"entry_id"`
+	TransactionID *string `json:"transaction_id,omitempty"`
+	EntryType     string  `json:"entry_type"`
+	Amount        string  `json:"amount"`
+	BalanceAfter  string  `json:"balance_after"`
+	CreatedAt     string  `json:"created_at"`
+}
+
+type LedgerResponse struct {
+	AccountID     int64                 `json:"account_id"`
+	Balance       string                `json:"balance"`
+	LedgerBalance string                `json:"ledger_balance"`
+	Balanced      bool                  `json:"balanced"`
+	Entries       []LedgerEntryResponse `json:"entries"`
+}
+
+func (h *AccountHandler) GetLedger(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	accountID := vars["account_id"]
+
+	audit, err := h.accountService.AuditAccount(r.Context(), accountID)
+	if err != nil {
+		writeServiceError(w, r, err)
 		return
 	}
 
-	response := AccountResponse{
-		AccountID: account.ID,
-		Balance:   account.Balance.String(),
+	response := LedgerResponse{
+		AccountID:     audit.Account.ID,
+		Balance:       audit.Account.Balance.String(),
+		LedgerBalance: audit.LedgerBalance.String(),
+		Balanced:      audit.Balanced(),
+		Entries:       make([]LedgerEntryResponse, 0, len(audit.Entries)),
+	}
+
+	for _, entry := range audit.Entries {
+		entryResponse := LedgerEntryResponse{
+			EntryID:      entry.ID.String(),
+			EntryType:    entry.EntryType,
+			Amount:       entry.Amount.String(),
+			BalanceAfter: entry.BalanceAfter.String(),
+			CreatedAt:    entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+		}
+		if entry.TransactionID != nil {
+			txID := entry.TransactionID.String()
+			entryResponse.TransactionID = &txID
+		}
+		response.Entries = append(response.Entries, entryResponse)
 	}
 
 	writeJSON(w, http.StatusOK, response)
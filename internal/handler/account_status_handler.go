@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+type ChangeAccountStatusRequest struct {
+	Reason           string `json:"reason"`
+	SweepToAccountID string `json:"sweep_to_account_id,omitempty"` // Close only
+}
+
+type StatusChangeResponse struct {
+	ChangeID           string  `json:"change_id"`
+	AccountID          int64   `json:"account_id"`
+	FromStatus         string  `json:"from_status"`
+	ToStatus           string  `json:"to_status"`
+	Reason             string  `json:"reason"`
+	SweepTransactionID *string `json:"sweep_transaction_id,omitempty"`
+	CreatedAt          string  `json:"created_at"`
+}
+
+type AccountStatusResponse struct {
+	AccountResponse
+	Change StatusChangeResponse `json:"change"`
+}
+
+type StatusHistoryResponse struct {
+	AccountID int64                  `json:"account_id"`
+	Changes   []StatusChangeResponse `json:"changes"`
+}
+
+func newStatusChangeResponse(change *domain.AccountStatusChange) StatusChangeResponse {
+	response := StatusChangeResponse{
+		ChangeID:   change.ID.String(),
+		AccountID:  change.AccountID,
+		FromStatus: change.FromStatus,
+		ToStatus:   change.ToStatus,
+		Reason:     change.Reason,
+		CreatedAt:  change.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	if change.SweepTransactionID != nil {
+		sweepID := change.SweepTransactionID.String()
+		response.SweepTransactionID = &sweepID
+	}
+
+	return response
+}
+
+type changeStatusFunc func(ctx context.Context, req *service.ChangeAccountStatusRequest) (*service.AccountStatusResult, error)
+
+// FreezeAccount serves POST /accounts/{account_id}/freeze
+func (h *AccountHandler) FreezeAccount(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, h.accountService.FreezeAccount)
+}
+
+// UnfreezeAccount serves POST /accounts/{account_id}/unfreeze
+func (h *AccountHandler) UnfreezeAccount(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, h.accountService.UnfreezeAccount)
+}
+
+// CloseAccount serves POST /accounts/{account_id}/close
+func (h *AccountHandler) CloseAccount(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, h.accountService.CloseAccount)
+}
+
+func (h *AccountHandler) changeStatus(w http.ResponseWriter, r *http.Request, change changeStatusFunc) {
+	var req ChangeAccountStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.NewAppError(errors.InvalidInput, "invalid request body").WithDetails(err.Error()))
+		return
+	}
+
+	result, err := change(r.Context(), &service.ChangeAccountStatusRequest{
+		AccountID:      mux.Vars(r)["account_id"],
+		Reason:         req.Reason,
+		SweepAccountID: req.SweepToAccountID,
+		ClientID:       clientID(r),
+	})
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, AccountStatusResponse{
+		AccountResponse: newAccountResponse(result.Account),
+		Change:          newStatusChangeResponse(result.Change),
+	})
+}
+
+// GetStatusHistory serves GET /accounts/{account_id}/status-history, oldest change first
+func (h *AccountHandler) GetStatusHistory(w http.ResponseWriter, r *http.Request) {
+	accountID := mux.Vars(r)["account_id"]
+
+	changes, err := h.accountService.ListStatusChanges(r.Context(), accountID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	// The service has validated the ID by now
+	id, _ := strconv.ParseInt(accountID, 10, 64)
+	response := StatusHistoryResponse{
+		AccountID: id,
+		Changes:   make([]StatusChangeResponse, 0, len(changes)),
+	}
+	for _, change := range changes {
+		response.Changes = append(response.Changes, newStatusChangeResponse(change))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+type APIKeyHandler struct {
+	authService *service.AuthService
+}
+
+func NewAPIKeyHandler(authService *service.AuthService) *APIKeyHandler {
+	return &APIKeyHandler{
+		authService: authService,
+	}
+}
+
+type CreateAPIKeyRequest struct {
+	Name            string   `json:"name"`
+	Scopes          []string `json:"scopes"`
+	DebitAccountIDs []int64  `json:"debit_account_ids,omitempty"` // Omitted to allow debiting any account
+}
+
+type APIKeyResponse struct {
+	APIKeyID        string   `json:"api_key_id"`
+	Name            string   `json:"name"`
+	Key             string   `json:"key,omitempty"` // Only returned when the key is created
+	Prefix          string   `json:"prefix"`
+	Scopes          []string `json:"scopes"`
+	DebitAccountIDs []int64  `json:"debit_account_ids,omitempty"`
+	RevokedAt       *string  `json:"revoked_at,omitempty"`
+	CreatedAt       string   `json:"created_at"`
+	UpdatedAt       string   `json:"updated_at"`
+}
+
+type APIKeyListResponse struct {
+	APIKeys []APIKeyResponse `json:"api_keys"`
+}
+
+func newAPIKeyResponse(key *domain.APIKey) APIKeyResponse {
+	response := APIKeyResponse{
+		APIKeyID:        key.ID.String(),
+		Name:            key.Name,
+		Prefix:          key.Prefix,
+		Scopes:          key.Scopes,
+		DebitAccountIDs: key.DebitAccountIDs,
+		CreatedAt:       key.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt:       key.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	if key.RevokedAt != nil {
+		revokedAt := key.RevokedAt.UTC().Format(time.RFC3339Nano)
+		response.RevokedAt = &revokedAt
+	}
+
+	return response
+}
+
+// CreateAPIKey serves POST /api-keys. The response carries the key, which is not shown again.
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.NewAppError(errors.InvalidInput, "invalid request body").WithDetails(err.Error()))
+		return
+	}
+
+	key, err := h.authService.CreateAPIKey(r.Context(), &service.CreateAPIKeyRequest{
+		Name:            req.Name,
+		Scopes:          req.Scopes,
+		DebitAccountIDs: req.DebitAccountIDs,
+	})
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	response := newAPIKeyResponse(key)
+	response.Key = key.Key
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// ListAPIKeys serves GET /api-keys
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.authService.ListAPIKeys(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	response := APIKeyListResponse{APIKeys: make([]APIKeyResponse, 0, len(keys))}
+	for _, key := range keys {
+		response.APIKeys = append(response.APIKeys, newAPIKeyResponse(key))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// GetAPIKey serves GET /api-keys/{key_id}
+func (h *APIKeyHandler) GetAPIKey(w http.ResponseWriter, r *http.Request) {
+	key, err := h.authService.GetAPIKey(r.Context(), mux.Vars(r)["key_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAPIKeyResponse(key))
+}
+
+// RevokeAPIKey serves POST /api-keys/{key_id}/revoke
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	key, err := h.authService.RevokeAPIKey(r.Context(), mux.Vars(r)["key_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAPIKeyResponse(key))
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/service"
+)
+
+const authorizationHeader = "Authorization"
+
+// Authenticator guards routes with the bearer token of the request
+type Authenticator struct {
+	authService *service.AuthService
+	enabled     bool // When false, every request is let through without a principal
+}
+
+func NewAuthenticator(authService *service.AuthService, enabled bool) *Authenticator {
+	return &Authenticator{
+		authService: authService,
+		enabled:     enabled,
+	}
+}
+
+// Require wraps a handler so it only runs for requests whose credentials hold the scope. The
+// authenticated principal is passed to it in the request context.
+func (a *Authenticator) Require(scope string, next http.HandlerFunc) http.Handler {
+	if !a.enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			writeUnauthorized(w)
+			return
+		}
+
+		principal, err := a.authService.Authenticate(r.Context(), token)
+		if err != nil {
+			if err == errors.ErrUnauthorized {
+				writeUnauthorized(w)
+				return
+			}
+			writeServiceError(w, r, err)
+			return
+		}
+
+		if !principal.HasScope(scope) {
+			writeError(w, errors.NewAppErrorf(errors.Forbidden, "credentials lack the %s scope", scope))
+			return
+		}
+
+		next(w, r.WithContext(domain.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// bearerToken returns the token of an "Authorization: Bearer <token>" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(r.Header.Get(authorizationHeader)), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func writeUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="internal-transfers"`)
+	writeError(w, errors.ErrUnauthorized)
+}
@@ -1,12 +1,25 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	stderrors "errors"
 	"net/http"
+	"strings"
 
+	"internal-transfers/internal/domain"
 	"internal-transfers/internal/errors"
+	"internal-transfers/internal/service"
 )
 
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	clientIDHeader       = "X-Client-ID"
+)
+
+// MaxRequestBodySize bounds request bodies. A batch of MaxBatchItems transfers fits well within it.
+const MaxRequestBodySize = 1 << 20
+
 type Response struct {
 	Data  interface{} `json:"data,omitempty"`
 	Error *Error      `json:"error,omitempty"`
@@ -39,3 +52,57 @@ func writeError(w http.ResponseWriter, appErr *errors.AppError) {
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(Response{Error: &errResponse})
 }
+
+// writeServiceError writes an error returned by the service layer. Errors caused by the
+// request deadline are reported as request_timeout regardless of where they surfaced.
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	if stderrors.Is(r.Context().Err(), context.DeadlineExceeded) {
+		writeError(w, errors.ErrRequestTimeout)
+		return
+	}
+
+	if appErr, ok := err.(*errors.AppError); ok {
+		writeError(w, appErr)
+		return
+	}
+
+	writeError(w, errors.NewAppError(errors.InternalError, "an unexpected error occurred"))
+}
+
+// idempotencyKey resolves the optional idempotency key from the Idempotency-Key header,
+// falling back to the legacy body field. Keys are opaque printable strings.
+func idempotencyKey(r *http.Request, bodyKey string) (*string, *errors.AppError) {
+	key := strings.TrimSpace(r.Header.Get(idempotencyKeyHeader))
+	bodyKey = strings.TrimSpace(bodyKey)
+
+	if key != "" && bodyKey != "" && key != bodyKey {
+		return nil, errors.NewAppError(errors.InvalidInput, "Idempotency-Key header does not match idempotency_key in body")
+	}
+	if key == "" {
+		key = bodyKey
+	}
+	if key == "" {
+		return nil, nil
+	}
+
+	if len(key) > service.MaxIdempotencyKeyLength {
+		return nil, errors.NewAppErrorf(errors.InvalidInput, "idempotency key must be at most %d characters", service.MaxIdempotencyKeyLength)
+	}
+	for _, c := range key {
+		if c < 0x20 || c == 0x7f {
+			return nil, errors.NewAppError(errors.InvalidInput, "idempotency key contains control characters")
+		}
+	}
+
+	return &key, nil
+}
+
+// clientID identifies the calling client that idempotency keys, FX quotes and transaction
+// lookups are scoped to: the authenticated principal, or the X-Client-ID header when
+// authentication is disabled. Authenticated callers cannot claim another client's header.
+func clientID(r *http.Request) string {
+	if principal := domain.PrincipalFromContext(r.Context()); principal != nil {
+		return principal.ID
+	}
+	return strings.TrimSpace(r.Header.Get(clientIDHeader))
+}
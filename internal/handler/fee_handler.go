@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/service"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+type FeeHandler struct {
+	feeService *service.FeeService
+}
+
+func NewFeeHandler(feeService *service.FeeService) *FeeHandler {
+	return &FeeHandler{
+		feeService: feeService,
+	}
+}
+
+type FeeTierRequest struct {
+	UpTo       string `json:"up_to,omitempty"` // Omitted on the last tier
+	Amount     string `json:"amount,omitempty"`
+	Percentage string `json:"percentage,omitempty"`
+}
+
+type SetFeeScheduleRequest struct {
+	Scope        string           `json:"scope"`                    // global or account
+	AccountID    json.Number      `json:"account_id,omitempty"`     // Account scope only
+	Currency     string           `json:"currency,omitempty"`       // Global scope only
+	FeeType      string           `json:"fee_type"`                 // flat, percentage or tiered
+	Amount       string           `json:"amount,omitempty"`         // Flat schedules only
+	Percentage   string           `json:"percentage,omitempty"`     // Percentage schedules only
+	Tiers        []FeeTierRequest `json:"tiers,omitempty"`          // Tiered schedules only
+	MinFee       string           `json:"min_fee,omitempty"`        // Percentage and tiered schedules only
+	MaxFee       string           `json:"max_fee,omitempty"`        // Percentage and tiered schedules only
+	FeeAccountID json.Number      `json:"fee_account_id,omitempty"` // Defaults to FEE_ACCOUNT_ID
+}
+
+type FeeTierResponse struct {
+	UpTo       *string `json:"up_to,omitempty"`
+	Amount     string  `json:"amount"`
+	Percentage string  `json:"percentage"`
+}
+
+type FeeScheduleResponse struct {
+	ScheduleID   string            `json:"schedule_id"`
+	Scope        string            `json:"scope"`
+	AccountID    *int64            `json:"account_id,omitempty"`
+	Currency     *string           `json:"currency,omitempty"`
+	FeeType      string            `json:"fee_type"`
+	Tiers        []FeeTierResponse `json:"tiers"`
+	MinFee       *string           `json:"min_fee,omitempty"`
+	MaxFee       *string           `json:"max_fee,omitempty"`
+	FeeAccountID *int64            `json:"fee_account_id,omitempty"` // Omitted when FEE_ACCOUNT_ID collects
+	CreatedAt    string            `json:"created_at"`
+	UpdatedAt    string            `json:"updated_at"`
+}
+
+type FeeScheduleListResponse struct {
+	Schedules []FeeScheduleResponse `json:"schedules"`
+}
+
+func newFeeScheduleResponse(schedule *domain.FeeSchedule) FeeScheduleResponse {
+	response := FeeScheduleResponse{
+		ScheduleID:   schedule.ID.String(),
+		Scope:        schedule.Scope,
+		AccountID:    schedule.AccountID,
+		Currency:     schedule.Currency,
+		FeeType:      schedule.FeeType,
+		Tiers:        make([]FeeTierResponse, 0, len(schedule.Tiers)),
+		MinFee:       optionalAmount(schedule.MinFee),
+		MaxFee:       optionalAmount(schedule.MaxFee),
+		FeeAccountID: schedule.FeeAccountID,
+		CreatedAt:    schedule.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt:    schedule.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	for _, tier := range schedule.Tiers {
+		response.Tiers = append(response.Tiers, FeeTierResponse{
+			UpTo:       optionalAmount(tier.UpTo),
+			Amount:     tier.Amount.String(),
+			Percentage: tier.Percentage.String(),
+		})
+	}
+	return response
+}
+
+func optionalAmount(amount *decimal.Decimal) *string {
+	if amount == nil {
+		return nil
+	}
+	s := amount.String()
+	return &s
+}
+
+// parseDecimal parses an optional decimal field of a request; empty means not set
+func parseDecimal(value, field string) (*decimal.Decimal, *errors.AppError) {
+	if value == "" {
+		return nil, nil
+	}
+	d, err := decimal.NewFromString(value)
+	if err != nil {
+		return nil, errors.NewAppErrorf(errors.InvalidAmount, "invalid %s format", field).WithDetails(err.Error())
+	}
+	return &d, nil
+}
+
+// SetFeeSchedule serves PUT /fee-schedules. It creates the schedule, or replaces the schedule
+// already defined for the same target and currency.
+func (h *FeeHandler) SetFeeSchedule(w http.ResponseWriter, r *http.Request) {
+	var req SetFeeScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.NewAppError(errors.InvalidInput, "invalid request body").WithDetails(err.Error()))
+		return
+	}
+
+	serviceReq := &service.SetFeeScheduleRequest{
+		Scope:        req.Scope,
+		AccountID:    req.AccountID.String(),
+		Currency:     req.Currency,
+		FeeType:      req.FeeType,
+		FeeAccountID: req.FeeAccountID.String(),
+	}
+
+	var appErr *errors.AppError
+	if serviceReq.Amount, appErr = parseDecimal(req.Amount, "amount"); appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	if serviceReq.Percentage, appErr = parseDecimal(req.Percentage, "percentage"); appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	if serviceReq.MinFee, appErr = parseDecimal(req.MinFee, "min_fee"); appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	if serviceReq.MaxFee, appErr = parseDecimal(req.MaxFee, "max_fee"); appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	// Tier amounts and percentages left out are zero
+	for _, tierReq := range req.Tiers {
+		var tier domain.FeeTier
+		if tier.UpTo, appErr = parseDecimal(tierReq.UpTo, "tier up_to"); appErr != nil {
+			writeError(w, appErr)
+			return
+		}
+		amount, appErr := parseDecimal(tierReq.Amount, "tier amount")
+		if appErr != nil {
+			writeError(w, appErr)
+			return
+		}
+		if amount != nil {
+			tier.Amount = *amount
+		}
+		percentage, appErr := parseDecimal(tierReq.Percentage, "tier percentage")
+		if appErr != nil {
+			writeError(w, appErr)
+			return
+		}
+		if percentage != nil {
+			tier.Percentage = *percentage
+		}
+		serviceReq.Tiers = append(serviceReq.Tiers, tier)
+	}
+
+	schedule, err := h.feeService.SetFeeSchedule(r.Context(), serviceReq)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newFeeScheduleResponse(schedule))
+}
+
+// ListFeeSchedules serves GET /fee-schedules
+func (h *FeeHandler) ListFeeSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := h.feeService.ListFeeSchedules(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	response := FeeScheduleListResponse{Schedules: make([]FeeScheduleResponse, 0, len(schedules))}
+	for _, schedule := range schedules {
+		response.Schedules = append(response.Schedules, newFeeScheduleResponse(schedule))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// DeleteFeeSchedule serves DELETE /fee-schedules/{schedule_id} and returns the deleted schedule
+func (h *FeeHandler) DeleteFeeSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, err := h.feeService.DeleteFeeSchedule(r.Context(), mux.Vars(r)["schedule_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newFeeScheduleResponse(schedule))
+}
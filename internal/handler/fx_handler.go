@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/service"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+type FXHandler struct {
+	fxService *service.FXService
+}
+
+func NewFXHandler(fxService *service.FXService) *FXHandler {
+	return &FXHandler{
+		fxService: fxService,
+	}
+}
+
+type CreateQuoteRequest struct {
+	SourceCurrency      string `json:"source_currency"`
+	DestinationCurrency string `json:"destination_currency"`
+	Amount              string `json:"amount,omitempty"` // Optional source amount to preview the conversion of
+	TTL                 string `json:"ttl,omitempty"`    // Go duration such as "30s"; defaults to FX_QUOTE_TTL
+}
+
+type QuoteResponse struct {
+	QuoteID             string  `json:"quote_id"`
+	SourceCurrency      string  `json:"source_currency"`
+	DestinationCurrency string  `json:"destination_currency"`
+	Rate                string  `json:"rate"`
+	Amount              *string `json:"amount,omitempty"`
+	DestinationAmount   *string `json:"destination_amount,omitempty"` // Amount converted at the quoted rate
+	ExpiresAt           string  `json:"expires_at"`
+	TransactionID       *string `json:"transaction_id,omitempty"` // Transfer that used the quote
+	CreatedAt           string  `json:"created_at"`
+}
+
+func newQuoteResponse(quote *domain.FXQuote) QuoteResponse {
+	response := QuoteResponse{
+		QuoteID:             quote.ID.String(),
+		SourceCurrency:      quote.SourceCurrency,
+		DestinationCurrency: quote.DestinationCurrency,
+		Rate:                quote.Rate.String(),
+		ExpiresAt:           quote.ExpiresAt.UTC().Format(time.RFC3339Nano),
+		CreatedAt:           quote.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	if quote.Amount != nil {
+		amount, destinationAmount := quote.Amount.String(), quote.DestinationAmount.String()
+		response.Amount, response.DestinationAmount = &amount, &destinationAmount
+	}
+
+	if quote.TransactionID != nil {
+		transactionID := quote.TransactionID.String()
+		response.TransactionID = &transactionID
+	}
+
+	return response
+}
+
+// CreateQuote serves POST /fx/quotes
+func (h *FXHandler) CreateQuote(w http.ResponseWriter, r *http.Request) {
+	var req CreateQuoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.NewAppError(errors.InvalidInput, "invalid request body").WithDetails(err.Error()))
+		return
+	}
+
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			writeError(w, errors.NewAppError(errors.InvalidInput, "ttl must be a positive duration such as 30s"))
+			return
+		}
+	}
+
+	var amount *decimal.Decimal
+	if req.Amount != "" {
+		parsed, err := decimal.NewFromString(req.Amount)
+		if err != nil {
+			writeError(w, errors.NewAppError(errors.InvalidAmount, "invalid amount format").WithDetails(err.Error()))
+			return
+		}
+		amount = &parsed
+	}
+
+	quote, err := h.fxService.CreateQuote(r.Context(), &service.CreateQuoteRequest{
+		SourceCurrency:      req.SourceCurrency,
+		DestinationCurrency: req.DestinationCurrency,
+		Amount:              amount,
+		TTL:                 ttl,
+		ClientID:            clientID(r),
+	})
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newQuoteResponse(quote))
+}
+
+// GetQuote serves GET /fx/quotes/{quote_id}
+func (h *FXHandler) GetQuote(w http.ResponseWriter, r *http.Request) {
+	quote, err := h.fxService.GetQuote(r.Context(), mux.Vars(r)["quote_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newQuoteResponse(quote))
+}
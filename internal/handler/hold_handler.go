@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/service"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+type HoldHandler struct {
+	holdService *service.HoldService
+}
+
+func NewHoldHandler(holdService *service.HoldService) *HoldHandler {
+	return &HoldHandler{
+		holdService: holdService,
+	}
+}
+
+type CreateHoldRequest struct {
+	SourceAccountID      json.Number `json:"source_account_id"`
+	DestinationAccountID json.Number `json:"destination_account_id"`
+	Amount               string      `json:"amount"`
+	ExpiresAt            *time.Time  `json:"expires_at,omitempty"` // RFC 3339
+	IdempotencyKey       string      `json:"idempotency_key,omitempty"`
+}
+
+type HoldResponse struct {
+	HoldID               string  `json:"hold_id"`
+	SourceAccountID      int64   `json:"source_account_id"`
+	DestinationAccountID int64   `json:"destination_account_id"`
+	Amount               string  `json:"amount"`
+	Status               string  `json:"status"`
+	TransactionID        *string `json:"transaction_id,omitempty"`
+	IdempotencyKey       *string `json:"idempotency_key,omitempty"`
+	ExpiresAt            string  `json:"expires_at"`
+	CreatedAt            string  `json:"created_at"`
+	UpdatedAt            string  `json:"updated_at"`
+}
+
+func newHoldResponse(hold *domain.Hold) HoldResponse {
+	response := HoldResponse{
+		HoldID:               hold.ID.String(),
+		SourceAccountID:      hold.SourceAccountID,
+		DestinationAccountID: hold.DestinationAccountID,
+		Amount:               hold.Amount.String(),
+		Status:               hold.Status,
+		IdempotencyKey:       hold.IdempotencyKey,
+		ExpiresAt:            hold.ExpiresAt.UTC().Format(time.RFC3339Nano),
+		CreatedAt:            hold.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt:            hold.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	if hold.TransactionID != nil {
+		transactionID := hold.TransactionID.String()
+		response.TransactionID = &transactionID
+	}
+
+	return response
+}
+
+// CreateHold serves POST /holds
+func (h *HoldHandler) CreateHold(w http.ResponseWriter, r *http.Request) {
+	var req CreateHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.NewAppError(errors.InvalidInput, "invalid request body").WithDetails(err.Error()))
+		return
+	}
+
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil {
+		writeError(w, errors.NewAppError(errors.InvalidAmount, "invalid amount format").WithDetails(err.Error()))
+		return
+	}
+
+	key, appErr := idempotencyKey(r, req.IdempotencyKey)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	hold, err := h.holdService.CreateHold(r.Context(), &service.CreateHoldRequest{
+		SourceAccountID:      req.SourceAccountID.String(),
+		DestinationAccountID: req.DestinationAccountID.String(),
+		Amount:               amount,
+		ExpiresAt:            req.ExpiresAt,
+		IdempotencyKey:       key,
+		ClientID:             clientID(r),
+	})
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	if hold.Replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+
+	writeJSON(w, http.StatusCreated, newHoldResponse(hold))
+}
+
+// GetHold serves GET /holds/{hold_id}
+func (h *HoldHandler) GetHold(w http.ResponseWriter, r *http.Request) {
+	hold, err := h.holdService.GetHold(r.Context(), mux.Vars(r)["hold_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newHoldResponse(hold))
+}
+
+type CaptureHoldRequest struct {
+	Amount string `json:"amount,omitempty"` // Omit to capture the full held amount
+}
+
+// CaptureHold serves POST /holds/{hold_id}/capture. The body is optional.
+func (h *HoldHandler) CaptureHold(w http.ResponseWriter, r *http.Request) {
+	var req CaptureHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, errors.NewAppError(errors.InvalidInput, "invalid request body").WithDetails(err.Error()))
+		return
+	}
+
+	captureReq := &service.CaptureHoldRequest{HoldID: mux.Vars(r)["hold_id"]}
+	if req.Amount != "" {
+		amount, err := decimal.NewFromString(req.Amount)
+		if err != nil {
+			writeError(w, errors.NewAppError(errors.InvalidAmount, "invalid amount format").WithDetails(err.Error()))
+			return
+		}
+		captureReq.Amount = &amount
+	}
+
+	transaction, err := h.holdService.CaptureHold(r.Context(), captureReq)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	if transaction.Replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+
+	writeJSON(w, http.StatusCreated, newTransactionDetailResponse(transaction))
+}
+
+// VoidHold serves POST /holds/{hold_id}/void
+func (h *HoldHandler) VoidHold(w http.ResponseWriter, r *http.Request) {
+	hold, err := h.holdService.VoidHold(r.Context(), mux.Vars(r)["hold_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	if hold.Replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+
+	writeJSON(w, http.StatusOK, newHoldResponse(hold))
+}
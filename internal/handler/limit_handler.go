@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/service"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+type LimitHandler struct {
+	limitService *service.LimitService
+}
+
+func NewLimitHandler(limitService *service.LimitService) *LimitHandler {
+	return &LimitHandler{
+		limitService: limitService,
+	}
+}
+
+type SetLimitRequest struct {
+	Scope     string      `json:"scope"`                // global, tier or account
+	Tier      string      `json:"tier,omitempty"`       // Tier scope only
+	AccountID json.Number `json:"account_id,omitempty"` // Account scope only
+	LimitType string      `json:"limit_type"`           // per_transfer, daily or monthly
+	Currency  string      `json:"currency,omitempty"`   // Global and tier scopes only
+	Amount    string      `json:"amount"`
+}
+
+type LimitResponse struct {
+	LimitID   string  `json:"limit_id"`
+	Scope     string  `json:"scope"`
+	Tier      *string `json:"tier,omitempty"`
+	AccountID *int64  `json:"account_id,omitempty"`
+	LimitType string  `json:"limit_type"`
+	Currency  *string `json:"currency,omitempty"`
+	Amount    string  `json:"amount"`
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+type LimitListResponse struct {
+	Limits []LimitResponse `json:"limits"`
+}
+
+type EffectiveLimitResponse struct {
+	LimitType   string  `json:"limit_type"`
+	Amount      string  `json:"amount"`
+	Scope       string  `json:"scope"`              // account, tier, global or default
+	LimitID     *string `json:"limit_id,omitempty"` // Omitted for configured defaults
+	Used        *string `json:"used,omitempty"`     // Daily and monthly limits only
+	Remaining   *string `json:"remaining,omitempty"`
+	WindowStart *string `json:"window_start,omitempty"`
+}
+
+type AccountLimitsResponse struct {
+	AccountID int64                    `json:"account_id"`
+	Tier      string                   `json:"tier"`
+	Currency  string                   `json:"currency"`
+	Limits    []EffectiveLimitResponse `json:"limits"`
+}
+
+func newLimitResponse(limit *domain.TransferLimit) LimitResponse {
+	return LimitResponse{
+		LimitID:   limit.ID.String(),
+		Scope:     limit.Scope,
+		Tier:      limit.Tier,
+		AccountID: limit.AccountID,
+		LimitType: limit.LimitType,
+		Currency:  limit.Currency,
+		Amount:    limit.Amount.String(),
+		CreatedAt: limit.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt: limit.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// SetLimit serves PUT /limits. It creates the limit, or replaces the amount of the limit
+// already defined for the same target, type and currency.
+func (h *LimitHandler) SetLimit(w http.ResponseWriter, r *http.Request) {
+	var req SetLimitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.NewAppError(errors.InvalidInput, "invalid request body").WithDetails(err.Error()))
+		return
+	}
+
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil {
+		writeError(w, errors.NewAppError(errors.InvalidAmount, "invalid amount format").WithDetails(err.Error()))
+		return
+	}
+
+	limit, err := h.limitService.SetLimit(r.Context(), &service.SetLimitRequest{
+		Scope:     req.Scope,
+		Tier:      req.Tier,
+		AccountID: req.AccountID.String(),
+		LimitType: req.LimitType,
+		Currency:  req.Currency,
+		Amount:    amount,
+	})
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newLimitResponse(limit))
+}
+
+// ListLimits serves GET /limits
+func (h *LimitHandler) ListLimits(w http.ResponseWriter, r *http.Request) {
+	limits, err := h.limitService.ListLimits(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	response := LimitListResponse{Limits: make([]LimitResponse, 0, len(limits))}
+	for _, limit := range limits {
+		response.Limits = append(response.Limits, newLimitResponse(limit))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// DeleteLimit serves DELETE /limits/{limit_id} and returns the deleted limit
+func (h *LimitHandler) DeleteLimit(w http.ResponseWriter, r *http.Request) {
+	limit, err := h.limitService.DeleteLimit(r.Context(), mux.Vars(r)["limit_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newLimitResponse(limit))
+}
+
+// GetAccountLimits serves GET /accounts/{account_id}/limits: the limits that apply to the
+// account and what it has used of them
+func (h *LimitHandler) GetAccountLimits(w http.ResponseWriter, r *http.Request) {
+	result, err := h.limitService.GetAccountLimits(r.Context(), mux.Vars(r)["account_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	response := AccountLimitsResponse{
+		AccountID: result.Account.ID,
+		Tier:      result.Account.Tier,
+		Currency:  result.Account.Currency,
+		Limits:    make([]EffectiveLimitResponse, 0, len(result.Limits)),
+	}
+	for _, limit := range result.Limits {
+		limitResponse := EffectiveLimitResponse{
+			LimitType: limit.LimitType,
+			Amount:    limit.Amount.String(),
+			Scope:     limit.Scope,
+		}
+		if limit.LimitID != nil {
+			limitID := limit.LimitID.String()
+			limitResponse.LimitID = &limitID
+		}
+		if limit.WindowStart != nil {
+			used, remaining := limit.Used.String(), limit.Remaining().String()
+			windowStart := limit.WindowStart.UTC().Format(time.RFC3339)
+			limitResponse.Used, limitResponse.Remaining, limitResponse.WindowStart = &used, &remaining, &windowStart
+		}
+		response.Limits = append(response.Limits, limitResponse)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/ratelimit"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	rateLimitLimitHeader     = "RateLimit-Limit"
+	rateLimitRemainingHeader = "RateLimit-Remaining"
+	rateLimitResetHeader     = "RateLimit-Reset"
+	retryAfterHeader         = "Retry-After"
+)
+
+// RateLimitConfig sets the limits a RateLimiter applies
+type RateLimitConfig struct {
+	// Rules limits routes per client and per source account, keyed by ratelimit.RouteKey
+	Rules map[string]ratelimit.Rule
+
+	// MaxInFlightPerAccount caps the requests to TransferRoutes in progress per source account,
+	// so transfers from a hot account do not queue up on its row lock. Zero for no cap.
+	MaxInFlightPerAccount int
+	TransferRoutes        []string
+}
+
+// RateLimiter throttles requests with a token bucket per client and per source account for
+// each limited route, and caps the transfers in flight per source account
+type RateLimiter struct {
+	routes         map[string]*routeLimits
+	inFlight       *ratelimit.InFlight // Nil when transfers in flight are not capped
+	transferRoutes []string
+}
+
+type routeLimits struct {
+	client  *ratelimit.Buckets
+	account *ratelimit.Buckets
+}
+
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	limiter := &RateLimiter{
+		routes:         make(map[string]*routeLimits, len(config.Rules)),
+		transferRoutes: config.TransferRoutes,
+	}
+	for route, rule := range config.Rules {
+		limits := &routeLimits{}
+		if rule.Client != nil {
+			limits.client = ratelimit.NewBuckets(*rule.Client)
+		}
+		if rule.Account != nil {
+			limits.account = ratelimit.NewBuckets(*rule.Account)
+		}
+		limiter.routes[route] = limits
+	}
+	if config.MaxInFlightPerAccount > 0 {
+		limiter.inFlight = ratelimit.NewInFlight(config.MaxInFlightPerAccount)
+	}
+	return limiter
+}
+
+// clientTokenKey is the context key of the client token LimitClient took for a request
+type clientTokenKey struct{}
+
+// clientToken is a token taken from a route's client bucket before the request was authenticated
+type clientToken struct {
+	key      string
+	decision ratelimit.Decision
+}
+
+// LimitClient wraps the authentication of a route so its client bucket is charged first, and
+// requests with bad credentials are limited like any other. Requests presenting credentials are
+// keyed by their address until they are verified; Limit then moves the token to their principal.
+func (l *RateLimiter) LimitClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limits := l.routes[currentRouteKey(r)]
+		if limits == nil || limits.client == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := rateLimitClient(r)
+		if _, ok := bearerToken(r); ok {
+			key = "ip:" + remoteHost(r)
+		}
+		decision := limits.client.Take(key, time.Now())
+		if !decision.Allowed {
+			writeRateLimited(w, decision, errors.NewAppError(errors.RateLimited, "rate limit exceeded for this client"))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientTokenKey{}, &clientToken{key: key, decision: decision})))
+	})
+}
+
+// Limit wraps a handler so requests beyond the limits of their route are rejected with
+// 429 rate_limited and a Retry-After header. A rejected request gives back the tokens it took
+// from the other buckets. Requests let through get RateLimit-* headers for the bucket closest
+// to running out. Clients are keyed by their principal, so Limit must run inside
+// Authenticator.Require, itself inside LimitClient.
+func (l *RateLimiter) Limit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		route := currentRouteKey(r)
+		limits := l.routes[route]
+		capped := l.inFlight != nil && slices.Contains(l.transferRoutes, route)
+		if limits == nil && !capped {
+			next(w, r)
+			return
+		}
+
+		var accounts []int64
+		if capped || limits.account != nil {
+			accounts = sourceAccounts(w, r)
+		}
+
+		var tightest *ratelimit.Decision
+		var taken []func()
+		now := time.Now()
+		take := func(buckets *ratelimit.Buckets, key string) bool {
+			decision := buckets.Take(key, now)
+			if tightest == nil || decision.Remaining < tightest.Remaining || !decision.Allowed {
+				tightest = &decision
+			}
+			if decision.Allowed {
+				taken = append(taken, func() { buckets.Return(key) })
+			}
+			return decision.Allowed
+		}
+		giveBack := func() {
+			for _, give := range taken {
+				give()
+			}
+		}
+
+		if limits != nil && limits.client != nil {
+			charged, _ := r.Context().Value(clientTokenKey{}).(*clientToken)
+			if charged != nil && domain.PrincipalFromContext(r.Context()) == nil {
+				// Without a principal the token LimitClient took is the client's
+				tightest = &charged.decision
+				taken = append(taken, func() { limits.client.Return(charged.key) })
+			} else {
+				if charged != nil {
+					limits.client.Return(charged.key)
+				}
+				if !take(limits.client, rateLimitClient(r)) {
+					writeRateLimited(w, *tightest, errors.NewAppError(errors.RateLimited, "rate limit exceeded for these credentials"))
+					return
+				}
+			}
+		}
+		if limits != nil && limits.account != nil {
+			for _, id := range accounts {
+				if !take(limits.account, strconv.FormatInt(id, 10)) {
+					giveBack()
+					writeRateLimited(w, *tightest, errors.NewAppErrorf(errors.RateLimited, "rate limit exceeded for account %d", id))
+					return
+				}
+			}
+		}
+
+		if capped {
+			for i, id := range accounts {
+				key := strconv.FormatInt(id, 10)
+				if !l.inFlight.Acquire(key) {
+					for _, acquired := range accounts[:i] {
+						l.inFlight.Release(strconv.FormatInt(acquired, 10))
+					}
+					giveBack()
+					w.Header().Set(retryAfterHeader, "1")
+					writeError(w, errors.NewAppErrorf(errors.RateLimited, "too many transfers in flight from account %d", id))
+					return
+				}
+				defer l.inFlight.Release(key)
+			}
+		}
+
+		if tightest != nil {
+			setRateLimitHeaders(w.Header(), *tightest)
+		}
+		next(w, r)
+	}
+}
+
+// currentRouteKey identifies the route of a request as its method and path template
+func currentRouteKey(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return r.Method + " " + template
+}
+
+// rateLimitClient keys the client bucket: the authenticated principal, or without one the
+// X-Client-ID header or the client's address
+func rateLimitClient(r *http.Request) string {
+	if principal := domain.PrincipalFromContext(r.Context()); principal != nil {
+		return principal.ID
+	}
+	if id := clientID(r); id != "" {
+		return "client:" + id
+	}
+	return "ip:" + remoteHost(r)
+}
+
+// remoteHost is the address of the client, without its port
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// sourceAccounts reads the distinct source accounts of a transfer, hold, standing order or batch
+// body, leaving the body to be read again by the handler. Bodies the handler will reject yield none.
+// At most MaxRequestBodySize bytes are read; the handler then fails to read a larger body as well.
+func sourceAccounts(w http.ResponseWriter, r *http.Request) []int64 {
+	if r.Body == nil {
+		return nil
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxRequestBodySize))
+	r.Body.Close()
+	if err != nil {
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), failingReader{err}))
+		return nil
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var req struct {
+		SourceAccountID json.Number `json:"source_account_id"`
+		Transfers       []struct {
+			SourceAccountID json.Number `json:"source_account_id"`
+		} `json:"transfers"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil
+	}
+
+	var accounts []int64
+	add := func(number json.Number) {
+		if id, err := number.Int64(); err == nil && id > 0 && !slices.Contains(accounts, id) {
+			accounts = append(accounts, id)
+		}
+	}
+	add(req.SourceAccountID)
+	for _, item := range req.Transfers {
+		add(item.SourceAccountID)
+	}
+	return accounts
+}
+
+// failingReader fails every read with err, for a body that could not be read in full
+type failingReader struct {
+	err error
+}
+
+func (f failingReader) Read([]byte) (int, error) {
+	return 0, f.err
+}
+
+func setRateLimitHeaders(header http.Header, decision ratelimit.Decision) {
+	header.Set(rateLimitLimitHeader, strconv.Itoa(decision.Limit))
+	header.Set(rateLimitRemainingHeader, strconv.Itoa(decision.Remaining))
+	header.Set(rateLimitResetHeader, strconv.Itoa(ceilSeconds(decision.Reset)))
+}
+
+func writeRateLimited(w http.ResponseWriter, decision ratelimit.Decision, appErr *errors.AppError) {
+	setRateLimitHeaders(w.Header(), decision)
+	w.Header().Set(retryAfterHeader, strconv.Itoa(max(ceilSeconds(decision.RetryAfter), 1)))
+	writeError(w, appErr)
+}
+
+// ceilSeconds rounds a duration up to whole seconds, as the headers carry
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
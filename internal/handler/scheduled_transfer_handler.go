@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+type ScheduledTransferHandler struct {
+	scheduledService *service.ScheduledTransferService
+}
+
+func NewScheduledTransferHandler(scheduledService *service.ScheduledTransferService) *ScheduledTransferHandler {
+	return &ScheduledTransferHandler{
+		scheduledService: scheduledService,
+	}
+}
+
+type ScheduledTransferResponse struct {
+	ScheduledTransferID  string  `json:"scheduled_transfer_id"`
+	SourceAccountID      int64   `json:"source_account_id"`
+	DestinationAccountID int64   `json:"destination_account_id"`
+	Amount               string  `json:"amount"`
+	Currency             string  `json:"currency"`
+	ExecuteAt            string  `json:"execute_at"`
+	Status               string  `json:"status"`
+	TransactionID        *string `json:"transaction_id,omitempty"`
+	FailureReason        *string `json:"failure_reason,omitempty"`
+	IdempotencyKey       *string `json:"idempotency_key,omitempty"`
+	InitiatedBy          *string `json:"initiated_by,omitempty"`
+	CreatedAt            string  `json:"created_at"`
+	UpdatedAt            string  `json:"updated_at"`
+}
+
+func newScheduledTransferResponse(scheduled *domain.ScheduledTransfer) ScheduledTransferResponse {
+	response := ScheduledTransferResponse{
+		ScheduledTransferID:  scheduled.ID.String(),
+		SourceAccountID:      scheduled.SourceAccountID,
+		DestinationAccountID: scheduled.DestinationAccountID,
+		Amount:               scheduled.Amount.String(),
+		Currency:             scheduled.Currency,
+		ExecuteAt:            scheduled.ExecuteAt.UTC().Format(time.RFC3339Nano),
+		Status:               scheduled.Status,
+		FailureReason:        scheduled.FailureReason,
+		IdempotencyKey:       scheduled.IdempotencyKey,
+		InitiatedBy:          scheduled.InitiatedBy,
+		CreatedAt:            scheduled.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt:            scheduled.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	if scheduled.TransactionID != nil {
+		transactionID := scheduled.TransactionID.String()
+		response.TransactionID = &transactionID
+	}
+
+	return response
+}
+
+// GetScheduledTransfer serves GET /scheduled-transfers/{scheduled_transfer_id}
+func (h *ScheduledTransferHandler) GetScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+	scheduled, err := h.scheduledService.GetScheduledTransfer(r.Context(), mux.Vars(r)["scheduled_transfer_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newScheduledTransferResponse(scheduled))
+}
+
+// CancelScheduledTransfer serves POST /scheduled-transfers/{scheduled_transfer_id}/cancel
+func (h *ScheduledTransferHandler) CancelScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+	scheduled, err := h.scheduledService.CancelScheduledTransfer(r.Context(), mux.Vars(r)["scheduled_transfer_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newScheduledTransferResponse(scheduled))
+}
@@ -0,0 +1,298 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/service"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+type StandingOrderHandler struct {
+	standingOrderService *service.StandingOrderService
+}
+
+func NewStandingOrderHandler(standingOrderService *service.StandingOrderService) *StandingOrderHandler {
+	return &StandingOrderHandler{
+		standingOrderService: standingOrderService,
+	}
+}
+
+type CreateStandingOrderRequest struct {
+	SourceAccountID      json.Number `json:"source_account_id"`
+	DestinationAccountID json.Number `json:"destination_account_id"`
+	Amount               string      `json:"amount"`
+	Cron                 string      `json:"cron,omitempty"`     // Five-field cron expression, evaluated in UTC
+	Interval             string      `json:"interval,omitempty"` // Go duration such as "24h"
+	StartAt              *time.Time  `json:"start_at,omitempty"` // RFC 3339
+	EndAt                *time.Time  `json:"end_at,omitempty"`   // RFC 3339
+	MaxRuns              *int        `json:"max_runs,omitempty"`
+	IdempotencyKey       string      `json:"idempotency_key,omitempty"`
+}
+
+type UpdateStandingOrderRequest struct {
+	Amount  string     `json:"amount,omitempty"`
+	EndAt   *time.Time `json:"end_at,omitempty"`
+	MaxRuns *int       `json:"max_runs,omitempty"`
+}
+
+type StandingOrderResponse struct {
+	StandingOrderID      string  `json:"standing_order_id"`
+	SourceAccountID      int64   `json:"source_account_id"`
+	DestinationAccountID int64   `json:"destination_account_id"`
+	Amount               string  `json:"amount"`
+	Cron                 string  `json:"cron,omitempty"`
+	Interval             string  `json:"interval,omitempty"`
+	StartAt              string  `json:"start_at"`
+	EndAt                *string `json:"end_at,omitempty"`
+	MaxRuns              *int    `json:"max_runs,omitempty"`
+	RunCount             int     `json:"run_count"`
+	NextRunAt            *string `json:"next_run_at,omitempty"`
+	Status               string  `json:"status"`
+	IdempotencyKey       *string `json:"idempotency_key,omitempty"`
+	InitiatedBy          *string `json:"initiated_by,omitempty"`
+	CreatedAt            string  `json:"created_at"`
+	UpdatedAt            string  `json:"updated_at"`
+}
+
+func newStandingOrderResponse(order *domain.StandingOrder) StandingOrderResponse {
+	response := StandingOrderResponse{
+		StandingOrderID:      order.ID.String(),
+		SourceAccountID:      order.SourceAccountID,
+		DestinationAccountID: order.DestinationAccountID,
+		Amount:               order.Amount.String(),
+		Cron:                 order.CronExpression,
+		StartAt:              order.StartAt.UTC().Format(time.RFC3339Nano),
+		MaxRuns:              order.MaxRuns,
+		RunCount:             order.RunCount,
+		Status:               order.Status,
+		IdempotencyKey:       order.IdempotencyKey,
+		InitiatedBy:          order.InitiatedBy,
+		CreatedAt:            order.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt:            order.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	if order.Interval > 0 {
+		response.Interval = order.Interval.String()
+	}
+	if order.EndAt != nil {
+		endAt := order.EndAt.UTC().Format(time.RFC3339Nano)
+		response.EndAt = &endAt
+	}
+	if order.NextRunAt != nil {
+		nextRunAt := order.NextRunAt.UTC().Format(time.RFC3339Nano)
+		response.NextRunAt = &nextRunAt
+	}
+
+	return response
+}
+
+type StandingOrderListResponse struct {
+	AccountID      int64                   `json:"account_id"`
+	StandingOrders []StandingOrderResponse `json:"standing_orders"`
+}
+
+type StandingOrderRunResponse struct {
+	RunID         string  `json:"run_id"`
+	Period        string  `json:"period"`
+	Status        string  `json:"status"`
+	TransactionID *string `json:"transaction_id,omitempty"`
+	FailureReason *string `json:"failure_reason,omitempty"`
+	CreatedAt     string  `json:"created_at"`
+}
+
+type StandingOrderRunsResponse struct {
+	StandingOrderID string                     `json:"standing_order_id"`
+	Runs            []StandingOrderRunResponse `json:"runs"`
+}
+
+// CreateStandingOrder serves POST /standing-orders
+func (h *StandingOrderHandler) CreateStandingOrder(w http.ResponseWriter, r *http.Request) {
+	var req CreateStandingOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.NewAppError(errors.InvalidInput, "invalid request body").WithDetails(err.Error()))
+		return
+	}
+
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil {
+		writeError(w, errors.NewAppError(errors.InvalidAmount, "invalid amount format").WithDetails(err.Error()))
+		return
+	}
+
+	var interval time.Duration
+	if req.Interval != "" {
+		interval, err = time.ParseDuration(req.Interval)
+		if err != nil || interval <= 0 {
+			writeError(w, errors.NewAppError(errors.InvalidInput, "interval must be a positive duration such as 24h"))
+			return
+		}
+	}
+
+	key, appErr := idempotencyKey(r, req.IdempotencyKey)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	order, err := h.standingOrderService.CreateStandingOrder(r.Context(), &service.CreateStandingOrderRequest{
+		SourceAccountID:      req.SourceAccountID.String(),
+		DestinationAccountID: req.DestinationAccountID.String(),
+		Amount:               amount,
+		CronExpression:       req.Cron,
+		Interval:             interval,
+		StartAt:              req.StartAt,
+		EndAt:                req.EndAt,
+		MaxRuns:              req.MaxRuns,
+		IdempotencyKey:       key,
+		ClientID:             clientID(r),
+	})
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	if order.Replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+
+	writeJSON(w, http.StatusCreated, newStandingOrderResponse(order))
+}
+
+// GetStandingOrder serves GET /standing-orders/{standing_order_id}
+func (h *StandingOrderHandler) GetStandingOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := h.standingOrderService.GetStandingOrder(r.Context(), mux.Vars(r)["standing_order_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newStandingOrderResponse(order))
+}
+
+// ListAccountStandingOrders serves GET /accounts/{account_id}/standing-orders
+func (h *StandingOrderHandler) ListAccountStandingOrders(w http.ResponseWriter, r *http.Request) {
+	accountID := mux.Vars(r)["account_id"]
+
+	orders, err := h.standingOrderService.ListAccountStandingOrders(r.Context(), accountID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	// The service has already validated the ID
+	id, _ := strconv.ParseInt(accountID, 10, 64)
+
+	response := StandingOrderListResponse{
+		AccountID:      id,
+		StandingOrders: make([]StandingOrderResponse, 0, len(orders)),
+	}
+	for _, order := range orders {
+		response.StandingOrders = append(response.StandingOrders, newStandingOrderResponse(order))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// UpdateStandingOrder serves PATCH /standing-orders/{standing_order_id}
+func (h *StandingOrderHandler) UpdateStandingOrder(w http.ResponseWriter, r *http.Request) {
+	var req UpdateStandingOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.NewAppError(errors.InvalidInput, "invalid request body").WithDetails(err.Error()))
+		return
+	}
+
+	updateReq := &service.UpdateStandingOrderRequest{
+		StandingOrderID: mux.Vars(r)["standing_order_id"],
+		EndAt:           req.EndAt,
+		MaxRuns:         req.MaxRuns,
+	}
+	if req.Amount != "" {
+		amount, err := decimal.NewFromString(req.Amount)
+		if err != nil {
+			writeError(w, errors.NewAppError(errors.InvalidAmount, "invalid amount format").WithDetails(err.Error()))
+			return
+		}
+		updateReq.Amount = &amount
+	}
+
+	order, err := h.standingOrderService.UpdateStandingOrder(r.Context(), updateReq)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newStandingOrderResponse(order))
+}
+
+// CancelStandingOrder serves DELETE /standing-orders/{standing_order_id}. The order is
+// cancelled rather than removed, so its run history stays available.
+func (h *StandingOrderHandler) CancelStandingOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := h.standingOrderService.CancelStandingOrder(r.Context(), mux.Vars(r)["standing_order_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newStandingOrderResponse(order))
+}
+
+// PauseStandingOrder serves POST /standing-orders/{standing_order_id}/pause
+func (h *StandingOrderHandler) PauseStandingOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := h.standingOrderService.PauseStandingOrder(r.Context(), mux.Vars(r)["standing_order_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newStandingOrderResponse(order))
+}
+
+// ResumeStandingOrder serves POST /standing-orders/{standing_order_id}/resume
+func (h *StandingOrderHandler) ResumeStandingOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := h.standingOrderService.ResumeStandingOrder(r.Context(), mux.Vars(r)["standing_order_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newStandingOrderResponse(order))
+}
+
+// ListStandingOrderRuns serves GET /standing-orders/{standing_order_id}/runs
+func (h *StandingOrderHandler) ListStandingOrderRuns(w http.ResponseWriter, r *http.Request) {
+	orderID := mux.Vars(r)["standing_order_id"]
+
+	runs, err := h.standingOrderService.ListStandingOrderRuns(r.Context(), orderID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	response := StandingOrderRunsResponse{
+		StandingOrderID: orderID,
+		Runs:            make([]StandingOrderRunResponse, 0, len(runs)),
+	}
+	for _, run := range runs {
+		runResponse := StandingOrderRunResponse{
+			RunID:         run.ID.String(),
+			Period:        run.Period.UTC().Format(time.RFC3339Nano),
+			Status:        run.Status,
+			FailureReason: run.FailureReason,
+			CreatedAt:     run.CreatedAt.UTC().Format(time.RFC3339Nano),
+		}
+		if run.TransactionID != nil {
+			transactionID := run.TransactionID.String()
+			runResponse.TransactionID = &transactionID
+		}
+		response.Runs = append(response.Runs, runResponse)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	lastEventIDHeader = "Last-Event-ID"
+
+	// streamSnapshotEvent opens a stream that does not resume an earlier one
+	streamSnapshotEvent = "snapshot"
+)
+
+type StreamHandler struct {
+	streamService *service.StreamService
+	heartbeat     time.Duration // Interval of keep-alive comments on an idle stream
+	writeTimeout  time.Duration // A client taking longer to accept a write is disconnected
+}
+
+func NewStreamHandler(streamService *service.StreamService, heartbeat, writeTimeout time.Duration) *StreamHandler {
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+	if writeTimeout <= 0 {
+		writeTimeout = 10 * time.Second
+	}
+	return &StreamHandler{
+		streamService: streamService,
+		heartbeat:     heartbeat,
+		writeTimeout:  writeTimeout,
+	}
+}
+
+// StreamEventResponse is the data of a stream event. Its id is the sequence.
+type StreamEventResponse struct {
+	Sequence    int64            `json:"sequence"`
+	Type        string           `json:"type"` // snapshot or an event type
+	AccountID   int64            `json:"account_id"`
+	Balance     *string          `json:"balance,omitempty"`     // Set when the event changed the balance
+	Account     *AccountResponse `json:"account,omitempty"`     // Snapshots only
+	Transaction json.RawMessage  `json:"transaction,omitempty"` // Transfer events only
+	CreatedAt   string           `json:"created_at"`
+}
+
+func newStreamEventResponse(event *service.StreamEvent) StreamEventResponse {
+	response := StreamEventResponse{
+		Sequence:  event.Sequence,
+		Type:      event.Type,
+		AccountID: event.AccountID,
+		CreatedAt: event.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	if event.Balance != nil {
+		balance := event.Balance.String()
+		response.Balance = &balance
+	}
+	if event.Type == domain.EventTransferCompleted || event.Type == domain.EventTransferFailed {
+		response.Transaction = event.Data
+	}
+
+	return response
+}
+
+func newStreamSnapshotResponse(snapshot *service.StreamSnapshot) StreamEventResponse {
+	account := newAccountResponse(snapshot.Account)
+	return StreamEventResponse{
+		Sequence:  snapshot.Sequence,
+		Type:      streamSnapshotEvent,
+		AccountID: snapshot.Account.ID,
+		Balance:   &account.Balance,
+		Account:   &account,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// StreamAccount serves GET /accounts/{account_id}/stream as Server-Sent Events. A new stream
+// starts with a snapshot of the account; one resuming from the Last-Event-ID header, or the
+// last_event_id query parameter, replays the events after it instead. Events follow as they
+// commit, each with its sequence as id.
+func (h *StreamHandler) StreamAccount(w http.ResponseWriter, r *http.Request) {
+	lastEventID := strings.TrimSpace(r.Header.Get(lastEventIDHeader))
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	var sequence int64
+	resume := lastEventID != ""
+	if resume {
+		n, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || n < 0 {
+			writeError(w, errors.NewAppError(errors.InvalidInput, "invalid Last-Event-ID"))
+			return
+		}
+		sequence = n
+	}
+
+	subscription, err := h.streamService.Subscribe(r.Context(), mux.Vars(r)["account_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	defer subscription.Close()
+
+	var snapshot *service.StreamSnapshot
+	if !resume {
+		snapshot, err = h.streamService.Snapshot(r.Context(), subscription.AccountID)
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		sequence = snapshot.Sequence
+	}
+
+	// The stream outlives the server's read and write timeouts, so each write gets a deadline
+	// of its own instead: a client that stops reading is dropped rather than held on to
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(format string, args ...interface{}) bool {
+		rc.SetWriteDeadline(time.Now().Add(h.writeTimeout))
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	sendEvent := func(response StreamEventResponse) bool {
+		data, err := json.Marshal(response)
+		if err != nil {
+			return false
+		}
+		return send("id: %d\nevent: %s\ndata: %s\n\n", response.Sequence, response.Type, data)
+	}
+
+	if snapshot != nil {
+		if !sendEvent(newStreamSnapshotResponse(snapshot)) {
+			return
+		}
+	} else if rc.Flush() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for pending := true; ; {
+		// Send everything committed since the last event sent. The subscription was taken before
+		// the first read, so an event committing meanwhile wakes the stream again.
+		for pending {
+			events, err := h.streamService.ReadEvents(r.Context(), subscription.AccountID, sequence)
+			if err != nil {
+				// The client reconnects with the last id it received
+				return
+			}
+			for _, event := range events {
+				if !sendEvent(newStreamEventResponse(event)) {
+					return
+				}
+				sequence = event.Sequence
+			}
+			pending = len(events) == service.StreamBatchSize
+		}
+
+		select {
+		case <-subscription.Wake():
+			pending = true
+		case <-heartbeat.C:
+			if !send(": keep-alive\n\n") {
+				return
+			}
+		case <-subscription.Done():
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -1,23 +1,36 @@
 package handler
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"internal-transfers/internal/domain"
 	"internal-transfers/internal/errors"
 	"internal-transfers/internal/service"
 
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/shopspring/decimal"
 )
 
 type TransactionHandler struct {
 	transactionService *service.TransactionService
+	scheduledService   *service.ScheduledTransferService
 }
 
-func NewTransactionHandler(transactionService *service.TransactionService) *TransactionHandler {
+func NewTransactionHandler(
+	transactionService *service.TransactionService,
+	scheduledService *service.ScheduledTransferService,
+) *TransactionHandler {
 	return &TransactionHandler{
 		transactionService: transactionService,
+		scheduledService:   scheduledService,
 	}
 }
 
@@ -25,13 +38,26 @@ type TransferRequest struct {
 	SourceAccountID      json.Number `json:"source_account_id"`      // Use json.Number
 	DestinationAccountID json.Number `json:"destination_account_id"` // Use json.Number
 	Amount               string      `json:"amount"`
+	Currency             string      `json:"currency,omitempty"` // ISO 4217; rejected unless it matches the source account
+	Convert              bool        `json:"convert,omitempty"`  // Convert at the market rate when the accounts' currencies differ
+	QuoteID              string      `json:"quote_id,omitempty"` // Convert at the rate locked by POST /fx/quotes
 	IdempotencyKey       string      `json:"idempotency_key,omitempty"`
+	ExecuteAt            *time.Time  `json:"execute_at,omitempty"` // RFC 3339; schedules the transfer instead of running it now
 }
 
 type TransferResponse struct {
-	TransactionID  string  `json:"transaction_id"`
-	Status         string  `json:"status"`
-	IdempotencyKey *string `json:"idempotency_key,omitempty"`
+	TransactionID       string  `json:"transaction_id"`
+	Status              string  `json:"status"`
+	Amount              string  `json:"amount"` // Gross amount debited from the source
+	Currency            string  `json:"currency"`
+	Fee                 string  `json:"fee"`
+	NetAmount           string  `json:"net_amount"` // Amount less the fee, before any conversion
+	FeeAccountID        *int64  `json:"fee_account_id,omitempty"`
+	DestinationAmount   *string `json:"destination_amount,omitempty"` // Set on FX transfers
+	DestinationCurrency *string `json:"destination_currency,omitempty"`
+	FXRate              *string `json:"fx_rate,omitempty"`
+	IdempotencyKey      *string `json:"idempotency_key,omitempty"`
+	InitiatedBy         *string `json:"initiated_by,omitempty"` // Authenticated principal that created it
 }
 
 func (h *TransactionHandler) Transfer(w http.ResponseWriter, r *http.Request) {
@@ -47,43 +73,465 @@ func (h *TransactionHandler) Transfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse optional idempotency key
-	var idempotencyKey *uuid.UUID
-	if req.IdempotencyKey != "" {
-		key, err := uuid.Parse(req.IdempotencyKey)
+	// Resolve optional idempotency key (header or body)
+	key, appErr := idempotencyKey(r, req.IdempotencyKey)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	// Future-dated transfers are stored and run later by the scheduler
+	if req.ExecuteAt != nil {
+		if req.Convert || req.QuoteID != "" {
+			writeError(w, errors.NewAppError(errors.InvalidInput, "scheduled transfers cannot convert currencies"))
+			return
+		}
+
+		scheduled, err := h.scheduledService.ScheduleTransfer(r.Context(), &service.ScheduleTransferRequest{
+			SourceAccountID:      req.SourceAccountID.String(),
+			DestinationAccountID: req.DestinationAccountID.String(),
+			Amount:               amount,
+			ExecuteAt:            *req.ExecuteAt,
+			Currency:             req.Currency,
+			IdempotencyKey:       key,
+			ClientID:             clientID(r),
+		})
 		if err != nil {
-			writeError(w, errors.NewAppError(errors.InvalidInput, "invalid idempotency_key format").WithDetails(err.Error()))
+			writeServiceError(w, r, err)
 			return
 		}
-		idempotencyKey = &key
+
+		if scheduled.Replayed {
+			w.Header().Set("Idempotent-Replayed", "true")
+		}
+
+		writeJSON(w, http.StatusAccepted, newScheduledTransferResponse(scheduled))
+		return
 	}
 
 	transferReq := &service.TransferRequest{
 		SourceAccountID:      req.SourceAccountID.String(),      // Convert to string
 		DestinationAccountID: req.DestinationAccountID.String(), // Convert to string
 		Amount:               amount,
-		IdempotencyKey:       idempotencyKey,
+		Currency:             req.Currency,
+		Convert:              req.Convert,
+		QuoteID:              req.QuoteID,
+		IdempotencyKey:       key,
+		ClientID:             clientID(r),
 	}
 
-	transaction, err := h.transactionService.Transfer(transferReq)
+	transaction, err := h.transactionService.Transfer(r.Context(), transferReq)
 	if err != nil {
-		if appErr, ok := err.(*errors.AppError); ok {
-			writeError(w, appErr)
-		} else {
-			writeError(w, errors.NewAppError(errors.InternalError, "an unexpected error occurred").WithDetails(err.Error()))
-		}
+		writeServiceError(w, r, err)
 		return
 	}
 
 	// Build response with optional idempotency key
 	response := TransferResponse{
-		TransactionID: transaction.ID.String(),
-		Status:        transaction.Status,
+		TransactionID:       transaction.ID.String(),
+		Status:              transaction.Status,
+		Amount:              transaction.Amount.String(),
+		Currency:            transaction.Currency,
+		Fee:                 transaction.Fee.String(),
+		NetAmount:           transaction.NetAmount().String(),
+		FeeAccountID:        transaction.FeeAccountID,
+		DestinationCurrency: transaction.DestinationCurrency,
+	}
+
+	response.IdempotencyKey = transaction.IdempotencyKey
+	response.InitiatedBy = transaction.InitiatedBy
+
+	if transaction.DestinationAmount != nil {
+		destinationAmount := transaction.DestinationAmount.String()
+		fxRate := transaction.FXRate.String()
+		response.DestinationAmount = &destinationAmount
+		response.FXRate = &fxRate
+	}
+
+	if transaction.Replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+
+	writeJSON(w, http.StatusCreated, response)
+}
+
+type TransactionDetailResponse struct {
+	TransactionID        string  `json:"transaction_id"`
+	SourceAccountID      int64   `json:"source_account_id"`
+	DestinationAccountID int64   `json:"destination_account_id"`
+	Amount               string  `json:"amount"`
+	Currency             string  `json:"currency"`
+	Fee                  string  `json:"fee"` // Charged by a transfer, refunded by a reversal
+	NetAmount            string  `json:"net_amount"`
+	FeeAccountID         *int64  `json:"fee_account_id,omitempty"`
+	DestinationAmount    *string `json:"destination_amount,omitempty"` // Set on FX transfers
+	DestinationCurrency  *string `json:"destination_currency,omitempty"`
+	FXRate               *string `json:"fx_rate,omitempty"`
+	FXQuoteID            *string `json:"fx_quote_id,omitempty"`
+	Status               string  `json:"status"`
+	FailureReason        *string `json:"failure_reason,omitempty"`
+	IdempotencyKey       *string `json:"idempotency_key,omitempty"`
+	InitiatedBy          *string `json:"initiated_by,omitempty"`
+	BatchID              *string `json:"batch_id,omitempty"`
+	ReversalOf           *string `json:"reversal_of,omitempty"`
+	ReversedAmount       *string `json:"reversed_amount,omitempty"`
+	CreatedAt            string  `json:"created_at"`
+	UpdatedAt            string  `json:"updated_at"`
+}
+
+func newTransactionDetailResponse(tx *domain.Transaction) TransactionDetailResponse {
+	response := TransactionDetailResponse{
+		TransactionID:        tx.ID.String(),
+		SourceAccountID:      tx.SourceAccountID,
+		DestinationAccountID: tx.DestinationAccountID,
+		Amount:               tx.Amount.String(),
+		Currency:             tx.Currency,
+		Fee:                  tx.Fee.String(),
+		NetAmount:            tx.NetAmount().String(),
+		FeeAccountID:         tx.FeeAccountID,
+		DestinationCurrency:  tx.DestinationCurrency,
+		Status:               tx.Status,
+		FailureReason:        tx.FailureReason,
+		IdempotencyKey:       tx.IdempotencyKey,
+		InitiatedBy:          tx.InitiatedBy,
+		CreatedAt:            tx.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt:            tx.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	if tx.DestinationAmount != nil {
+		destinationAmount := tx.DestinationAmount.String()
+		response.DestinationAmount = &destinationAmount
+	}
+
+	if tx.FXRate != nil {
+		fxRate := tx.FXRate.String()
+		response.FXRate = &fxRate
+	}
+
+	if tx.FXQuoteID != nil {
+		fxQuoteID := tx.FXQuoteID.String()
+		response.FXQuoteID = &fxQuoteID
+	}
+
+	if tx.BatchID != nil {
+		batchID := tx.BatchID.String()
+		response.BatchID = &batchID
+	}
+
+	if tx.ReversalOf != nil {
+		reversalOf := tx.ReversalOf.String()
+		response.ReversalOf = &reversalOf
+	}
+
+	if !tx.ReversedAmount.IsZero() {
+		reversedAmount := tx.ReversedAmount.String()
+		response.ReversedAmount = &reversedAmount
+	}
+
+	return response
+}
+
+func (h *TransactionHandler) GetTransaction(w http.ResponseWriter, r *http.Request) {
+	transactionID := mux.Vars(r)["transaction_id"]
+
+	transaction, err := h.transactionService.GetTransaction(r.Context(), transactionID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newTransactionDetailResponse(transaction))
+}
+
+type ReverseTransferRequest struct {
+	Amount         string `json:"amount,omitempty"` // Omit to reverse the remaining amount
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+// ReverseTransfer serves POST /transactions/{transaction_id}/reverse. The body is optional.
+func (h *TransactionHandler) ReverseTransfer(w http.ResponseWriter, r *http.Request) {
+	var req ReverseTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, errors.NewAppError(errors.InvalidInput, "invalid request body").WithDetails(err.Error()))
+		return
+	}
+
+	key, appErr := idempotencyKey(r, req.IdempotencyKey)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	reverseReq := &service.ReverseTransferRequest{
+		TransactionID:  mux.Vars(r)["transaction_id"],
+		IdempotencyKey: key,
+		ClientID:       clientID(r),
+	}
+
+	if req.Amount != "" {
+		amount, err := decimal.NewFromString(req.Amount)
+		if err != nil {
+			writeError(w, errors.NewAppError(errors.InvalidAmount, "invalid amount format").WithDetails(err.Error()))
+			return
+		}
+		reverseReq.Amount = &amount
+	}
+
+	reversal, err := h.transactionService.ReverseTransfer(r.Context(), reverseReq)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	if reversal.Replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+
+	writeJSON(w, http.StatusCreated, newTransactionDetailResponse(reversal))
+}
+
+// FindTransaction serves GET /transactions?idempotency_key=
+func (h *TransactionHandler) FindTransaction(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("idempotency_key")
+	if key == "" {
+		writeError(w, errors.NewAppError(errors.InvalidInput, "idempotency_key query parameter is required"))
+		return
+	}
+
+	transaction, err := h.transactionService.GetTransactionByIdempotencyKey(r.Context(), clientID(r), key)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newTransactionDetailResponse(transaction))
+}
+
+type StatementEntryResponse struct {
+	TransactionID        string  `json:"transaction_id"`
+	SourceAccountID      int64   `json:"source_account_id"`
+	DestinationAccountID int64   `json:"destination_account_id"`
+	Direction            string  `json:"direction"`
+	Amount               string  `json:"amount"`
+	Currency             string  `json:"currency"`
+	Status               string  `json:"status"`
+	FailureReason        *string `json:"failure_reason,omitempty"`
+	CreatedAt            string  `json:"created_at"`
+}
+
+type StatementResponse struct {
+	AccountID    int64                    `json:"account_id"`
+	Transactions []StatementEntryResponse `json:"transactions"`
+	NextCursor   *string                  `json:"next_cursor,omitempty"`
+}
+
+// ListAccountTransactions serves GET /accounts/{account_id}/transactions.
+// Supported query parameters: limit, cursor, from, to (RFC 3339), status and direction.
+func (h *TransactionHandler) ListAccountTransactions(w http.ResponseWriter, r *http.Request) {
+	accountID := mux.Vars(r)["account_id"]
+	query := r.URL.Query()
+
+	req := &service.ListTransactionsRequest{
+		AccountID: accountID,
+		Direction: query.Get("direction"),
+		Status:    query.Get("status"),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			writeError(w, errors.NewAppError(errors.InvalidInput, "invalid limit").WithDetails(err.Error()))
+			return
+		}
+		req.Limit = n
+	}
+
+	for name, target := range map[string]**time.Time{"from": &req.From, "to": &req.To} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				writeError(w, errors.NewAppErrorf(errors.InvalidInput, "invalid %s timestamp", name).WithDetails(err.Error()))
+				return
+			}
+			*target = &t
+		}
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			writeError(w, errors.NewAppError(errors.InvalidInput, "invalid cursor").WithDetails(err.Error()))
+			return
+		}
+		req.After = after
+	}
+
+	page, err := h.transactionService.ListAccountTransactions(r.Context(), req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	id, _ := strconv.ParseInt(accountID, 10, 64)
+	response := StatementResponse{
+		AccountID:    id,
+		Transactions: make([]StatementEntryResponse, 0, len(page.Transactions)),
+	}
+
+	for _, tx := range page.Transactions {
+		// Entries are in the account's own currency: FX transfers are credited the converted amount
+		direction, amount, currency := domain.DirectionCredit, tx.CreditedAmount(), tx.Currency
+		if tx.DestinationCurrency != nil {
+			currency = *tx.DestinationCurrency
+		}
+		if tx.SourceAccountID == id {
+			direction, amount, currency = domain.DirectionDebit, tx.Amount, tx.Currency
+		}
+
+		response.Transactions = append(response.Transactions, StatementEntryResponse{
+			TransactionID:        tx.ID.String(),
+			SourceAccountID:      tx.SourceAccountID,
+			DestinationAccountID: tx.DestinationAccountID,
+			Direction:            direction,
+			Amount:               amount.String(),
+			Currency:             currency,
+			Status:               tx.Status,
+			FailureReason:        tx.FailureReason,
+			CreatedAt:            tx.CreatedAt.UTC().Format(time.RFC3339Nano),
+		})
+	}
+
+	if page.NextCursor != nil {
+		cursor := encodeCursor(page.NextCursor)
+		response.NextCursor = &cursor
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// encodeCursor renders a cursor as opaque URL-safe base64 of "<created_at RFC3339Nano>|<id>"
+func encodeCursor(cursor *domain.TransactionCursor) string {
+	raw := cursor.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + cursor.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(encoded string) (*domain.TransactionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	txID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.TransactionCursor{CreatedAt: t, ID: txID}, nil
+}
+
+type BatchTransferItemRequest struct {
+	SourceAccountID      json.Number `json:"source_account_id"`
+	DestinationAccountID json.Number `json:"destination_account_id"`
+	Amount               string      `json:"amount"`
+}
+
+type BatchTransferRequest struct {
+	Mode           string                     `json:"mode,omitempty"`
+	IdempotencyKey string                     `json:"idempotency_key,omitempty"`
+	Transfers      []BatchTransferItemRequest `json:"transfers"`
+}
+
+type BatchItemResponse struct {
+	Index         int    `json:"index"`
+	TransactionID string `json:"transaction_id"`
+	Status        string `json:"status"`
+	Error         *Error `json:"error,omitempty"`
+}
+
+type BatchTransferResponse struct {
+	BatchID        string              `json:"batch_id"`
+	Mode           string              `json:"mode"`
+	Status         string              `json:"status"`
+	IdempotencyKey *string             `json:"idempotency_key,omitempty"`
+	Results        []BatchItemResponse `json:"results"`
+}
+
+// BatchTransfer serves POST /transactions/batch
+func (h *TransactionHandler) BatchTransfer(w http.ResponseWriter, r *http.Request) {
+	var req BatchTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.NewAppError(errors.InvalidInput, "invalid request body").WithDetails(err.Error()))
+		return
+	}
+
+	key, appErr := idempotencyKey(r, req.IdempotencyKey)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	batchReq := &service.BatchTransferRequest{
+		Mode:           req.Mode,
+		Items:          make([]service.BatchTransferItem, len(req.Transfers)),
+		IdempotencyKey: key,
+		ClientID:       clientID(r),
+	}
+
+	for i, item := range req.Transfers {
+		amount, err := decimal.NewFromString(item.Amount)
+		if err != nil {
+			writeError(w, errors.NewAppError(errors.InvalidAmount, "invalid amount format").WithDetails(fmt.Sprintf("item: %d", i)))
+			return
+		}
+		batchReq.Items[i] = service.BatchTransferItem{
+			SourceAccountID:      item.SourceAccountID.String(),
+			DestinationAccountID: item.DestinationAccountID.String(),
+			Amount:               amount,
+		}
+	}
+
+	result, err := h.transactionService.BatchTransfer(r.Context(), batchReq)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	response := BatchTransferResponse{
+		BatchID:        result.Batch.ID.String(),
+		Mode:           result.Batch.Mode,
+		Status:         result.Batch.Status,
+		IdempotencyKey: result.Batch.IdempotencyKey,
+		Results:        make([]BatchItemResponse, 0, len(result.Items)),
+	}
+
+	for _, item := range result.Items {
+		itemResponse := BatchItemResponse{
+			Index:         item.Index,
+			TransactionID: item.Transaction.ID.String(),
+			Status:        item.Transaction.Status,
+		}
+		if item.Error != nil {
+			itemResponse.Error = &Error{
+				Code:    string(item.Error.Code),
+				Message: item.Error.Message,
+				Details: item.Error.Details,
+			}
+		}
+		response.Results = append(response.Results, itemResponse)
 	}
 
-	if transaction.IdempotencyKey != nil {
-		keyStr := transaction.IdempotencyKey.String()
-		response.IdempotencyKey = &keyStr
+	if result.Replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
 	}
 
 	writeJSON(w, http.StatusCreated, response)
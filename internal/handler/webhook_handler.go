@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+}
+
+func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+type CreateWebhookRequest struct {
+	URL        string      `json:"url"`
+	EventTypes []string    `json:"event_types"`
+	AccountID  json.Number `json:"account_id,omitempty"` // Omitted to receive the events of every account
+}
+
+type UpdateWebhookRequest struct {
+	URL        *string  `json:"url,omitempty"`
+	EventTypes []string `json:"event_types,omitempty"`
+	Active     *bool    `json:"active,omitempty"`
+}
+
+type WebhookResponse struct {
+	WebhookID  string   `json:"webhook_id"`
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	AccountID  *int64   `json:"account_id,omitempty"`
+	Active     bool     `json:"active"`
+	Secret     string   `json:"secret,omitempty"` // Only returned when the webhook is created
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+}
+
+type WebhookListResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+type WebhookAttemptResponse struct {
+	StatusCode  *int    `json:"status_code,omitempty"` // Omitted when no response was received
+	Error       *string `json:"error,omitempty"`
+	DurationMs  int64   `json:"duration_ms"`
+	AttemptedAt string  `json:"attempted_at"`
+}
+
+type WebhookDeliveryResponse struct {
+	DeliveryID     string                   `json:"delivery_id"`
+	WebhookID      string                   `json:"webhook_id"`
+	EventID        string                   `json:"event_id"`
+	EventType      string                   `json:"event_type"`
+	Status         string                   `json:"status"`
+	Attempts       int                      `json:"attempts"`
+	NextAttemptAt  *string                  `json:"next_attempt_at,omitempty"` // Pending deliveries only
+	LastStatusCode *int                     `json:"last_status_code,omitempty"`
+	LastError      *string                  `json:"last_error,omitempty"`
+	DeliveredAt    *string                  `json:"delivered_at,omitempty"`
+	Event          json.RawMessage          `json:"event"` // The body POSTed to the webhook
+	AttemptLog     []WebhookAttemptResponse `json:"attempt_log"`
+	CreatedAt      string                   `json:"created_at"`
+	UpdatedAt      string                   `json:"updated_at"`
+}
+
+type WebhookDeliveryListResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+}
+
+func newWebhookResponse(subscription *domain.WebhookSubscription) WebhookResponse {
+	return WebhookResponse{
+		WebhookID:  subscription.ID.String(),
+		URL:        subscription.URL,
+		EventTypes: subscription.EventTypes,
+		AccountID:  subscription.AccountID,
+		Active:     subscription.Active,
+		CreatedAt:  subscription.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt:  subscription.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+func newWebhookDeliveryResponse(delivery *domain.WebhookDelivery) WebhookDeliveryResponse {
+	response := WebhookDeliveryResponse{
+		DeliveryID:     delivery.ID.String(),
+		WebhookID:      delivery.SubscriptionID.String(),
+		EventID:        delivery.EventID.String(),
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		Event:          delivery.Payload,
+		AttemptLog:     make([]WebhookAttemptResponse, 0, len(delivery.AttemptLog)),
+		CreatedAt:      delivery.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UpdatedAt:      delivery.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	if delivery.Status == domain.WebhookDeliveryPending {
+		nextAttemptAt := delivery.NextAttemptAt.UTC().Format(time.RFC3339Nano)
+		response.NextAttemptAt = &nextAttemptAt
+	}
+	if delivery.DeliveredAt != nil {
+		deliveredAt := delivery.DeliveredAt.UTC().Format(time.RFC3339Nano)
+		response.DeliveredAt = &deliveredAt
+	}
+	for _, attempt := range delivery.AttemptLog {
+		response.AttemptLog = append(response.AttemptLog, WebhookAttemptResponse{
+			StatusCode:  attempt.StatusCode,
+			Error:       attempt.Error,
+			DurationMs:  attempt.Duration.Milliseconds(),
+			AttemptedAt: attempt.AttemptedAt.UTC().Format(time.RFC3339Nano),
+		})
+	}
+
+	return response
+}
+
+// CreateWebhook serves POST /webhooks. The response carries the signing secret, which is not
+// shown again.
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.NewAppError(errors.InvalidInput, "invalid request body").WithDetails(err.Error()))
+		return
+	}
+
+	subscription, err := h.webhookService.CreateWebhook(r.Context(), &service.CreateWebhookRequest{
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+		AccountID:  req.AccountID.String(),
+	})
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	response := newWebhookResponse(subscription)
+	response.Secret = subscription.Secret
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// ListWebhooks serves GET /webhooks, optionally filtered with ?account_id=
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := h.webhookService.ListWebhooks(r.Context(), r.URL.Query().Get("account_id"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	response := WebhookListResponse{Webhooks: make([]WebhookResponse, 0, len(subscriptions))}
+	for _, subscription := range subscriptions {
+		response.Webhooks = append(response.Webhooks, newWebhookResponse(subscription))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// GetWebhook serves GET /webhooks/{webhook_id}
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	subscription, err := h.webhookService.GetWebhook(r.Context(), mux.Vars(r)["webhook_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newWebhookResponse(subscription))
+}
+
+// UpdateWebhook serves PATCH /webhooks/{webhook_id}
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req UpdateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errors.NewAppError(errors.InvalidInput, "invalid request body").WithDetails(err.Error()))
+		return
+	}
+
+	subscription, err := h.webhookService.UpdateWebhook(r.Context(), &service.UpdateWebhookRequest{
+		WebhookID:  mux.Vars(r)["webhook_id"],
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+		Active:     req.Active,
+	})
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newWebhookResponse(subscription))
+}
+
+// DeleteWebhook serves DELETE /webhooks/{webhook_id} and returns the deleted webhook
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	subscription, err := h.webhookService.DeleteWebhook(r.Context(), mux.Vars(r)["webhook_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newWebhookResponse(subscription))
+}
+
+// ListDeliveries serves GET /webhooks/{webhook_id}/deliveries, newest first.
+// Supported query parameters: status and limit.
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := &service.ListWebhookDeliveriesRequest{
+		WebhookID: mux.Vars(r)["webhook_id"],
+		Status:    query.Get("status"),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			writeError(w, errors.NewAppError(errors.InvalidInput, "invalid limit").WithDetails(err.Error()))
+			return
+		}
+		req.Limit = n
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(r.Context(), req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	response := WebhookDeliveryListResponse{Deliveries: make([]WebhookDeliveryResponse, 0, len(deliveries))}
+	for _, delivery := range deliveries {
+		response.Deliveries = append(response.Deliveries, newWebhookDeliveryResponse(delivery))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// GetDelivery serves GET /webhooks/{webhook_id}/deliveries/{delivery_id}
+func (h *WebhookHandler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	delivery, err := h.webhookService.GetDelivery(r.Context(), vars["webhook_id"], vars["delivery_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newWebhookDeliveryResponse(delivery))
+}
+
+// Redeliver serves POST /webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver. The delivery
+// is queued and sent by the dispatcher, so the response shows it pending.
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	delivery, err := h.webhookService.Redeliver(r.Context(), vars["webhook_id"], vars["delivery_id"])
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, newWebhookDeliveryResponse(delivery))
+}
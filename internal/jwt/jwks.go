@@ -0,0 +1,312 @@
+// Package jwt verifies JWT bearer tokens against the JSON Web Key Set of an identity provider
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"internal-transfers/internal/domain"
+)
+
+const (
+	// minRSAKeyBits is the smallest RSA modulus accepted from a key set
+	minRSAKeyBits = 2048
+	// maxKeySetSize bounds the JWKS document read from a URL
+	maxKeySetSize = 1 << 20
+	// httpMinReload is the least time between fetches of a JWKS URL triggered by unknown key IDs
+	httpMinReload = 30 * time.Second
+)
+
+// signingKey is a public key of a key set with the algorithm it verifies
+type signingKey struct {
+	alg    string
+	public crypto.PublicKey
+}
+
+// jsonWebKey is a member of a JWKS document (RFC 7517). RSA keys carry n and e; EC keys crv, x and y.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseKeySet reads the signing keys of a JWKS document by key ID. Encryption keys and key types
+// other than RSA and EC are skipped; the document must hold at least one usable key.
+func parseKeySet(data []byte) (map[string]*signingKey, error) {
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("parse key set: %w", err)
+	}
+
+	keys := make(map[string]*signingKey, len(document.Keys))
+	for _, jwk := range document.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var key *signingKey
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			key, err = parseRSAKey(jwk)
+		case "EC":
+			key, err = parseECKey(jwk)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", jwk.Kid, err)
+		}
+		if _, ok := keys[jwk.Kid]; ok {
+			return nil, fmt.Errorf("duplicate key ID %q", jwk.Kid)
+		}
+		keys[jwk.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("key set holds no RSA or EC signing keys")
+	}
+	return keys, nil
+}
+
+func parseRSAKey(jwk jsonWebKey) (*signingKey, error) {
+	alg := jwk.Alg
+	if alg == "" {
+		alg = "RS256"
+	}
+	if _, ok := rsaHashes[alg]; !ok {
+		return nil, fmt.Errorf("unsupported algorithm %q for an RSA key", alg)
+	}
+
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, fmt.Errorf("invalid exponent")
+	}
+
+	public := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	if public.N.BitLen() < minRSAKeyBits {
+		return nil, fmt.Errorf("RSA modulus must be at least %d bits", minRSAKeyBits)
+	}
+	if public.E < 3 || public.E%2 == 0 {
+		return nil, fmt.Errorf("invalid exponent")
+	}
+
+	return &signingKey{alg: alg, public: public}, nil
+}
+
+func parseECKey(jwk jsonWebKey) (*signingKey, error) {
+	var curve elliptic.Curve
+	var alg string
+	switch jwk.Crv {
+	case "P-256":
+		curve, alg = elliptic.P256(), "ES256"
+	case "P-384":
+		curve, alg = elliptic.P384(), "ES384"
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+	}
+	if jwk.Alg != "" && jwk.Alg != alg {
+		return nil, fmt.Errorf("algorithm %q does not match curve %s", jwk.Alg, jwk.Crv)
+	}
+
+	size := (curve.Params().BitSize + 7) / 8
+	x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+	y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if errX != nil || errY != nil || len(x) != size || len(y) != size {
+		return nil, fmt.Errorf("invalid coordinates")
+	}
+
+	// The uncompressed point encoding is 0x04 followed by both coordinates
+	point := append(append([]byte{4}, x...), y...)
+	public, err := ecdsa.ParseUncompressedPublicKey(curve, point)
+	if err != nil {
+		return nil, fmt.Errorf("invalid point: %w", err)
+	}
+
+	return &signingKey{alg: alg, public: public}, nil
+}
+
+// KeySource serves the signing keys of a JWKS document. The document is cached for the refresh
+// interval, then loaded again so rotated keys are picked up. A key ID missing from the cache
+// loads it early, at most once per minReload, for keys an identity provider starts signing with
+// before the cache expires. A failed load keeps serving the keys loaded before.
+//
+// Loads run outside the lock and one at a time. A due refresh loads in the background while the
+// cached keys keep verifying tokens; only tokens naming a key the cache lacks wait for a load.
+type KeySource struct {
+	load      func(ctx context.Context) ([]byte, error)
+	refresh   time.Duration
+	minReload time.Duration
+
+	mu       sync.Mutex
+	keys     map[string]*signingKey
+	err      error         // Error of the last load, returned while no keys are loaded
+	loadedAt time.Time     // Start of the last load, failed or not
+	loading  chan struct{} // Closed when the load in progress ends; nil when none is
+}
+
+func newKeySource(load func(ctx context.Context) ([]byte, error), refresh, minReload time.Duration) *KeySource {
+	if refresh <= 0 {
+		refresh = 15 * time.Minute
+	}
+	return &KeySource{load: load, refresh: refresh, minReload: minReload}
+}
+
+// NewFileKeySource serves the keys of a JWKS file. The file is read now, so a missing or invalid
+// file fails at startup, and again whenever a token names a key ID it does not hold.
+func NewFileKeySource(path string, refresh time.Duration) (*KeySource, error) {
+	source := newKeySource(func(context.Context) ([]byte, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read key set: %w", err)
+		}
+		return data, nil
+	}, refresh, 0)
+
+	source.mu.Lock()
+	loading := source.begin()
+	source.mu.Unlock()
+	source.run(context.Background(), loading)
+	if source.err != nil {
+		return nil, fmt.Errorf("load key set %s: %w", path, source.err)
+	}
+	return source, nil
+}
+
+// NewHTTPKeySource serves the keys of a JWKS URL, such as the jwks_uri of an OpenID provider.
+// The URL is first fetched by the first token to verify, so the provider may be down at startup.
+func NewHTTPKeySource(url string, timeout, refresh time.Duration) *KeySource {
+	client := &http.Client{Timeout: timeout}
+	return newKeySource(func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("fetch key set: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetch key set: unexpected status %d", resp.StatusCode)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, maxKeySetSize))
+	}, refresh, httpMinReload)
+}
+
+// key returns the key with an ID. A token without a key ID is verified with the only key of a
+// set holding one.
+func (s *KeySource) key(ctx context.Context, kid string) (*signingKey, error) {
+	s.mu.Lock()
+	if s.keys != nil && s.loading == nil && time.Since(s.loadedAt) >= s.refresh {
+		go s.run(context.WithoutCancel(ctx), s.begin())
+	}
+
+	key, ok := s.find(kid)
+	var loading chan struct{}
+	var own bool
+	switch {
+	case ok:
+	case s.loading != nil:
+		// The load in progress may bring the key
+		loading = s.loading
+	case s.keys == nil || time.Since(s.loadedAt) >= s.minReload:
+		loading, own = s.begin(), true
+	}
+	s.mu.Unlock()
+
+	if own {
+		s.run(ctx, loading)
+	} else if loading != nil {
+		select {
+		case <-loading:
+		case <-ctx.Done():
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !ok {
+		key, ok = s.find(kid)
+	}
+	if s.keys == nil {
+		if s.err == nil {
+			return nil, ctx.Err()
+		}
+		return nil, s.err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key ID %q", domain.ErrInvalidToken, kid)
+	}
+	return key, nil
+}
+
+func (s *KeySource) find(kid string) (*signingKey, bool) {
+	if key, ok := s.keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// begin marks a load as in progress. The caller holds the lock and must pass the returned
+// channel to run.
+func (s *KeySource) begin() chan struct{} {
+	loading := make(chan struct{})
+	s.loading, s.loadedAt = loading, time.Now()
+	return loading
+}
+
+// run loads the key set outside the lock, stores the outcome and ends the load begun with loading
+func (s *KeySource) run(ctx context.Context, loading chan struct{}) {
+	keys, err := s.fetch(ctx)
+
+	s.mu.Lock()
+	if err != nil {
+		s.err = err
+	} else {
+		s.keys, s.err = keys, nil
+	}
+	s.loading = nil
+	s.mu.Unlock()
+	close(loading)
+}
+
+func (s *KeySource) fetch(ctx context.Context) (map[string]*signingKey, error) {
+	data, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parseKeySet(data)
+}
@@ -0,0 +1,208 @@
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256" // Registers the hashes used by RS256 and ES256
+	_ "crypto/sha512" // Registers the hashes used by RS384, RS512 and ES384
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"internal-transfers/internal/domain"
+)
+
+// rsaHashes maps the RSA algorithms accepted to their hash
+var rsaHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+}
+
+// ecHashes maps the ECDSA algorithms accepted to their hash
+var ecHashes = map[string]crypto.Hash{
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+}
+
+// VerifierConfig sets the claims a token must carry besides a valid signature
+type VerifierConfig struct {
+	Issuer    string        // Required iss
+	Audience  string        // Required member of aud
+	ClockSkew time.Duration // Leeway on exp, nbf and iat
+}
+
+// Verifier checks the signature, lifetime, issuer and audience of JWTs (RFC 7519) signed with
+// the keys of a KeySource. Only asymmetric algorithms are accepted, and a token must use the
+// algorithm of the key it names, so a public key cannot be turned into an HMAC secret.
+type Verifier struct {
+	keys   *KeySource
+	config VerifierConfig
+}
+
+func NewVerifier(keys *KeySource, config VerifierConfig) *Verifier {
+	return &Verifier{keys: keys, config: config}
+}
+
+type header struct {
+	Alg  string   `json:"alg"`
+	Kid  string   `json:"kid"`
+	Crit []string `json:"crit"`
+}
+
+// Verify returns the claims of a valid token. Invalid tokens fail with domain.ErrInvalidToken;
+// other errors mean the key set could not be loaded.
+func (v *Verifier) Verify(ctx context.Context, token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalid("expected three segments")
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, invalid("header: %v", err)
+	}
+	if len(h.Crit) > 0 {
+		return nil, invalid("unsupported critical headers %v", h.Crit)
+	}
+	if _, ok := rsaHashes[h.Alg]; !ok {
+		if _, ok := ecHashes[h.Alg]; !ok {
+			return nil, invalid("unsupported algorithm %q", h.Alg)
+		}
+	}
+
+	key, err := v.keys.key(ctx, h.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if key.alg != h.Alg {
+		return nil, invalid("algorithm %q does not match key %q", h.Alg, h.Kid)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalid("signature: %v", err)
+	}
+	if !verifySignature(key, parts[0]+"."+parts[1], signature) {
+		return nil, invalid("bad signature")
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, invalid("claims: %v", err)
+	}
+	if err := v.checkClaims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func verifySignature(key *signingKey, signed string, signature []byte) bool {
+	switch public := key.public.(type) {
+	case *rsa.PublicKey:
+		hash := rsaHashes[key.alg]
+		digest := hash.New()
+		digest.Write([]byte(signed))
+		return rsa.VerifyPKCS1v15(public, hash, digest.Sum(nil), signature) == nil
+	case *ecdsa.PublicKey:
+		// JWS ECDSA signatures are r and s as fixed-size big-endian integers (RFC 7518, 3.4)
+		size := (public.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		hash := ecHashes[key.alg]
+		digest := hash.New()
+		digest.Write([]byte(signed))
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(public, digest.Sum(nil), r, s)
+	default:
+		return false
+	}
+}
+
+// checkClaims checks the registered claims at a time. exp is required; nbf and iat are checked
+// when present.
+func (v *Verifier) checkClaims(claims map[string]interface{}, now time.Time) error {
+	exp, ok, err := numericDate(claims, "exp")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return invalid("missing exp")
+	}
+	if now.After(exp.Add(v.config.ClockSkew)) {
+		return invalid("expired at %s", exp.UTC().Format(time.RFC3339))
+	}
+
+	if nbf, ok, err := numericDate(claims, "nbf"); err != nil {
+		return err
+	} else if ok && now.Add(v.config.ClockSkew).Before(nbf) {
+		return invalid("not valid before %s", nbf.UTC().Format(time.RFC3339))
+	}
+	if iat, ok, err := numericDate(claims, "iat"); err != nil {
+		return err
+	} else if ok && now.Add(v.config.ClockSkew).Before(iat) {
+		return invalid("issued in the future")
+	}
+
+	if iss, _ := claims["iss"].(string); iss != v.config.Issuer {
+		return invalid("unexpected issuer %q", iss)
+	}
+
+	var audiences []string
+	switch aud := claims["aud"].(type) {
+	case string:
+		audiences = []string{aud}
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+	if !slices.Contains(audiences, v.config.Audience) {
+		return invalid("audience does not include %q", v.config.Audience)
+	}
+
+	return nil
+}
+
+// numericDate reads a claim holding seconds since the epoch
+func numericDate(claims map[string]interface{}, name string) (time.Time, bool, error) {
+	value, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false, invalid("%s is not a number", name)
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false, invalid("%s is not a number", name)
+	}
+	return time.Unix(int64(seconds), 0), true, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", domain.ErrInvalidToken, fmt.Sprintf(format, args...))
+}
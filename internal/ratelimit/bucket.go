@@ -0,0 +1,111 @@
+// Package ratelimit throttles requests with token buckets and caps the requests in flight per key
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets left idle long enough to refill are dropped
+const sweepInterval = time.Minute
+
+// Rate allows Count requests per period, in bursts of up to Burst
+type Rate struct {
+	Count int
+	Per   time.Duration
+	Burst int
+}
+
+// interval is the time a bucket takes to regain one token
+func (r Rate) interval() time.Duration {
+	return r.Per / time.Duration(r.Count)
+}
+
+// Decision is the outcome of taking a token from a bucket
+type Decision struct {
+	Allowed    bool
+	Limit      int           // Size of the bucket
+	Remaining  int           // Whole tokens left after the request
+	Reset      time.Duration // Until the bucket is full again
+	RetryAfter time.Duration // Until a token is available; zero when the request was allowed
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Buckets keeps a token bucket per key. A bucket starts full, holds up to Burst tokens and
+// regains Count of them per period; each request takes one.
+type Buckets struct {
+	rate Rate
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+func NewBuckets(rate Rate) *Buckets {
+	return &Buckets{rate: rate, buckets: make(map[string]*bucket)}
+}
+
+// Take takes a token from the bucket of a key, if it holds one
+func (b *Buckets) Take(key string, now time.Time) Decision {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if now.Sub(b.swept) >= sweepInterval {
+		b.sweep(now)
+	}
+
+	bk, ok := b.buckets[key]
+	if !ok {
+		bk = &bucket{tokens: float64(b.rate.Burst), updated: now}
+		b.buckets[key] = bk
+	}
+	b.refill(bk, now)
+
+	interval := b.rate.interval()
+	decision := Decision{Limit: b.rate.Burst}
+	if bk.tokens >= 1 {
+		bk.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = time.Duration((1 - bk.tokens) * float64(interval))
+	}
+	decision.Remaining = int(bk.tokens)
+	decision.Reset = time.Duration((float64(b.rate.Burst) - bk.tokens) * float64(interval))
+
+	return decision
+}
+
+// Return gives back a token taken from the bucket of a key, for a request another limit turned away
+func (b *Buckets) Return(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if bk, ok := b.buckets[key]; ok {
+		bk.tokens = min(bk.tokens+1, float64(b.rate.Burst))
+	}
+}
+
+func (b *Buckets) refill(bk *bucket, now time.Time) {
+	if elapsed := now.Sub(bk.updated); elapsed > 0 {
+		bk.tokens += float64(elapsed) / float64(b.rate.interval())
+		if bk.tokens > float64(b.rate.Burst) {
+			bk.tokens = float64(b.rate.Burst)
+		}
+		bk.updated = now
+	}
+}
+
+// sweep drops the buckets that have refilled, which a new bucket would replace as is
+func (b *Buckets) sweep(now time.Time) {
+	for key, bk := range b.buckets {
+		b.refill(bk, now)
+		if bk.tokens >= float64(b.rate.Burst) {
+			delete(b.buckets, key)
+		}
+	}
+	b.swept = now
+}
@@ -0,0 +1,40 @@
+package ratelimit
+
+import "sync"
+
+// InFlight counts the requests in progress per key, up to a maximum
+type InFlight struct {
+	max int
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func NewInFlight(max int) *InFlight {
+	return &InFlight{max: max, counts: make(map[string]int)}
+}
+
+// Acquire counts a request for a key, unless the key already has the maximum in progress. Each
+// successful Acquire must be followed by a Release.
+func (f *InFlight) Acquire(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.counts[key] >= f.max {
+		return false
+	}
+	f.counts[key]++
+	return true
+}
+
+// Release ends a request counted by Acquire
+func (f *InFlight) Release(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.counts[key] <= 1 {
+		delete(f.counts, key)
+		return
+	}
+	f.counts[key]--
+}
@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule limits the requests to a route per client and per source account. A nil rate leaves
+// that key unlimited.
+type Rule struct {
+	Client  *Rate
+	Account *Rate
+}
+
+// ParseRules reads route rules separated by semicolons. Each rule names a route by method and
+// path template, and the rates of its client and account buckets:
+//
+//	POST /transactions=client:100/s:200,account:20/s:40; GET /accounts/{account_id}=client:50/s
+//
+// A rate is a count per s, m or h, optionally followed by the burst, which defaults to the count.
+// "off" sets no rules.
+func ParseRules(spec string) (map[string]Rule, error) {
+	rules := make(map[string]Rule)
+	if strings.TrimSpace(spec) == "off" {
+		return rules, nil
+	}
+	for _, entry := range strings.Split(spec, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		route, limits, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("rate limit rule %q: expected <method> <path>=<limits>", strings.TrimSpace(entry))
+		}
+		key, err := RouteKey(route)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := rules[key]; ok {
+			return nil, fmt.Errorf("duplicate rate limit rule for %s", key)
+		}
+
+		var rule Rule
+		for _, limit := range strings.Split(limits, ",") {
+			name, value, ok := strings.Cut(strings.TrimSpace(limit), ":")
+			if !ok {
+				return nil, fmt.Errorf("rate limit rule for %s: expected client:<rate> or account:<rate>, got %q", key, strings.TrimSpace(limit))
+			}
+			rate, err := ParseRate(value)
+			if err != nil {
+				return nil, fmt.Errorf("rate limit rule for %s: %w", key, err)
+			}
+
+			switch name {
+			case "client":
+				rule.Client = &rate
+			case "account":
+				rule.Account = &rate
+			default:
+				return nil, fmt.Errorf("rate limit rule for %s: unknown key %q, expected client or account", key, name)
+			}
+		}
+		rules[key] = rule
+	}
+	return rules, nil
+}
+
+// RouteKey normalises a route to its method in upper case and path template, e.g. "POST /transactions"
+func RouteKey(route string) (string, error) {
+	fields := strings.Fields(route)
+	if len(fields) != 2 || !strings.HasPrefix(fields[1], "/") {
+		return "", fmt.Errorf("rate limit route %q: expected <method> <path>", strings.TrimSpace(route))
+	}
+	return strings.ToUpper(fields[0]) + " " + fields[1], nil
+}
+
+// ParseRate reads a rate such as "100/s", "600/m" or "100/s:200", where 200 is the burst
+func ParseRate(s string) (Rate, error) {
+	value, burst, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+	count, unit, ok := strings.Cut(value, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("rate %q: expected <count>/<s|m|h>[:<burst>]", s)
+	}
+
+	var rate Rate
+	var err error
+	if rate.Count, err = strconv.Atoi(count); err != nil || rate.Count <= 0 {
+		return Rate{}, fmt.Errorf("rate %q: count must be a positive integer", s)
+	}
+	switch unit {
+	case "s":
+		rate.Per = time.Second
+	case "m":
+		rate.Per = time.Minute
+	case "h":
+		rate.Per = time.Hour
+	default:
+		return Rate{}, fmt.Errorf("rate %q: unit must be s, m or h", s)
+	}
+
+	rate.Burst = rate.Count
+	if hasBurst {
+		if rate.Burst, err = strconv.Atoi(burst); err != nil || rate.Burst <= 0 {
+			return Rate{}, fmt.Errorf("rate %q: burst must be a positive integer", s)
+		}
+	}
+	return rate, nil
+}
@@ -1,10 +1,12 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"log/slog"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 
@@ -24,17 +26,37 @@ func NewAccountRepository(db SQLExecutor, logger *slog.Logger) domain.AccountRep
 	}
 }
 
-func (r *accountRepository) CreateAccount(account *domain.Account) error {
+func (r *accountRepository) CreateAccount(ctx context.Context, account *domain.Account) error {
 	query := `
-		INSERT INTO accounts (id, balance, created_at, updated_at) 
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO accounts (id, balance, currency, status, tier, overdraft_limit, client_id, idempotency_key, request_hash, created_at, updated_at) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11)
 	`
 
+	// Handle optional idempotency key
+	var idempotencyKey interface{}
+	if account.IdempotencyKey != nil {
+		idempotencyKey = *account.IdempotencyKey
+	}
+
+	if account.Status == "" {
+		account.Status = domain.AccountStatusActive
+	}
+	if account.Tier == "" {
+		account.Tier = domain.DefaultAccountTier
+	}
+
 	now := time.Now()
-	_, err := r.db.Exec(
+	_, err := r.db.ExecContext(ctx,
 		query,
 		account.ID,
 		account.Balance.String(),
+		account.Currency,
+		account.Status,
+		account.Tier,
+		account.OverdraftLimit.String(),
+		account.ClientID,
+		idempotencyKey,
+		account.RequestHash,
 		now,
 		now,
 	)
@@ -47,84 +69,490 @@ func (r *accountRepository) CreateAccount(account *domain.Account) error {
 			}
 		}
 		r.logger.Error("Failed to create account", "account_id", account.ID, "error", err)
-		return errors.NewAppError(errors.InternalError, "failed to create account").WithDetails(err.Error())
+		return errors.Wrap(err, errors.InternalError, "failed to create account")
 	}
 
+	account.CreatedAt = now
+	account.UpdatedAt = now
 	r.logger.Info("Account created successfully", "account_id", account.ID)
 	return nil
 }
 
-func (r *accountRepository) GetAccount(id int64) (*domain.Account, error) {
+// accountColumns lists the columns read by scanAccountRow, in scan order
+const accountColumns = `id, balance, held_balance, currency, status, tier, overdraft_limit, client_id, idempotency_key, request_hash, created_at, updated_at`
+
+func (r *accountRepository) GetAccount(ctx context.Context, id int64) (*domain.Account, error) {
 	query := `
-		SELECT id, balance, created_at, updated_at 
+		SELECT ` + accountColumns + ` 
 		FROM accounts WHERE id = $1
 	`
 
-	return r.scanAccount(query, id)
+	return r.scanAccount(ctx, query, id)
 }
 
-func (r *accountRepository) GetAccountForUpdate(id int64) (*domain.Account, error) {
+func (r *accountRepository) GetAccountForUpdate(ctx context.Context, id int64) (*domain.Account, error) {
 	query := `
-		SELECT id, balance, created_at, updated_at 
+		SELECT ` + accountColumns + ` 
 		FROM accounts WHERE id = $1 FOR UPDATE
 	`
 
-	return r.scanAccount(query, id)
+	return r.scanAccount(ctx, query, id)
 }
 
-func (r *accountRepository) scanAccount(query string, id int64) (*domain.Account, error) {
-	var account domain.Account
-	var balanceStr string
+func (r *accountRepository) GetAccountByIdempotencyKey(ctx context.Context, clientID, key string) (*domain.Account, error) {
+	query := `
+		SELECT ` + accountColumns + ` 
+		FROM accounts WHERE client_id = $1 AND idempotency_key = $2
+	`
 
-	err := r.db.QueryRow(query, id).Scan(
-		&account.ID,
-		&balanceStr,
-		&account.CreatedAt,
-		&account.UpdatedAt,
-	)
+	account, err := scanAccountRow(r.db.QueryRowContext(ctx, query, clientID, key))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get account by idempotency key", "idempotency_key", key, "error", err)
+		if appErr, ok := err.(*errors.AppError); ok {
+			return nil, appErr
+		}
+		return nil, errors.Wrap(err, errors.InternalError, "failed to get account")
+	}
+
+	return account, nil
+}
 
+func (r *accountRepository) scanAccount(ctx context.Context, query string, id int64) (*domain.Account, error) {
+	account, err := scanAccountRow(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			r.logger.Warn("Account not found", "account_id", id)
 			return nil, errors.ErrAccountNotFound
 		}
 		r.logger.Error("Failed to get account", "account_id", id, "error", err)
-		return nil, errors.NewAppError(errors.InternalError, "failed to get account").WithDetails(err.Error())
+		if appErr, ok := err.(*errors.AppError); ok {
+			return nil, appErr
+		}
+		return nil, errors.Wrap(err, errors.InternalError, "failed to get account")
 	}
 
-	balance, err := decimal.NewFromString(balanceStr)
+	return account, nil
+}
+
+func scanAccountRow(row rowScanner) (*domain.Account, error) {
+	var account domain.Account
+	var balanceStr, heldBalanceStr, overdraftLimitStr string
+	var idempotencyKey sql.NullString
+	var requestHash sql.NullString
+
+	err := row.Scan(
+		&account.ID,
+		&balanceStr,
+		&heldBalanceStr,
+		&account.Currency,
+		&account.Status,
+		&account.Tier,
+		&overdraftLimitStr,
+		&account.ClientID,
+		&idempotencyKey,
+		&requestHash,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+	)
 	if err != nil {
-		r.logger.Error("Failed to parse balance", "account_id", id, "balance_str", balanceStr, "error", err)
-		return nil, errors.NewAppError(errors.InternalError, "failed to parse balance").WithDetails(err.Error())
+		return nil, err
 	}
 
+	balance, err := decimal.NewFromString(balanceStr)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to parse balance")
+	}
 	account.Balance = balance
+
+	heldBalance, err := decimal.NewFromString(heldBalanceStr)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to parse held balance")
+	}
+	account.HeldBalance = heldBalance
+
+	overdraftLimit, err := decimal.NewFromString(overdraftLimitStr)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to parse overdraft limit")
+	}
+	account.OverdraftLimit = overdraftLimit
+
+	if idempotencyKey.Valid {
+		account.IdempotencyKey = &idempotencyKey.String
+	}
+	account.RequestHash = requestHash.String
+
 	return &account, nil
 }
 
-func (r *accountRepository) UpdateAccountBalance(id int64, newBalance decimal.Decimal) error {
+// PurgeIdempotencyKeys releases up to limit idempotency keys of accounts created before the cutoff
+func (r *accountRepository) PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time, limit int) (int64, error) {
 	query := `
+		UPDATE accounts SET idempotency_key = NULL, request_hash = NULL
+		WHERE id IN (
+			SELECT id FROM accounts
+			WHERE idempotency_key IS NOT NULL AND created_at < $1
+			LIMIT $2
+		)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, createdBefore, limit)
+	if err != nil {
+		r.logger.Error("Failed to purge account idempotency keys", "error", err)
+		return 0, errors.Wrap(err, errors.InternalError, "failed to purge idempotency keys")
+	}
+
+	return result.RowsAffected()
+}
+
+// AdjustHeldBalance reserves (positive delta) or releases (negative delta) funds on an account.
+// Reserving more than the balance plus the overdraft limit violates accounts_held_balance_check and is reported as insufficient balance.
+func (r *accountRepository) AdjustHeldBalance(ctx context.Context, accountID int64, delta decimal.Decimal) error {
+	query := `UPDATE accounts SET held_balance = held_balance + $1, updated_at = $2 WHERE id = $3`
+
+	result, err := r.db.ExecContext(ctx, query, delta.String(), time.Now(), accountID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23514" { // check_violation
+			return errors.ErrInsufficientBalance
+		}
+		r.logger.Error("Failed to adjust held balance", "account_id", accountID, "delta", delta, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to adjust held balance")
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errors.ErrAccountNotFound
+	}
+
+	return nil
+}
+
+func (r *accountRepository) PostLedgerEntry(ctx context.Context, entry *domain.LedgerEntry) error {
+	var delta decimal.Decimal
+	switch entry.EntryType {
+	case domain.LedgerEntryDebit:
+		delta = entry.Amount.Neg()
+	case domain.LedgerEntryCredit:
+		delta = entry.Amount
+	default:
+		return errors.NewAppErrorf(errors.InternalError, "unknown ledger entry type %q", entry.EntryType)
+	}
+
+	updateQuery := `
 		UPDATE accounts 
-		SET balance = $1, updated_at = $2 
+		SET balance = balance + $1, updated_at = $2 
 		WHERE id = $3
+		RETURNING balance
 	`
 
-	result, err := r.db.Exec(query, newBalance.String(), time.Now(), id)
+	now := time.Now()
+	var balanceStr string
+	err := r.db.QueryRowContext(ctx, updateQuery, delta.String(), now, entry.AccountID).Scan(&balanceStr)
 	if err != nil {
-		r.logger.Error("Failed to update account balance", "account_id", id, "error", err)
-		return errors.NewAppError(errors.InternalError, "failed to update account balance").WithDetails(err.Error())
+		if err == sql.ErrNoRows {
+			r.logger.Warn("No account found to post ledger entry", "account_id", entry.AccountID)
+			return errors.ErrAccountNotFound
+		}
+		if pqErr, ok := err.(*pq.Error); ok {
+			if pqErr.Code == "23514" { // check_violation
+				r.logger.Warn("Ledger entry would overdraw account", "account_id", entry.AccountID, "amount", entry.Amount)
+				return errors.ErrInsufficientBalance
+			}
+		}
+		r.logger.Error("Failed to update account balance", "account_id", entry.AccountID, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to update account balance")
+	}
+
+	balanceAfter, err := decimal.NewFromString(balanceStr)
+	if err != nil {
+		return errors.Wrap(err, errors.InternalError, "failed to parse balance")
+	}
+
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	// Handle optional transaction reference (nil for opening balances)
+	var transactionID interface{}
+	if entry.TransactionID != nil {
+		transactionID = *entry.TransactionID
+	}
+
+	insertQuery := `
+		INSERT INTO ledger_entries
+		(id, transaction_id, account_id, entry_type, amount, balance_after, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err = r.db.ExecContext(ctx,
+		insertQuery,
+		entry.ID,
+		transactionID,
+		entry.AccountID,
+		entry.EntryType,
+		entry.Amount.String(),
+		balanceAfter.String(),
+		now,
+	)
 	if err != nil {
-		return errors.NewAppError(errors.InternalError, "failed to get rows affected").WithDetails(err.Error())
+		r.logger.Error("Failed to create ledger entry", "account_id", entry.AccountID, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to create ledger entry")
 	}
 
-	if rowsAffected == 0 {
-		r.logger.Warn("No account found to update", "account_id", id)
+	entry.BalanceAfter = balanceAfter
+	entry.CreatedAt = now
+	r.logger.Info("Ledger entry posted",
+		"account_id", entry.AccountID,
+		"entry_type", entry.EntryType,
+		"amount", entry.Amount,
+		"balance_after", balanceAfter)
+	return nil
+}
+
+func (r *accountRepository) GetLedgerEntries(ctx context.Context, accountID int64) ([]*domain.LedgerEntry, error) {
+	query := `
+		SELECT id, transaction_id, account_id, entry_type, amount, balance_after, created_at
+		FROM ledger_entries WHERE account_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, accountID)
+	if err != nil {
+		r.logger.Error("Failed to get ledger entries", "account_id", accountID, "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to get ledger entries")
+	}
+	defer rows.Close()
+
+	var entries []*domain.LedgerEntry
+	for rows.Next() {
+		var entry domain.LedgerEntry
+		var transactionID uuid.NullUUID
+		var amountStr, balanceAfterStr string
+
+		if err := rows.Scan(
+			&entry.ID,
+			&transactionID,
+			&entry.AccountID,
+			&entry.EntryType,
+			&amountStr,
+			&balanceAfterStr,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, errors.Wrap(err, errors.InternalError, "failed to scan ledger entry")
+		}
+
+		if entry.Amount, err = decimal.NewFromString(amountStr); err != nil {
+			return nil, errors.Wrap(err, errors.InternalError, "failed to parse amount")
+		}
+		if entry.BalanceAfter, err = decimal.NewFromString(balanceAfterStr); err != nil {
+			return nil, errors.Wrap(err, errors.InternalError, "failed to parse balance")
+		}
+		if transactionID.Valid {
+			entry.TransactionID = &transactionID.UUID
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read ledger entries")
+	}
+
+	return entries, nil
+}
+
+func (r *accountRepository) GetBalancesAfter(ctx context.Context, accountID int64, transactionIDs []uuid.UUID) (map[uuid.UUID]decimal.Decimal, error) {
+	query := `
+		SELECT transaction_id, balance_after
+		FROM ledger_entries
+		WHERE account_id = $1 AND transaction_id = ANY($2::uuid[])
+		ORDER BY created_at, id
+	`
+
+	keys := make([]string, len(transactionIDs))
+	for i, id := range transactionIDs {
+		keys[i] = id.String()
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, accountID, pq.Array(keys))
+	if err != nil {
+		r.logger.Error("Failed to get balances after transactions", "account_id", accountID, "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to get balances after transactions")
+	}
+	defer rows.Close()
+
+	balances := make(map[uuid.UUID]decimal.Decimal, len(transactionIDs))
+	for rows.Next() {
+		var transactionID uuid.UUID
+		var balanceStr string
+		if err := rows.Scan(&transactionID, &balanceStr); err != nil {
+			return nil, errors.Wrap(err, errors.InternalError, "failed to scan ledger entry")
+		}
+		balance, err := decimal.NewFromString(balanceStr)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.InternalError, "failed to parse balance")
+		}
+		balances[transactionID] = balance // An account posted to twice keeps the later balance
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read ledger entries")
+	}
+
+	return balances, nil
+}
+
+// GetLedgerBalance rebuilds an account balance from its ledger entries
+func (r *accountRepository) GetLedgerBalance(ctx context.Context, accountID int64) (decimal.Decimal, error) {
+	query := `
+		SELECT COALESCE(SUM(CASE WHEN entry_type = 'credit' THEN amount ELSE -amount END), 0)
+		FROM ledger_entries WHERE account_id = $1
+	`
+
+	var balanceStr string
+	if err := r.db.QueryRowContext(ctx, query, accountID).Scan(&balanceStr); err != nil {
+		r.logger.Error("Failed to get ledger balance", "account_id", accountID, "error", err)
+		return decimal.Zero, errors.Wrap(err, errors.InternalError, "failed to get ledger balance")
+	}
+
+	balance, err := decimal.NewFromString(balanceStr)
+	if err != nil {
+		return decimal.Zero, errors.Wrap(err, errors.InternalError, "failed to parse ledger balance")
+	}
+
+	return balance, nil
+}
+
+func (r *accountRepository) UpdateAccountStatus(ctx context.Context, id int64, status string) error {
+	query := `UPDATE accounts SET status = $1, updated_at = $2 WHERE id = $3`
+
+	result, err := r.db.ExecContext(ctx, query, status, time.Now(), id)
+	if err != nil {
+		r.logger.Error("Failed to update account status", "account_id", id, "status", status, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to update account status")
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
 		return errors.ErrAccountNotFound
 	}
 
-	r.logger.Info("Account balance updated", "account_id", id, "new_balance", newBalance)
+	r.logger.Info("Account status updated", "account_id", id, "status", status)
 	return nil
 }
+
+func (r *accountRepository) UpdateAccountTier(ctx context.Context, id int64, tier string) error {
+	query := `UPDATE accounts SET tier = $1, updated_at = $2 WHERE id = $3`
+
+	result, err := r.db.ExecContext(ctx, query, tier, time.Now(), id)
+	if err != nil {
+		r.logger.Error("Failed to update account tier", "account_id", id, "tier", tier, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to update account tier")
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errors.ErrAccountNotFound
+	}
+
+	r.logger.Info("Account tier updated", "account_id", id, "tier", tier)
+	return nil
+}
+
+// UpdateOverdraftLimit sets how far below zero an account's balance may go. A limit smaller than
+// what the account is already overdrawn by violates accounts_balance_check or
+// accounts_held_balance_check and is reported as an invalid amount.
+func (r *accountRepository) UpdateOverdraftLimit(ctx context.Context, id int64, limit decimal.Decimal) error {
+	query := `UPDATE accounts SET overdraft_limit = $1, updated_at = $2 WHERE id = $3`
+
+	result, err := r.db.ExecContext(ctx, query, limit.String(), time.Now(), id)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23514" { // check_violation
+			return errors.NewAppError(errors.InvalidAmount, "overdraft limit is smaller than the amount already drawn")
+		}
+		r.logger.Error("Failed to update overdraft limit", "account_id", id, "overdraft_limit", limit, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to update overdraft limit")
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errors.ErrAccountNotFound
+	}
+
+	r.logger.Info("Account overdraft limit updated", "account_id", id, "overdraft_limit", limit)
+	return nil
+}
+
+func (r *accountRepository) CreateStatusChange(ctx context.Context, change *domain.AccountStatusChange) error {
+	query := `
+		INSERT INTO account_status_changes
+		(id, account_id, from_status, to_status, reason, client_id, sweep_transaction_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	if change.ID == uuid.Nil {
+		change.ID = uuid.New()
+	}
+
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx,
+		query,
+		change.ID,
+		change.AccountID,
+		change.FromStatus,
+		change.ToStatus,
+		change.Reason,
+		change.ClientID,
+		change.SweepTransactionID,
+		now,
+	)
+	if err != nil {
+		r.logger.Error("Failed to record account status change", "account_id", change.AccountID, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to record account status change")
+	}
+
+	change.CreatedAt = now
+	return nil
+}
+
+// ListStatusChanges returns the status audit trail of an account, oldest first
+func (r *accountRepository) ListStatusChanges(ctx context.Context, accountID int64) ([]*domain.AccountStatusChange, error) {
+	query := `
+		SELECT id, account_id, from_status, to_status, reason, client_id, sweep_transaction_id, created_at
+		FROM account_status_changes WHERE account_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, accountID)
+	if err != nil {
+		r.logger.Error("Failed to list account status changes", "account_id", accountID, "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to list account status changes")
+	}
+	defer rows.Close()
+
+	var changes []*domain.AccountStatusChange
+	for rows.Next() {
+		var change domain.AccountStatusChange
+		var sweepTransactionID uuid.NullUUID
+		if err := rows.Scan(
+			&change.ID,
+			&change.AccountID,
+			&change.FromStatus,
+			&change.ToStatus,
+			&change.Reason,
+			&change.ClientID,
+			&sweepTransactionID,
+			&change.CreatedAt,
+		); err != nil {
+			return nil, errors.Wrap(err, errors.InternalError, "failed to scan account status change")
+		}
+		if sweepTransactionID.Valid {
+			change.SweepTransactionID = &sweepTransactionID.UUID
+		}
+		changes = append(changes, &change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read account status changes")
+	}
+
+	return changes, nil
+}
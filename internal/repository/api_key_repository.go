@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+)
+
+// apiKeyColumns lists the columns read by scanAPIKeyRow, in scan order
+const apiKeyColumns = `id, name, key_prefix, key_hash, scopes, debit_account_ids, revoked_at, created_at, updated_at`
+
+type apiKeyRepository struct {
+	db     SQLExecutor
+	logger *slog.Logger
+}
+
+func NewAPIKeyRepository(db SQLExecutor, logger *slog.Logger) domain.APIKeyRepository {
+	return &apiKeyRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *apiKeyRepository) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	query := `
+		INSERT INTO api_keys (id, name, key_prefix, key_hash, scopes, debit_account_ids, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING created_at, updated_at
+	`
+
+	if key.ID == uuid.Nil {
+		key.ID = uuid.New()
+	}
+
+	err := r.db.QueryRowContext(ctx,
+		query,
+		key.ID,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		pq.Array(key.Scopes),
+		pq.Array(key.DebitAccountIDs),
+		time.Now(),
+	).Scan(&key.CreatedAt, &key.UpdatedAt)
+	if err != nil {
+		r.logger.Error("Failed to create api key", "name", key.Name, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to create api key")
+	}
+
+	r.logger.Info("API key created", "api_key_id", key.ID, "name", key.Name, "scopes", key.Scopes)
+	return nil
+}
+
+func (r *apiKeyRepository) GetAPIKey(ctx context.Context, id uuid.UUID) (*domain.APIKey, error) {
+	return r.getAPIKey(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, id)
+}
+
+func (r *apiKeyRepository) GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	return r.getAPIKey(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`, keyHash)
+}
+
+func (r *apiKeyRepository) getAPIKey(ctx context.Context, query string, arg interface{}) (*domain.APIKey, error) {
+	key, err := scanAPIKeyRow(r.db.QueryRowContext(ctx, query, arg))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get api key", "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to get api key")
+	}
+
+	return key, nil
+}
+
+func (r *apiKeyRepository) ListAPIKeys(ctx context.Context) ([]*domain.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY created_at DESC, id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		r.logger.Error("Failed to list api keys", "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to list api keys")
+	}
+	defer rows.Close()
+
+	var keys []*domain.APIKey
+	for rows.Next() {
+		key, err := scanAPIKeyRow(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.InternalError, "failed to scan api key")
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read api keys")
+	}
+
+	return keys, nil
+}
+
+func (r *apiKeyRepository) RevokeAPIKey(ctx context.Context, id uuid.UUID, revokedAt time.Time) (*domain.APIKey, error) {
+	query := `
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $2)
+		WHERE id = $1
+		RETURNING ` + apiKeyColumns
+
+	key, err := scanAPIKeyRow(r.db.QueryRowContext(ctx, query, id, revokedAt))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to revoke api key", "api_key_id", id, "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to revoke api key")
+	}
+
+	r.logger.Info("API key revoked", "api_key_id", id)
+	return key, nil
+}
+
+func scanAPIKeyRow(row rowScanner) (*domain.APIKey, error) {
+	var key domain.APIKey
+	var revokedAt sql.NullTime
+
+	err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		pq.Array(&key.Scopes),
+		pq.Array(&key.DebitAccountIDs),
+		&revokedAt,
+		&key.CreatedAt,
+		&key.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	return &key, nil
+}
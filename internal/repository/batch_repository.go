@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+)
+
+type batchRepository struct {
+	db     SQLExecutor
+	logger *slog.Logger
+}
+
+func NewBatchRepository(db SQLExecutor, logger *slog.Logger) domain.BatchRepository {
+	return &batchRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *batchRepository) CreateBatch(ctx context.Context, batch *domain.TransactionBatch) error {
+	query := `
+		INSERT INTO transaction_batches
+		(id, client_id, idempotency_key, request_hash, mode, status, item_count, failure_reason, failed_item, created_at, updated_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx,
+		query,
+		batch.ID,
+		batch.ClientID,
+		batch.IdempotencyKey,
+		batch.RequestHash,
+		batch.Mode,
+		batch.Status,
+		batch.ItemCount,
+		batch.FailureReason,
+		batch.FailedItem,
+		now,
+		now,
+	)
+
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			if pqErr.Code == "23505" { // unique_violation
+				r.logger.Warn("Duplicate batch idempotency key", "client_id", batch.ClientID, "idempotency_key", batch.IdempotencyKey)
+				return errors.ErrDuplicateTransaction
+			}
+		}
+		r.logger.Error("Failed to create transaction batch", "batch_id", batch.ID, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to create transaction batch")
+	}
+
+	batch.CreatedAt = now
+	batch.UpdatedAt = now
+	r.logger.Info("Transaction batch created", "batch_id", batch.ID, "status", batch.Status, "items", batch.ItemCount)
+	return nil
+}
+
+func (r *batchRepository) GetBatchByIdempotencyKey(ctx context.Context, clientID, key string) (*domain.TransactionBatch, error) {
+	query := `
+		SELECT id, client_id, idempotency_key, request_hash, mode, status, item_count, failure_reason, failed_item, created_at, updated_at
+		FROM transaction_batches WHERE client_id = $1 AND idempotency_key = $2
+	`
+
+	var batch domain.TransactionBatch
+	var idempotencyKey, requestHash, failureReason sql.NullString
+	var failedItem sql.NullInt32
+
+	err := r.db.QueryRowContext(ctx, query, clientID, key).Scan(
+		&batch.ID,
+		&batch.ClientID,
+		&idempotencyKey,
+		&requestHash,
+		&batch.Mode,
+		&batch.Status,
+		&batch.ItemCount,
+		&failureReason,
+		&failedItem,
+		&batch.CreatedAt,
+		&batch.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get transaction batch", "idempotency_key", key, "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to get transaction batch")
+	}
+
+	if idempotencyKey.Valid {
+		batch.IdempotencyKey = &idempotencyKey.String
+	}
+	batch.RequestHash = requestHash.String
+	if failureReason.Valid {
+		batch.FailureReason = &failureReason.String
+	}
+	if failedItem.Valid {
+		item := int(failedItem.Int32)
+		batch.FailedItem = &item
+	}
+
+	return &batch, nil
+}
+
+func (r *batchRepository) ListBatchTransactions(ctx context.Context, batchID uuid.UUID) ([]*domain.Transaction, error) {
+	query := `
+		SELECT ` + transactionColumns + `
+		FROM transactions WHERE batch_id = $1
+		ORDER BY batch_item
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, batchID)
+	if err != nil {
+		r.logger.Error("Failed to list batch transactions", "batch_id", batchID, "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to list batch transactions")
+	}
+	defer rows.Close()
+
+	var transactions []*domain.Transaction
+	for rows.Next() {
+		transaction, err := scanTransactionRow(rows)
+		if err != nil {
+			if appErr, ok := err.(*errors.AppError); ok {
+				return nil, appErr
+			}
+			return nil, errors.Wrap(err, errors.InternalError, "failed to scan transaction")
+		}
+		transactions = append(transactions, transaction)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read batch transactions")
+	}
+
+	return transactions, nil
+}
+
+// PurgeIdempotencyKeys releases up to limit batch idempotency keys created before the cutoff
+func (r *batchRepository) PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time, limit int) (int64, error) {
+	query := `
+		UPDATE transaction_batches SET idempotency_key = NULL, request_hash = NULL
+		WHERE id IN (
+			SELECT id FROM transaction_batches
+			WHERE idempotency_key IS NOT NULL AND created_at < $1
+			LIMIT $2
+		)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, createdBefore, limit)
+	if err != nil {
+		r.logger.Error("Failed to purge batch idempotency keys", "error", err)
+		return 0, errors.Wrap(err, errors.InternalError, "failed to purge idempotency keys")
+	}
+
+	return result.RowsAffected()
+}
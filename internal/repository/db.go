@@ -1,20 +1,21 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 )
 
 // SQLExecutor represents both sql.DB and sql.Tx
 type SQLExecutor interface {
-	Exec(query string, args ...interface{}) (sql.Result, error)
-	Query(query string, args ...interface{}) (*sql.Rows, error)
-	QueryRow(query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
 // DB represents a database that can begin transactions
 type DB interface {
 	SQLExecutor
-	Begin() (*sql.Tx, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
 }
 
 // Ensure sql.DB implements DB interface
@@ -25,14 +26,14 @@ type TxWrapper struct {
 	*sql.Tx
 }
 
-func (t *TxWrapper) Exec(query string, args ...interface{}) (sql.Result, error) {
-	return t.Tx.Exec(query, args...)
+func (t *TxWrapper) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.ExecContext(ctx, query, args...)
 }
 
-func (t *TxWrapper) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	return t.Tx.Query(query, args...)
+func (t *TxWrapper) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.QueryContext(ctx, query, args...)
 }
 
-func (t *TxWrapper) QueryRow(query string, args ...interface{}) *sql.Row {
-	return t.Tx.QueryRow(query, args...)
+func (t *TxWrapper) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRowContext(ctx, query, args...)
 }
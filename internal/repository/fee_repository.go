@@ -0,0 +1,285 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+)
+
+type feeRepository struct {
+	db     SQLExecutor
+	logger *slog.Logger
+}
+
+func NewFeeRepository(db SQLExecutor, logger *slog.Logger) domain.FeeRepository {
+	return &feeRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// UpsertSchedule creates a schedule, or replaces the schedule with the same target and currency,
+// tiers included. It must run inside a transaction. The schedule's ID and timestamps are set
+// from the stored row.
+func (r *feeRepository) UpsertSchedule(ctx context.Context, schedule *domain.FeeSchedule) error {
+	query := `
+		INSERT INTO fee_schedules (id, scope, account_id, currency, fee_type, min_fee, max_fee, fee_account_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+		ON CONFLICT ON CONSTRAINT unique_fee_schedule
+		DO UPDATE SET fee_type = EXCLUDED.fee_type, min_fee = EXCLUDED.min_fee, max_fee = EXCLUDED.max_fee,
+			fee_account_id = EXCLUDED.fee_account_id
+		RETURNING id, created_at, updated_at
+	`
+
+	if schedule.ID == uuid.Nil {
+		schedule.ID = uuid.New()
+	}
+
+	err := r.db.QueryRowContext(ctx,
+		query,
+		schedule.ID,
+		schedule.Scope,
+		schedule.AccountID,
+		schedule.Currency,
+		schedule.FeeType,
+		nullDecimal(schedule.MinFee),
+		nullDecimal(schedule.MaxFee),
+		schedule.FeeAccountID,
+		time.Now(),
+	).Scan(&schedule.ID, &schedule.CreatedAt, &schedule.UpdatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" { // foreign_key_violation
+			return errors.ErrAccountNotFound
+		}
+		r.logger.Error("Failed to save fee schedule", "scope", schedule.Scope, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to save fee schedule")
+	}
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM fee_schedule_tiers WHERE schedule_id = $1`, schedule.ID); err != nil {
+		r.logger.Error("Failed to replace fee tiers", "schedule_id", schedule.ID, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to save fee schedule")
+	}
+
+	tierQuery := `
+		INSERT INTO fee_schedule_tiers (schedule_id, position, up_to, amount, percentage)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	for i, tier := range schedule.Tiers {
+		_, err := r.db.ExecContext(ctx, tierQuery, schedule.ID, i, nullDecimal(tier.UpTo), tier.Amount.String(), tier.Percentage.String())
+		if err != nil {
+			r.logger.Error("Failed to save fee tier", "schedule_id", schedule.ID, "position", i, "error", err)
+			return errors.Wrap(err, errors.InternalError, "failed to save fee schedule")
+		}
+	}
+
+	r.logger.Info("Fee schedule saved", "schedule_id", schedule.ID, "scope", schedule.Scope, "fee_type", schedule.FeeType)
+	return nil
+}
+
+// feeScheduleColumns lists the columns read by scanFeeScheduleRow, in scan order
+const feeScheduleColumns = `id, scope, account_id, currency, fee_type, min_fee, max_fee, fee_account_id, created_at, updated_at`
+
+// DeleteSchedule removes a schedule with its tiers and returns it
+func (r *feeRepository) DeleteSchedule(ctx context.Context, id uuid.UUID) (*domain.FeeSchedule, error) {
+	query := `DELETE FROM fee_schedules WHERE id = $1 RETURNING ` + feeScheduleColumns
+
+	// Tiers are deleted with the schedule, so read them first
+	tiers, err := r.loadTiers(ctx, []uuid.UUID{id})
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := scanFeeScheduleRow(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to delete fee schedule", "schedule_id", id, "error", err)
+		if appErr, ok := err.(*errors.AppError); ok {
+			return nil, appErr
+		}
+		return nil, errors.Wrap(err, errors.InternalError, "failed to delete fee schedule")
+	}
+	schedule.Tiers = tiers[id]
+
+	return schedule, nil
+}
+
+func (r *feeRepository) ListSchedules(ctx context.Context) ([]*domain.FeeSchedule, error) {
+	query := `
+		SELECT ` + feeScheduleColumns + `
+		FROM fee_schedules
+		ORDER BY scope, account_id, currency
+	`
+
+	return r.querySchedules(ctx, query)
+}
+
+func (r *feeRepository) GetApplicableSchedules(ctx context.Context, accountID int64, currency string) ([]*domain.FeeSchedule, error) {
+	query := `
+		SELECT ` + feeScheduleColumns + `
+		FROM fee_schedules
+		WHERE (scope = 'account' AND account_id = $1)
+		   OR (scope = 'global' AND (currency IS NULL OR currency = $2))
+	`
+
+	return r.querySchedules(ctx, query, accountID, currency)
+}
+
+func (r *feeRepository) querySchedules(ctx context.Context, query string, args ...interface{}) ([]*domain.FeeSchedule, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("Failed to list fee schedules", "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to list fee schedules")
+	}
+	defer rows.Close()
+
+	var schedules []*domain.FeeSchedule
+	var ids []uuid.UUID
+	for rows.Next() {
+		schedule, err := scanFeeScheduleRow(rows)
+		if err != nil {
+			if appErr, ok := err.(*errors.AppError); ok {
+				return nil, appErr
+			}
+			return nil, errors.Wrap(err, errors.InternalError, "failed to scan fee schedule")
+		}
+		schedules = append(schedules, schedule)
+		ids = append(ids, schedule.ID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read fee schedules")
+	}
+	if len(schedules) == 0 {
+		return schedules, nil
+	}
+
+	tiers, err := r.loadTiers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, schedule := range schedules {
+		schedule.Tiers = tiers[schedule.ID]
+	}
+
+	return schedules, nil
+}
+
+// loadTiers reads the tiers of the given schedules, in position order
+func (r *feeRepository) loadTiers(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]domain.FeeTier, error) {
+	query := `
+		SELECT schedule_id, up_to, amount, percentage
+		FROM fee_schedule_tiers
+		WHERE schedule_id = ANY($1::uuid[])
+		ORDER BY schedule_id, position
+	`
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = id.String()
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(keys))
+	if err != nil {
+		r.logger.Error("Failed to list fee tiers", "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to list fee tiers")
+	}
+	defer rows.Close()
+
+	tiers := make(map[uuid.UUID][]domain.FeeTier, len(ids))
+	for rows.Next() {
+		var scheduleID uuid.UUID
+		var upToStr sql.NullString
+		var amountStr, percentageStr string
+		if err := rows.Scan(&scheduleID, &upToStr, &amountStr, &percentageStr); err != nil {
+			return nil, errors.Wrap(err, errors.InternalError, "failed to scan fee tier")
+		}
+
+		var tier domain.FeeTier
+		if upToStr.Valid {
+			upTo, err := decimal.NewFromString(upToStr.String)
+			if err != nil {
+				return nil, errors.Wrap(err, errors.InternalError, "failed to parse fee tier bound")
+			}
+			tier.UpTo = &upTo
+		}
+		if tier.Amount, err = decimal.NewFromString(amountStr); err != nil {
+			return nil, errors.Wrap(err, errors.InternalError, "failed to parse fee tier amount")
+		}
+		if tier.Percentage, err = decimal.NewFromString(percentageStr); err != nil {
+			return nil, errors.Wrap(err, errors.InternalError, "failed to parse fee tier percentage")
+		}
+		tiers[scheduleID] = append(tiers[scheduleID], tier)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read fee tiers")
+	}
+
+	return tiers, nil
+}
+
+func scanFeeScheduleRow(row rowScanner) (*domain.FeeSchedule, error) {
+	var schedule domain.FeeSchedule
+	var accountID, feeAccountID sql.NullInt64
+	var currency, minFeeStr, maxFeeStr sql.NullString
+
+	err := row.Scan(
+		&schedule.ID,
+		&schedule.Scope,
+		&accountID,
+		&currency,
+		&schedule.FeeType,
+		&minFeeStr,
+		&maxFeeStr,
+		&feeAccountID,
+		&schedule.CreatedAt,
+		&schedule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if accountID.Valid {
+		schedule.AccountID = &accountID.Int64
+	}
+	if currency.Valid {
+		schedule.Currency = &currency.String
+	}
+	if feeAccountID.Valid {
+		schedule.FeeAccountID = &feeAccountID.Int64
+	}
+	if minFeeStr.Valid {
+		minFee, err := decimal.NewFromString(minFeeStr.String)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.InternalError, "failed to parse minimum fee")
+		}
+		schedule.MinFee = &minFee
+	}
+	if maxFeeStr.Valid {
+		maxFee, err := decimal.NewFromString(maxFeeStr.String)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.InternalError, "failed to parse maximum fee")
+		}
+		schedule.MaxFee = &maxFee
+	}
+
+	return &schedule, nil
+}
+
+// nullDecimal renders an optional amount for a nullable column
+func nullDecimal(d *decimal.Decimal) interface{} {
+	if d == nil {
+		return nil
+	}
+	return d.String()
+}
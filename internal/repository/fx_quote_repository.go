@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+)
+
+type fxQuoteRepository struct {
+	db     SQLExecutor
+	logger *slog.Logger
+}
+
+func NewFXQuoteRepository(db SQLExecutor, logger *slog.Logger) domain.FXQuoteRepository {
+	return &fxQuoteRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *fxQuoteRepository) CreateQuote(ctx context.Context, quote *domain.FXQuote) error {
+	query := `
+		INSERT INTO fx_quotes
+		(id, source_currency, destination_currency, rate, client_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx,
+		query,
+		quote.ID,
+		quote.SourceCurrency,
+		quote.DestinationCurrency,
+		quote.Rate.String(),
+		quote.ClientID,
+		quote.ExpiresAt,
+		now,
+	)
+	if err != nil {
+		r.logger.Error("Failed to create fx quote",
+			"source_currency", quote.SourceCurrency,
+			"destination_currency", quote.DestinationCurrency,
+			"error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to create fx quote")
+	}
+
+	quote.CreatedAt = now
+	r.logger.Info("FX quote created", "fx_quote_id", quote.ID, "rate", quote.Rate, "expires_at", quote.ExpiresAt)
+	return nil
+}
+
+// fxQuoteColumns lists the columns read by scanFXQuoteRow, in scan order
+const fxQuoteColumns = `id, source_currency, destination_currency, rate, client_id, expires_at, transaction_id, created_at`
+
+func (r *fxQuoteRepository) GetQuote(ctx context.Context, id uuid.UUID) (*domain.FXQuote, error) {
+	query := `SELECT ` + fxQuoteColumns + ` FROM fx_quotes WHERE id = $1`
+
+	return r.scanFXQuote(ctx, query, id)
+}
+
+// GetQuoteForUpdate reads a quote and locks it, so two transfers cannot use it at once
+func (r *fxQuoteRepository) GetQuoteForUpdate(ctx context.Context, id uuid.UUID) (*domain.FXQuote, error) {
+	query := `SELECT ` + fxQuoteColumns + ` FROM fx_quotes WHERE id = $1 FOR UPDATE`
+
+	return r.scanFXQuote(ctx, query, id)
+}
+
+func (r *fxQuoteRepository) scanFXQuote(ctx context.Context, query string, id uuid.UUID) (*domain.FXQuote, error) {
+	quote, err := scanFXQuoteRow(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get fx quote", "fx_quote_id", id, "error", err)
+		if appErr, ok := err.(*errors.AppError); ok {
+			return nil, appErr
+		}
+		return nil, errors.Wrap(err, errors.InternalError, "failed to get fx quote")
+	}
+
+	return quote, nil
+}
+
+func scanFXQuoteRow(row rowScanner) (*domain.FXQuote, error) {
+	var quote domain.FXQuote
+	var rateStr string
+	var transactionID uuid.NullUUID
+
+	err := row.Scan(
+		&quote.ID,
+		&quote.SourceCurrency,
+		&quote.DestinationCurrency,
+		&rateStr,
+		&quote.ClientID,
+		&quote.ExpiresAt,
+		&transactionID,
+		&quote.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rate, err := decimal.NewFromString(rateStr)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to parse fx rate")
+	}
+	quote.Rate = rate
+
+	if transactionID.Valid {
+		quote.TransactionID = &transactionID.UUID
+	}
+
+	return &quote, nil
+}
+
+func (r *fxQuoteRepository) MarkQuoteUsed(ctx context.Context, id, transactionID uuid.UUID) error {
+	query := `UPDATE fx_quotes SET transaction_id = $1 WHERE id = $2`
+
+	if _, err := r.db.ExecContext(ctx, query, transactionID, id); err != nil {
+		r.logger.Error("Failed to mark fx quote used", "fx_quote_id", id, "transaction_id", transactionID, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to mark fx quote used")
+	}
+
+	return nil
+}
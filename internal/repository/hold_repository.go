@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+)
+
+type holdRepository struct {
+	db     SQLExecutor
+	logger *slog.Logger
+}
+
+func NewHoldRepository(db SQLExecutor, logger *slog.Logger) domain.HoldRepository {
+	return &holdRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *holdRepository) CreateHold(ctx context.Context, hold *domain.Hold) error {
+	query := `
+		INSERT INTO holds
+		(id, source_account_id, destination_account_id, amount, status, client_id, idempotency_key, request_hash, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, $11)
+	`
+
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx,
+		query,
+		hold.ID,
+		hold.SourceAccountID,
+		hold.DestinationAccountID,
+		hold.Amount.String(),
+		hold.Status,
+		hold.ClientID,
+		hold.IdempotencyKey,
+		hold.RequestHash,
+		hold.ExpiresAt,
+		now,
+		now,
+	)
+
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			if pqErr.Code == "23505" { // unique_violation
+				r.logger.Warn("Duplicate hold idempotency key", "client_id", hold.ClientID, "idempotency_key", hold.IdempotencyKey)
+				return errors.ErrDuplicateTransaction
+			}
+		}
+		r.logger.Error("Failed to create hold", "source_account_id", hold.SourceAccountID, "amount", hold.Amount, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to create hold")
+	}
+
+	hold.CreatedAt = now
+	hold.UpdatedAt = now
+	r.logger.Info("Hold created", "hold_id", hold.ID, "amount", hold.Amount, "expires_at", hold.ExpiresAt)
+	return nil
+}
+
+// holdColumns lists the columns read by scanHoldRow, in scan order
+const holdColumns = `id, source_account_id, destination_account_id, amount, status, transaction_id, client_id, idempotency_key, request_hash, expires_at, created_at, updated_at`
+
+func (r *holdRepository) GetHold(ctx context.Context, id uuid.UUID) (*domain.Hold, error) {
+	query := `SELECT ` + holdColumns + ` FROM holds WHERE id = $1`
+
+	return r.scanHold(ctx, query, id)
+}
+
+func (r *holdRepository) GetHoldForUpdate(ctx context.Context, id uuid.UUID) (*domain.Hold, error) {
+	query := `SELECT ` + holdColumns + ` FROM holds WHERE id = $1 FOR UPDATE`
+
+	return r.scanHold(ctx, query, id)
+}
+
+func (r *holdRepository) GetHoldByIdempotencyKey(ctx context.Context, clientID, key string) (*domain.Hold, error) {
+	query := `SELECT ` + holdColumns + ` FROM holds WHERE client_id = $1 AND idempotency_key = $2`
+
+	return r.scanHold(ctx, query, clientID, key)
+}
+
+func (r *holdRepository) scanHold(ctx context.Context, query string, args ...interface{}) (*domain.Hold, error) {
+	hold, err := scanHoldRow(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get hold", "args", args, "error", err)
+		if appErr, ok := err.(*errors.AppError); ok {
+			return nil, appErr
+		}
+		return nil, errors.Wrap(err, errors.InternalError, "failed to get hold")
+	}
+
+	return hold, nil
+}
+
+func scanHoldRow(row rowScanner) (*domain.Hold, error) {
+	var hold domain.Hold
+	var amountStr string
+	var transactionID uuid.NullUUID
+	var idempotencyKey, requestHash sql.NullString
+
+	err := row.Scan(
+		&hold.ID,
+		&hold.SourceAccountID,
+		&hold.DestinationAccountID,
+		&amountStr,
+		&hold.Status,
+		&transactionID,
+		&hold.ClientID,
+		&idempotencyKey,
+		&requestHash,
+		&hold.ExpiresAt,
+		&hold.CreatedAt,
+		&hold.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	amount, err := decimal.NewFromString(amountStr)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to parse amount")
+	}
+	hold.Amount = amount
+
+	if transactionID.Valid {
+		hold.TransactionID = &transactionID.UUID
+	}
+	if idempotencyKey.Valid {
+		hold.IdempotencyKey = &idempotencyKey.String
+	}
+	hold.RequestHash = requestHash.String
+
+	return &hold, nil
+}
+
+func (r *holdRepository) UpdateHoldStatus(ctx context.Context, id uuid.UUID, status string, transactionID *uuid.UUID) error {
+	query := `UPDATE holds SET status = $1, transaction_id = $2, updated_at = $3 WHERE id = $4`
+
+	_, err := r.db.ExecContext(ctx, query, status, transactionID, time.Now(), id)
+	if err != nil {
+		r.logger.Error("Failed to update hold status", "hold_id", id, "status", status, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to update hold status")
+	}
+
+	r.logger.Info("Hold status updated", "hold_id", id, "status", status)
+	return nil
+}
+
+// LockExpiredHolds locks up to limit active holds that expired before now, ordered by source
+// account so their held balances are released in ascending account order. Holds already locked
+// by a capture or void in progress are skipped.
+func (r *holdRepository) LockExpiredHolds(ctx context.Context, now time.Time, limit int) ([]*domain.Hold, error) {
+	query := `
+		SELECT ` + holdColumns + `
+		FROM holds
+		WHERE status = 'active' AND expires_at <= $1
+		ORDER BY source_account_id, id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, now, limit)
+	if err != nil {
+		r.logger.Error("Failed to lock expired holds", "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to lock expired holds")
+	}
+	defer rows.Close()
+
+	var holds []*domain.Hold
+	for rows.Next() {
+		hold, err := scanHoldRow(rows)
+		if err != nil {
+			if appErr, ok := err.(*errors.AppError); ok {
+				return nil, appErr
+			}
+			return nil, errors.Wrap(err, errors.InternalError, "failed to scan hold")
+		}
+		holds = append(holds, hold)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read expired holds")
+	}
+
+	return holds, nil
+}
+
+// PurgeIdempotencyKeys releases up to limit hold idempotency keys created before the cutoff
+func (r *holdRepository) PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time, limit int) (int64, error) {
+	query := `
+		UPDATE holds SET idempotency_key = NULL, request_hash = NULL
+		WHERE id IN (
+			SELECT id FROM holds
+			WHERE idempotency_key IS NOT NULL AND created_at < $1
+			LIMIT $2
+		)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, createdBefore, limit)
+	if err != nil {
+		r.logger.Error("Failed to purge hold idempotency keys", "error", err)
+		return 0, errors.Wrap(err, errors.InternalError, "failed to purge idempotency keys")
+	}
+
+	return result.RowsAffected()
+}
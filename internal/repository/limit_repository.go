@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+)
+
+type limitRepository struct {
+	db     SQLExecutor
+	logger *slog.Logger
+}
+
+func NewLimitRepository(db SQLExecutor, logger *slog.Logger) domain.LimitRepository {
+	return &limitRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// UpsertLimit creates a limit, or updates the amount of the existing limit with the same target,
+// type and currency. The limit's ID and timestamps are set from the stored row.
+func (r *limitRepository) UpsertLimit(ctx context.Context, limit *domain.TransferLimit) error {
+	query := `
+		INSERT INTO transfer_limits (id, scope, tier, account_id, limit_type, currency, amount, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+		ON CONFLICT ON CONSTRAINT unique_transfer_limit
+		DO UPDATE SET amount = EXCLUDED.amount
+		RETURNING id, created_at, updated_at
+	`
+
+	if limit.ID == uuid.Nil {
+		limit.ID = uuid.New()
+	}
+
+	err := r.db.QueryRowContext(ctx,
+		query,
+		limit.ID,
+		limit.Scope,
+		limit.Tier,
+		limit.AccountID,
+		limit.LimitType,
+		limit.Currency,
+		limit.Amount.String(),
+		time.Now(),
+	).Scan(&limit.ID, &limit.CreatedAt, &limit.UpdatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" { // foreign_key_violation
+			return errors.ErrAccountNotFound
+		}
+		r.logger.Error("Failed to save transfer limit", "scope", limit.Scope, "limit_type", limit.LimitType, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to save transfer limit")
+	}
+
+	r.logger.Info("Transfer limit saved", "limit_id", limit.ID, "scope", limit.Scope, "limit_type", limit.LimitType, "amount", limit.Amount)
+	return nil
+}
+
+// limitColumns lists the columns read by scanLimitRow, in scan order
+const limitColumns = `id, scope, tier, account_id, limit_type, currency, amount, created_at, updated_at`
+
+func (r *limitRepository) DeleteLimit(ctx context.Context, id uuid.UUID) (*domain.TransferLimit, error) {
+	query := `DELETE FROM transfer_limits WHERE id = $1 RETURNING ` + limitColumns
+
+	limit, err := scanLimitRow(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to delete transfer limit", "limit_id", id, "error", err)
+		if appErr, ok := err.(*errors.AppError); ok {
+			return nil, appErr
+		}
+		return nil, errors.Wrap(err, errors.InternalError, "failed to delete transfer limit")
+	}
+
+	return limit, nil
+}
+
+func (r *limitRepository) ListLimits(ctx context.Context) ([]*domain.TransferLimit, error) {
+	query := `
+		SELECT ` + limitColumns + `
+		FROM transfer_limits
+		ORDER BY scope, tier, account_id, limit_type, currency
+	`
+
+	return r.queryLimits(ctx, query)
+}
+
+func (r *limitRepository) GetApplicableLimits(ctx context.Context, accountID int64, tier, currency string) ([]*domain.TransferLimit, error) {
+	query := `
+		SELECT ` + limitColumns + `
+		FROM transfer_limits
+		WHERE (scope = 'account' AND account_id = $1)
+		   OR (scope = 'tier' AND tier = $2 AND (currency IS NULL OR currency = $3))
+		   OR (scope = 'global' AND (currency IS NULL OR currency = $3))
+	`
+
+	return r.queryLimits(ctx, query, accountID, tier, currency)
+}
+
+func (r *limitRepository) queryLimits(ctx context.Context, query string, args ...interface{}) ([]*domain.TransferLimit, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("Failed to list transfer limits", "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to list transfer limits")
+	}
+	defer rows.Close()
+
+	var limits []*domain.TransferLimit
+	for rows.Next() {
+		limit, err := scanLimitRow(rows)
+		if err != nil {
+			if appErr, ok := err.(*errors.AppError); ok {
+				return nil, appErr
+			}
+			return nil, errors.Wrap(err, errors.InternalError, "failed to scan transfer limit")
+		}
+		limits = append(limits, limit)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read transfer limits")
+	}
+
+	return limits, nil
+}
+
+func scanLimitRow(row rowScanner) (*domain.TransferLimit, error) {
+	var limit domain.TransferLimit
+	var tier, currency sql.NullString
+	var accountID sql.NullInt64
+	var amountStr string
+
+	err := row.Scan(
+		&limit.ID,
+		&limit.Scope,
+		&tier,
+		&accountID,
+		&limit.LimitType,
+		&currency,
+		&amountStr,
+		&limit.CreatedAt,
+		&limit.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	amount, err := decimal.NewFromString(amountStr)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to parse limit amount")
+	}
+	limit.Amount = amount
+
+	if tier.Valid {
+		limit.Tier = &tier.String
+	}
+	if accountID.Valid {
+		limit.AccountID = &accountID.Int64
+	}
+	if currency.Valid {
+		limit.Currency = &currency.String
+	}
+
+	return &limit, nil
+}
+
+func (r *limitRepository) GetOutgoingTotal(ctx context.Context, accountID int64, since time.Time) (decimal.Decimal, error) {
+	query := `
+		SELECT COALESCE(SUM(amount), 0) FROM transactions
+		WHERE source_account_id = $1 AND status IN ('completed', 'partially_reversed', 'reversed')
+		  AND reversal_of IS NULL AND created_at >= $2
+	`
+
+	var totalStr string
+	if err := r.db.QueryRowContext(ctx, query, accountID, since).Scan(&totalStr); err != nil {
+		r.logger.Error("Failed to sum outgoing transfers", "account_id", accountID, "error", err)
+		return decimal.Zero, errors.Wrap(err, errors.InternalError, "failed to sum outgoing transfers")
+	}
+
+	total, err := decimal.NewFromString(totalStr)
+	if err != nil {
+		return decimal.Zero, errors.Wrap(err, errors.InternalError, "failed to parse outgoing total")
+	}
+
+	return total, nil
+}
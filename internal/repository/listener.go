@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"internal-transfers/internal/domain"
+)
+
+// listenerPingInterval is how long the listener waits without a notification before checking
+// its connection is still alive
+const listenerPingInterval = 90 * time.Second
+
+// listener implements domain.EventListener with LISTEN on a connection of its own, outside the
+// pool, which lib/pq re-establishes when it drops
+type listener struct {
+	listener      *pq.Listener
+	logger        *slog.Logger
+	notifications chan string
+	done          chan struct{}
+	closeOnce     sync.Once
+	wg            sync.WaitGroup
+}
+
+// NewListener connects to the database and listens on a channel
+func NewListener(connStr, channel string, logger *slog.Logger) (domain.EventListener, error) {
+	pqListener := pq.NewListener(connStr, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			logger.Warn("Notification listener disconnected", "channel", channel, "error", err)
+		case pq.ListenerEventReconnected:
+			logger.Info("Notification listener reconnected", "channel", channel)
+		case pq.ListenerEventConnectionAttemptFailed:
+			logger.Error("Notification listener failed to connect", "channel", channel, "error", err)
+		}
+	})
+	if err := pqListener.Listen(channel); err != nil {
+		pqListener.Close()
+		return nil, err
+	}
+
+	l := &listener{
+		listener:      pqListener,
+		logger:        logger,
+		notifications: make(chan string, 64),
+		done:          make(chan struct{}),
+	}
+
+	l.wg.Add(1)
+	go l.run()
+
+	return l, nil
+}
+
+func (l *listener) Notifications() <-chan string {
+	return l.notifications
+}
+
+func (l *listener) run() {
+	defer l.wg.Done()
+	defer close(l.notifications)
+
+	for {
+		var payload string
+		select {
+		case notification := <-l.listener.Notify:
+			// lib/pq sends nil once it has reconnected
+			if notification != nil {
+				payload = notification.Extra
+			}
+		case <-time.After(listenerPingInterval):
+			// A failed ping makes lib/pq reconnect
+			go l.listener.Ping()
+			continue
+		case <-l.done:
+			return
+		}
+
+		select {
+		case l.notifications <- payload:
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// Close stops listening and closes the connection
+func (l *listener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.done)
+		l.wg.Wait()
+		err = l.listener.Close()
+	})
+	return err
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+)
+
+// outboxRelayLockKey is the advisory lock key held by the running outbox relay
+const outboxRelayLockKey = 0x6f7574626f78 // "outbox"
+
+type outboxRepository struct {
+	db     SQLExecutor
+	logger *slog.Logger
+}
+
+func NewOutboxRepository(db SQLExecutor, logger *slog.Logger) domain.OutboxRepository {
+	return &outboxRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *outboxRepository) Enqueue(ctx context.Context, event *domain.Event) error {
+	query := `
+		INSERT INTO outbox (id, event_type, account_ids, payload, created_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		RETURNING sequence
+	`
+
+	now := time.Now()
+	err := r.db.QueryRowContext(ctx,
+		query,
+		event.ID,
+		event.Type,
+		pq.Array(event.AccountIDs),
+		string(event.Data),
+		now,
+	).Scan(&event.Sequence)
+	if err != nil {
+		r.logger.Error("Failed to enqueue event", "event_id", event.ID, "event_type", event.Type, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to enqueue event")
+	}
+
+	event.CreatedAt = now
+	event.NextAttemptAt = now
+	return nil
+}
+
+func (r *outboxRepository) TryLockRelay(ctx context.Context) (bool, error) {
+	var locked bool
+	if err := r.db.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, outboxRelayLockKey).Scan(&locked); err != nil {
+		r.logger.Error("Failed to lock outbox relay", "error", err)
+		return false, errors.Wrap(err, errors.InternalError, "failed to lock outbox relay")
+	}
+	return locked, nil
+}
+
+func (r *outboxRepository) ListPending(ctx context.Context, afterSequence int64, limit int) ([]*domain.Event, error) {
+	query := `
+		SELECT sequence, id, event_type, account_ids, payload, created_at, attempts, next_attempt_at, last_error, published_at, dead_lettered_at
+		FROM outbox
+		WHERE published_at IS NULL AND dead_lettered_at IS NULL AND sequence > $1
+		ORDER BY sequence
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, afterSequence, limit)
+	if err != nil {
+		r.logger.Error("Failed to list pending events", "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to list pending events")
+	}
+	defer rows.Close()
+
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read pending events")
+	}
+
+	return events, nil
+}
+
+func (r *outboxRepository) MarkPublished(ctx context.Context, sequence int64, publishedAt time.Time) error {
+	query := `UPDATE outbox SET published_at = $2, last_error = NULL WHERE sequence = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, sequence, publishedAt); err != nil {
+		r.logger.Error("Failed to mark event published", "sequence", sequence, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to mark event published")
+	}
+	return nil
+}
+
+func (r *outboxRepository) MarkFailed(ctx context.Context, sequence int64, nextAttemptAt time.Time, lastError string) error {
+	query := `
+		UPDATE outbox SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3
+		WHERE sequence = $1
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, sequence, nextAttemptAt, lastError); err != nil {
+		r.logger.Error("Failed to record event delivery failure", "sequence", sequence, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to record event delivery failure")
+	}
+	return nil
+}
+
+func (r *outboxRepository) MarkDeadLettered(ctx context.Context, sequence int64, deadLetteredAt time.Time, lastError string) error {
+	query := `
+		UPDATE outbox SET attempts = attempts + 1, dead_lettered_at = $2, last_error = $3
+		WHERE sequence = $1
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, sequence, deadLetteredAt, lastError); err != nil {
+		r.logger.Error("Failed to dead-letter event", "sequence", sequence, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to dead-letter event")
+	}
+	return nil
+}
+
+func (r *outboxRepository) PurgePublished(ctx context.Context, publishedBefore time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM outbox
+		WHERE sequence IN (
+			SELECT sequence FROM outbox
+			WHERE published_at IS NOT NULL AND published_at < $1
+			LIMIT $2
+		)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, publishedBefore, limit)
+	if err != nil {
+		r.logger.Error("Failed to purge published events", "error", err)
+		return 0, errors.Wrap(err, errors.InternalError, "failed to purge published events")
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *outboxRepository) ListAccountEvents(ctx context.Context, accountID, afterSequence int64, limit int) ([]*domain.Event, error) {
+	query := `
+		SELECT sequence, id, event_type, account_ids, payload, created_at, attempts, next_attempt_at, last_error, published_at, dead_lettered_at
+		FROM outbox
+		WHERE account_ids @> ARRAY[$1::bigint] AND sequence > $2
+		ORDER BY sequence
+		LIMIT $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, accountID, afterSequence, limit)
+	if err != nil {
+		r.logger.Error("Failed to list account events", "account_id", accountID, "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to list account events")
+	}
+	defer rows.Close()
+
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read account events")
+	}
+
+	return events, nil
+}
+
+func (r *outboxRepository) LatestAccountSequence(ctx context.Context, accountID int64) (int64, error) {
+	query := `SELECT COALESCE(MAX(sequence), 0) FROM outbox WHERE account_ids @> ARRAY[$1::bigint]`
+
+	var sequence int64
+	if err := r.db.QueryRowContext(ctx, query, accountID).Scan(&sequence); err != nil {
+		r.logger.Error("Failed to get latest account event", "account_id", accountID, "error", err)
+		return 0, errors.Wrap(err, errors.InternalError, "failed to get latest account event")
+	}
+	return sequence, nil
+}
+
+func scanEvents(rows *sql.Rows) ([]*domain.Event, error) {
+	var events []*domain.Event
+	for rows.Next() {
+		var event domain.Event
+		var payload []byte
+		var lastError sql.NullString
+		var publishedAt, deadLetteredAt sql.NullTime
+		if err := rows.Scan(
+			&event.Sequence,
+			&event.ID,
+			&event.Type,
+			pq.Array(&event.AccountIDs),
+			&payload,
+			&event.CreatedAt,
+			&event.Attempts,
+			&event.NextAttemptAt,
+			&lastError,
+			&publishedAt,
+			&deadLetteredAt,
+		); err != nil {
+			return nil, err
+		}
+
+		event.Data = payload
+		if lastError.Valid {
+			event.LastError = &lastError.String
+		}
+		if publishedAt.Valid {
+			event.PublishedAt = &publishedAt.Time
+		}
+		if deadLetteredAt.Valid {
+			event.DeadLetteredAt = &deadLetteredAt.Time
+		}
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
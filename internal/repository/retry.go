@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"database/sql"
+	stderrors "errors"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Postgres error codes that indicate the transaction can safely be run again
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// RetryPolicy bounds how often a transaction is re-run after a serialization failure or deadlock
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts including the first; 1 disables retries
+	BaseDelay   time.Duration // Backoff before the first retry, doubled for each further retry
+	MaxDelay    time.Duration // Upper bound for a single backoff
+}
+
+// DefaultRetryPolicy is used when a Store is created with a zero RetryPolicy
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    250 * time.Millisecond,
+}
+
+// TxOptions configures a single WithTransactionOptions call
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	Retry     *RetryPolicy // Nil uses the store's policy
+}
+
+// backoff returns the delay before the given retry (1-based), with full jitter
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay << (retry - 1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(delay))) + 1
+}
+
+// isRetryable reports whether err, or an error it wraps, is a serialization failure or deadlock
+func isRetryable(err error) bool {
+	var pqErr *pq.Error
+	if stderrors.As(err, &pqErr) {
+		return pqErr.Code == serializationFailure || pqErr.Code == deadlockDetected
+	}
+	return false
+}
+
+// TxStats counts transaction retries since the store was created
+type TxStats struct {
+	retries   atomic.Int64
+	exhausted atomic.Int64
+}
+
+// TxStatsSnapshot is a point-in-time copy of TxStats
+type TxStatsSnapshot struct {
+	Retries   int64 `json:"retries"`
+	Exhausted int64 `json:"retries_exhausted"`
+}
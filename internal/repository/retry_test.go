@@ -0,0 +1,49 @@
+package repository
+
+import (
+	stderrors "errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetryable(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err  error
+		want bool
+	}{
+		"serialization failure":         {&pq.Error{Code: serializationFailure}, true},
+		"deadlock":                      {&pq.Error{Code: deadlockDetected}, true},
+		"wrapped serialization failure": {fmt.Errorf("lock account: %w", &pq.Error{Code: serializationFailure}), true},
+		"unique violation":              {&pq.Error{Code: "23505"}, false},
+		"lock timeout":                  {&pq.Error{Code: "55P03"}, false},
+		"other error":                   {stderrors.New("connection reset"), false},
+		"nil":                           {nil, false},
+	} {
+		assert.Equal(t, tc.want, isRetryable(tc.err), name)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+
+	// Full jitter keeps each delay above zero and within the doubled base, capped at MaxDelay
+	for retry, bound := range map[int]time.Duration{
+		1:  10 * time.Millisecond,
+		2:  20 * time.Millisecond,
+		3:  40 * time.Millisecond,
+		4:  50 * time.Millisecond,
+		64: 50 * time.Millisecond,
+	} {
+		for range 100 {
+			delay := policy.backoff(retry)
+			assert.Greater(t, delay, time.Duration(0), "retry %d", retry)
+			assert.LessOrEqual(t, delay, bound, "retry %d", retry)
+		}
+	}
+
+	assert.Zero(t, RetryPolicy{MaxAttempts: 3}.backoff(1))
+}
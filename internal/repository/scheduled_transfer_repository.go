@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+)
+
+type scheduledTransferRepository struct {
+	db     SQLExecutor
+	logger *slog.Logger
+}
+
+func NewScheduledTransferRepository(db SQLExecutor, logger *slog.Logger) domain.ScheduledTransferRepository {
+	return &scheduledTransferRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *scheduledTransferRepository) CreateScheduledTransfer(ctx context.Context, transfer *domain.ScheduledTransfer) error {
+	query := `
+		INSERT INTO scheduled_transfers
+		(id, source_account_id, destination_account_id, amount, currency, execute_at, status, client_id, idempotency_key, request_hash,
+		 initiated_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11, $12, $13)
+	`
+
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx,
+		query,
+		transfer.ID,
+		transfer.SourceAccountID,
+		transfer.DestinationAccountID,
+		transfer.Amount.String(),
+		transfer.Currency,
+		transfer.ExecuteAt,
+		transfer.Status,
+		transfer.ClientID,
+		transfer.IdempotencyKey,
+		transfer.RequestHash,
+		transfer.InitiatedBy,
+		now,
+		now,
+	)
+
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			if pqErr.Code == "23505" { // unique_violation
+				r.logger.Warn("Duplicate scheduled transfer idempotency key", "client_id", transfer.ClientID, "idempotency_key", transfer.IdempotencyKey)
+				return errors.ErrDuplicateTransaction
+			}
+		}
+		r.logger.Error("Failed to create scheduled transfer", "source_account_id", transfer.SourceAccountID, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to create scheduled transfer")
+	}
+
+	transfer.CreatedAt = now
+	transfer.UpdatedAt = now
+	r.logger.Info("Scheduled transfer created", "scheduled_transfer_id", transfer.ID, "execute_at", transfer.ExecuteAt)
+	return nil
+}
+
+// scheduledTransferColumns lists the columns read by scanScheduledTransferRow, in scan order
+const scheduledTransferColumns = `id, source_account_id, destination_account_id, amount, currency, execute_at, status, transaction_id, failure_reason, client_id, idempotency_key, request_hash, initiated_by, created_at, updated_at`
+
+func (r *scheduledTransferRepository) GetScheduledTransfer(ctx context.Context, id uuid.UUID) (*domain.ScheduledTransfer, error) {
+	query := `SELECT ` + scheduledTransferColumns + ` FROM scheduled_transfers WHERE id = $1`
+
+	return r.scanScheduledTransfer(ctx, query, id)
+}
+
+func (r *scheduledTransferRepository) GetScheduledTransferByIdempotencyKey(ctx context.Context, clientID, key string) (*domain.ScheduledTransfer, error) {
+	query := `SELECT ` + scheduledTransferColumns + ` FROM scheduled_transfers WHERE client_id = $1 AND idempotency_key = $2`
+
+	return r.scanScheduledTransfer(ctx, query, clientID, key)
+}
+
+func (r *scheduledTransferRepository) scanScheduledTransfer(ctx context.Context, query string, args ...interface{}) (*domain.ScheduledTransfer, error) {
+	transfer, err := scanScheduledTransferRow(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get scheduled transfer", "args", args, "error", err)
+		if appErr, ok := err.(*errors.AppError); ok {
+			return nil, appErr
+		}
+		return nil, errors.Wrap(err, errors.InternalError, "failed to get scheduled transfer")
+	}
+
+	return transfer, nil
+}
+
+func scanScheduledTransferRow(row rowScanner) (*domain.ScheduledTransfer, error) {
+	var transfer domain.ScheduledTransfer
+	var amountStr string
+	var transactionID uuid.NullUUID
+	var failureReason, idempotencyKey, requestHash, initiatedBy sql.NullString
+
+	err := row.Scan(
+		&transfer.ID,
+		&transfer.SourceAccountID,
+		&transfer.DestinationAccountID,
+		&amountStr,
+		&transfer.Currency,
+		&transfer.ExecuteAt,
+		&transfer.Status,
+		&transactionID,
+		&failureReason,
+		&transfer.ClientID,
+		&idempotencyKey,
+		&requestHash,
+		&initiatedBy,
+		&transfer.CreatedAt,
+		&transfer.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	amount, err := decimal.NewFromString(amountStr)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to parse amount")
+	}
+	transfer.Amount = amount
+
+	if transactionID.Valid {
+		transfer.TransactionID = &transactionID.UUID
+	}
+	if failureReason.Valid {
+		transfer.FailureReason = &failureReason.String
+	}
+	if idempotencyKey.Valid {
+		transfer.IdempotencyKey = &idempotencyKey.String
+	}
+	transfer.RequestHash = requestHash.String
+	if initiatedBy.Valid {
+		transfer.InitiatedBy = &initiatedBy.String
+	}
+
+	return &transfer, nil
+}
+
+func (r *scheduledTransferRepository) CancelScheduledTransfer(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `UPDATE scheduled_transfers SET status = $1 WHERE id = $2 AND status = $3`
+
+	result, err := r.db.ExecContext(ctx, query, domain.ScheduledStatusCancelled, id, domain.ScheduledStatusScheduled)
+	if err != nil {
+		r.logger.Error("Failed to cancel scheduled transfer", "scheduled_transfer_id", id, "error", err)
+		return false, errors.Wrap(err, errors.InternalError, "failed to cancel scheduled transfer")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, errors.InternalError, "failed to cancel scheduled transfer")
+	}
+
+	return rows > 0, nil
+}
+
+// ClaimDueScheduledTransfers claims due transfers in one statement. FOR UPDATE SKIP LOCKED lets
+// several replicas claim concurrently without blocking on, or double-claiming, the same rows.
+func (r *scheduledTransferRepository) ClaimDueScheduledTransfers(ctx context.Context, now, staleBefore time.Time, limit int) ([]*domain.ScheduledTransfer, error) {
+	query := `
+		UPDATE scheduled_transfers SET status = 'running'
+		WHERE id IN (
+			SELECT id FROM scheduled_transfers
+			WHERE (status = 'scheduled' AND execute_at <= $1)
+			   OR (status = 'running' AND updated_at < $2)
+			ORDER BY execute_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + scheduledTransferColumns
+
+	rows, err := r.db.QueryContext(ctx, query, now, staleBefore, limit)
+	if err != nil {
+		r.logger.Error("Failed to claim scheduled transfers", "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to claim scheduled transfers")
+	}
+	defer rows.Close()
+
+	var transfers []*domain.ScheduledTransfer
+	for rows.Next() {
+		transfer, err := scanScheduledTransferRow(rows)
+		if err != nil {
+			if appErr, ok := err.(*errors.AppError); ok {
+				return nil, appErr
+			}
+			return nil, errors.Wrap(err, errors.InternalError, "failed to scan scheduled transfer")
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read scheduled transfers")
+	}
+
+	return transfers, nil
+}
+
+func (r *scheduledTransferRepository) FinishScheduledTransfer(ctx context.Context, id uuid.UUID, status string, transactionID *uuid.UUID, failureReason *string) error {
+	query := `UPDATE scheduled_transfers SET status = $1, transaction_id = $2, failure_reason = $3 WHERE id = $4`
+
+	_, err := r.db.ExecContext(ctx, query, status, transactionID, failureReason, id)
+	if err != nil {
+		r.logger.Error("Failed to update scheduled transfer", "scheduled_transfer_id", id, "status", status, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to update scheduled transfer")
+	}
+
+	r.logger.Info("Scheduled transfer finished", "scheduled_transfer_id", id, "status", status)
+	return nil
+}
+
+// PurgeIdempotencyKeys releases up to limit scheduled transfer idempotency keys created before the cutoff
+func (r *scheduledTransferRepository) PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time, limit int) (int64, error) {
+	query := `
+		UPDATE scheduled_transfers SET idempotency_key = NULL, request_hash = NULL
+		WHERE id IN (
+			SELECT id FROM scheduled_transfers
+			WHERE idempotency_key IS NOT NULL AND created_at < $1
+			LIMIT $2
+		)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, createdBefore, limit)
+	if err != nil {
+		r.logger.Error("Failed to purge scheduled transfer idempotency keys", "error", err)
+		return 0, errors.Wrap(err, errors.InternalError, "failed to purge idempotency keys")
+	}
+
+	return result.RowsAffected()
+}
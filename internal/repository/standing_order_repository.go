@@ -0,0 +1,354 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+)
+
+type standingOrderRepository struct {
+	db     SQLExecutor
+	logger *slog.Logger
+}
+
+func NewStandingOrderRepository(db SQLExecutor, logger *slog.Logger) domain.StandingOrderRepository {
+	return &standingOrderRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *standingOrderRepository) CreateStandingOrder(ctx context.Context, order *domain.StandingOrder) error {
+	query := `
+		INSERT INTO standing_orders
+		(id, source_account_id, destination_account_id, amount, cron_expression, interval_seconds, start_at, end_at, max_runs,
+		 run_count, next_run_at, status, client_id, idempotency_key, request_hash, initiated_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, 0), $7, $8, $9, $10, $11, $12, $13, $14, NULLIF($15, ''), $16, $17, $18)
+	`
+
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx,
+		query,
+		order.ID,
+		order.SourceAccountID,
+		order.DestinationAccountID,
+		order.Amount.String(),
+		order.CronExpression,
+		int64(order.Interval/time.Second),
+		order.StartAt,
+		order.EndAt,
+		order.MaxRuns,
+		order.RunCount,
+		order.NextRunAt,
+		order.Status,
+		order.ClientID,
+		order.IdempotencyKey,
+		order.RequestHash,
+		order.InitiatedBy,
+		now,
+		now,
+	)
+
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			if pqErr.Code == "23505" { // unique_violation
+				r.logger.Warn("Duplicate standing order idempotency key", "client_id", order.ClientID, "idempotency_key", order.IdempotencyKey)
+				return errors.ErrDuplicateTransaction
+			}
+		}
+		r.logger.Error("Failed to create standing order", "source_account_id", order.SourceAccountID, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to create standing order")
+	}
+
+	order.CreatedAt = now
+	order.UpdatedAt = now
+	r.logger.Info("Standing order created", "standing_order_id", order.ID, "next_run_at", order.NextRunAt)
+	return nil
+}
+
+// standingOrderColumns lists the columns read by scanStandingOrderRow, in scan order
+const standingOrderColumns = `id, source_account_id, destination_account_id, amount, cron_expression, interval_seconds, start_at, end_at, max_runs, run_count, next_run_at, status, client_id, idempotency_key, request_hash, initiated_by, created_at, updated_at`
+
+func (r *standingOrderRepository) GetStandingOrder(ctx context.Context, id uuid.UUID) (*domain.StandingOrder, error) {
+	query := `SELECT ` + standingOrderColumns + ` FROM standing_orders WHERE id = $1`
+
+	return r.scanStandingOrder(ctx, query, id)
+}
+
+func (r *standingOrderRepository) GetStandingOrderForUpdate(ctx context.Context, id uuid.UUID) (*domain.StandingOrder, error) {
+	query := `SELECT ` + standingOrderColumns + ` FROM standing_orders WHERE id = $1 FOR UPDATE`
+
+	return r.scanStandingOrder(ctx, query, id)
+}
+
+func (r *standingOrderRepository) GetStandingOrderByIdempotencyKey(ctx context.Context, clientID, key string) (*domain.StandingOrder, error) {
+	query := `SELECT ` + standingOrderColumns + ` FROM standing_orders WHERE client_id = $1 AND idempotency_key = $2`
+
+	return r.scanStandingOrder(ctx, query, clientID, key)
+}
+
+func (r *standingOrderRepository) scanStandingOrder(ctx context.Context, query string, args ...interface{}) (*domain.StandingOrder, error) {
+	order, err := scanStandingOrderRow(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get standing order", "args", args, "error", err)
+		if appErr, ok := err.(*errors.AppError); ok {
+			return nil, appErr
+		}
+		return nil, errors.Wrap(err, errors.InternalError, "failed to get standing order")
+	}
+
+	return order, nil
+}
+
+func scanStandingOrderRow(row rowScanner) (*domain.StandingOrder, error) {
+	var order domain.StandingOrder
+	var amountStr string
+	var cronExpression, idempotencyKey, requestHash, initiatedBy sql.NullString
+	var intervalSeconds, maxRuns sql.NullInt64
+	var endAt, nextRunAt sql.NullTime
+
+	err := row.Scan(
+		&order.ID,
+		&order.SourceAccountID,
+		&order.DestinationAccountID,
+		&amountStr,
+		&cronExpression,
+		&intervalSeconds,
+		&order.StartAt,
+		&endAt,
+		&maxRuns,
+		&order.RunCount,
+		&nextRunAt,
+		&order.Status,
+		&order.ClientID,
+		&idempotencyKey,
+		&requestHash,
+		&initiatedBy,
+		&order.CreatedAt,
+		&order.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	amount, err := decimal.NewFromString(amountStr)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to parse amount")
+	}
+	order.Amount = amount
+
+	order.CronExpression = cronExpression.String
+	order.Interval = time.Duration(intervalSeconds.Int64) * time.Second
+	if endAt.Valid {
+		order.EndAt = &endAt.Time
+	}
+	if maxRuns.Valid {
+		n := int(maxRuns.Int64)
+		order.MaxRuns = &n
+	}
+	if nextRunAt.Valid {
+		order.NextRunAt = &nextRunAt.Time
+	}
+	if idempotencyKey.Valid {
+		order.IdempotencyKey = &idempotencyKey.String
+	}
+	order.RequestHash = requestHash.String
+	if initiatedBy.Valid {
+		order.InitiatedBy = &initiatedBy.String
+	}
+
+	return &order, nil
+}
+
+func (r *standingOrderRepository) ListStandingOrdersByAccount(ctx context.Context, accountID int64) ([]*domain.StandingOrder, error) {
+	query := `SELECT ` + standingOrderColumns + ` FROM standing_orders WHERE source_account_id = $1 ORDER BY created_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, accountID)
+	if err != nil {
+		r.logger.Error("Failed to list standing orders", "account_id", accountID, "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to list standing orders")
+	}
+	defer rows.Close()
+
+	return r.collectStandingOrders(rows)
+}
+
+func (r *standingOrderRepository) collectStandingOrders(rows *sql.Rows) ([]*domain.StandingOrder, error) {
+	orders := []*domain.StandingOrder{}
+	for rows.Next() {
+		order, err := scanStandingOrderRow(rows)
+		if err != nil {
+			if appErr, ok := err.(*errors.AppError); ok {
+				return nil, appErr
+			}
+			return nil, errors.Wrap(err, errors.InternalError, "failed to scan standing order")
+		}
+		orders = append(orders, order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read standing orders")
+	}
+
+	return orders, nil
+}
+
+func (r *standingOrderRepository) UpdateStandingOrder(ctx context.Context, order *domain.StandingOrder) error {
+	query := `
+		UPDATE standing_orders SET amount = $1, end_at = $2, max_runs = $3, next_run_at = $4, status = $5
+		WHERE id = $6
+	`
+
+	_, err := r.db.ExecContext(ctx, query, order.Amount.String(), order.EndAt, order.MaxRuns, order.NextRunAt, order.Status, order.ID)
+	if err != nil {
+		r.logger.Error("Failed to update standing order", "standing_order_id", order.ID, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to update standing order")
+	}
+
+	r.logger.Info("Standing order updated", "standing_order_id", order.ID, "status", order.Status)
+	return nil
+}
+
+// ClaimDueStandingOrders leases due orders in one statement. FOR UPDATE SKIP LOCKED lets several
+// replicas claim concurrently without blocking on, or double-claiming, the same rows.
+func (r *standingOrderRepository) ClaimDueStandingOrders(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*domain.StandingOrder, error) {
+	query := `
+		UPDATE standing_orders SET locked_until = $2
+		WHERE id IN (
+			SELECT id FROM standing_orders
+			WHERE status = 'active' AND next_run_at <= $1
+			  AND (locked_until IS NULL OR locked_until < $1)
+			ORDER BY next_run_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + standingOrderColumns
+
+	rows, err := r.db.QueryContext(ctx, query, now, leaseUntil, limit)
+	if err != nil {
+		r.logger.Error("Failed to claim standing orders", "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to claim standing orders")
+	}
+	defer rows.Close()
+
+	return r.collectStandingOrders(rows)
+}
+
+func (r *standingOrderRepository) AdvanceStandingOrder(ctx context.Context, order *domain.StandingOrder) error {
+	query := `
+		UPDATE standing_orders SET run_count = $1, next_run_at = $2, status = $3, locked_until = NULL
+		WHERE id = $4
+	`
+
+	_, err := r.db.ExecContext(ctx, query, order.RunCount, order.NextRunAt, order.Status, order.ID)
+	if err != nil {
+		r.logger.Error("Failed to advance standing order", "standing_order_id", order.ID, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to advance standing order")
+	}
+
+	return nil
+}
+
+func (r *standingOrderRepository) CreateStandingOrderRun(ctx context.Context, run *domain.StandingOrderRun) error {
+	query := `
+		INSERT INTO standing_order_runs (id, standing_order_id, period, status, transaction_id, failure_reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx, query, run.ID, run.StandingOrderID, run.Period, run.Status, run.TransactionID, run.FailureReason, now)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			if pqErr.Code == "23505" { // unique_violation
+				r.logger.Warn("Standing order period already recorded", "standing_order_id", run.StandingOrderID, "period", run.Period)
+				return errors.ErrDuplicateTransaction
+			}
+		}
+		r.logger.Error("Failed to record standing order run", "standing_order_id", run.StandingOrderID, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to record standing order run")
+	}
+
+	run.CreatedAt = now
+	r.logger.Info("Standing order run recorded", "standing_order_id", run.StandingOrderID, "period", run.Period, "status", run.Status)
+	return nil
+}
+
+func (r *standingOrderRepository) ListStandingOrderRuns(ctx context.Context, orderID uuid.UUID) ([]*domain.StandingOrderRun, error) {
+	query := `
+		SELECT id, standing_order_id, period, status, transaction_id, failure_reason, created_at
+		FROM standing_order_runs
+		WHERE standing_order_id = $1
+		ORDER BY period
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		r.logger.Error("Failed to list standing order runs", "standing_order_id", orderID, "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to list standing order runs")
+	}
+	defer rows.Close()
+
+	runs := []*domain.StandingOrderRun{}
+	for rows.Next() {
+		var run domain.StandingOrderRun
+		var transactionID uuid.NullUUID
+		var failureReason sql.NullString
+
+		if err := rows.Scan(
+			&run.ID,
+			&run.StandingOrderID,
+			&run.Period,
+			&run.Status,
+			&transactionID,
+			&failureReason,
+			&run.CreatedAt,
+		); err != nil {
+			return nil, errors.Wrap(err, errors.InternalError, "failed to scan standing order run")
+		}
+
+		if transactionID.Valid {
+			run.TransactionID = &transactionID.UUID
+		}
+		if failureReason.Valid {
+			run.FailureReason = &failureReason.String
+		}
+		runs = append(runs, &run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read standing order runs")
+	}
+
+	return runs, nil
+}
+
+// PurgeIdempotencyKeys releases up to limit standing order idempotency keys created before the cutoff
+func (r *standingOrderRepository) PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time, limit int) (int64, error) {
+	query := `
+		UPDATE standing_orders SET idempotency_key = NULL, request_hash = NULL
+		WHERE id IN (
+			SELECT id FROM standing_orders
+			WHERE idempotency_key IS NOT NULL AND created_at < $1
+			LIMIT $2
+		)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, createdBefore, limit)
+	if err != nil {
+		r.logger.Error("Failed to purge standing order idempotency keys", "error", err)
+		return 0, errors.Wrap(err, errors.InternalError, "failed to purge idempotency keys")
+	}
+
+	return result.RowsAffected()
+}
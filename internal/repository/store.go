@@ -1,8 +1,10 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"log/slog"
+	"time"
 
 	"internal-transfers/internal/domain"
 	"internal-transfers/internal/errors"
@@ -12,13 +14,28 @@ import (
 type Store struct {
 	executor SQLExecutor
 	logger   *slog.Logger
+	retry    RetryPolicy
+	stats    *TxStats
+	notices  *[]notification // Sent after commit; nil outside a transaction
 }
 
-// NewStore creates a new Store instance
-func NewStore(db *sql.DB, logger *slog.Logger) *Store {
+// notification is a Postgres NOTIFY held back until its transaction commits
+type notification struct {
+	channel string
+	payload string
+}
+
+// NewStore creates a new Store instance. A zero retry policy falls back to DefaultRetryPolicy.
+func NewStore(db *sql.DB, logger *slog.Logger, retry RetryPolicy) *Store {
+	if retry.MaxAttempts <= 0 {
+		retry = DefaultRetryPolicy
+	}
+
 	return &Store{
 		executor: db,
 		logger:   logger,
+		retry:    retry,
+		stats:    &TxStats{},
 	}
 }
 
@@ -32,15 +49,137 @@ func (s *Store) Transaction() domain.TransactionRepository {
 	return NewTransactionRepository(s.executor, s.logger)
 }
 
-// WithTransaction executes a function within a database transaction
-func (s *Store) WithTransaction(fn func(*Store) error) error {
+// Batch returns a BatchRepository using the current executor
+func (s *Store) Batch() domain.BatchRepository {
+	return NewBatchRepository(s.executor, s.logger)
+}
+
+// Hold returns a HoldRepository using the current executor
+func (s *Store) Hold() domain.HoldRepository {
+	return NewHoldRepository(s.executor, s.logger)
+}
+
+// ScheduledTransfer returns a ScheduledTransferRepository using the current executor
+func (s *Store) ScheduledTransfer() domain.ScheduledTransferRepository {
+	return NewScheduledTransferRepository(s.executor, s.logger)
+}
+
+// StandingOrder returns a StandingOrderRepository using the current executor
+func (s *Store) StandingOrder() domain.StandingOrderRepository {
+	return NewStandingOrderRepository(s.executor, s.logger)
+}
+
+// FXQuote returns an FXQuoteRepository using the current executor
+func (s *Store) FXQuote() domain.FXQuoteRepository {
+	return NewFXQuoteRepository(s.executor, s.logger)
+}
+
+// Limit returns a LimitRepository using the current executor
+func (s *Store) Limit() domain.LimitRepository {
+	return NewLimitRepository(s.executor, s.logger)
+}
+
+// Fee returns a FeeRepository using the current executor
+func (s *Store) Fee() domain.FeeRepository {
+	return NewFeeRepository(s.executor, s.logger)
+}
+
+// Outbox returns an OutboxRepository using the current executor
+func (s *Store) Outbox() domain.OutboxRepository {
+	return NewOutboxRepository(s.executor, s.logger)
+}
+
+// Webhook returns a WebhookRepository using the current executor
+func (s *Store) Webhook() domain.WebhookRepository {
+	return NewWebhookRepository(s.executor, s.logger)
+}
+
+// APIKey returns an APIKeyRepository using the current executor
+func (s *Store) APIKey() domain.APIKeyRepository {
+	return NewAPIKeyRepository(s.executor, s.logger)
+}
+
+// NotifyAfterCommit sends a Postgres notification on channel once the current transaction has
+// committed, so listeners only hear about committed changes and never wait on open transactions.
+// Notifications of an attempt that rolls back are dropped. Outside a transaction the
+// notification is sent right away.
+func (s *Store) NotifyAfterCommit(ctx context.Context, channel, payload string) {
+	if s.notices == nil {
+		s.notify(ctx, s.executor, []notification{{channel: channel, payload: payload}})
+		return
+	}
+	*s.notices = append(*s.notices, notification{channel: channel, payload: payload})
+}
+
+// notify sends notifications, logging failures: the change they announce has already committed
+func (s *Store) notify(ctx context.Context, executor SQLExecutor, notices []notification) {
+	// The transaction committed even if ctx was cancelled since
+	ctx = context.WithoutCancel(ctx)
+	for _, notice := range notices {
+		if _, err := executor.ExecContext(ctx, `SELECT pg_notify($1, $2)`, notice.channel, notice.payload); err != nil {
+			s.logger.Error("Failed to send notification", "channel", notice.channel, "error", err)
+		}
+	}
+}
+
+// Stats returns how many transactions have been retried, and how many ran out of attempts
+func (s *Store) Stats() TxStatsSnapshot {
+	return TxStatsSnapshot{
+		Retries:   s.stats.retries.Load(),
+		Exhausted: s.stats.exhausted.Load(),
+	}
+}
+
+// WithTransaction executes a function within a database transaction using default options.
+// The transaction is rolled back if ctx is cancelled before it commits.
+func (s *Store) WithTransaction(ctx context.Context, fn func(*Store) error) error {
+	return s.WithTransactionOptions(ctx, TxOptions{}, fn)
+}
+
+// WithTransactionOptions executes fn within a database transaction, re-running it from the
+// start with exponential backoff when Postgres reports a serialization failure or deadlock.
+// fn may therefore be called more than once and must reset any state it captures.
+func (s *Store) WithTransactionOptions(ctx context.Context, opts TxOptions, fn func(*Store) error) error {
 	// Only sql.DB can begin transactions
 	db, ok := s.executor.(*sql.DB)
 	if !ok {
 		return errors.ErrCannotBeginTransaction
 	}
 
-	tx, err := db.Begin()
+	policy := s.retry
+	if opts.Retry != nil {
+		policy = *opts.Retry
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := s.runTransaction(ctx, db, opts, fn)
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+
+		if attempt >= policy.MaxAttempts {
+			s.stats.exhausted.Add(1)
+			s.logger.Error("Transaction retries exhausted", "attempts", attempt, "error", err)
+			return err
+		}
+
+		delay := policy.backoff(attempt)
+		s.stats.retries.Add(1)
+		s.logger.Warn("Retrying transaction", "attempt", attempt, "backoff", delay, "error", err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+func (s *Store) runTransaction(ctx context.Context, db *sql.DB, opts TxOptions, fn func(*Store) error) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: opts.Isolation,
+		ReadOnly:  opts.ReadOnly,
+	})
 	if err != nil {
 		return err
 	}
@@ -48,6 +187,9 @@ func (s *Store) WithTransaction(fn func(*Store) error) error {
 	txStore := &Store{
 		executor: &TxWrapper{Tx: tx},
 		logger:   s.logger,
+		retry:    s.retry,
+		stats:    s.stats,
+		notices:  &[]notification{},
 	}
 
 	defer func() {
@@ -62,5 +204,10 @@ func (s *Store) WithTransaction(fn func(*Store) error) error {
 		return err
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.notify(ctx, db, *txStore.notices)
+	return nil
 }
@@ -1,8 +1,11 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -25,11 +28,13 @@ func NewTransactionRepository(db SQLExecutor, logger *slog.Logger) domain.Transa
 	}
 }
 
-func (r *transactionRepository) CreateTransaction(tx *domain.Transaction) error {
+func (r *transactionRepository) CreateTransaction(ctx context.Context, tx *domain.Transaction) error {
 	query := `
 		INSERT INTO transactions
-		(id, source_account_id, destination_account_id, amount, idempotency_key, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		(id, source_account_id, destination_account_id, amount, currency, fee_amount, fee_account_id, destination_amount, destination_currency,
+		 fx_rate, fx_quote_id, idempotency_key, client_id, request_hash, status, failure_reason, batch_id, batch_item, reversal_of, initiated_by,
+		 created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NULLIF($14, ''), $15, $16, $17, $18, $19, $20, $21, $22)
 	`
 
 	now := time.Now()
@@ -42,14 +47,37 @@ func (r *transactionRepository) CreateTransaction(tx *domain.Transaction) error
 		idempotencyKey = nil
 	}
 
-	_, err := r.db.Exec(
+	// Conversion fields are only set on FX transfers
+	var destinationAmount, fxRate interface{}
+	if tx.DestinationAmount != nil {
+		destinationAmount = tx.DestinationAmount.String()
+	}
+	if tx.FXRate != nil {
+		fxRate = tx.FXRate.String()
+	}
+
+	_, err := r.db.ExecContext(ctx,
 		query,
 		tx.ID,
 		tx.SourceAccountID,
 		tx.DestinationAccountID,
 		tx.Amount.String(),
+		tx.Currency,
+		tx.Fee.String(),
+		tx.FeeAccountID,
+		destinationAmount,
+		tx.DestinationCurrency,
+		fxRate,
+		tx.FXQuoteID,
 		idempotencyKey,
+		tx.ClientID,
+		tx.RequestHash,
 		tx.Status,
+		tx.FailureReason,
+		tx.BatchID,
+		tx.BatchItem,
+		tx.ReversalOf,
+		tx.InitiatedBy,
 		now,
 		now,
 	)
@@ -59,7 +87,7 @@ func (r *transactionRepository) CreateTransaction(tx *domain.Transaction) error
 			if pqErr.Code == "23505" { // unique_violation
 				// Check if it's idempotency key violation
 				if pqErr.Constraint == "idx_transactions_idempotency_key" {
-					r.logger.Warn("Duplicate idempotency key", "idempotency_key", tx.IdempotencyKey)
+					r.logger.Warn("Duplicate idempotency key", "client_id", tx.ClientID, "idempotency_key", *tx.IdempotencyKey)
 					return errors.ErrDuplicateTransaction
 				}
 			}
@@ -69,7 +97,7 @@ func (r *transactionRepository) CreateTransaction(tx *domain.Transaction) error
 			"destination_account_id", tx.DestinationAccountID,
 			"amount", tx.Amount,
 			"error", err)
-		return errors.NewAppError(errors.InternalError, "failed to create transaction").WithDetails(err.Error())
+		return errors.Wrap(err, errors.InternalError, "failed to create transaction")
 	}
 
 	tx.CreatedAt = now
@@ -78,77 +106,308 @@ func (r *transactionRepository) CreateTransaction(tx *domain.Transaction) error
 	return nil
 }
 
-func (r *transactionRepository) GetTransactionByID(id uuid.UUID) (*domain.Transaction, error) {
+// transactionColumns lists the columns read by scanTransactionRow, in scan order
+const transactionColumns = `id, source_account_id, destination_account_id, amount, currency, fee_amount, fee_account_id, destination_amount, destination_currency, fx_rate, fx_quote_id, idempotency_key, client_id, request_hash, status, failure_reason, batch_id, batch_item, reversal_of, reversed_amount, initiated_by, created_at, updated_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (r *transactionRepository) GetTransactionByID(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
 	query := `
-		SELECT id, source_account_id, destination_account_id, amount, idempotency_key, status, created_at, updated_at
+		SELECT ` + transactionColumns + `
 		FROM transactions WHERE id = $1
 	`
 
-	return r.scanTransaction(query, id)
+	return r.scanTransaction(ctx, query, id)
 }
 
-func (r *transactionRepository) GetTransactionByIDempotencyKey(key uuid.UUID) (*domain.Transaction, error) {
+func (r *transactionRepository) GetTransactionByIDempotencyKey(ctx context.Context, clientID, key string) (*domain.Transaction, error) {
 	query := `
-		SELECT id, source_account_id, destination_account_id, amount, idempotency_key, status, created_at, updated_at
-		FROM transactions WHERE idempotency_key = $1
+		SELECT ` + transactionColumns + `
+		FROM transactions WHERE client_id = $1 AND idempotency_key = $2
 	`
 
-	return r.scanTransaction(query, key)
+	return r.scanTransaction(ctx, query, clientID, key)
 }
 
-func (r *transactionRepository) scanTransaction(query string, arg interface{}) (*domain.Transaction, error) {
+// GetTransactionForUpdate reads a transaction and locks its row until the database transaction ends
+func (r *transactionRepository) GetTransactionForUpdate(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
+	query := `
+		SELECT ` + transactionColumns + `
+		FROM transactions WHERE id = $1
+		FOR UPDATE
+	`
+
+	return r.scanTransaction(ctx, query, id)
+}
+
+func (r *transactionRepository) scanTransaction(ctx context.Context, query string, args ...interface{}) (*domain.Transaction, error) {
+	transaction, err := scanTransactionRow(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get transaction", "args", args, "error", err)
+		if appErr, ok := err.(*errors.AppError); ok {
+			return nil, appErr
+		}
+		return nil, errors.Wrap(err, errors.InternalError, "failed to get transaction")
+	}
+
+	return transaction, nil
+}
+
+func scanTransactionRow(row rowScanner) (*domain.Transaction, error) {
 	var transaction domain.Transaction
-	var amountStr string
+	var amountStr, feeStr string
+	var feeAccountID sql.NullInt64
 	var idempotencyKey sql.NullString
+	var requestHash sql.NullString
+	var failureReason sql.NullString
+	var batchID uuid.NullUUID
+	var batchItem sql.NullInt32
+	var reversalOf uuid.NullUUID
+	var reversedAmountStr string
+	var destinationAmountStr, destinationCurrency, fxRateStr sql.NullString
+	var fxQuoteID uuid.NullUUID
+	var initiatedBy sql.NullString
 
-	err := r.db.QueryRow(query, arg).Scan(
+	err := row.Scan(
 		&transaction.ID,
 		&transaction.SourceAccountID,
 		&transaction.DestinationAccountID,
 		&amountStr,
+		&transaction.Currency,
+		&feeStr,
+		&feeAccountID,
+		&destinationAmountStr,
+		&destinationCurrency,
+		&fxRateStr,
+		&fxQuoteID,
 		&idempotencyKey,
+		&transaction.ClientID,
+		&requestHash,
 		&transaction.Status,
+		&failureReason,
+		&batchID,
+		&batchItem,
+		&reversalOf,
+		&reversedAmountStr,
+		&initiatedBy,
 		&transaction.CreatedAt,
 		&transaction.UpdatedAt,
 	)
-
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		r.logger.Error("Failed to get transaction", "arg", arg, "error", err)
-		return nil, errors.NewAppError(errors.InternalError, "failed to get transaction").WithDetails(err.Error())
+		return nil, err
 	}
 
 	// Parse amount
 	amount, err := decimal.NewFromString(amountStr)
 	if err != nil {
-		return nil, errors.NewAppError(errors.InternalError, "failed to parse amount").WithDetails(err.Error())
+		return nil, errors.Wrap(err, errors.InternalError, "failed to parse amount")
 	}
 	transaction.Amount = amount
 
-	// Parse optional idempotency key
-	if idempotencyKey.Valid {
-		key, err := uuid.Parse(idempotencyKey.String)
+	fee, err := decimal.NewFromString(feeStr)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to parse fee")
+	}
+	transaction.Fee = fee
+	if feeAccountID.Valid {
+		transaction.FeeAccountID = &feeAccountID.Int64
+	}
+
+	reversedAmount, err := decimal.NewFromString(reversedAmountStr)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to parse reversed amount")
+	}
+	transaction.ReversedAmount = reversedAmount
+
+	if destinationAmountStr.Valid {
+		destinationAmount, err := decimal.NewFromString(destinationAmountStr.String)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.InternalError, "failed to parse destination amount")
+		}
+		transaction.DestinationAmount = &destinationAmount
+	}
+	if destinationCurrency.Valid {
+		transaction.DestinationCurrency = &destinationCurrency.String
+	}
+	if fxRateStr.Valid {
+		fxRate, err := decimal.NewFromString(fxRateStr.String)
 		if err != nil {
-			return nil, errors.NewAppError(errors.InternalError, "failed to parse idempotency key").WithDetails(err.Error())
+			return nil, errors.Wrap(err, errors.InternalError, "failed to parse fx rate")
 		}
-		transaction.IdempotencyKey = &key
+		transaction.FXRate = &fxRate
+	}
+	if fxQuoteID.Valid {
+		transaction.FXQuoteID = &fxQuoteID.UUID
+	}
+
+	// Optional idempotency key
+	if idempotencyKey.Valid {
+		transaction.IdempotencyKey = &idempotencyKey.String
+	}
+
+	transaction.RequestHash = requestHash.String
+
+	if failureReason.Valid {
+		transaction.FailureReason = &failureReason.String
+	}
+
+	if batchID.Valid {
+		item := int(batchItem.Int32)
+		transaction.BatchID = &batchID.UUID
+		transaction.BatchItem = &item
+	}
+
+	if reversalOf.Valid {
+		transaction.ReversalOf = &reversalOf.UUID
+	}
+	if initiatedBy.Valid {
+		transaction.InitiatedBy = &initiatedBy.String
 	}
 
 	return &transaction, nil
 }
 
-func (r *transactionRepository) UpdateTransactionStatus(id uuid.UUID, status string) error {
+// ListTransactions returns a page of an account's transactions ordered by (created_at, id) descending.
+// The source and destination branches are queried separately so each can use its account index.
+func (r *transactionRepository) ListTransactions(ctx context.Context, filter domain.TransactionFilter) ([]*domain.Transaction, error) {
+	args := []interface{}{filter.AccountID}
+	var conditions []string
+
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if filter.After != nil {
+		args = append(args, filter.After.CreatedAt, filter.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	extra := ""
+	if len(conditions) > 0 {
+		extra = " AND " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit)
+	limit := fmt.Sprintf("$%d", len(args))
+
+	var branches []string
+	if filter.Direction != domain.DirectionCredit {
+		branches = append(branches, `(SELECT `+transactionColumns+` FROM transactions
+			WHERE source_account_id = $1`+extra+`
+			ORDER BY created_at DESC, id DESC LIMIT `+limit+`)`)
+	}
+	if filter.Direction != domain.DirectionDebit {
+		branches = append(branches, `(SELECT `+transactionColumns+` FROM transactions
+			WHERE destination_account_id = $1`+extra+`
+			ORDER BY created_at DESC, id DESC LIMIT `+limit+`)`)
+	}
+
+	query := strings.Join(branches, " UNION ALL ") + ` ORDER BY created_at DESC, id DESC LIMIT ` + limit
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("Failed to list transactions", "account_id", filter.AccountID, "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to list transactions")
+	}
+	defer rows.Close()
+
+	var transactions []*domain.Transaction
+	for rows.Next() {
+		transaction, err := scanTransactionRow(rows)
+		if err != nil {
+			if appErr, ok := err.(*errors.AppError); ok {
+				return nil, appErr
+			}
+			return nil, errors.Wrap(err, errors.InternalError, "failed to scan transaction")
+		}
+		transactions = append(transactions, transaction)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read transactions")
+	}
+
+	return transactions, nil
+}
+
+func (r *transactionRepository) UpdateTransactionStatus(ctx context.Context, id uuid.UUID, status string) error {
 	query := `UPDATE transactions SET status = $1, updated_at = $2 WHERE id = $3`
 
-	_, err := r.db.Exec(query, status, time.Now(), id)
+	_, err := r.db.ExecContext(ctx, query, status, time.Now(), id)
 	if err != nil {
 		r.logger.Error("Failed to update transaction status",
 			"transaction_id", id, "status", status, "error", err)
-		return errors.NewAppError(errors.InternalError, "failed to update transaction status").WithDetails(err.Error())
+		return errors.Wrap(err, errors.InternalError, "failed to update transaction status")
 	}
 
 	r.logger.Info("Transaction status updated", "transaction_id", id, "status", status)
 	return nil
 }
+
+func (r *transactionRepository) MarkTransactionFailed(ctx context.Context, id uuid.UUID, reason string) error {
+	query := `UPDATE transactions SET status = $1, failure_reason = $2, updated_at = $3 WHERE id = $4`
+
+	_, err := r.db.ExecContext(ctx, query, "failed", reason, time.Now(), id)
+	if err != nil {
+		r.logger.Error("Failed to mark transaction as failed",
+			"transaction_id", id, "failure_reason", reason, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to mark transaction as failed")
+	}
+
+	r.logger.Info("Transaction marked as failed", "transaction_id", id, "failure_reason", reason)
+	return nil
+}
+
+// RecordReversal adds amount to the reversed total of a transaction and moves it to status.
+// The reversed_amount check constraint guards against reversing more than the original amount.
+func (r *transactionRepository) RecordReversal(ctx context.Context, id uuid.UUID, amount decimal.Decimal, status string) error {
+	query := `UPDATE transactions SET reversed_amount = reversed_amount + $1, status = $2, updated_at = $3 WHERE id = $4`
+
+	_, err := r.db.ExecContext(ctx, query, amount.String(), status, time.Now(), id)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23514" { // check_violation
+			return errors.ErrReversalExceedsAmount
+		}
+		r.logger.Error("Failed to record reversal",
+			"transaction_id", id, "amount", amount, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to record reversal")
+	}
+
+	r.logger.Info("Reversal recorded", "transaction_id", id, "amount", amount, "status", status)
+	return nil
+}
+
+// PurgeIdempotencyKeys releases up to limit idempotency keys of transactions created before the
+// cutoff. The transactions are kept; only the key is cleared so the unique index stays small.
+func (r *transactionRepository) PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time, limit int) (int64, error) {
+	query := `
+		UPDATE transactions SET idempotency_key = NULL, request_hash = NULL
+		WHERE id IN (
+			SELECT id FROM transactions
+			WHERE idempotency_key IS NOT NULL AND created_at < $1
+			LIMIT $2
+		)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, createdBefore, limit)
+	if err != nil {
+		r.logger.Error("Failed to purge transaction idempotency keys", "error", err)
+		return 0, errors.Wrap(err, errors.InternalError, "failed to purge idempotency keys")
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,467 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+)
+
+type webhookRepository struct {
+	db     SQLExecutor
+	logger *slog.Logger
+}
+
+func NewWebhookRepository(db SQLExecutor, logger *slog.Logger) domain.WebhookRepository {
+	return &webhookRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// webhookSubscriptionColumns lists the columns read by scanWebhookSubscriptionRow, in scan order
+const webhookSubscriptionColumns = `id, url, secret, event_types, account_id, active, created_at, updated_at`
+
+// CreateSubscription stores a new subscription. Its timestamps are set from the stored row.
+func (r *webhookRepository) CreateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) error {
+	query := `
+		INSERT INTO webhook_subscriptions (id, url, secret, event_types, account_id, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING created_at, updated_at
+	`
+
+	if subscription.ID == uuid.Nil {
+		subscription.ID = uuid.New()
+	}
+
+	err := r.db.QueryRowContext(ctx,
+		query,
+		subscription.ID,
+		subscription.URL,
+		subscription.Secret,
+		pq.Array(subscription.EventTypes),
+		subscription.AccountID,
+		subscription.Active,
+		time.Now(),
+	).Scan(&subscription.CreatedAt, &subscription.UpdatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" { // foreign_key_violation
+			return errors.ErrAccountNotFound
+		}
+		r.logger.Error("Failed to create webhook subscription", "url", subscription.URL, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to create webhook subscription")
+	}
+
+	r.logger.Info("Webhook subscription created", "webhook_id", subscription.ID, "url", subscription.URL)
+	return nil
+}
+
+func (r *webhookRepository) GetSubscription(ctx context.Context, id uuid.UUID) (*domain.WebhookSubscription, error) {
+	return r.getSubscription(ctx, `SELECT `+webhookSubscriptionColumns+` FROM webhook_subscriptions WHERE id = $1`, id)
+}
+
+func (r *webhookRepository) GetSubscriptionForUpdate(ctx context.Context, id uuid.UUID) (*domain.WebhookSubscription, error) {
+	return r.getSubscription(ctx, `SELECT `+webhookSubscriptionColumns+` FROM webhook_subscriptions WHERE id = $1 FOR UPDATE`, id)
+}
+
+func (r *webhookRepository) getSubscription(ctx context.Context, query string, id uuid.UUID) (*domain.WebhookSubscription, error) {
+	subscription, err := scanWebhookSubscriptionRow(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get webhook subscription", "webhook_id", id, "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to get webhook subscription")
+	}
+
+	return subscription, nil
+}
+
+func (r *webhookRepository) ListSubscriptions(ctx context.Context, accountID *int64) ([]*domain.WebhookSubscription, error) {
+	query := `
+		SELECT ` + webhookSubscriptionColumns + `
+		FROM webhook_subscriptions
+		WHERE $1::bigint IS NULL OR account_id = $1
+		ORDER BY created_at, id
+	`
+
+	return r.querySubscriptions(ctx, query, accountID)
+}
+
+func (r *webhookRepository) UpdateSubscription(ctx context.Context, subscription *domain.WebhookSubscription) error {
+	query := `
+		UPDATE webhook_subscriptions SET url = $2, event_types = $3, active = $4
+		WHERE id = $1
+		RETURNING updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx,
+		query,
+		subscription.ID,
+		subscription.URL,
+		pq.Array(subscription.EventTypes),
+		subscription.Active,
+	).Scan(&subscription.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.ErrWebhookNotFound
+		}
+		r.logger.Error("Failed to update webhook subscription", "webhook_id", subscription.ID, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to update webhook subscription")
+	}
+
+	return nil
+}
+
+// DeleteSubscription removes a subscription along with its deliveries and their attempts
+func (r *webhookRepository) DeleteSubscription(ctx context.Context, id uuid.UUID) (*domain.WebhookSubscription, error) {
+	query := `DELETE FROM webhook_subscriptions WHERE id = $1 RETURNING ` + webhookSubscriptionColumns
+
+	subscription, err := scanWebhookSubscriptionRow(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to delete webhook subscription", "webhook_id", id, "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to delete webhook subscription")
+	}
+
+	r.logger.Info("Webhook subscription deleted", "webhook_id", id)
+	return subscription, nil
+}
+
+func (r *webhookRepository) ListMatchingSubscriptions(ctx context.Context, eventType string, accountIDs []int64) ([]*domain.WebhookSubscription, error) {
+	query := `
+		SELECT ` + webhookSubscriptionColumns + `
+		FROM webhook_subscriptions
+		WHERE $1 = ANY(event_types)
+		  AND (account_id IS NULL OR account_id = ANY($2))
+		ORDER BY created_at, id
+	`
+
+	return r.querySubscriptions(ctx, query, eventType, pq.Array(accountIDs))
+}
+
+func (r *webhookRepository) querySubscriptions(ctx context.Context, query string, args ...interface{}) ([]*domain.WebhookSubscription, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("Failed to list webhook subscriptions", "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to list webhook subscriptions")
+	}
+	defer rows.Close()
+
+	var subscriptions []*domain.WebhookSubscription
+	for rows.Next() {
+		subscription, err := scanWebhookSubscriptionRow(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.InternalError, "failed to scan webhook subscription")
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read webhook subscriptions")
+	}
+
+	return subscriptions, nil
+}
+
+func scanWebhookSubscriptionRow(row rowScanner) (*domain.WebhookSubscription, error) {
+	var subscription domain.WebhookSubscription
+	var accountID sql.NullInt64
+
+	err := row.Scan(
+		&subscription.ID,
+		&subscription.URL,
+		&subscription.Secret,
+		pq.Array(&subscription.EventTypes),
+		&accountID,
+		&subscription.Active,
+		&subscription.CreatedAt,
+		&subscription.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if accountID.Valid {
+		subscription.AccountID = &accountID.Int64
+	}
+
+	return &subscription, nil
+}
+
+// webhookDeliveryColumns lists the columns read by scanWebhookDeliveryRow, in scan order
+const webhookDeliveryColumns = `id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+	last_status_code, last_error, delivered_at, created_at, updated_at`
+
+// CreateDelivery queues a delivery, due now. Its timestamps are set from the stored row.
+func (r *webhookRepository) CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (id, subscription_id, event_id, event_type, payload, status, next_attempt_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7, $7)
+		RETURNING next_attempt_at, created_at, updated_at
+	`
+
+	if delivery.ID == uuid.Nil {
+		delivery.ID = uuid.New()
+	}
+	delivery.Status = domain.WebhookDeliveryPending
+
+	err := r.db.QueryRowContext(ctx,
+		query,
+		delivery.ID,
+		delivery.SubscriptionID,
+		delivery.EventID,
+		delivery.EventType,
+		string(delivery.Payload),
+		delivery.Status,
+		time.Now(),
+	).Scan(&delivery.NextAttemptAt, &delivery.CreatedAt, &delivery.UpdatedAt)
+	if err != nil {
+		r.logger.Error("Failed to create webhook delivery", "webhook_id", delivery.SubscriptionID, "event_id", delivery.EventID, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to create webhook delivery")
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) GetDelivery(ctx context.Context, subscriptionID, id uuid.UUID) (*domain.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = $1 AND subscription_id = $2`
+
+	delivery, err := scanWebhookDeliveryRow(r.db.QueryRowContext(ctx, query, id, subscriptionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.logger.Error("Failed to get webhook delivery", "delivery_id", id, "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to get webhook delivery")
+	}
+
+	return delivery, nil
+}
+
+func (r *webhookRepository) ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, status string, limit int) ([]*domain.WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries
+		WHERE subscription_id = $1 AND ($2::text = '' OR status = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, subscriptionID, status, limit)
+	if err != nil {
+		r.logger.Error("Failed to list webhook deliveries", "webhook_id", subscriptionID, "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to list webhook deliveries")
+	}
+	defer rows.Close()
+
+	return collectWebhookDeliveries(rows)
+}
+
+func (r *webhookRepository) ListAttempts(ctx context.Context, deliveryIDs []uuid.UUID) (map[uuid.UUID][]*domain.WebhookAttempt, error) {
+	query := `
+		SELECT id, delivery_id, status_code, error, duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = ANY($1::uuid[])
+		ORDER BY delivery_id, id
+	`
+
+	keys := make([]string, len(deliveryIDs))
+	for i, id := range deliveryIDs {
+		keys[i] = id.String()
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(keys))
+	if err != nil {
+		r.logger.Error("Failed to list webhook delivery attempts", "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to list webhook delivery attempts")
+	}
+	defer rows.Close()
+
+	attempts := make(map[uuid.UUID][]*domain.WebhookAttempt, len(deliveryIDs))
+	for rows.Next() {
+		var attempt domain.WebhookAttempt
+		var statusCode sql.NullInt64
+		var attemptErr sql.NullString
+		var durationMs int64
+		if err := rows.Scan(&attempt.ID, &attempt.DeliveryID, &statusCode, &attemptErr, &durationMs, &attempt.AttemptedAt); err != nil {
+			return nil, errors.Wrap(err, errors.InternalError, "failed to scan webhook delivery attempt")
+		}
+
+		if statusCode.Valid {
+			code := int(statusCode.Int64)
+			attempt.StatusCode = &code
+		}
+		if attemptErr.Valid {
+			attempt.Error = &attemptErr.String
+		}
+		attempt.Duration = time.Duration(durationMs) * time.Millisecond
+		attempts[attempt.DeliveryID] = append(attempts[attempt.DeliveryID], &attempt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read webhook delivery attempts")
+	}
+
+	return attempts, nil
+}
+
+// ClaimDueDeliveries leases due deliveries in one statement. FOR UPDATE SKIP LOCKED lets several
+// replicas claim concurrently without blocking on, or double-claiming, the same rows.
+func (r *webhookRepository) ClaimDueDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*domain.WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT d.id FROM webhook_deliveries d
+			JOIN webhook_subscriptions s ON s.id = d.subscription_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= $1 AND s.active
+			ORDER BY d.next_attempt_at
+			LIMIT $3
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		RETURNING ` + webhookDeliveryColumns
+
+	rows, err := r.db.QueryContext(ctx, query, now, leaseUntil, limit)
+	if err != nil {
+		r.logger.Error("Failed to claim webhook deliveries", "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to claim webhook deliveries")
+	}
+	defer rows.Close()
+
+	return collectWebhookDeliveries(rows)
+}
+
+func (r *webhookRepository) RecordAttempt(ctx context.Context, delivery *domain.WebhookDelivery, attempt *domain.WebhookAttempt) error {
+	attemptQuery := `
+		INSERT INTO webhook_delivery_attempts (delivery_id, status_code, error, duration_ms, attempted_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	attempt.DeliveryID = delivery.ID
+	err := r.db.QueryRowContext(ctx,
+		attemptQuery,
+		attempt.DeliveryID,
+		attempt.StatusCode,
+		attempt.Error,
+		attempt.Duration.Milliseconds(),
+		attempt.AttemptedAt,
+	).Scan(&attempt.ID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" { // foreign_key_violation
+			return errors.ErrWebhookDeliveryNotFound
+		}
+		r.logger.Error("Failed to record webhook delivery attempt", "delivery_id", delivery.ID, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to record webhook delivery attempt")
+	}
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, next_attempt_at = $4, last_status_code = $5, last_error = $6, delivered_at = $7
+		WHERE id = $1
+		RETURNING updated_at
+	`
+
+	err = r.db.QueryRowContext(ctx,
+		query,
+		delivery.ID,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.LastStatusCode,
+		delivery.LastError,
+		delivery.DeliveredAt,
+	).Scan(&delivery.UpdatedAt)
+	if err != nil {
+		r.logger.Error("Failed to update webhook delivery", "delivery_id", delivery.ID, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to update webhook delivery")
+	}
+
+	return nil
+}
+
+func (r *webhookRepository) RequeueDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = $2
+		WHERE id = $1
+		RETURNING status, attempts, next_attempt_at, updated_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, delivery.ID, time.Now()).
+		Scan(&delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.ErrWebhookDeliveryNotFound
+		}
+		r.logger.Error("Failed to requeue webhook delivery", "delivery_id", delivery.ID, "error", err)
+		return errors.Wrap(err, errors.InternalError, "failed to requeue webhook delivery")
+	}
+
+	return nil
+}
+
+func collectWebhookDeliveries(rows *sql.Rows) ([]*domain.WebhookDelivery, error) {
+	var deliveries []*domain.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDeliveryRow(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.InternalError, "failed to scan webhook delivery")
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.InternalError, "failed to read webhook deliveries")
+	}
+
+	return deliveries, nil
+}
+
+func scanWebhookDeliveryRow(row rowScanner) (*domain.WebhookDelivery, error) {
+	var delivery domain.WebhookDelivery
+	var payload []byte
+	var statusCode sql.NullInt64
+	var lastError sql.NullString
+	var deliveredAt sql.NullTime
+
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.SubscriptionID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&statusCode,
+		&lastError,
+		&deliveredAt,
+		&delivery.CreatedAt,
+		&delivery.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Payload = payload
+	if statusCode.Valid {
+		code := int(statusCode.Int64)
+		delivery.LastStatusCode = &code
+	}
+	if lastError.Valid {
+		delivery.LastError = &lastError.String
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+
+	return &delivery, nil
+}
@@ -4,16 +4,23 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"time"
 
 	"internal-transfers/internal/config"
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/events"
+	"internal-transfers/internal/fx"
 	"internal-transfers/internal/handler"
+	"internal-transfers/internal/jwt"
+	"internal-transfers/internal/ratelimit"
 	"internal-transfers/internal/repository"
 	"internal-transfers/internal/service"
 
@@ -21,13 +28,27 @@ import (
 	_ "github.com/lib/pq"
 )
 
+// streamRoute names the account stream route, which is exempt from the request timeout
+const streamRoute = "account-stream"
+
+// transferRoutes move funds as they are served, so their transfers in flight are capped per
+// source account
+var transferRoutes = []string{"POST /transactions", "POST /transactions/batch"}
+
 // Server represents the HTTP server
 type Server struct {
-	router *mux.Router
-	server *http.Server
-	db     *sql.DB
-	logger *slog.Logger
-	port   string
+	router     *mux.Router
+	server     *http.Server
+	db         *sql.DB
+	logger     *slog.Logger
+	port       string
+	sweeper    *service.IdempotencySweeper
+	expirer    *service.HoldExpirer
+	scheduler  *service.Scheduler
+	relay      *service.OutboxRelay
+	publisher  domain.Publisher
+	dispatcher *service.WebhookDispatcher
+	streams    *service.StreamService
 }
 
 // NewServer creates a new server instance
@@ -54,15 +75,109 @@ func NewServer(cfg *config.Config, logger *slog.Logger) (*Server, error) {
 	}
 
 	// Initialize store (Unit of Work)
-	store := repository.NewStore(db, logger)
+	store := repository.NewStore(db, logger, repository.RetryPolicy{
+		MaxAttempts: cfg.TxMaxAttempts,
+		BaseDelay:   cfg.TxRetryBaseDelay,
+		MaxDelay:    cfg.TxRetryMaxBackoff,
+	})
+
+	rates, err := newRateProvider(cfg)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	tokens, err := newTokenVerifier(cfg)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	rateLimits, err := ratelimit.ParseRules(cfg.RateLimits)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	publisher, err := newPublisher(cfg)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
 
 	// Initialize services
-	accountService := service.NewAccountService(store, logger)
-	transactionService := service.NewTransactionService(store, logger)
+	limitService := service.NewLimitService(store, logger, service.LimitDefaults{
+		PerTransfer: cfg.LimitPerTransfer,
+		Daily:       cfg.LimitDaily,
+		Monthly:     cfg.LimitMonthly,
+	})
+	accountService := service.NewAccountService(store, logger, cfg.MaxInitialBalance)
+	feeService := service.NewFeeService(store, logger, cfg.FeeAccountID)
+	transactionService := service.NewTransactionService(store, logger, rates, limitService, feeService)
+	fxService := service.NewFXService(store, logger, rates, cfg.FXQuoteTTL)
+	holdService := service.NewHoldService(store, logger, cfg.HoldDefaultTTL, limitService, feeService)
+	scheduledService := service.NewScheduledTransferService(store, logger, transactionService)
+	standingOrderService := service.NewStandingOrderService(store, logger, transactionService)
+	sweeper := service.NewIdempotencySweeper(store, logger, cfg.IdempotencyKeyRetention, cfg.IdempotencySweepInterval)
+	expirer := service.NewHoldExpirer(holdService, logger, cfg.HoldExpiryInterval)
+	scheduler := service.NewScheduler(scheduledService, standingOrderService, logger, cfg.SchedulerInterval)
+	relay := service.NewOutboxRelay(store, publisher, logger, service.OutboxRelayConfig{
+		Interval:        cfg.OutboxPollInterval,
+		BatchSize:       cfg.OutboxBatchSize,
+		RetryBaseDelay:  cfg.OutboxRetryBaseDelay,
+		RetryMaxBackoff: cfg.OutboxRetryMaxBackoff,
+		MaxAttempts:     cfg.OutboxMaxAttempts,
+		Retention:       cfg.OutboxRetention,
+	})
+	listener, err := repository.NewListener(cfg.GetDBConnectionString(), domain.EventsChannel, logger)
+	if err != nil {
+		if closer, ok := publisher.(io.Closer); ok {
+			closer.Close()
+		}
+		db.Close()
+		return nil, err
+	}
+	streamService := service.NewStreamService(store, listener, logger)
+	authService := service.NewAuthService(store, logger, service.AuthConfig{
+		AdminKey:     cfg.AdminAPIKey,
+		Tokens:       tokens,
+		SubjectClaim: cfg.JWTSubjectClaim,
+		ScopeClaim:   cfg.JWTScopeClaim,
+	})
+	webhookService := service.NewWebhookService(store, logger)
+	dispatcher := service.NewWebhookDispatcher(store, events.NewSignedWebhookSender(cfg.WebhookTimeout), logger, service.WebhookDispatcherConfig{
+		Interval:        cfg.WebhookDispatchInterval,
+		BatchSize:       cfg.WebhookBatchSize,
+		Timeout:         cfg.WebhookTimeout,
+		MaxAttempts:     cfg.WebhookMaxAttempts,
+		RetryBaseDelay:  cfg.WebhookRetryBaseDelay,
+		RetryMaxBackoff: cfg.WebhookRetryMaxBackoff,
+	})
 
 	// Initialize handlers
 	accountHandler := handler.NewAccountHandler(accountService)
-	transactionHandler := handler.NewTransactionHandler(transactionService)
+	transactionHandler := handler.NewTransactionHandler(transactionService, scheduledService)
+	scheduledHandler := handler.NewScheduledTransferHandler(scheduledService)
+	holdHandler := handler.NewHoldHandler(holdService)
+	standingOrderHandler := handler.NewStandingOrderHandler(standingOrderService)
+	fxHandler := handler.NewFXHandler(fxService)
+	limitHandler := handler.NewLimitHandler(limitService)
+	feeHandler := handler.NewFeeHandler(feeService)
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+	streamHandler := handler.NewStreamHandler(streamService, cfg.StreamHeartbeatInterval, cfg.StreamWriteTimeout)
+	apiKeyHandler := handler.NewAPIKeyHandler(authService)
+	auth := handler.NewAuthenticator(authService, cfg.AuthEnabled)
+	limiter := handler.NewRateLimiter(handler.RateLimitConfig{
+		Rules:                 rateLimits,
+		MaxInFlightPerAccount: cfg.MaxInFlightTransfersPerAccount,
+		TransferRoutes:        transferRoutes,
+	})
+
+	// guard charges a route's client bucket, authenticates its requests against its scope, then
+	// applies its other rate limits
+	guard := func(scope string, next http.HandlerFunc) http.Handler {
+		return limiter.LimitClient(auth.Require(scope, limiter.Limit(next)))
+	}
 
 	// Setup router
 	router := mux.NewRouter()
@@ -70,36 +185,216 @@ func NewServer(cfg *config.Config, logger *slog.Logger) (*Server, error) {
 	// Add middleware for logging
 	router.Use(loggingMiddleware(logger))
 
+	// Bound every request so cancelled or slow requests release their locks and connections.
+	// Streams stay open and bound each write instead.
+	router.Use(timeoutMiddleware(cfg.RequestTimeout, streamRoute))
+
+	// Cap request bodies; a larger body fails to decode as an invalid request body
+	router.Use(bodyLimitMiddleware(handler.MaxRequestBodySize))
+
+	// Every route but the health check needs credentials holding the scope it is registered with,
+	// and is subject to the rate limits configured for it
+
 	// Account routes
-	router.HandleFunc("/accounts", accountHandler.CreateAccount).Methods("POST")
-	router.HandleFunc("/accounts/{account_id}", accountHandler.GetAccount).Methods("GET")
+	router.Handle("/accounts", guard(domain.ScopeAccountsWrite, accountHandler.CreateAccount)).Methods("POST")
+	router.Handle("/accounts/{account_id}", guard(domain.ScopeAccountsRead, accountHandler.GetAccount)).Methods("GET")
+	router.Handle("/accounts/{account_id}/ledger", guard(domain.ScopeAccountsRead, accountHandler.GetLedger)).Methods("GET")
+	router.Handle("/accounts/{account_id}/freeze", guard(domain.ScopeAdmin, accountHandler.FreezeAccount)).Methods("POST")
+	router.Handle("/accounts/{account_id}/unfreeze", guard(domain.ScopeAdmin, accountHandler.UnfreezeAccount)).Methods("POST")
+	router.Handle("/accounts/{account_id}/close", guard(domain.ScopeAdmin, accountHandler.CloseAccount)).Methods("POST")
+	router.Handle("/accounts/{account_id}/status-history", guard(domain.ScopeAccountsRead, accountHandler.GetStatusHistory)).Methods("GET")
+	router.Handle("/accounts/{account_id}/tier", guard(domain.ScopeAdmin, accountHandler.UpdateTier)).Methods("PUT")
+	router.Handle("/accounts/{account_id}/overdraft", guard(domain.ScopeAdmin, accountHandler.UpdateOverdraft)).Methods("PUT")
+	router.Handle("/accounts/{account_id}/limits", guard(domain.ScopeAccountsRead, limitHandler.GetAccountLimits)).Methods("GET")
+	router.Handle("/accounts/{account_id}/transactions", guard(domain.ScopeAccountsRead, transactionHandler.ListAccountTransactions)).Methods("GET")
+	router.Handle("/accounts/{account_id}/standing-orders", guard(domain.ScopeAccountsRead, standingOrderHandler.ListAccountStandingOrders)).Methods("GET")
+	router.Handle("/accounts/{account_id}/stream", guard(domain.ScopeAccountsRead, streamHandler.StreamAccount)).Methods("GET").Name(streamRoute)
 
 	// Transaction routes
-	router.HandleFunc("/transactions", transactionHandler.Transfer).Methods("POST")
-
-	// Health check
+	router.Handle("/transactions", guard(domain.ScopeTransfersWrite, transactionHandler.Transfer)).Methods("POST")
+	router.Handle("/transactions/batch", guard(domain.ScopeTransfersWrite, transactionHandler.BatchTransfer)).Methods("POST")
+	router.Handle("/transactions", guard(domain.ScopeAccountsRead, transactionHandler.FindTransaction)).Methods("GET")
+	router.Handle("/transactions/{transaction_id}", guard(domain.ScopeAccountsRead, transactionHandler.GetTransaction)).Methods("GET")
+	router.Handle("/transactions/{transaction_id}/reverse", guard(domain.ScopeTransfersWrite, transactionHandler.ReverseTransfer)).Methods("POST")
+
+	// Hold routes
+	router.Handle("/holds", guard(domain.ScopeTransfersWrite, holdHandler.CreateHold)).Methods("POST")
+	router.Handle("/holds/{hold_id}", guard(domain.ScopeAccountsRead, holdHandler.GetHold)).Methods("GET")
+	router.Handle("/holds/{hold_id}/capture", guard(domain.ScopeTransfersWrite, holdHandler.CaptureHold)).Methods("POST")
+	router.Handle("/holds/{hold_id}/void", guard(domain.ScopeTransfersWrite, holdHandler.VoidHold)).Methods("POST")
+
+	// Scheduled transfer routes (created via POST /transactions with execute_at)
+	router.Handle("/scheduled-transfers/{scheduled_transfer_id}", guard(domain.ScopeAccountsRead, scheduledHandler.GetScheduledTransfer)).Methods("GET")
+	router.Handle("/scheduled-transfers/{scheduled_transfer_id}/cancel", guard(domain.ScopeTransfersWrite, scheduledHandler.CancelScheduledTransfer)).Methods("POST")
+
+	// Standing order routes
+	router.Handle("/standing-orders", guard(domain.ScopeTransfersWrite, standingOrderHandler.CreateStandingOrder)).Methods("POST")
+	router.Handle("/standing-orders/{standing_order_id}", guard(domain.ScopeAccountsRead, standingOrderHandler.GetStandingOrder)).Methods("GET")
+	router.Handle("/standing-orders/{standing_order_id}", guard(domain.ScopeTransfersWrite, standingOrderHandler.UpdateStandingOrder)).Methods("PATCH")
+	router.Handle("/standing-orders/{standing_order_id}", guard(domain.ScopeTransfersWrite, standingOrderHandler.CancelStandingOrder)).Methods("DELETE")
+	router.Handle("/standing-orders/{standing_order_id}/pause", guard(domain.ScopeTransfersWrite, standingOrderHandler.PauseStandingOrder)).Methods("POST")
+	router.Handle("/standing-orders/{standing_order_id}/resume", guard(domain.ScopeTransfersWrite, standingOrderHandler.ResumeStandingOrder)).Methods("POST")
+	router.Handle("/standing-orders/{standing_order_id}/runs", guard(domain.ScopeAccountsRead, standingOrderHandler.ListStandingOrderRuns)).Methods("GET")
+
+	// FX routes
+	router.Handle("/fx/quotes", guard(domain.ScopeTransfersWrite, fxHandler.CreateQuote)).Methods("POST")
+	router.Handle("/fx/quotes/{quote_id}", guard(domain.ScopeAccountsRead, fxHandler.GetQuote)).Methods("GET")
+
+	// Transfer limit routes
+	router.Handle("/limits", guard(domain.ScopeAdmin, limitHandler.SetLimit)).Methods("PUT")
+	router.Handle("/limits", guard(domain.ScopeAdmin, limitHandler.ListLimits)).Methods("GET")
+	router.Handle("/limits/{limit_id}", guard(domain.ScopeAdmin, limitHandler.DeleteLimit)).Methods("DELETE")
+
+	// Fee schedule routes
+	router.Handle("/fee-schedules", guard(domain.ScopeAdmin, feeHandler.SetFeeSchedule)).Methods("PUT")
+	router.Handle("/fee-schedules", guard(domain.ScopeAdmin, feeHandler.ListFeeSchedules)).Methods("GET")
+	router.Handle("/fee-schedules/{schedule_id}", guard(domain.ScopeAdmin, feeHandler.DeleteFeeSchedule)).Methods("DELETE")
+
+	// Webhook routes
+	router.Handle("/webhooks", guard(domain.ScopeAdmin, webhookHandler.CreateWebhook)).Methods("POST")
+	router.Handle("/webhooks", guard(domain.ScopeAdmin, webhookHandler.ListWebhooks)).Methods("GET")
+	router.Handle("/webhooks/{webhook_id}", guard(domain.ScopeAdmin, webhookHandler.GetWebhook)).Methods("GET")
+	router.Handle("/webhooks/{webhook_id}", guard(domain.ScopeAdmin, webhookHandler.UpdateWebhook)).Methods("PATCH")
+	router.Handle("/webhooks/{webhook_id}", guard(domain.ScopeAdmin, webhookHandler.DeleteWebhook)).Methods("DELETE")
+	router.Handle("/webhooks/{webhook_id}/deliveries", guard(domain.ScopeAdmin, webhookHandler.ListDeliveries)).Methods("GET")
+	router.Handle("/webhooks/{webhook_id}/deliveries/{delivery_id}", guard(domain.ScopeAdmin, webhookHandler.GetDelivery)).Methods("GET")
+	router.Handle("/webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver", guard(domain.ScopeAdmin, webhookHandler.Redeliver)).Methods("POST")
+
+	// API key routes
+	router.Handle("/api-keys", guard(domain.ScopeAdmin, apiKeyHandler.CreateAPIKey)).Methods("POST")
+	router.Handle("/api-keys", guard(domain.ScopeAdmin, apiKeyHandler.ListAPIKeys)).Methods("GET")
+	router.Handle("/api-keys/{key_id}", guard(domain.ScopeAdmin, apiKeyHandler.GetAPIKey)).Methods("GET")
+	router.Handle("/api-keys/{key_id}/revoke", guard(domain.ScopeAdmin, apiKeyHandler.RevokeAPIKey)).Methods("POST")
+
+	// Health check, open so probes need no credentials
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		// Check database connectivity in health check
-		if err := db.Ping(); err != nil {
+		if err := db.PingContext(r.Context()); err != nil {
 			w.WriteHeader(http.StatusServiceUnavailable)
 			json.NewEncoder(w).Encode(map[string]string{"status": "unhealthy", "error": "database unavailable"})
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{
-			"status":    "healthy",
-			"timestamp": time.Now().UTC().Format(time.RFC3339),
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":       "healthy",
+			"timestamp":    time.Now().UTC().Format(time.RFC3339),
+			"transactions": store.Stats(),
 		})
 	}).Methods("GET")
 
+	if err := checkRateLimitRoutes(router, rateLimits); err != nil {
+		streamService.Stop()
+		if closer, ok := publisher.(io.Closer); ok {
+			closer.Close()
+		}
+		db.Close()
+		return nil, err
+	}
+
 	return &Server{
-		router: router,
-		db:     db,
-		logger: logger,
+		router:     router,
+		db:         db,
+		logger:     logger,
+		sweeper:    sweeper,
+		expirer:    expirer,
+		scheduler:  scheduler,
+		relay:      relay,
+		publisher:  publisher,
+		dispatcher: dispatcher,
+		streams:    streamService,
 	}, nil
 }
 
+// newPublisher picks where outbox events are published, or returns nil to keep them in the outbox
+func newPublisher(cfg *config.Config) (domain.Publisher, error) {
+	switch cfg.EventPublisher {
+	case "":
+		return nil, nil
+	case "jsonl":
+		if cfg.EventJSONLFile == "" {
+			return events.NewJSONLPublisher(os.Stdout), nil
+		}
+		return events.OpenJSONLFile(cfg.EventJSONLFile)
+	case "webhook":
+		if cfg.EventWebhookURL == "" {
+			return nil, fmt.Errorf("EVENT_WEBHOOK_URL is required by the webhook event publisher")
+		}
+		return events.NewWebhookPublisher(cfg.EventWebhookURL, cfg.EventWebhookTimeout), nil
+	default:
+		return nil, fmt.Errorf("unknown event publisher %q, expected jsonl or webhook", cfg.EventPublisher)
+	}
+}
+
+// newTokenVerifier picks the signing keys JWTs are verified with: the key set at JWTJWKSURL or
+// in JWTJWKSFile. Without either it returns nil, and only API keys are accepted. A key set
+// requires JWTIssuer and JWTAudience, so tokens the provider issues for other services are refused.
+func newTokenVerifier(cfg *config.Config) (domain.TokenVerifier, error) {
+	if (cfg.JWTJWKSURL != "" || cfg.JWTJWKSFile != "") && (cfg.JWTIssuer == "" || cfg.JWTAudience == "") {
+		return nil, fmt.Errorf("JWT_ISSUER and JWT_AUDIENCE are required when JWT_JWKS_URL or JWT_JWKS_FILE is set")
+	}
+
+	var keys *jwt.KeySource
+	switch {
+	case cfg.JWTJWKSURL != "":
+		keys = jwt.NewHTTPKeySource(cfg.JWTJWKSURL, cfg.JWTJWKSTimeout, cfg.JWTJWKSRefreshInterval)
+	case cfg.JWTJWKSFile != "":
+		source, err := jwt.NewFileKeySource(cfg.JWTJWKSFile, cfg.JWTJWKSRefreshInterval)
+		if err != nil {
+			return nil, err
+		}
+		keys = source
+	default:
+		return nil, nil
+	}
+
+	return jwt.NewVerifier(keys, jwt.VerifierConfig{
+		Issuer:    cfg.JWTIssuer,
+		Audience:  cfg.JWTAudience,
+		ClockSkew: cfg.JWTClockSkew,
+	}), nil
+}
+
+// checkRateLimitRoutes fails on rate limit rules naming routes the router does not serve, which
+// would otherwise never apply
+func checkRateLimitRoutes(router *mux.Router, rules map[string]ratelimit.Rule) error {
+	routes := make(map[string]bool)
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			routes[method+" "+template] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for route := range rules {
+		if !routes[route] {
+			return fmt.Errorf("rate limit rule for unknown route %s", route)
+		}
+	}
+	return nil
+}
+
+// newRateProvider picks the exchange rate source: the rate service at FXRateURL, the rate file at
+// FXRatesFile, or an empty table that rejects every conversion
+func newRateProvider(cfg *config.Config) (domain.RateProvider, error) {
+	switch {
+	case cfg.FXRateURL != "":
+		return fx.NewHTTPRateProvider(cfg.FXRateURL, cfg.FXRateTimeout), nil
+	case cfg.FXRatesFile != "":
+		return fx.LoadRateFile(cfg.FXRatesFile)
+	default:
+		return fx.NewStaticRateProvider(nil)
+	}
+}
+
 // loggingMiddleware adds request logging
 func loggingMiddleware(logger *slog.Logger) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
@@ -122,6 +417,40 @@ func loggingMiddleware(logger *slog.Logger) mux.MiddlewareFunc {
 	}
 }
 
+// timeoutMiddleware attaches a deadline to the request context. The context is passed down to
+// every query, so a timeout or client disconnect cancels in-flight database work. Requests to
+// the named routes are left without a deadline.
+func timeoutMiddleware(timeout time.Duration, exemptRoutes ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if route := mux.CurrentRoute(r); route != nil && slices.Contains(exemptRoutes, route.GetName()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// bodyLimitMiddleware stops reading request bodies after limit bytes
+func bodyLimitMiddleware(limit int64) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // responseWriter wraps http.ResponseWriter to capture status code
 type responseWriter struct {
 	http.ResponseWriter
@@ -133,6 +462,11 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController flush and set deadlines through the wrapper
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // Start starts the HTTP server on the specified port
 func (s *Server) Start(port string) (string, error) {
 	// Create listener first to get actual port
@@ -157,6 +491,14 @@ func (s *Server) Start(port string) (string, error) {
 		s.logger.Info("Starting server", "port", s.port)
 	}
 
+	// Start background workers
+	s.sweeper.Start()
+	s.expirer.Start()
+	s.scheduler.Start()
+	s.relay.Start()
+	s.dispatcher.Start()
+	s.streams.Start()
+
 	// Start server in background
 	go func() {
 		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
@@ -175,6 +517,30 @@ func (s *Server) Stop(ctx context.Context) error {
 		s.logger.Info("Shutting down server")
 	}
 
+	// Stop background workers before the database goes away
+	if s.sweeper != nil {
+		s.sweeper.Stop()
+	}
+	if s.expirer != nil {
+		s.expirer.Stop()
+	}
+	if s.scheduler != nil {
+		s.scheduler.Stop()
+	}
+	if s.relay != nil {
+		s.relay.Stop()
+	}
+	if s.dispatcher != nil {
+		s.dispatcher.Stop()
+	}
+	// Ending the streams lets their requests finish before the server shuts down
+	if s.streams != nil {
+		s.streams.Stop()
+	}
+	if closer, ok := s.publisher.(io.Closer); ok {
+		closer.Close()
+	}
+
 	// Close database connection
 	if s.db != nil {
 		s.db.Close()
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+	"database/sql"
 	"log/slog"
 	"strconv"
 
@@ -12,28 +14,45 @@ import (
 )
 
 type AccountService struct {
-	store  *repository.Store
-	logger *slog.Logger
+	store             *repository.Store
+	logger            *slog.Logger
+	maxInitialBalance decimal.Decimal // Zero allows any initial balance
 }
 
-func NewAccountService(store *repository.Store, logger *slog.Logger) *AccountService {
+func NewAccountService(store *repository.Store, logger *slog.Logger, maxInitialBalance decimal.Decimal) *AccountService {
 	return &AccountService{
-		store:  store,
-		logger: logger,
+		store:             store,
+		logger:            logger,
+		maxInitialBalance: maxInitialBalance,
 	}
 }
 
-func (s *AccountService) CreateAccount(accountID int64, initialBalance decimal.Decimal) (*domain.Account, error) {
-	s.logger.Info("Creating account", "account_id", accountID, "initial_balance", initialBalance)
+type CreateAccountRequest struct {
+	AccountID      int64
+	InitialBalance decimal.Decimal
+	Currency       string  // ISO 4217 code; defaults to domain.DefaultCurrency
+	Tier           string  // Limit tier; defaults to domain.DefaultAccountTier
+	IdempotencyKey *string // Optional, scoped to ClientID
+	ClientID       string
+}
+
+func (s *AccountService) CreateAccount(ctx context.Context, req *CreateAccountRequest) (*domain.Account, error) {
+	accountID, initialBalance := req.AccountID, req.InitialBalance
+	s.logger.Info("Creating account",
+		"account_id", accountID,
+		"initial_balance", initialBalance,
+		"currency", req.Currency,
+		"tier", req.Tier,
+		"client_id", req.ClientID,
+		"idempotency_key", req.IdempotencyKey)
 
 	if initialBalance.IsNegative() {
 		return nil, errors.ErrInvalidAmount
 	}
 
-	// Validate reasonable limits
-	maxInitialBalance := decimal.NewFromInt(10_000_000_000) // 10 billion
-	if initialBalance.GreaterThan(maxInitialBalance) {
-		return nil, errors.NewAppError(errors.InvalidAmount, "initial balance exceeds maximum limit")
+	if s.maxInitialBalance.IsPositive() && initialBalance.GreaterThan(s.maxInitialBalance) {
+		return nil, errors.NewAppError(errors.InvalidAmount, "initial balance exceeds maximum limit").
+			WithDetails("maximum: " + s.maxInitialBalance.String())
 	}
 
 	// Validate account ID is positive
@@ -41,20 +60,90 @@ func (s *AccountService) CreateAccount(accountID int64, initialBalance decimal.D
 		return nil, errors.NewAppError(errors.InvalidInput, "account ID must be positive")
 	}
 
+	currency, err := normalizeCurrency(req.Currency, domain.DefaultCurrency)
+	if err != nil {
+		return nil, err
+	}
+	tier, err := normalizeTier(req.Tier, domain.DefaultAccountTier)
+	if err != nil {
+		return nil, err
+	}
+	if initialBalance.IsPositive() {
+		if err := validateAmountPrecision(initialBalance, currency); err != nil {
+			return nil, err
+		}
+	}
+
 	account := &domain.Account{
-		ID:      accountID,
-		Balance: initialBalance,
+		ID:             accountID,
+		Balance:        decimal.Zero,
+		Currency:       currency,
+		Tier:           tier,
+		ClientID:       req.ClientID,
+		IdempotencyKey: req.IdempotencyKey,
+	}
+
+	if req.IdempotencyKey != nil {
+		account.RequestHash = fingerprintAccount(accountID, initialBalance, currency, tier)
 	}
 
-	if err := s.store.Account().CreateAccount(account); err != nil {
+	var replayed *domain.Account
+
+	// Open the account empty and credit the initial balance through the ledger
+	err = s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		// Reset outcome in case this is a retry after a serialization failure
+		replayed = nil
+
+		if req.IdempotencyKey != nil {
+			existing, err := store.Account().GetAccountByIdempotencyKey(ctx, req.ClientID, *req.IdempotencyKey)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				if existing.RequestHash != account.RequestHash {
+					s.logger.Warn("Idempotency key reused with a different payload",
+						"idempotency_key", *req.IdempotencyKey, "account_id", existing.ID)
+					return errors.ErrIdempotencyKeyReused
+				}
+				existing.Replayed = true
+				replayed = existing
+				return nil
+			}
+		}
+
+		if err := store.Account().CreateAccount(ctx, account); err != nil {
+			return err
+		}
+
+		if initialBalance.IsPositive() {
+			if err := store.Account().PostLedgerEntry(ctx, &domain.LedgerEntry{
+				AccountID: accountID,
+				EntryType: domain.LedgerEntryCredit,
+				Amount:    initialBalance,
+			}); err != nil {
+				return err
+			}
+		}
+
+		created := *account
+		created.Balance = initialBalance
+		return enqueueEvent(ctx, store, domain.EventAccountCreated, []int64{accountID}, &created)
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	if replayed != nil {
+		s.logger.Info("Returning existing account for idempotency key", "account_id", replayed.ID)
+		return replayed, nil
+	}
+	account.Balance = initialBalance
+
 	s.logger.Info("Account created successfully", "account_id", account.ID)
 	return account, nil
 }
 
-func (s *AccountService) GetAccount(accountID string) (*domain.Account, error) {
+func (s *AccountService) GetAccount(ctx context.Context, accountID string) (*domain.Account, error) {
 	s.logger.Info("Getting account", "account_id", accountID)
 
 	id, err := strconv.ParseInt(accountID, 10, 64)
@@ -62,5 +151,151 @@ func (s *AccountService) GetAccount(accountID string) (*domain.Account, error) {
 		return nil, errors.ErrInvalidAccountID
 	}
 
-	return s.store.Account().GetAccount(id)
+	return s.store.Account().GetAccount(ctx, id)
+}
+
+// UpdateAccountTier moves an account to another tier, and so to that tier's transfer limits
+func (s *AccountService) UpdateAccountTier(ctx context.Context, accountID, tier string) (*domain.Account, error) {
+	s.logger.Info("Updating account tier", "account_id", accountID, "tier", tier)
+
+	id, err := strconv.ParseInt(accountID, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, errors.ErrInvalidAccountID
+	}
+
+	normalized, err := normalizeTier(tier, "")
+	if err != nil {
+		return nil, err
+	}
+	if normalized == "" {
+		return nil, errors.NewAppError(errors.InvalidInput, "tier is required")
+	}
+
+	var account *domain.Account
+	err = s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		var err error
+		account, err = store.Account().GetAccountForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if account.Status == domain.AccountStatusClosed {
+			return errors.ErrAccountClosed
+		}
+
+		if err := store.Account().UpdateAccountTier(ctx, id, normalized); err != nil {
+			return err
+		}
+		account.Tier = normalized
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// UpdateOverdraftLimit sets how far below zero an account's balance may go. Zero removes the
+// overdraft. A limit cannot be lowered below what the account has already drawn on it.
+func (s *AccountService) UpdateOverdraftLimit(ctx context.Context, accountID string, limit decimal.Decimal) (*domain.Account, error) {
+	s.logger.Info("Updating overdraft limit", "account_id", accountID, "overdraft_limit", limit)
+
+	id, err := strconv.ParseInt(accountID, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, errors.ErrInvalidAccountID
+	}
+
+	if limit.IsNegative() {
+		return nil, errors.NewAppError(errors.InvalidAmount, "overdraft limit must not be negative")
+	}
+
+	var account *domain.Account
+	err = s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		var err error
+		account, err = store.Account().GetAccountForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if account.Status == domain.AccountStatusClosed {
+			return errors.ErrAccountClosed
+		}
+		if limit.IsPositive() {
+			if err := validateAmountPrecision(limit, account.Currency); err != nil {
+				return err
+			}
+		}
+
+		drawn := account.AvailableBalance().Neg()
+		if limit.LessThan(drawn) {
+			return errors.NewAppError(errors.InvalidAmount, "overdraft limit is smaller than the amount already drawn").
+				WithDetails("drawn: " + drawn.String())
+		}
+
+		if err := store.Account().UpdateOverdraftLimit(ctx, id, limit); err != nil {
+			return err
+		}
+		account.OverdraftLimit = limit
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// LedgerAudit compares an account balance with the balance rebuilt from its ledger entries
+type LedgerAudit struct {
+	Account       *domain.Account
+	LedgerBalance decimal.Decimal
+	Entries       []*domain.LedgerEntry
+}
+
+func (a *LedgerAudit) Balanced() bool {
+	return a.Account.Balance.Equal(a.LedgerBalance)
+}
+
+func (s *AccountService) AuditAccount(ctx context.Context, accountID string) (*LedgerAudit, error) {
+	s.logger.Info("Auditing account ledger", "account_id", accountID)
+
+	id, err := strconv.ParseInt(accountID, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, errors.ErrInvalidAccountID
+	}
+
+	audit := &LedgerAudit{}
+
+	// Read the balance and the entries from one snapshot so concurrent postings can't skew the comparison
+	err = s.store.WithTransactionOptions(ctx, repository.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	}, func(store *repository.Store) error {
+		account, err := store.Account().GetAccount(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		ledgerBalance, err := store.Account().GetLedgerBalance(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		entries, err := store.Account().GetLedgerEntries(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		audit.Account, audit.LedgerBalance, audit.Entries = account, ledgerBalance, entries
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !audit.Balanced() {
+		s.logger.Error("Account balance does not match ledger",
+			"account_id", id, "balance", audit.Account.Balance, "ledger_balance", audit.LedgerBalance)
+	}
+
+	return audit, nil
 }
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/repository"
+)
+
+// maxStatusReasonLength bounds the reason recorded with a status change
+const maxStatusReasonLength = 1000
+
+type ChangeAccountStatusRequest struct {
+	AccountID      string
+	Reason         string // Required; recorded in the audit trail
+	SweepAccountID string // Close only: account that receives a non-zero balance
+	ClientID       string
+}
+
+// AccountStatusResult is an account after a status change, with the audit entry recorded for it
+type AccountStatusResult struct {
+	Account *domain.Account
+	Change  *domain.AccountStatusChange
+}
+
+// FreezeAccount stops an active account from sending funds. It can still receive them.
+func (s *AccountService) FreezeAccount(ctx context.Context, req *ChangeAccountStatusRequest) (*AccountStatusResult, error) {
+	return s.changeStatus(ctx, req, domain.AccountStatusFrozen)
+}
+
+// UnfreezeAccount returns a frozen account to active
+func (s *AccountService) UnfreezeAccount(ctx context.Context, req *ChangeAccountStatusRequest) (*AccountStatusResult, error) {
+	return s.changeStatus(ctx, req, domain.AccountStatusActive)
+}
+
+// CloseAccount closes an active or frozen account for good. The account must hold no reserved
+// funds, and its balance must be zero unless SweepAccountID names an account of the same currency
+// to transfer it to. Only active accounts can be swept.
+func (s *AccountService) CloseAccount(ctx context.Context, req *ChangeAccountStatusRequest) (*AccountStatusResult, error) {
+	return s.changeStatus(ctx, req, domain.AccountStatusClosed)
+}
+
+func (s *AccountService) changeStatus(ctx context.Context, req *ChangeAccountStatusRequest, to string) (*AccountStatusResult, error) {
+	s.logger.Info("Changing account status",
+		"account_id", req.AccountID,
+		"status", to,
+		"sweep_account_id", req.SweepAccountID,
+		"client_id", req.ClientID)
+
+	id, err := strconv.ParseInt(req.AccountID, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, errors.ErrInvalidAccountID
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, errors.NewAppError(errors.InvalidInput, "reason is required")
+	}
+	if len(reason) > maxStatusReasonLength {
+		return nil, errors.NewAppErrorf(errors.InvalidInput, "reason must be at most %d characters", maxStatusReasonLength)
+	}
+
+	var sweepID int64
+	if req.SweepAccountID != "" {
+		if to != domain.AccountStatusClosed {
+			return nil, errors.NewAppError(errors.InvalidInput, "only closing an account can sweep its balance")
+		}
+		sweepID, err = strconv.ParseInt(req.SweepAccountID, 10, 64)
+		if err != nil || sweepID <= 0 {
+			return nil, errors.NewAppError(errors.InvalidInput, "invalid sweep account ID")
+		}
+		if sweepID == id {
+			return nil, errors.ErrSameAccountTransfer
+		}
+		// Sweeping moves the balance out of the account
+		if err := authorizeDebit(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	var result *AccountStatusResult
+
+	err = s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		// Reset outcome in case this is a retry after a serialization failure
+		result = nil
+
+		// Lock the account, and the sweep account, in ascending ID order as transfers do
+		lockIDs := []int64{id}
+		if sweepID != 0 {
+			lockIDs = []int64{id, sweepID}
+			if sweepID < id {
+				lockIDs = []int64{sweepID, id}
+			}
+		}
+		locked := make(map[int64]*domain.Account, len(lockIDs))
+		for _, lockID := range lockIDs {
+			account, err := store.Account().GetAccountForUpdate(ctx, lockID)
+			if err != nil {
+				return err
+			}
+			locked[lockID] = account
+		}
+		account := locked[id]
+
+		if err := checkStatusTransition(account, to); err != nil {
+			return err
+		}
+
+		change := &domain.AccountStatusChange{
+			ID:         uuid.New(),
+			AccountID:  id,
+			FromStatus: account.Status,
+			ToStatus:   to,
+			Reason:     reason,
+			ClientID:   req.ClientID,
+		}
+
+		if to == domain.AccountStatusClosed && account.Balance.IsPositive() {
+			if sweepID == 0 {
+				return errors.NewAppError(errors.AccountNotEmpty, errors.ErrAccountNotEmpty.Message).
+					WithDetails("balance: " + account.Balance.String())
+			}
+
+			sweep, err := sweepBalance(ctx, store, account, locked[sweepID], req.ClientID)
+			if err != nil {
+				return err
+			}
+			change.SweepTransactionID = &sweep.ID
+			account.Balance = decimal.Zero
+		}
+
+		if err := store.Account().UpdateAccountStatus(ctx, id, to); err != nil {
+			return err
+		}
+		if err := store.Account().CreateStatusChange(ctx, change); err != nil {
+			return err
+		}
+
+		account.Status = to
+		result = &AccountStatusResult{Account: account, Change: change}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("Account status change failed", "account_id", req.AccountID, "status", to, "error", err)
+		return nil, err
+	}
+
+	s.logger.Info("Account status changed",
+		"account_id", id,
+		"from_status", result.Change.FromStatus,
+		"to_status", to,
+		"sweep_transaction_id", result.Change.SweepTransactionID)
+	return result, nil
+}
+
+// checkStatusTransition allows active <-> frozen, and active or frozen -> closed. Closing needs
+// every reservation released and an overdrawn balance paid back first.
+func checkStatusTransition(account *domain.Account, to string) error {
+	if account.Status == domain.AccountStatusClosed {
+		return errors.ErrAccountClosed
+	}
+
+	switch to {
+	case domain.AccountStatusFrozen:
+		if account.Status != domain.AccountStatusActive {
+			return errors.NewAppErrorf(errors.InvalidAccountStatus, "account is already %s", account.Status)
+		}
+	case domain.AccountStatusActive:
+		if account.Status != domain.AccountStatusFrozen {
+			return errors.NewAppErrorf(errors.InvalidAccountStatus, "account is %s, not frozen", account.Status)
+		}
+	case domain.AccountStatusClosed:
+		if account.HeldBalance.IsPositive() {
+			return errors.NewAppError(errors.AccountNotEmpty, "account has funds reserved by active holds").
+				WithDetails("held_balance: " + account.HeldBalance.String())
+		}
+		if account.Balance.IsNegative() {
+			return errors.NewAppError(errors.AccountNotEmpty, "account is overdrawn; fund it back to zero before closing").
+				WithDetails("balance: " + account.Balance.String())
+		}
+	}
+	return nil
+}
+
+// sweepBalance transfers the whole balance of an account that is being closed to the sweep account
+func sweepBalance(ctx context.Context, store *repository.Store, account, sweep *domain.Account, clientID string) (*domain.Transaction, error) {
+	if err := checkAccountStatus(account, sweep); err != nil {
+		return nil, err
+	}
+	currency, err := checkTransferCurrency(account, sweep, "", account.Balance)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction := &domain.Transaction{
+		ID:                   uuid.New(),
+		SourceAccountID:      account.ID,
+		DestinationAccountID: sweep.ID,
+		Amount:               account.Balance,
+		Currency:             currency,
+		ClientID:             clientID,
+		InitiatedBy:          initiatedBy(ctx),
+		Status:               "pending",
+	}
+	if err := postTransfer(ctx, store, transaction); err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+func (s *AccountService) ListStatusChanges(ctx context.Context, accountID string) ([]*domain.AccountStatusChange, error) {
+	id, err := strconv.ParseInt(accountID, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, errors.ErrInvalidAccountID
+	}
+
+	// Distinguish an unknown account from one that has never changed status
+	if _, err := s.store.Account().GetAccount(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.store.Account().ListStatusChanges(ctx, id)
+}
+
+// checkAccountStatus rejects a movement of funds that the accounts' statuses forbid: nothing moves
+// into or out of a closed account, and a frozen account cannot send funds
+func checkAccountStatus(source, dest *domain.Account) error {
+	for _, account := range []*domain.Account{source, dest} {
+		if account.Status == domain.AccountStatusClosed {
+			return errors.NewAppError(errors.AccountClosed, errors.ErrAccountClosed.Message).
+				WithDetails(fmt.Sprintf("account_id: %d", account.ID))
+		}
+	}
+	if source.Status == domain.AccountStatusFrozen {
+		return errors.NewAppError(errors.AccountFrozen, errors.ErrAccountFrozen.Message).
+			WithDetails(fmt.Sprintf("account_id: %d", source.ID))
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	stderrors "errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/repository"
+)
+
+const (
+	// apiKeyPrefix marks API keys so they are recognisable in configuration and logs
+	apiKeyPrefix = "itk_"
+	// apiKeyShownPrefix is how much of a key is kept in clear to recognise it
+	apiKeyShownPrefix = len(apiKeyPrefix) + 8
+	// adminPrincipalID identifies requests made with the configured admin key
+	adminPrincipalID = "admin"
+	// tokenPrincipalPrefix precedes the subject of a JWT in its principal's ID
+	tokenPrincipalPrefix = "jwt:"
+)
+
+// AuthConfig sets how bearer tokens are authenticated besides API keys
+type AuthConfig struct {
+	// AdminKey is accepted with every scope, so the first API keys can be created. Empty for none.
+	AdminKey string
+
+	// Tokens verifies JWTs from an identity provider; nil to accept API keys only. The subject
+	// of a token is read from SubjectClaim and its scopes from ScopeClaim, a space-separated
+	// string or an array.
+	Tokens       domain.TokenVerifier
+	SubjectClaim string
+	ScopeClaim   string
+}
+
+// AuthService manages API keys and authenticates the bearer tokens of requests
+type AuthService struct {
+	store        *repository.Store
+	logger       *slog.Logger
+	adminKeyHash string // Hash of the configured admin key; empty when there is none
+	tokens       domain.TokenVerifier
+	subjectClaim string
+	scopeClaim   string
+}
+
+func NewAuthService(store *repository.Store, logger *slog.Logger, config AuthConfig) *AuthService {
+	service := &AuthService{
+		store:        store,
+		logger:       logger,
+		tokens:       config.Tokens,
+		subjectClaim: config.SubjectClaim,
+		scopeClaim:   config.ScopeClaim,
+	}
+	if config.AdminKey != "" {
+		service.adminKeyHash = hashAPIKey(config.AdminKey)
+	}
+	if service.subjectClaim == "" {
+		service.subjectClaim = "sub"
+	}
+	if service.scopeClaim == "" {
+		service.scopeClaim = "scope"
+	}
+	return service
+}
+
+// Authenticate resolves the principal of a bearer token: the admin key, a JWT when token
+// verification is configured, or an API key. Unknown, revoked and invalid tokens fail with
+// ErrUnauthorized.
+func (s *AuthService) Authenticate(ctx context.Context, token string) (*domain.Principal, error) {
+	if token == "" {
+		return nil, errors.ErrUnauthorized
+	}
+
+	hash := hashAPIKey(token)
+	if s.adminKeyHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(s.adminKeyHash)) == 1 {
+		return &domain.Principal{ID: adminPrincipalID, Scopes: []string{domain.ScopeAdmin}}, nil
+	}
+
+	// API keys never contain dots, while a JWT is three dot-separated segments
+	if s.tokens != nil && strings.Count(token, ".") == 2 {
+		return s.authenticateToken(ctx, token)
+	}
+
+	key, err := s.store.APIKey().GetActiveAPIKeyByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errors.ErrUnauthorized
+	}
+
+	return key.Principal(), nil
+}
+
+// authenticateToken maps the claims of a verified JWT to a principal. Scopes the service does
+// not know, such as openid, are ignored.
+func (s *AuthService) authenticateToken(ctx context.Context, token string) (*domain.Principal, error) {
+	claims, err := s.tokens.Verify(ctx, token)
+	if err != nil {
+		if stderrors.Is(err, domain.ErrInvalidToken) {
+			s.logger.Warn("Bearer token rejected", "error", err)
+			return nil, errors.ErrUnauthorized
+		}
+		s.logger.Error("Failed to verify bearer token", "error", err)
+		return nil, errors.Wrap(err, errors.InternalError, "failed to verify bearer token")
+	}
+
+	subject, _ := claims[s.subjectClaim].(string)
+	if subject == "" {
+		s.logger.Warn("Bearer token rejected", "error", "missing "+s.subjectClaim+" claim")
+		return nil, errors.ErrUnauthorized
+	}
+
+	var granted []string
+	switch scopes := claims[s.scopeClaim].(type) {
+	case string:
+		granted = strings.Fields(scopes)
+	case []interface{}:
+		for _, scope := range scopes {
+			if name, ok := scope.(string); ok {
+				granted = append(granted, name)
+			}
+		}
+	}
+
+	principal := &domain.Principal{ID: tokenPrincipalPrefix + subject}
+	for _, scope := range granted {
+		if slices.Contains(domain.Scopes, scope) && !principal.HasScope(scope) {
+			principal.Scopes = append(principal.Scopes, scope)
+		}
+	}
+	return principal, nil
+}
+
+type CreateAPIKeyRequest struct {
+	Name            string
+	Scopes          []string
+	DebitAccountIDs []int64 // Nil to allow any account
+}
+
+// CreateAPIKey issues a key. The key is only returned here; it is stored hashed.
+func (s *AuthService) CreateAPIKey(ctx context.Context, req *CreateAPIKeyRequest) (*domain.APIKey, error) {
+	s.logger.Info("Creating api key", "name", req.Name, "scopes", req.Scopes, "debit_account_ids", req.DebitAccountIDs)
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 255 {
+		return nil, errors.NewAppError(errors.InvalidInput, "name is required and must be at most 255 characters")
+	}
+
+	scopes, err := validateScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DebitAccountIDs != nil {
+		if len(req.DebitAccountIDs) == 0 {
+			return nil, errors.NewAppError(errors.InvalidInput, "debit_account_ids must list at least one account; omit it to allow any account")
+		}
+		for _, id := range req.DebitAccountIDs {
+			if id <= 0 {
+				return nil, errors.ErrInvalidAccountID
+			}
+		}
+	}
+
+	plain, err := newAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key := &domain.APIKey{
+		ID:              uuid.New(),
+		Name:            name,
+		Key:             plain,
+		Prefix:          plain[:apiKeyShownPrefix],
+		KeyHash:         hashAPIKey(plain),
+		Scopes:          scopes,
+		DebitAccountIDs: req.DebitAccountIDs,
+	}
+	if err := s.store.APIKey().CreateAPIKey(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (s *AuthService) GetAPIKey(ctx context.Context, keyID string) (*domain.APIKey, error) {
+	id, err := uuid.Parse(keyID)
+	if err != nil {
+		return nil, errors.ErrInvalidAPIKeyID
+	}
+
+	key, err := s.store.APIKey().GetAPIKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errors.ErrAPIKeyNotFound
+	}
+
+	return key, nil
+}
+
+func (s *AuthService) ListAPIKeys(ctx context.Context) ([]*domain.APIKey, error) {
+	return s.store.APIKey().ListAPIKeys(ctx)
+}
+
+// RevokeAPIKey stops a key from authenticating. Revoking a revoked key returns it unchanged.
+func (s *AuthService) RevokeAPIKey(ctx context.Context, keyID string) (*domain.APIKey, error) {
+	s.logger.Info("Revoking api key", "api_key_id", keyID)
+
+	id, err := uuid.Parse(keyID)
+	if err != nil {
+		return nil, errors.ErrInvalidAPIKeyID
+	}
+
+	key, err := s.store.APIKey().RevokeAPIKey(ctx, id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errors.ErrAPIKeyNotFound
+	}
+
+	return key, nil
+}
+
+// authorizeDebit checks that the request's principal may move funds out of an account. Requests
+// without a principal, when authentication is disabled or from background workers, may.
+func authorizeDebit(ctx context.Context, accountID int64) error {
+	principal := domain.PrincipalFromContext(ctx)
+	if principal == nil || principal.CanDebit(accountID) {
+		return nil
+	}
+	return errors.NewAppErrorf(errors.Forbidden, "credentials do not allow debiting account %d", accountID)
+}
+
+// initiatedBy identifies the principal acting in a context, to record on the transactions it
+// creates. Nil without a principal.
+func initiatedBy(ctx context.Context) *string {
+	principal := domain.PrincipalFromContext(ctx)
+	if principal == nil {
+		return nil
+	}
+	id := principal.ID
+	return &id
+}
+
+// validateScopes checks scopes against the known ones, dropping duplicates
+func validateScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, errors.NewAppError(errors.InvalidInput, "scopes must list at least one scope")
+	}
+
+	var unique []string
+	for _, scope := range scopes {
+		if !slices.Contains(domain.Scopes, scope) {
+			return nil, errors.NewAppErrorf(errors.InvalidInput, "unknown scope %q, expected one of %s", scope, strings.Join(domain.Scopes, ", "))
+		}
+		if !slices.Contains(unique, scope) {
+			unique = append(unique, scope)
+		}
+	}
+	return unique, nil
+}
+
+func newAPIKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", errors.Wrap(err, errors.InternalError, "failed to generate api key")
+	}
+	return apiKeyPrefix + hex.EncodeToString(key), nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,369 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/repository"
+)
+
+// MaxBatchItems bounds the number of transfers accepted in one batch
+const MaxBatchItems = 1000
+
+type BatchTransferItem struct {
+	SourceAccountID      string
+	DestinationAccountID string
+	Amount               decimal.Decimal
+}
+
+type BatchTransferRequest struct {
+	Mode           string // atomic (default) or best_effort
+	Items          []BatchTransferItem
+	IdempotencyKey *string // Required, scoped to ClientID
+	ClientID       string
+}
+
+type BatchItemResult struct {
+	Index       int
+	Transaction *domain.Transaction // Nil for items of a failed atomic batch
+	Error       *errors.AppError    // Set when the item failed
+}
+
+type BatchResult struct {
+	Batch    *domain.TransactionBatch
+	Items    []*BatchItemResult
+	Replayed bool
+}
+
+// batchItem is a validated BatchTransferItem
+type batchItem struct {
+	sourceID int64
+	destID   int64
+	amount   decimal.Decimal
+}
+
+// BatchTransfer executes a list of transfers in a single database transaction. Every
+// involved account is locked in ascending ID order, as Transfer does for its pair, so
+// concurrent batches and transfers cannot deadlock. In atomic mode any failed item
+// fails the whole batch; in best_effort mode successful items commit and failed ones
+// are recorded as failed transactions.
+func (s *TransactionService) BatchTransfer(ctx context.Context, req *BatchTransferRequest) (*BatchResult, error) {
+	s.logger.Info("Processing batch transfer",
+		"mode", req.Mode,
+		"items", len(req.Items),
+		"client_id", req.ClientID,
+		"idempotency_key", req.IdempotencyKey)
+
+	mode := req.Mode
+	if mode == "" {
+		mode = domain.BatchModeAtomic
+	}
+	if mode != domain.BatchModeAtomic && mode != domain.BatchModeBestEffort {
+		return nil, errors.NewAppError(errors.InvalidInput, "mode must be atomic or best_effort")
+	}
+
+	if req.IdempotencyKey == nil {
+		return nil, errors.NewAppError(errors.InvalidInput, "idempotency key is required for batch transfers")
+	}
+
+	if len(req.Items) == 0 {
+		return nil, errors.NewAppError(errors.InvalidInput, "batch must contain at least one transfer")
+	}
+	if len(req.Items) > MaxBatchItems {
+		return nil, errors.NewAppErrorf(errors.InvalidInput, "batch must contain at most %d transfers", MaxBatchItems)
+	}
+
+	// Validate every item up front; a malformed item rejects the whole batch in either mode
+	items := make([]batchItem, len(req.Items))
+	for i, item := range req.Items {
+		sourceID, destID, err := parseAccountIDs(item.SourceAccountID, item.DestinationAccountID)
+		if err == nil {
+			err = validateTransfer(sourceID, destID, item.Amount)
+		}
+		if err == nil {
+			err = authorizeDebit(ctx, sourceID)
+		}
+		if err != nil {
+			return nil, batchItemError(err, i)
+		}
+		items[i] = batchItem{sourceID: sourceID, destID: destID, amount: item.Amount}
+	}
+
+	requestHash := fingerprintBatch(mode, items)
+
+	var result *BatchResult
+	var declined *errors.AppError
+
+	err := s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		// Reset outcome in case this is a retry after a serialization failure
+		result, declined = nil, nil
+
+		existing, err := store.Batch().GetBatchByIdempotencyKey(ctx, req.ClientID, *req.IdempotencyKey)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			if existing.RequestHash != requestHash {
+				s.logger.Warn("Idempotency key reused with a different payload",
+					"idempotency_key", req.IdempotencyKey,
+					"batch_id", existing.ID)
+				return errors.ErrIdempotencyKeyReused
+			}
+
+			s.logger.Info("Returning existing batch for idempotency key",
+				"idempotency_key", req.IdempotencyKey,
+				"batch_id", existing.ID)
+			result, declined, err = s.replayBatch(ctx, store, existing)
+			return err
+		}
+
+		// The fees decide which fee accounts are locked with the sources and destinations. They
+		// only depend on the sources' IDs and currencies, which never change, so they are priced first.
+		sources := make(map[int64]*domain.Account)
+		fees := make([]*transferFee, len(items))
+		for i, item := range items {
+			source, ok := sources[item.sourceID]
+			if !ok {
+				if source, err = store.Account().GetAccount(ctx, item.sourceID); err != nil {
+					return err
+				}
+				sources[item.sourceID] = source
+			}
+			if fees[i], err = s.fees.price(ctx, store, source, item.destID, item.amount); err != nil {
+				return batchItemError(err, i)
+			}
+		}
+
+		// Lock every involved account once, in ascending ID order, to avoid deadlocks
+		balances := make(map[int64]decimal.Decimal)
+		for i, item := range items {
+			balances[item.sourceID] = decimal.Zero
+			balances[item.destID] = decimal.Zero
+			if fees[i] != nil {
+				balances[fees[i].accountID] = decimal.Zero
+			}
+		}
+
+		accountIDs := make([]int64, 0, len(balances))
+		for id := range balances {
+			accountIDs = append(accountIDs, id)
+		}
+		sort.Slice(accountIDs, func(i, j int) bool { return accountIDs[i] < accountIDs[j] })
+
+		accounts := make(map[int64]*domain.Account, len(accountIDs))
+		for _, id := range accountIDs {
+			account, err := store.Account().GetAccountForUpdate(ctx, id)
+			if err != nil {
+				return err
+			}
+			accounts[id] = account
+			balances[id] = account.SpendableBalance()
+		}
+
+		// A closed or frozen account, a currency mismatch, a precision error or a broken limit
+		// rejects the whole batch in either mode. Limits count every earlier item of the batch.
+		currencies := make([]string, len(items))
+		pending := make(map[int64]decimal.Decimal, len(accountIDs))
+		for i, item := range items {
+			if err := checkAccountStatus(accounts[item.sourceID], accounts[item.destID]); err != nil {
+				return batchItemError(err, i)
+			}
+			if fees[i] != nil {
+				if err := checkFeeAccount(accounts[item.sourceID], accounts[fees[i].accountID]); err != nil {
+					return batchItemError(err, i)
+				}
+			}
+			currency, err := checkTransferCurrency(accounts[item.sourceID], accounts[item.destID], "", item.amount)
+			if err != nil {
+				return batchItemError(err, i)
+			}
+			if err := s.limits.checkTransfer(ctx, store, accounts[item.sourceID], item.amount, pending[item.sourceID]); err != nil {
+				return batchItemError(err, i)
+			}
+			pending[item.sourceID] = pending[item.sourceID].Add(item.amount)
+			currencies[i] = currency
+		}
+
+		// Simulate the items in order against the locked balances, overdraft limits included.
+		// Sources are debited the gross amount; destinations and fee accounts are credited their share.
+		failed := make([]bool, len(items))
+		firstFailure := -1
+		for i, item := range items {
+			if balances[item.sourceID].LessThan(item.amount) {
+				failed[i] = true
+				if firstFailure < 0 {
+					firstFailure = i
+				}
+				continue
+			}
+			net := item.amount
+			if fees[i] != nil {
+				net = item.amount.Sub(fees[i].amount)
+				balances[fees[i].accountID] = balances[fees[i].accountID].Add(fees[i].amount)
+			}
+			balances[item.sourceID] = balances[item.sourceID].Sub(item.amount)
+			balances[item.destID] = balances[item.destID].Add(net)
+		}
+
+		batch := &domain.TransactionBatch{
+			ID:             uuid.New(),
+			ClientID:       req.ClientID,
+			IdempotencyKey: req.IdempotencyKey,
+			RequestHash:    requestHash,
+			Mode:           mode,
+			Status:         "completed",
+			ItemCount:      len(items),
+		}
+
+		// An atomic batch with a failed item is recorded without posting anything,
+		// so replays of the same key report the same failure.
+		if mode == domain.BatchModeAtomic && firstFailure >= 0 {
+			reason := string(errors.InsufficientBalance)
+			batch.Status = "failed"
+			batch.FailureReason = &reason
+			batch.FailedItem = &firstFailure
+			if err := store.Batch().CreateBatch(ctx, batch); err != nil {
+				return err
+			}
+			declined = failedBatchError(batch)
+			return nil
+		}
+
+		succeeded := 0
+		for i := range items {
+			if !failed[i] {
+				succeeded++
+			}
+		}
+		switch {
+		case succeeded == 0:
+			batch.Status = "failed"
+		case succeeded < len(items):
+			batch.Status = "partially_completed"
+		}
+
+		if err := store.Batch().CreateBatch(ctx, batch); err != nil {
+			return err
+		}
+
+		result = &BatchResult{Batch: batch, Items: make([]*BatchItemResult, len(items))}
+		for i, item := range items {
+			index := i
+			transaction := &domain.Transaction{
+				ID:                   uuid.New(),
+				SourceAccountID:      item.sourceID,
+				DestinationAccountID: item.destID,
+				Amount:               item.amount,
+				Currency:             currencies[i],
+				ClientID:             req.ClientID,
+				InitiatedBy:          initiatedBy(ctx),
+				Status:               "pending",
+				BatchID:              &batch.ID,
+				BatchItem:            &index,
+			}
+			if fees[i] != nil {
+				transaction.Fee, transaction.FeeAccountID = fees[i].amount, &fees[i].accountID
+			}
+
+			if failed[i] {
+				reason := string(errors.InsufficientBalance)
+				transaction.Status = "failed"
+				transaction.FailureReason = &reason
+				if err := store.Transaction().CreateTransaction(ctx, transaction); err != nil {
+					return err
+				}
+				if err := enqueueTransferEvent(ctx, store, transaction); err != nil {
+					return err
+				}
+				result.Items[i] = &BatchItemResult{Index: i, Transaction: transaction, Error: failedTransferError(transaction)}
+				continue
+			}
+
+			if err := postTransfer(ctx, store, transaction); err != nil {
+				return err
+			}
+			result.Items[i] = &BatchItemResult{Index: i, Transaction: transaction}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		s.logger.Error("Batch transfer failed", "error", err)
+		return nil, err
+	}
+
+	if declined != nil {
+		s.logger.Warn("Batch transfer declined", "failure_reason", declined.Code, "details", declined.Details)
+		return nil, declined
+	}
+
+	s.logger.Info("Batch transfer processed", "batch_id", result.Batch.ID, "status", result.Batch.Status)
+	return result, nil
+}
+
+// replayBatch rebuilds the outcome of a previously processed batch
+func (s *TransactionService) replayBatch(ctx context.Context, store *repository.Store, batch *domain.TransactionBatch) (*BatchResult, *errors.AppError, error) {
+	if batch.Mode == domain.BatchModeAtomic && batch.Status == "failed" {
+		return nil, failedBatchError(batch), nil
+	}
+
+	transactions, err := store.Batch().ListBatchTransactions(ctx, batch.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := &BatchResult{Batch: batch, Replayed: true}
+	for i, transaction := range transactions {
+		item := &BatchItemResult{Index: i, Transaction: transaction}
+		if transaction.BatchItem != nil {
+			item.Index = *transaction.BatchItem
+		}
+		if transaction.Status == "failed" {
+			item.Error = failedTransferError(transaction)
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	return result, nil, nil
+}
+
+// failedBatchError rebuilds the error for an atomic batch persisted as failed
+func failedBatchError(batch *domain.TransactionBatch) *errors.AppError {
+	reason := string(errors.InsufficientBalance)
+	if batch.FailureReason != nil {
+		reason = *batch.FailureReason
+	}
+
+	details := "batch_id: " + batch.ID.String()
+	if batch.FailedItem != nil {
+		details += fmt.Sprintf(", item: %d", *batch.FailedItem)
+	}
+
+	return errors.NewAppError(errors.ErrorCode(reason), strings.ReplaceAll(reason, "_", " ")).WithDetails(details)
+}
+
+// batchItemError copies a validation error and tags it with the offending item index, ahead
+// of any details it already carries. The copy keeps the cause, so a serialization failure
+// still unwraps to the driver error and the batch transaction is retried.
+// Shared predefined errors are never mutated.
+func batchItemError(err error, index int) error {
+	appErr, ok := err.(*errors.AppError)
+	if !ok {
+		return err
+	}
+
+	tagged := *appErr
+	tagged.Details = fmt.Sprintf("item: %d", index)
+	if appErr.Details != "" {
+		tagged.Details += ", " + appErr.Details
+	}
+	return &tagged
+}
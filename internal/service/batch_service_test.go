@@ -0,0 +1,25 @@
+package service
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"internal-transfers/internal/errors"
+)
+
+func TestBatchItemError(t *testing.T) {
+	cause := &pq.Error{Code: "40001"}
+	err := batchItemError(errors.Wrap(cause, errors.InternalError, "failed to sum outgoing transfers"), 3)
+
+	var pqErr *pq.Error
+	assert.True(t, stderrors.As(err, &pqErr), "the driver error must stay reachable for the retry")
+	assert.Equal(t, "item: 3, "+cause.Error(), err.(*errors.AppError).Details)
+
+	// Shared errors are copied, not tagged in place
+	err = batchItemError(errors.ErrInsufficientBalance, 1)
+	assert.Equal(t, "item: 1", err.(*errors.AppError).Details)
+	assert.Empty(t, errors.ErrInsufficientBalance.Details)
+}
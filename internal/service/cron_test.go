@@ -0,0 +1,80 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCronRejects(t *testing.T) {
+	for _, expression := range []string{
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+		"1,,2 * * * *",
+	} {
+		_, err := parseCron(expression)
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2025, time.January, 15, 10, 30, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		expression string
+		want       time.Time
+	}{
+		{"* * * * *", time.Date(2025, 1, 15, 10, 31, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2025, 1, 16, 10, 30, 0, 0, time.UTC)}, // Strictly after from
+		{"*/15 * * * *", time.Date(2025, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"10/20 * * * *", time.Date(2025, 1, 15, 10, 50, 0, 0, time.UTC)},
+		{"5,10 * * * *", time.Date(2025, 1, 15, 11, 5, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2025, 1, 15, 13, 0, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2025, 1, 16, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 3,6 *", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 1-5", time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)},
+		{"0 8 * * 0", time.Date(2025, 1, 19, 8, 0, 0, 0, time.UTC)},
+		{"0 8 * * 7", time.Date(2025, 1, 19, 8, 0, 0, 0, time.UTC)},
+		// With both day fields restricted either matches: Thursday the 16th, not a Monday the 16th
+		{"0 0 16 * 1", time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC)},
+		// With one restricted, only it counts
+		{"0 0 * * 1", time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 16 * *", time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Never fires
+		{"0 0 30 2 *", time.Time{}},
+		{"0 0 31 4,6,9,11 *", time.Time{}},
+	} {
+		schedule, err := parseCron(tc.expression)
+		if !assert.NoError(t, err, tc.expression) {
+			continue
+		}
+		assert.Equal(t, tc.want, schedule.next(from), tc.expression)
+	}
+}
+
+func TestCronNextNormalisesTime(t *testing.T) {
+	schedule, err := parseCron("0 * * * *")
+	assert.NoError(t, err)
+
+	// Seconds are dropped and other zones are read in UTC
+	from := time.Date(2025, time.January, 15, 12, 59, 59, 999, time.FixedZone("CET", 3600))
+	assert.Equal(t, time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC), schedule.next(from))
+}
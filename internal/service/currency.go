@@ -0,0 +1,59 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+)
+
+// normalizeCurrency upper-cases an ISO 4217 code and checks that it is supported.
+// An empty code falls back to fallback.
+func normalizeCurrency(code, fallback string) (string, error) {
+	if code == "" {
+		return fallback, nil
+	}
+
+	currency := strings.ToUpper(code)
+	if _, ok := domain.MinorUnits(currency); !ok {
+		return "", errors.NewAppError(errors.InvalidInput, errors.ErrUnsupportedCurrency.Message).WithDetails("currency: " + code)
+	}
+	return currency, nil
+}
+
+// validateAmountPrecision checks an amount against the minor unit of its currency: it must be
+// at least one minor unit and have no more decimal places than the currency allows
+func validateAmountPrecision(amount decimal.Decimal, currency string) error {
+	units, _ := domain.MinorUnits(currency)
+
+	if amount.LessThan(domain.MinorUnit(currency)) {
+		return errors.NewAppError(errors.InvalidAmount, "amount below minimum limit").
+			WithDetails(fmt.Sprintf("minimum: %s %s", domain.MinorUnit(currency), currency))
+	}
+	if !amount.Equal(amount.Truncate(units)) {
+		return errors.NewAppErrorf(errors.InvalidAmount, "%s amounts have at most %d decimal places", currency, units)
+	}
+	return nil
+}
+
+// checkTransferCurrency rejects a transfer between accounts of different currencies, or in a
+// currency other than the accounts' when the request names one, and validates the amount
+// against that currency. It returns the currency of the transfer.
+func checkTransferCurrency(source, dest *domain.Account, requested string, amount decimal.Decimal) (string, error) {
+	if source.Currency != dest.Currency {
+		return "", errors.NewAppError(errors.CurrencyMismatch, errors.ErrCurrencyMismatch.Message).
+			WithDetails(fmt.Sprintf("source: %s, destination: %s", source.Currency, dest.Currency))
+	}
+	if requested != "" && requested != source.Currency {
+		return "", errors.NewAppError(errors.CurrencyMismatch, "requested currency does not match the accounts").
+			WithDetails(fmt.Sprintf("requested: %s, accounts: %s", requested, source.Currency))
+	}
+
+	if err := validateAmountPrecision(amount, source.Currency); err != nil {
+		return "", err
+	}
+	return source.Currency, nil
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/repository"
+)
+
+// maxFeePercentageScale is the number of decimal places a fee percentage can have
+const maxFeePercentageScale = 6
+
+// FeeService manages fee schedules and prices transfers with them
+type FeeService struct {
+	store        *repository.Store
+	logger       *slog.Logger
+	feeAccountID int64 // Collects fees of schedules without their own fee account; zero for none
+}
+
+func NewFeeService(store *repository.Store, logger *slog.Logger, feeAccountID int64) *FeeService {
+	return &FeeService{
+		store:        store,
+		logger:       logger,
+		feeAccountID: feeAccountID,
+	}
+}
+
+type SetFeeScheduleRequest struct {
+	Scope        string // global or account
+	AccountID    string // Account scope only
+	Currency     string // Optional for the global scope
+	FeeType      string // flat, percentage or tiered
+	Amount       *decimal.Decimal
+	Percentage   *decimal.Decimal
+	Tiers        []domain.FeeTier // Tiered schedules only
+	MinFee       *decimal.Decimal
+	MaxFee       *decimal.Decimal
+	FeeAccountID string // Optional; defaults to the configured fee account
+}
+
+// SetFeeSchedule defines a schedule, replacing the schedule already defined for the target and
+// currency. Flat and percentage schedules are stored as a single tier.
+func (s *FeeService) SetFeeSchedule(ctx context.Context, req *SetFeeScheduleRequest) (*domain.FeeSchedule, error) {
+	s.logger.Info("Setting fee schedule",
+		"scope", req.Scope,
+		"account_id", req.AccountID,
+		"currency", req.Currency,
+		"fee_type", req.FeeType,
+		"fee_account_id", req.FeeAccountID)
+
+	schedule := &domain.FeeSchedule{
+		Scope:   req.Scope,
+		FeeType: req.FeeType,
+		MinFee:  req.MinFee,
+		MaxFee:  req.MaxFee,
+	}
+
+	switch req.Scope {
+	case domain.FeeScopeGlobal:
+		if req.AccountID != "" {
+			return nil, errors.NewAppError(errors.InvalidInput, "global fee schedules do not take an account_id")
+		}
+	case domain.FeeScopeAccount:
+		if req.Currency != "" {
+			return nil, errors.NewAppError(errors.InvalidInput, "account fee schedules do not take a currency; they are in the account's currency")
+		}
+		id, err := strconv.ParseInt(req.AccountID, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, errors.ErrInvalidAccountID
+		}
+		schedule.AccountID = &id
+	default:
+		return nil, errors.NewAppError(errors.InvalidInput, "scope must be one of global, account")
+	}
+
+	tiers, err := feeTiers(req)
+	if err != nil {
+		return nil, err
+	}
+	schedule.Tiers = tiers
+
+	for _, bound := range []*decimal.Decimal{req.MinFee, req.MaxFee} {
+		if bound != nil && bound.IsNegative() {
+			return nil, errors.NewAppError(errors.InvalidAmount, "min_fee and max_fee must not be negative")
+		}
+	}
+	if req.MinFee != nil && req.MaxFee != nil && req.MinFee.GreaterThan(*req.MaxFee) {
+		return nil, errors.NewAppError(errors.InvalidAmount, "min_fee must not exceed max_fee")
+	}
+
+	currency, err := normalizeCurrency(req.Currency, "")
+	if err != nil {
+		return nil, err
+	}
+	if currency != "" {
+		schedule.Currency = &currency
+	}
+
+	feeAccountID := s.feeAccountID
+	if req.FeeAccountID != "" {
+		id, err := strconv.ParseInt(req.FeeAccountID, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, errors.NewAppError(errors.InvalidInput, "invalid fee account ID")
+		}
+		schedule.FeeAccountID, feeAccountID = &id, id
+	}
+	if feeAccountID == 0 {
+		return nil, errors.NewAppError(errors.InvalidInput, "fee_account_id is required when no default fee account is configured")
+	}
+
+	err = s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		feeAccount, err := store.Account().GetAccount(ctx, feeAccountID)
+		if err != nil {
+			return err
+		}
+
+		// Amounts are in the currency of the accounts the schedule prices, when it is known
+		if schedule.AccountID != nil {
+			account, err := store.Account().GetAccount(ctx, *schedule.AccountID)
+			if err != nil {
+				return err
+			}
+			currency = account.Currency
+		}
+		if currency != "" {
+			if err := validateFeeAmounts(schedule, currency); err != nil {
+				return err
+			}
+			if feeAccount.Currency != currency {
+				return errors.NewAppError(errors.CurrencyMismatch, "fee account holds a different currency").
+					WithDetails(fmt.Sprintf("fee_account_id: %d, fee account: %s, schedule: %s", feeAccount.ID, feeAccount.Currency, currency))
+			}
+		}
+
+		return store.Fee().UpsertSchedule(ctx, schedule)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// feeTiers builds the tiers of a schedule from the fields of its fee type
+func feeTiers(req *SetFeeScheduleRequest) ([]domain.FeeTier, error) {
+	hundred := decimal.NewFromInt(100)
+
+	switch req.FeeType {
+	case domain.FeeTypeFlat:
+		if req.Percentage != nil || len(req.Tiers) > 0 || req.MinFee != nil || req.MaxFee != nil {
+			return nil, errors.NewAppError(errors.InvalidInput, "flat fee schedules take only an amount")
+		}
+		if req.Amount == nil || !req.Amount.IsPositive() {
+			return nil, errors.NewAppError(errors.InvalidAmount, "amount must be positive")
+		}
+		return []domain.FeeTier{{Amount: *req.Amount}}, nil
+
+	case domain.FeeTypePercentage:
+		if req.Amount != nil || len(req.Tiers) > 0 {
+			return nil, errors.NewAppError(errors.InvalidInput, "percentage fee schedules take a percentage and optional min_fee and max_fee")
+		}
+		if req.Percentage == nil || !req.Percentage.IsPositive() || req.Percentage.GreaterThan(hundred) {
+			return nil, errors.NewAppError(errors.InvalidInput, "percentage must be greater than 0 and at most 100")
+		}
+		if err := validateFeePercentage(*req.Percentage); err != nil {
+			return nil, err
+		}
+		return []domain.FeeTier{{Percentage: *req.Percentage}}, nil
+
+	case domain.FeeTypeTiered:
+		if req.Amount != nil || req.Percentage != nil {
+			return nil, errors.NewAppError(errors.InvalidInput, "tiered fee schedules take tiers and optional min_fee and max_fee")
+		}
+		if len(req.Tiers) == 0 {
+			return nil, errors.NewAppError(errors.InvalidInput, "tiers are required for tiered fee schedules")
+		}
+
+		var previous *decimal.Decimal
+		for i, tier := range req.Tiers {
+			last := i == len(req.Tiers)-1
+			switch {
+			case last && tier.UpTo != nil:
+				return nil, errors.NewAppError(errors.InvalidInput, "the last tier must not have an up_to; it covers every larger amount")
+			case !last && tier.UpTo == nil:
+				return nil, errors.NewAppErrorf(errors.InvalidInput, "tier %d needs an up_to", i)
+			case !last && !tier.UpTo.IsPositive():
+				return nil, errors.NewAppErrorf(errors.InvalidAmount, "tier %d up_to must be positive", i)
+			case !last && previous != nil && !tier.UpTo.GreaterThan(*previous):
+				return nil, errors.NewAppError(errors.InvalidInput, "tiers must be in ascending order of up_to")
+			}
+			if tier.Amount.IsNegative() {
+				return nil, errors.NewAppErrorf(errors.InvalidAmount, "tier %d amount must not be negative", i)
+			}
+			if tier.Percentage.IsNegative() || tier.Percentage.GreaterThan(hundred) {
+				return nil, errors.NewAppErrorf(errors.InvalidInput, "tier %d percentage must be between 0 and 100", i)
+			}
+			if err := validateFeePercentage(tier.Percentage); err != nil {
+				return nil, err
+			}
+			previous = tier.UpTo
+		}
+		return req.Tiers, nil
+	}
+
+	return nil, errors.NewAppErrorf(errors.InvalidInput, "fee_type must be one of %s", strings.Join(domain.FeeTypes, ", "))
+}
+
+func validateFeePercentage(percentage decimal.Decimal) error {
+	if !percentage.Equal(percentage.Truncate(maxFeePercentageScale)) {
+		return errors.NewAppErrorf(errors.InvalidInput, "percentage supports at most %d decimal places", maxFeePercentageScale)
+	}
+	return nil
+}
+
+// validateFeeAmounts checks that the amounts of a schedule fit the minor unit of its currency
+func validateFeeAmounts(schedule *domain.FeeSchedule, currency string) error {
+	amounts := []*decimal.Decimal{schedule.MinFee, schedule.MaxFee}
+	for i := range schedule.Tiers {
+		amounts = append(amounts, &schedule.Tiers[i].Amount, schedule.Tiers[i].UpTo)
+	}
+
+	for _, amount := range amounts {
+		if amount == nil || amount.IsZero() {
+			continue
+		}
+		if err := validateAmountPrecision(*amount, currency); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FeeService) ListFeeSchedules(ctx context.Context) ([]*domain.FeeSchedule, error) {
+	return s.store.Fee().ListSchedules(ctx)
+}
+
+// DeleteFeeSchedule removes a schedule; the next most specific schedule applies instead
+func (s *FeeService) DeleteFeeSchedule(ctx context.Context, scheduleID string) (*domain.FeeSchedule, error) {
+	id, err := uuid.Parse(scheduleID)
+	if err != nil {
+		return nil, errors.ErrInvalidFeeScheduleID
+	}
+
+	var schedule *domain.FeeSchedule
+	err = s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		var err error
+		schedule, err = store.Fee().DeleteSchedule(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if schedule == nil {
+		return nil, errors.ErrFeeScheduleNotFound
+	}
+
+	s.logger.Info("Fee schedule deleted", "schedule_id", id)
+	return schedule, nil
+}
+
+// transferFee is the fee a transfer pays and the account that collects it
+type transferFee struct {
+	amount    decimal.Decimal
+	accountID int64
+}
+
+// price works out the fee of a transfer of amount from source to destID, or nil when it pays
+// none. Transfers to or from the collecting fee account are free. A fee that would take the
+// whole amount is rejected.
+func (s *FeeService) price(ctx context.Context, store *repository.Store, source *domain.Account, destID int64, amount decimal.Decimal) (*transferFee, error) {
+	schedules, err := store.Fee().GetApplicableSchedules(ctx, source.ID, source.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if len(schedules) == 0 {
+		return nil, nil
+	}
+	sort.SliceStable(schedules, func(i, j int) bool { return schedules[i].Rank() < schedules[j].Rank() })
+	schedule := schedules[0]
+
+	feeAccountID := s.feeAccountID
+	if schedule.FeeAccountID != nil {
+		feeAccountID = *schedule.FeeAccountID
+	}
+	if feeAccountID == 0 || feeAccountID == source.ID || feeAccountID == destID {
+		return nil, nil
+	}
+
+	fee := domain.RoundAmount(schedule.Fee(amount), source.Currency)
+	if !fee.IsPositive() {
+		return nil, nil
+	}
+	if fee.GreaterThanOrEqual(amount) {
+		return nil, errors.NewAppError(errors.InvalidAmount, "amount does not cover the transfer fee").
+			WithDetails(fmt.Sprintf("fee: %s %s", fee, source.Currency))
+	}
+
+	return &transferFee{amount: fee, accountID: feeAccountID}, nil
+}
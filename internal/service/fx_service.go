@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/repository"
+)
+
+const (
+	// maxQuoteTTL bounds how long a client can lock a rate for
+	maxQuoteTTL = 15 * time.Minute
+
+	// fxRatePrecision matches the scale of the fx_rate and rate columns
+	fxRatePrecision = 12
+)
+
+// FXService locks exchange rates into quotes that a later transfer can convert with
+type FXService struct {
+	store    *repository.Store
+	logger   *slog.Logger
+	rates    domain.RateProvider
+	quoteTTL time.Duration
+}
+
+func NewFXService(store *repository.Store, logger *slog.Logger, rates domain.RateProvider, quoteTTL time.Duration) *FXService {
+	return &FXService{
+		store:    store,
+		logger:   logger,
+		rates:    rates,
+		quoteTTL: quoteTTL,
+	}
+}
+
+type CreateQuoteRequest struct {
+	SourceCurrency      string
+	DestinationCurrency string
+	Amount              *decimal.Decimal // Optional source amount to preview the conversion of
+	TTL                 time.Duration    // Zero uses the configured default
+	ClientID            string
+}
+
+// CreateQuote fetches the current rate for a currency pair and locks it until the quote expires
+func (s *FXService) CreateQuote(ctx context.Context, req *CreateQuoteRequest) (*domain.FXQuote, error) {
+	s.logger.Info("Creating fx quote",
+		"source_currency", req.SourceCurrency,
+		"destination_currency", req.DestinationCurrency,
+		"ttl", req.TTL,
+		"client_id", req.ClientID)
+
+	from, err := normalizeCurrency(req.SourceCurrency, "")
+	if err != nil {
+		return nil, err
+	}
+	to, err := normalizeCurrency(req.DestinationCurrency, "")
+	if err != nil {
+		return nil, err
+	}
+	if from == "" || to == "" {
+		return nil, errors.NewAppError(errors.InvalidInput, "source_currency and destination_currency are required")
+	}
+	if from == to {
+		return nil, errors.NewAppError(errors.InvalidInput, "source and destination currencies must differ")
+	}
+
+	ttl := req.TTL
+	if ttl == 0 {
+		ttl = s.quoteTTL
+	}
+	if ttl <= 0 || ttl > maxQuoteTTL {
+		return nil, errors.NewAppErrorf(errors.InvalidInput, "ttl must be positive and at most %s", maxQuoteTTL)
+	}
+
+	if req.Amount != nil {
+		if err := validateAmountPrecision(*req.Amount, from); err != nil {
+			return nil, err
+		}
+	}
+
+	rate, err := lookupRate(ctx, s.rates, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := &domain.FXQuote{
+		ID:                  uuid.New(),
+		SourceCurrency:      from,
+		DestinationCurrency: to,
+		Rate:                rate,
+		ClientID:            req.ClientID,
+		ExpiresAt:           time.Now().Add(ttl),
+	}
+	if req.Amount != nil {
+		destinationAmount, err := convertAmount(*req.Amount, rate, to)
+		if err != nil {
+			return nil, err
+		}
+		quote.Amount, quote.DestinationAmount = req.Amount, &destinationAmount
+	}
+
+	if err := s.store.FXQuote().CreateQuote(ctx, quote); err != nil {
+		return nil, err
+	}
+
+	return quote, nil
+}
+
+func (s *FXService) GetQuote(ctx context.Context, quoteID string) (*domain.FXQuote, error) {
+	id, err := uuid.Parse(quoteID)
+	if err != nil {
+		return nil, errors.ErrInvalidFXQuoteID
+	}
+
+	quote, err := s.store.FXQuote().GetQuote(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if quote == nil {
+		return nil, errors.ErrFXQuoteNotFound
+	}
+
+	return quote, nil
+}
+
+// lookupRate asks the provider for a rate, separating pairs it does not know from provider failures
+func lookupRate(ctx context.Context, rates domain.RateProvider, from, to string) (decimal.Decimal, error) {
+	rate, err := rates.Rate(ctx, from, to)
+	if err == domain.ErrRateNotFound {
+		return decimal.Decimal{}, errors.NewAppError(errors.FXRateUnavailable, errors.ErrFXRateUnavailable.Message).
+			WithDetails(from + "/" + to)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return decimal.Decimal{}, ctx.Err()
+		}
+		return decimal.Decimal{}, errors.Wrap(err, errors.FXProviderUnavailable, "exchange rate provider is unavailable")
+	}
+	// Rounded to the stored scale, so the rate recorded is exactly the rate applied
+	return rate.Round(fxRatePrecision), nil
+}
+
+// convertAmount converts an amount at rate and rounds it with the rules of the target currency.
+// A result smaller than the currency's minor unit is rejected.
+func convertAmount(amount, rate decimal.Decimal, currency string) (decimal.Decimal, error) {
+	converted := domain.RoundAmount(amount.Mul(rate), currency)
+	if converted.LessThan(domain.MinorUnit(currency)) {
+		return decimal.Decimal{}, errors.NewAppError(errors.InvalidAmount, "converted amount below minimum limit").
+			WithDetails(fmt.Sprintf("minimum: %s %s", domain.MinorUnit(currency), currency))
+	}
+	return converted, nil
+}
+
+// fxConversion is the conversion an FX transfer applies
+type fxConversion struct {
+	rate              decimal.Decimal
+	destinationAmount decimal.Decimal
+	quote             *domain.FXQuote // Nil when converted at the market rate
+}
+
+// prepareConversion checks an FX transfer between two locked accounts and converts its amount,
+// either with the rate locked by quoteID or with marketRate. The amount is in the source
+// currency; a quote must belong to clientID, match the accounts' currencies and be unused and
+// unexpired. The quote row stays locked until the surrounding transaction ends.
+func prepareConversion(ctx context.Context, store *repository.Store, source, dest *domain.Account, requested string,
+	amount decimal.Decimal, marketRate *decimal.Decimal, quoteID *uuid.UUID, clientID string) (*fxConversion, error) {
+	if requested != "" && requested != source.Currency {
+		return nil, errors.NewAppError(errors.CurrencyMismatch, "requested currency does not match the source account").
+			WithDetails(fmt.Sprintf("requested: %s, source: %s", requested, source.Currency))
+	}
+	if err := validateAmountPrecision(amount, source.Currency); err != nil {
+		return nil, err
+	}
+
+	conversion := &fxConversion{}
+	switch {
+	case quoteID != nil:
+		quote, err := store.FXQuote().GetQuoteForUpdate(ctx, *quoteID)
+		if err != nil {
+			return nil, err
+		}
+		if quote == nil || quote.ClientID != clientID {
+			return nil, errors.ErrFXQuoteNotFound
+		}
+		if quote.TransactionID != nil {
+			return nil, errors.NewAppError(errors.FXQuoteUsed, errors.ErrFXQuoteUsed.Message).
+				WithDetails("transaction_id: " + quote.TransactionID.String())
+		}
+		if quote.Expired(time.Now()) {
+			return nil, errors.ErrFXQuoteExpired
+		}
+		if quote.SourceCurrency != source.Currency || quote.DestinationCurrency != dest.Currency {
+			return nil, errors.NewAppError(errors.CurrencyMismatch, "fx quote is for a different currency pair").
+				WithDetails(fmt.Sprintf("quote: %s/%s, accounts: %s/%s",
+					quote.SourceCurrency, quote.DestinationCurrency, source.Currency, dest.Currency))
+		}
+		conversion.rate, conversion.quote = quote.Rate, quote
+	case marketRate != nil:
+		conversion.rate = *marketRate
+	default:
+		return nil, errors.NewAppError(errors.CurrencyMismatch, errors.ErrCurrencyMismatch.Message).
+			WithDetails(fmt.Sprintf("source: %s, destination: %s", source.Currency, dest.Currency))
+	}
+
+	destinationAmount, err := convertAmount(amount, conversion.rate, dest.Currency)
+	if err != nil {
+		return nil, err
+	}
+	conversion.destinationAmount = destinationAmount
+
+	return conversion, nil
+}
+
+// apply records the conversion on a transaction before it is created
+func (c *fxConversion) apply(transaction *domain.Transaction, destCurrency string) {
+	transaction.DestinationAmount = &c.destinationAmount
+	transaction.DestinationCurrency = &destCurrency
+	transaction.FXRate = &c.rate
+	if c.quote != nil {
+		transaction.FXQuoteID = &c.quote.ID
+	}
+}
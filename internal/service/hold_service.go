@@ -0,0 +1,494 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/repository"
+)
+
+const (
+	// DefaultHoldTTL applies when neither the request nor the configuration sets an expiry
+	DefaultHoldTTL = 7 * 24 * time.Hour
+
+	expireBatchSize = 100
+)
+
+// HoldService reserves funds ahead of a transfer. A hold is later captured into a
+// normal transfer, voided, or released automatically once it expires.
+type HoldService struct {
+	store      *repository.Store
+	logger     *slog.Logger
+	defaultTTL time.Duration
+	limits     *LimitService
+	fees       *FeeService
+}
+
+// NewHoldService creates a HoldService. A non-positive defaultTTL falls back to DefaultHoldTTL.
+func NewHoldService(store *repository.Store, logger *slog.Logger, defaultTTL time.Duration, limits *LimitService, fees *FeeService) *HoldService {
+	if defaultTTL <= 0 {
+		defaultTTL = DefaultHoldTTL
+	}
+
+	return &HoldService{
+		store:      store,
+		logger:     logger,
+		defaultTTL: defaultTTL,
+		limits:     limits,
+		fees:       fees,
+	}
+}
+
+type CreateHoldRequest struct {
+	SourceAccountID      string
+	DestinationAccountID string
+	Amount               decimal.Decimal
+	ExpiresAt            *time.Time // Optional, defaults to now plus the configured TTL
+	IdempotencyKey       *string    // Optional, scoped to ClientID
+	ClientID             string
+}
+
+func (s *HoldService) CreateHold(ctx context.Context, req *CreateHoldRequest) (*domain.Hold, error) {
+	s.logger.Info("Creating hold",
+		"source_account_id", req.SourceAccountID,
+		"destination_account_id", req.DestinationAccountID,
+		"amount", req.Amount,
+		"client_id", req.ClientID,
+		"idempotency_key", req.IdempotencyKey)
+
+	sourceID, destID, err := parseAccountIDs(req.SourceAccountID, req.DestinationAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateTransfer(sourceID, destID, req.Amount); err != nil {
+		return nil, err
+	}
+	if err := authorizeDebit(ctx, sourceID); err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(s.defaultTTL)
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			return nil, errors.NewAppError(errors.InvalidInput, "expires_at must be in the future")
+		}
+		expiresAt = *req.ExpiresAt
+	}
+
+	var requestHash string
+	if req.IdempotencyKey != nil {
+		requestHash = fingerprintHold(sourceID, destID, req.Amount, req.ExpiresAt)
+	}
+
+	var hold *domain.Hold
+
+	err = s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		// Reset outcome in case this is a retry after a serialization failure
+		hold = nil
+
+		if req.IdempotencyKey != nil {
+			existing, err := store.Hold().GetHoldByIdempotencyKey(ctx, req.ClientID, *req.IdempotencyKey)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				if existing.RequestHash != requestHash {
+					s.logger.Warn("Idempotency key reused with a different payload",
+						"idempotency_key", req.IdempotencyKey,
+						"hold_id", existing.ID)
+					return errors.ErrIdempotencyKeyReused
+				}
+
+				existing.Replayed = true
+				hold = existing
+				return nil
+			}
+		}
+
+		// Only the source balance changes, so only the source account is locked
+		source, err := store.Account().GetAccountForUpdate(ctx, sourceID)
+		if err != nil {
+			return err
+		}
+
+		dest, err := store.Account().GetAccount(ctx, destID)
+		if err != nil {
+			return err
+		}
+
+		if err := checkAccountStatus(source, dest); err != nil {
+			return err
+		}
+		if _, err := checkTransferCurrency(source, dest, "", req.Amount); err != nil {
+			return err
+		}
+
+		// Limits are checked when funds are reserved; capturing them later needs no second check
+		if err := s.limits.checkTransfer(ctx, store, source, req.Amount, decimal.Zero); err != nil {
+			return err
+		}
+
+		if source.SpendableBalance().LessThan(req.Amount) {
+			return errors.ErrInsufficientBalance
+		}
+
+		if err := store.Account().AdjustHeldBalance(ctx, sourceID, req.Amount); err != nil {
+			return err
+		}
+
+		hold = &domain.Hold{
+			ID:                   uuid.New(),
+			SourceAccountID:      sourceID,
+			DestinationAccountID: destID,
+			Amount:               req.Amount,
+			Status:               domain.HoldStatusActive,
+			ClientID:             req.ClientID,
+			IdempotencyKey:       req.IdempotencyKey,
+			RequestHash:          requestHash,
+			ExpiresAt:            expiresAt,
+		}
+
+		return store.Hold().CreateHold(ctx, hold)
+	})
+
+	if err != nil {
+		s.logger.Error("Hold creation failed", "error", err)
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+func (s *HoldService) GetHold(ctx context.Context, holdID string) (*domain.Hold, error) {
+	id, err := uuid.Parse(holdID)
+	if err != nil {
+		return nil, errors.ErrInvalidHoldID
+	}
+
+	hold, err := s.store.Hold().GetHold(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if hold == nil {
+		return nil, errors.ErrHoldNotFound
+	}
+
+	return hold, nil
+}
+
+type CaptureHoldRequest struct {
+	HoldID string
+	Amount *decimal.Decimal // Nil captures the full held amount
+}
+
+// CaptureHold turns an active hold into a completed transfer of up to the held amount.
+// The whole hold is released; any amount not captured becomes available again.
+// Capturing an already captured hold with the same amount returns the original transfer.
+func (s *HoldService) CaptureHold(ctx context.Context, req *CaptureHoldRequest) (*domain.Transaction, error) {
+	s.logger.Info("Capturing hold", "hold_id", req.HoldID, "amount", req.Amount)
+
+	id, err := uuid.Parse(req.HoldID)
+	if err != nil {
+		return nil, errors.ErrInvalidHoldID
+	}
+
+	if req.Amount != nil && (req.Amount.IsNegative() || req.Amount.IsZero()) {
+		return nil, errors.NewAppError(errors.InvalidAmount, "amount must be positive")
+	}
+
+	var transaction *domain.Transaction
+	var declined *errors.AppError
+
+	err = s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		// Reset outcome in case this is a retry after a serialization failure
+		transaction, declined = nil, nil
+
+		// Lock the hold first, then its accounts, as the expiry sweep does
+		hold, err := store.Hold().GetHoldForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if hold == nil {
+			return errors.ErrHoldNotFound
+		}
+		if err := authorizeDebit(ctx, hold.SourceAccountID); err != nil {
+			return err
+		}
+
+		if hold.Status == domain.HoldStatusCaptured && hold.TransactionID != nil {
+			captured, err := store.Transaction().GetTransactionByID(ctx, *hold.TransactionID)
+			if err != nil {
+				return err
+			}
+			if captured != nil && (req.Amount == nil || req.Amount.Equal(captured.Amount)) {
+				captured.Replayed = true
+				transaction = captured
+				return nil
+			}
+		}
+
+		if hold.Status != domain.HoldStatusActive {
+			return holdNotActiveError(hold.Status)
+		}
+
+		// Expire an overdue hold here rather than waiting for the sweep
+		if !hold.ExpiresAt.After(time.Now()) {
+			if err := releaseHold(ctx, store, hold, domain.HoldStatusExpired); err != nil {
+				return err
+			}
+			declined = holdNotActiveError(domain.HoldStatusExpired)
+			return nil
+		}
+
+		amount := hold.Amount
+		if req.Amount != nil {
+			amount = *req.Amount
+		}
+		if amount.GreaterThan(hold.Amount) {
+			return errors.NewAppError(errors.CaptureExceedsHold, errors.ErrCaptureExceedsHold.Message).
+				WithDetails("held: " + hold.Amount.String())
+		}
+
+		// Price the capture first, as Transfer does, so the fee account joins the lock set
+		unlockedSource, err := store.Account().GetAccount(ctx, hold.SourceAccountID)
+		if err != nil {
+			return err
+		}
+		fee, err := s.fees.price(ctx, store, unlockedSource, hold.DestinationAccountID, amount)
+		if err != nil {
+			return err
+		}
+
+		// Lock the accounts in ascending ID order, as Transfer does, to avoid deadlocks
+		lockIDs := []int64{hold.SourceAccountID, hold.DestinationAccountID}
+		if fee != nil {
+			lockIDs = append(lockIDs, fee.accountID)
+		}
+		locked, err := lockAccounts(ctx, store, lockIDs...)
+		if err != nil {
+			return err
+		}
+		source, dest := locked[hold.SourceAccountID], locked[hold.DestinationAccountID]
+
+		// A frozen source or a closed account blocks the capture; the hold stays active
+		if err := checkAccountStatus(source, dest); err != nil {
+			return err
+		}
+		if fee != nil {
+			if err := checkFeeAccount(source, locked[fee.accountID]); err != nil {
+				return err
+			}
+		}
+
+		// Holds are only placed between accounts of one currency
+		if err := validateAmountPrecision(amount, source.Currency); err != nil {
+			return err
+		}
+
+		transaction = &domain.Transaction{
+			ID:                   uuid.New(),
+			SourceAccountID:      hold.SourceAccountID,
+			DestinationAccountID: hold.DestinationAccountID,
+			Amount:               amount,
+			Currency:             source.Currency,
+			ClientID:             hold.ClientID,
+			InitiatedBy:          initiatedBy(ctx),
+			Status:               "pending",
+		}
+		if fee != nil {
+			transaction.Fee, transaction.FeeAccountID = fee.amount, &fee.accountID
+		}
+
+		// Release the reservation before debiting so the funds it covered can be spent
+		if err := store.Account().AdjustHeldBalance(ctx, hold.SourceAccountID, hold.Amount.Neg()); err != nil {
+			return err
+		}
+
+		if err := postTransfer(ctx, store, transaction); err != nil {
+			return err
+		}
+
+		return store.Hold().UpdateHoldStatus(ctx, hold.ID, domain.HoldStatusCaptured, &transaction.ID)
+	})
+
+	if err != nil {
+		s.logger.Error("Hold capture failed", "hold_id", req.HoldID, "error", err)
+		return nil, err
+	}
+
+	if declined != nil {
+		return nil, declined
+	}
+
+	s.logger.Info("Hold captured", "hold_id", req.HoldID, "transaction_id", transaction.ID)
+	return transaction, nil
+}
+
+// VoidHold releases an active hold. Voiding an already voided hold returns it unchanged.
+func (s *HoldService) VoidHold(ctx context.Context, holdID string) (*domain.Hold, error) {
+	s.logger.Info("Voiding hold", "hold_id", holdID)
+
+	id, err := uuid.Parse(holdID)
+	if err != nil {
+		return nil, errors.ErrInvalidHoldID
+	}
+
+	var hold *domain.Hold
+
+	err = s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		var err error
+		hold, err = store.Hold().GetHoldForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if hold == nil {
+			return errors.ErrHoldNotFound
+		}
+		if err := authorizeDebit(ctx, hold.SourceAccountID); err != nil {
+			return err
+		}
+
+		switch hold.Status {
+		case domain.HoldStatusVoided:
+			hold.Replayed = true
+			return nil
+		case domain.HoldStatusActive:
+			if _, err := store.Account().GetAccountForUpdate(ctx, hold.SourceAccountID); err != nil {
+				return err
+			}
+			return releaseHold(ctx, store, hold, domain.HoldStatusVoided)
+		default:
+			return holdNotActiveError(hold.Status)
+		}
+	})
+
+	if err != nil {
+		s.logger.Error("Hold void failed", "hold_id", holdID, "error", err)
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+// ExpireHolds releases every active hold past its expiry, in batches, and returns how many were expired
+func (s *HoldService) ExpireHolds(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		var expired int
+		err := s.store.WithTransaction(ctx, func(store *repository.Store) error {
+			expired = 0
+
+			holds, err := store.Hold().LockExpiredHolds(ctx, time.Now(), expireBatchSize)
+			if err != nil {
+				return err
+			}
+
+			// Holds come back ordered by source account, so accounts are locked in ascending order
+			for _, hold := range holds {
+				if err := releaseHold(ctx, store, hold, domain.HoldStatusExpired); err != nil {
+					return err
+				}
+			}
+
+			expired = len(holds)
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += expired
+		if expired < expireBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		s.logger.Info("Expired holds", "count", total)
+	}
+	return total, nil
+}
+
+// releaseHold returns the held amount to the source account's available balance and closes the hold
+func releaseHold(ctx context.Context, store *repository.Store, hold *domain.Hold, status string) error {
+	if err := store.Account().AdjustHeldBalance(ctx, hold.SourceAccountID, hold.Amount.Neg()); err != nil {
+		return err
+	}
+
+	if err := store.Hold().UpdateHoldStatus(ctx, hold.ID, status, nil); err != nil {
+		return err
+	}
+
+	hold.Status = status
+	return nil
+}
+
+func holdNotActiveError(status string) *errors.AppError {
+	return errors.NewAppError(errors.HoldNotActive, errors.ErrHoldNotActive.Message).WithDetails("status: " + status)
+}
+
+// HoldExpirer periodically releases holds that were neither captured nor voided in time
+type HoldExpirer struct {
+	holds    *HoldService
+	logger   *slog.Logger
+	interval time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewHoldExpirer(holds *HoldService, logger *slog.Logger, interval time.Duration) *HoldExpirer {
+	return &HoldExpirer{
+		holds:    holds,
+		logger:   logger,
+		interval: interval,
+	}
+}
+
+// Start launches the background expiry loop. It is a no-op when interval is not positive.
+func (e *HoldExpirer) Start() {
+	if e.interval <= 0 {
+		e.logger.Info("Hold expirer disabled")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := e.holds.ExpireHolds(ctx); err != nil && ctx.Err() == nil {
+					e.logger.Error("Hold expiry failed", "error", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	e.logger.Info("Hold expirer started", "interval", e.interval)
+}
+
+// Stop cancels the expiry loop, including an in-flight run, and waits for it to exit
+func (e *HoldExpirer) Stop() {
+	if e.cancel != nil {
+		e.cancel()
+	}
+	e.wg.Wait()
+}
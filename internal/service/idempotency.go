@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/repository"
+)
+
+const (
+	// MaxIdempotencyKeyLength bounds the opaque keys accepted from clients
+	MaxIdempotencyKeyLength = 255
+
+	sweepBatchSize = 1000
+)
+
+// fingerprintTransfer hashes the fields that define a transfer. The amount is
+// normalised so "100" and "100.00" produce the same fingerprint. A transfer without
+// an explicit currency or conversion hashes as it did before either existed.
+func fingerprintTransfer(sourceID, destID int64, amount decimal.Decimal, currency, fx string) string {
+	payload := fmt.Sprintf("transfer|%d|%d|%s", sourceID, destID, amount.String())
+	if currency != "" {
+		payload += "|" + currency
+	}
+	if fx != "" {
+		payload += "|fx:" + fx
+	}
+	return fingerprint(payload)
+}
+
+// fxMode describes the conversion a transfer asked for: the quote it locked, "market", or
+// nothing when it asked for none
+func fxMode(convert bool, quoteID string) string {
+	if quoteID != "" {
+		return strings.ToLower(quoteID)
+	}
+	if convert {
+		return "market"
+	}
+	return ""
+}
+
+// fingerprintAccount hashes the fields that define an account creation request. Accounts
+// in the default currency and tier hash as they did before currencies and tiers existed.
+func fingerprintAccount(accountID int64, initialBalance decimal.Decimal, currency, tier string) string {
+	switch {
+	case tier != domain.DefaultAccountTier:
+		return fingerprint(fmt.Sprintf("account|%d|%s|%s|%s", accountID, initialBalance.String(), currency, tier))
+	case currency != domain.DefaultCurrency:
+		return fingerprint(fmt.Sprintf("account|%d|%s|%s", accountID, initialBalance.String(), currency))
+	}
+	return fingerprint(fmt.Sprintf("account|%d|%s", accountID, initialBalance.String()))
+}
+
+// fingerprintHold hashes the fields that define a hold request
+func fingerprintHold(sourceID, destID int64, amount decimal.Decimal, expiresAt *time.Time) string {
+	expiry := "default"
+	if expiresAt != nil {
+		expiry = expiresAt.UTC().Format(time.RFC3339Nano)
+	}
+	return fingerprint(fmt.Sprintf("hold|%d|%d|%s|%s", sourceID, destID, amount.String(), expiry))
+}
+
+// fingerprintScheduledTransfer hashes the fields that define a future-dated transfer. Requests
+// without a currency hash as they did before the currency was part of the fingerprint.
+func fingerprintScheduledTransfer(sourceID, destID int64, amount decimal.Decimal, currency string, executeAt time.Time) string {
+	payload := fmt.Sprintf("scheduled|%d|%d|%s|%s", sourceID, destID, amount.String(), executeAt.UTC().Format(time.RFC3339Nano))
+	if currency != "" {
+		payload += "|" + currency
+	}
+	return fingerprint(payload)
+}
+
+// fingerprintStandingOrder hashes the fields that define a standing order. Optional fields
+// are fingerprinted as "none" when absent.
+func fingerprintStandingOrder(sourceID, destID int64, amount decimal.Decimal, cronExpression string, interval time.Duration,
+	startAt *time.Time, endAt *time.Time, maxRuns *int) string {
+	start, end, runs := "none", "none", "none"
+	if startAt != nil {
+		start = startAt.UTC().Format(time.RFC3339Nano)
+	}
+	if endAt != nil {
+		end = endAt.UTC().Format(time.RFC3339Nano)
+	}
+	if maxRuns != nil {
+		runs = fmt.Sprint(*maxRuns)
+	}
+	return fingerprint(fmt.Sprintf("standing_order|%d|%d|%s|%s|%s|%s|%s|%s",
+		sourceID, destID, amount.String(), cronExpression, interval, start, end, runs))
+}
+
+// fingerprintReversal hashes the fields that define a reversal request. A full reversal,
+// which has no amount, is fingerprinted as "full".
+func fingerprintReversal(originalID uuid.UUID, amount *decimal.Decimal) string {
+	requested := "full"
+	if amount != nil {
+		requested = amount.String()
+	}
+	return fingerprint(fmt.Sprintf("reversal|%s|%s", originalID, requested))
+}
+
+// fingerprintBatch hashes the mode and every item of a batch, in order
+func fingerprintBatch(mode string, items []batchItem) string {
+	var b strings.Builder
+	b.WriteString("batch|" + mode)
+	for _, item := range items {
+		fmt.Fprintf(&b, "|%d|%d|%s", item.sourceID, item.destID, item.amount.String())
+	}
+	return fingerprint(b.String())
+}
+
+func fingerprint(payload string) string {
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
+
+// IdempotencySweeper periodically releases idempotency keys older than the retention
+// window so the partial unique indexes on transactions and accounts stay bounded.
+type IdempotencySweeper struct {
+	store     *repository.Store
+	logger    *slog.Logger
+	retention time.Duration
+	interval  time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewIdempotencySweeper(store *repository.Store, logger *slog.Logger, retention, interval time.Duration) *IdempotencySweeper {
+	return &IdempotencySweeper{
+		store:     store,
+		logger:    logger,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Start launches the background sweep loop. It is a no-op when retention or interval is not positive.
+func (s *IdempotencySweeper) Start() {
+	if s.retention <= 0 || s.interval <= 0 {
+		s.logger.Info("Idempotency key sweeper disabled")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.Sweep(ctx); err != nil && ctx.Err() == nil {
+					s.logger.Error("Idempotency key sweep failed", "error", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	s.logger.Info("Idempotency key sweeper started", "retention", s.retention, "interval", s.interval)
+}
+
+// Stop cancels the sweep loop, including an in-flight sweep, and waits for it to exit
+func (s *IdempotencySweeper) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// Sweep purges every expired key in batches and returns how many were released
+func (s *IdempotencySweeper) Sweep(ctx context.Context) (int64, error) {
+	cutoff := time.Now().Add(-s.retention)
+
+	var total int64
+	purgers := []func(context.Context, time.Time, int) (int64, error){
+		s.store.Transaction().PurgeIdempotencyKeys,
+		s.store.Account().PurgeIdempotencyKeys,
+		s.store.Batch().PurgeIdempotencyKeys,
+		s.store.Hold().PurgeIdempotencyKeys,
+		s.store.ScheduledTransfer().PurgeIdempotencyKeys,
+		s.store.StandingOrder().PurgeIdempotencyKeys,
+	}
+
+	for _, purge := range purgers {
+		for {
+			n, err := purge(ctx, cutoff, sweepBatchSize)
+			if err != nil {
+				return total, err
+			}
+			total += n
+			if n < sweepBatchSize {
+				break
+			}
+		}
+	}
+
+	if total > 0 {
+		s.logger.Info("Purged expired idempotency keys", "count", total, "cutoff", cutoff)
+	}
+	return total, nil
+}
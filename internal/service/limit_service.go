@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/repository"
+)
+
+// tierPattern restricts tier names to short lower-case slugs such as "premium" or "business-eu"
+var tierPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// LimitDefaults are the limits used when none is defined in the database. Zero disables a limit.
+type LimitDefaults struct {
+	PerTransfer decimal.Decimal
+	Daily       decimal.Decimal
+	Monthly     decimal.Decimal
+}
+
+// LimitService manages transfer limits and checks transfers against them
+type LimitService struct {
+	store    *repository.Store
+	logger   *slog.Logger
+	defaults LimitDefaults
+}
+
+func NewLimitService(store *repository.Store, logger *slog.Logger, defaults LimitDefaults) *LimitService {
+	return &LimitService{
+		store:    store,
+		logger:   logger,
+		defaults: defaults,
+	}
+}
+
+type SetLimitRequest struct {
+	Scope     string // global, tier or account
+	Tier      string // Tier scope only
+	AccountID string // Account scope only
+	LimitType string // per_transfer, daily or monthly
+	Currency  string // Optional for global and tier scopes
+	Amount    decimal.Decimal
+}
+
+// SetLimit defines a limit, replacing the limit of the same type already defined for the target
+// and currency
+func (s *LimitService) SetLimit(ctx context.Context, req *SetLimitRequest) (*domain.TransferLimit, error) {
+	s.logger.Info("Setting transfer limit",
+		"scope", req.Scope,
+		"tier", req.Tier,
+		"account_id", req.AccountID,
+		"limit_type", req.LimitType,
+		"currency", req.Currency,
+		"amount", req.Amount)
+
+	if !isLimitType(req.LimitType) {
+		return nil, errors.NewAppErrorf(errors.InvalidInput, "limit_type must be one of %s", strings.Join(domain.LimitTypes, ", "))
+	}
+	if !req.Amount.IsPositive() {
+		return nil, errors.NewAppError(errors.InvalidAmount, "amount must be positive")
+	}
+
+	limit := &domain.TransferLimit{
+		Scope:     req.Scope,
+		LimitType: req.LimitType,
+		Amount:    req.Amount,
+	}
+
+	switch req.Scope {
+	case domain.LimitScopeGlobal:
+		if req.Tier != "" || req.AccountID != "" {
+			return nil, errors.NewAppError(errors.InvalidInput, "global limits take neither tier nor account_id")
+		}
+	case domain.LimitScopeTier:
+		if req.AccountID != "" {
+			return nil, errors.NewAppError(errors.InvalidInput, "tier limits do not take an account_id")
+		}
+		tier, err := normalizeTier(req.Tier, "")
+		if err != nil {
+			return nil, err
+		}
+		if tier == "" {
+			return nil, errors.NewAppError(errors.InvalidInput, "tier is required for tier limits")
+		}
+		limit.Tier = &tier
+	case domain.LimitScopeAccount:
+		if req.Tier != "" || req.Currency != "" {
+			return nil, errors.NewAppError(errors.InvalidInput, "account limits take neither tier nor currency; they are in the account's currency")
+		}
+		id, err := strconv.ParseInt(req.AccountID, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, errors.ErrInvalidAccountID
+		}
+		limit.AccountID = &id
+	default:
+		return nil, errors.NewAppError(errors.InvalidInput, "scope must be one of global, tier, account")
+	}
+
+	currency, err := normalizeCurrency(req.Currency, "")
+	if err != nil {
+		return nil, err
+	}
+	if currency != "" {
+		if err := validateAmountPrecision(req.Amount, currency); err != nil {
+			return nil, err
+		}
+		limit.Currency = &currency
+	}
+
+	if err := s.store.Limit().UpsertLimit(ctx, limit); err != nil {
+		return nil, err
+	}
+
+	return limit, nil
+}
+
+func (s *LimitService) ListLimits(ctx context.Context) ([]*domain.TransferLimit, error) {
+	return s.store.Limit().ListLimits(ctx)
+}
+
+// DeleteLimit removes a limit; the next most specific limit of its type applies instead
+func (s *LimitService) DeleteLimit(ctx context.Context, limitID string) (*domain.TransferLimit, error) {
+	id, err := uuid.Parse(limitID)
+	if err != nil {
+		return nil, errors.ErrInvalidLimitID
+	}
+
+	limit, err := s.store.Limit().DeleteLimit(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if limit == nil {
+		return nil, errors.ErrLimitNotFound
+	}
+
+	s.logger.Info("Transfer limit deleted", "limit_id", id)
+	return limit, nil
+}
+
+// EffectiveLimit is the limit of one type that applies to an account
+type EffectiveLimit struct {
+	LimitType   string
+	Amount      decimal.Decimal
+	Scope       string     // Where the limit is defined: account, tier, global or default
+	LimitID     *uuid.UUID // Nil for configured defaults
+	Used        decimal.Decimal
+	WindowStart *time.Time // Daily and monthly limits only
+}
+
+// Remaining is how much more the account can send under the limit
+func (l *EffectiveLimit) Remaining() decimal.Decimal {
+	remaining := l.Amount.Sub(l.Used)
+	if remaining.IsNegative() {
+		return decimal.Zero
+	}
+	return remaining
+}
+
+// AccountLimits are the limits that apply to an account, with its usage of them
+type AccountLimits struct {
+	Account *domain.Account
+	Limits  []*EffectiveLimit
+}
+
+// GetAccountLimits resolves the limits of an account and its usage in the current windows
+func (s *LimitService) GetAccountLimits(ctx context.Context, accountID string) (*AccountLimits, error) {
+	id, err := strconv.ParseInt(accountID, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, errors.ErrInvalidAccountID
+	}
+
+	account, err := s.store.Account().GetAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	limits, err := s.resolve(ctx, s.store, account, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &AccountLimits{Account: account, Limits: limits}, nil
+}
+
+// resolve picks the most specific limit of each type for an account and fills in the usage of
+// daily and monthly limits. Types without any limit are left out.
+func (s *LimitService) resolve(ctx context.Context, store *repository.Store, account *domain.Account, now time.Time) ([]*EffectiveLimit, error) {
+	defined, err := store.Limit().GetApplicableLimits(ctx, account.ID, account.Tier, account.Currency)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(defined, func(i, j int) bool { return defined[i].Rank() < defined[j].Rank() })
+
+	defaults := map[string]decimal.Decimal{
+		domain.LimitPerTransfer: s.defaults.PerTransfer,
+		domain.LimitDaily:       s.defaults.Daily,
+		domain.LimitMonthly:     s.defaults.Monthly,
+	}
+
+	var limits []*EffectiveLimit
+	for _, limitType := range domain.LimitTypes {
+		limit := &EffectiveLimit{LimitType: limitType, Scope: domain.LimitScopeDefault, Amount: defaults[limitType]}
+		for _, candidate := range defined {
+			if candidate.LimitType == limitType {
+				limit.Scope, limit.Amount, limit.LimitID = candidate.Scope, candidate.Amount, &candidate.ID
+				break
+			}
+		}
+		if !limit.Amount.IsPositive() {
+			continue
+		}
+
+		if limitType != domain.LimitPerTransfer {
+			start := limitWindowStart(limitType, now)
+			sent, err := store.Limit().GetOutgoingTotal(ctx, account.ID, start)
+			if err != nil {
+				return nil, err
+			}
+			// Funds reserved by holds are as good as spent until released
+			limit.Used, limit.WindowStart = sent.Add(account.HeldBalance), &start
+		}
+
+		limits = append(limits, limit)
+	}
+
+	return limits, nil
+}
+
+// checkTransfer rejects a debit of amount from an account that would break one of its limits.
+// pending is what the same request already debits from the account ahead of this amount, e.g.
+// earlier items of a batch. The account must be locked, so that no concurrent debit can change
+// its usage before the surrounding transaction commits.
+func (s *LimitService) checkTransfer(ctx context.Context, store *repository.Store, account *domain.Account, amount, pending decimal.Decimal) error {
+	limits, err := s.resolve(ctx, store, account, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, limit := range limits {
+		total := amount
+		if limit.LimitType != domain.LimitPerTransfer {
+			total = limit.Used.Add(pending).Add(amount)
+		}
+		if total.LessThanOrEqual(limit.Amount) {
+			continue
+		}
+
+		details := fmt.Sprintf("limit: %s, scope: %s, amount: %s %s", limit.LimitType, limit.Scope, limit.Amount, account.Currency)
+		if limit.LimitType != domain.LimitPerTransfer {
+			used := limit.Used.Add(pending)
+			remaining := limit.Amount.Sub(used)
+			if remaining.IsNegative() {
+				remaining = decimal.Zero
+			}
+			details += fmt.Sprintf(", used: %s, remaining: %s", used, remaining)
+		}
+		return errors.NewAppErrorf(errors.LimitExceeded, "transfer exceeds the %s limit", limit.LimitType).WithDetails(details)
+	}
+
+	return nil
+}
+
+// limitWindowStart returns the start of the UTC calendar day or month containing now
+func limitWindowStart(limitType string, now time.Time) time.Time {
+	now = now.UTC()
+	if limitType == domain.LimitMonthly {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func isLimitType(limitType string) bool {
+	for _, t := range domain.LimitTypes {
+		if t == limitType {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeTier lower-cases a tier name and checks its format. An empty name falls back to fallback.
+func normalizeTier(tier, fallback string) (string, error) {
+	if tier == "" {
+		return fallback, nil
+	}
+
+	normalized := strings.ToLower(strings.TrimSpace(tier))
+	if !tierPattern.MatchString(normalized) {
+		return "", errors.NewAppError(errors.InvalidInput, "tier must be 1-50 lower-case letters, digits, '-' or '_'").
+			WithDetails("tier: " + tier)
+	}
+	return normalized, nil
+}
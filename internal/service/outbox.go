@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/repository"
+)
+
+// enqueueEvent records an event in the outbox, along with a delivery to each webhook subscribed to
+// it, and announces it on EventsChannel once committed. It must run in the database transaction
+// that makes the change the event describes, so the event is published if and only if it commits.
+func enqueueEvent(ctx context.Context, store *repository.Store, eventType string, accountIDs []int64, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return errors.Wrap(err, errors.InternalError, "failed to encode event")
+	}
+
+	event := &domain.Event{
+		ID:         uuid.New(),
+		Type:       eventType,
+		AccountIDs: accountIDs,
+		Data:       payload,
+	}
+	if err := store.Outbox().Enqueue(ctx, event); err != nil {
+		return err
+	}
+
+	// Wakes the streams of the accounts once the event has committed
+	notice, err := json.Marshal(domain.EventNotification{Sequence: event.Sequence, AccountIDs: accountIDs})
+	if err != nil {
+		return errors.Wrap(err, errors.InternalError, "failed to encode event notification")
+	}
+	store.NotifyAfterCommit(ctx, domain.EventsChannel, string(notice))
+
+	return enqueueWebhookDeliveries(ctx, store, event)
+}
+
+// transferEventData is the data of transfer events: the transaction and its net amount
+type transferEventData struct {
+	*domain.Transaction
+	NetAmount decimal.Decimal `json:"net_amount"`
+}
+
+// enqueueTransferEvent records transfer.completed or transfer.failed for a transaction that
+// reached its final status. Events concern the source, destination and fee accounts, which are
+// locked by then, so the events of an account are enqueued in the order they commit.
+func enqueueTransferEvent(ctx context.Context, store *repository.Store, transaction *domain.Transaction) error {
+	eventType := domain.EventTransferCompleted
+	if transaction.Status == "failed" {
+		eventType = domain.EventTransferFailed
+	}
+
+	accountIDs := []int64{transaction.SourceAccountID, transaction.DestinationAccountID}
+	if transaction.FeeAccountID != nil {
+		accountIDs = append(accountIDs, *transaction.FeeAccountID)
+	}
+
+	return enqueueEvent(ctx, store, eventType, accountIDs, transferEventData{
+		Transaction: transaction,
+		NetAmount:   transaction.NetAmount(),
+	})
+}
+
+type OutboxRelayConfig struct {
+	Interval        time.Duration // How often pending events are relayed; zero disables the relay
+	BatchSize       int           // Events read per round
+	RetryBaseDelay  time.Duration // Wait after a first failed delivery, doubled per failure
+	RetryMaxBackoff time.Duration // Upper bound of the wait between deliveries of an event
+	MaxAttempts     int           // Failed deliveries before an event is dead-lettered
+	Retention       time.Duration // How long published events are kept; zero keeps them
+}
+
+// OutboxRelay periodically publishes pending outbox events. Delivery is at least once: an event
+// is marked published only after the publisher accepts it, and a failed delivery is retried
+// with exponential backoff until MaxAttempts, after which the event is dead-lettered. Events are
+// published in sequence order, and an event waits while an earlier event of one of its accounts
+// is still pending, so each account's events stay in order.
+type OutboxRelay struct {
+	store     *repository.Store
+	publisher domain.Publisher
+	logger    *slog.Logger
+	config    OutboxRelayConfig
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewOutboxRelay(store *repository.Store, publisher domain.Publisher, logger *slog.Logger, config OutboxRelayConfig) *OutboxRelay {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 20
+	}
+	return &OutboxRelay{
+		store:     store,
+		publisher: publisher,
+		logger:    logger,
+		config:    config,
+	}
+}
+
+// Start launches the background relay loop. It is a no-op without a publisher or when the
+// interval is not positive; events then stay in the outbox.
+func (r *OutboxRelay) Start() {
+	if r.publisher == nil || r.config.Interval <= 0 {
+		r.logger.Info("Outbox relay disabled")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := r.Relay(ctx); err != nil && ctx.Err() == nil {
+					r.logger.Error("Outbox relay failed", "error", err)
+				}
+				if _, err := r.Purge(ctx); err != nil && ctx.Err() == nil {
+					r.logger.Error("Outbox purge failed", "error", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	r.logger.Info("Outbox relay started", "interval", r.config.Interval, "batch_size", r.config.BatchSize)
+}
+
+// Stop cancels the relay loop, including an in-flight round, and waits for it to exit
+func (r *OutboxRelay) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+}
+
+// Relay publishes pending events in rounds, each reading the batch after the previous one,
+// until it reaches the end of the outbox, and returns how many were published
+func (r *OutboxRelay) Relay(ctx context.Context) (int, error) {
+	total := 0
+	var after int64
+	// Accounts with an event still pending this pass; their later events wait for it
+	blocked := make(map[int64]bool)
+	for {
+		published, last, more, err := r.relayBatch(ctx, after, blocked)
+		total += published
+		if err != nil || !more {
+			return total, err
+		}
+		after = last
+	}
+}
+
+// relayBatch publishes the pending events of one batch after a sequence and returns the last
+// sequence it read. Events not due yet, and the later events of their accounts, are skipped
+// and block those accounts for the rest of the pass. Each round holds the relay lock for its
+// database transaction, so only one relay publishes at a time even across instances. Marks are
+// committed with the round; a round that fails to commit is published again by the next one.
+func (r *OutboxRelay) relayBatch(ctx context.Context, after int64, blocked map[int64]bool) (published int, last int64, more bool, err error) {
+	// Accounts blocked this round, added to blocked once the round commits
+	var roundBlocked map[int64]bool
+
+	err = r.store.WithTransaction(ctx, func(store *repository.Store) error {
+		// Reset outcome in case this is a retry after a serialization failure
+		published, last, more = 0, after, false
+		roundBlocked = make(map[int64]bool)
+
+		locked, err := store.Outbox().TryLockRelay(ctx)
+		if err != nil || !locked {
+			return err
+		}
+
+		events, err := store.Outbox().ListPending(ctx, after, r.config.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		block := func(event *domain.Event) {
+			for _, id := range event.AccountIDs {
+				roundBlocked[id] = true
+			}
+		}
+
+		now := time.Now()
+		for _, event := range events {
+			last = event.Sequence
+
+			waiting := event.NextAttemptAt.After(now)
+			for _, id := range event.AccountIDs {
+				waiting = waiting || blocked[id] || roundBlocked[id]
+			}
+			if waiting {
+				block(event)
+				continue
+			}
+
+			if err := r.publisher.Publish(ctx, event); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+
+				// An event out of attempts stops holding up the later events of its accounts
+				attempt := event.Attempts + 1
+				if attempt >= r.config.MaxAttempts {
+					r.logger.Error("Event dead-lettered",
+						"event_id", event.ID,
+						"event_type", event.Type,
+						"attempts", attempt,
+						"error", err)
+					if err := store.Outbox().MarkDeadLettered(ctx, event.Sequence, now, err.Error()); err != nil {
+						return err
+					}
+					continue
+				}
+
+				delay := r.backoff(attempt)
+				r.logger.Warn("Event delivery failed",
+					"event_id", event.ID,
+					"event_type", event.Type,
+					"attempt", attempt,
+					"retry_in", delay,
+					"error", err)
+				if err := store.Outbox().MarkFailed(ctx, event.Sequence, now.Add(delay), err.Error()); err != nil {
+					return err
+				}
+				block(event)
+				continue
+			}
+
+			if err := store.Outbox().MarkPublished(ctx, event.Sequence, time.Now()); err != nil {
+				return err
+			}
+			published++
+		}
+
+		// A full batch may have more events behind it
+		more = len(events) == r.config.BatchSize
+		return nil
+	})
+
+	if err == nil {
+		for id := range roundBlocked {
+			blocked[id] = true
+		}
+	}
+	if published > 0 {
+		r.logger.Info("Published outbox events", "count", published)
+	}
+	return published, last, more, err
+}
+
+// backoff is the wait before the next delivery of an event that has failed attempts times
+func (r *OutboxRelay) backoff(attempts int) time.Duration {
+	return retryBackoff(r.config.RetryBaseDelay, r.config.RetryMaxBackoff, attempts)
+}
+
+// retryBackoff doubles base for every failed attempt after the first, up to max
+func retryBackoff(base, max time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if max > 0 && delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// Purge deletes published events older than the retention window in batches, and returns how
+// many were deleted
+func (r *OutboxRelay) Purge(ctx context.Context) (int64, error) {
+	if r.config.Retention <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().Add(-r.config.Retention)
+
+	var total int64
+	for {
+		n, err := r.store.Outbox().PurgePublished(ctx, cutoff, r.config.BatchSize)
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < int64(r.config.BatchSize) {
+			break
+		}
+	}
+
+	if total > 0 {
+		r.logger.Info("Purged published outbox events", "count", total, "cutoff", cutoff)
+	}
+	return total, nil
+}
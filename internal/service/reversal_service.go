@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/repository"
+)
+
+type ReverseTransferRequest struct {
+	TransactionID  string
+	Amount         *decimal.Decimal // Nil reverses whatever is left of the original
+	IdempotencyKey *string          // Optional, scoped to ClientID
+	ClientID       string
+}
+
+// ReverseTransfer moves money back from the destination to the source of a completed transfer.
+// The reversal is a new transaction linked to the original through ReversalOf, and the original
+// moves to partially_reversed or reversed. Reversals never exceed the original amount in total.
+func (s *TransactionService) ReverseTransfer(ctx context.Context, req *ReverseTransferRequest) (*domain.Transaction, error) {
+	s.logger.Info("Processing reversal",
+		"transaction_id", req.TransactionID,
+		"amount", req.Amount,
+		"client_id", req.ClientID,
+		"idempotency_key", req.IdempotencyKey)
+
+	originalID, err := uuid.Parse(req.TransactionID)
+	if err != nil {
+		return nil, errors.ErrInvalidTransactionID
+	}
+
+	if req.Amount != nil {
+		if req.Amount.IsNegative() || req.Amount.IsZero() {
+			return nil, errors.NewAppError(errors.InvalidAmount, "amount must be positive")
+		}
+	}
+
+	var requestHash string
+	if req.IdempotencyKey != nil {
+		requestHash = fingerprintReversal(originalID, req.Amount)
+	}
+
+	var reversal *domain.Transaction
+	var declined *errors.AppError
+
+	err = s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		// Reset outcome in case this is a retry after a serialization failure
+		reversal, declined = nil, nil
+
+		if req.IdempotencyKey != nil {
+			existingTx, err := store.Transaction().GetTransactionByIDempotencyKey(ctx, req.ClientID, *req.IdempotencyKey)
+			if err != nil {
+				return err
+			}
+			if existingTx != nil {
+				if existingTx.RequestHash != requestHash {
+					s.logger.Warn("Idempotency key reused with a different payload",
+						"idempotency_key", req.IdempotencyKey,
+						"transaction_id", existingTx.ID)
+					return errors.ErrIdempotencyKeyReused
+				}
+
+				s.logger.Info("Returning existing reversal for idempotency key",
+					"idempotency_key", req.IdempotencyKey,
+					"transaction_id", existingTx.ID)
+				existingTx.Replayed = true
+				reversal = existingTx
+				if existingTx.Status == "failed" {
+					declined = failedTransferError(existingTx)
+				}
+				return nil
+			}
+		}
+
+		// Lock the original first so concurrent reversals of it are serialised
+		original, err := store.Transaction().GetTransactionForUpdate(ctx, originalID)
+		if err != nil {
+			return err
+		}
+		if original == nil {
+			return errors.ErrTransactionNotFound
+		}
+
+		if original.ReversalOf != nil ||
+			(original.Status != "completed" && original.Status != domain.StatusPartiallyReversed) {
+			return errors.ErrNotReversible
+		}
+
+		remaining := original.Amount.Sub(original.ReversedAmount)
+		amount := remaining
+		if req.Amount != nil {
+			if err := validateAmountPrecision(*req.Amount, original.Currency); err != nil {
+				return err
+			}
+			amount = *req.Amount
+		}
+		if amount.GreaterThan(remaining) {
+			return errors.NewAppError(errors.ReversalExceedsAmount, errors.ErrReversalExceedsAmount.Message).
+				WithDetails("remaining: " + remaining.String())
+		}
+
+		// The reversal flows from the original destination back to the original source, and
+		// refunds its share of the fee from the fee account
+		sourceID, destID := original.DestinationAccountID, original.SourceAccountID
+		if err := authorizeDebit(ctx, sourceID); err != nil {
+			return err
+		}
+		fee := reversalFeeShare(original, original.ReversedAmount.Add(amount)).Sub(reversalFeeShare(original, original.ReversedAmount))
+
+		lockIDs := []int64{sourceID, destID}
+		if fee.IsPositive() {
+			lockIDs = append(lockIDs, *original.FeeAccountID)
+		}
+		locked, err := lockAccounts(ctx, store, lockIDs...)
+		if err != nil {
+			return err
+		}
+		sourceAccount, destAccount := locked[sourceID], locked[destID]
+
+		if err := checkAccountStatus(sourceAccount, destAccount); err != nil {
+			return err
+		}
+		var feeAccount *domain.Account
+		if fee.IsPositive() {
+			feeAccount = locked[*original.FeeAccountID]
+			if err := checkAccountStatus(feeAccount, destAccount); err != nil {
+				return err
+			}
+		}
+
+		reversal = &domain.Transaction{
+			ID:                   uuid.New(),
+			SourceAccountID:      sourceID,
+			DestinationAccountID: destID,
+			Amount:               amount,
+			Currency:             original.Currency,
+			IdempotencyKey:       req.IdempotencyKey,
+			ClientID:             req.ClientID,
+			InitiatedBy:          initiatedBy(ctx),
+			RequestHash:          requestHash,
+			Status:               "pending",
+			ReversalOf:           &original.ID,
+		}
+		if fee.IsPositive() {
+			reversal.Fee, reversal.FeeAccountID = fee, original.FeeAccountID
+		}
+		if original.DestinationAmount != nil {
+			if err := convertReversal(original, reversal); err != nil {
+				return err
+			}
+		} else {
+			// The destination only received the net amount, so only that is taken back from it
+			reversal.Amount = amount.Sub(fee)
+			if !reversal.Amount.IsPositive() {
+				return errors.NewAppError(errors.InvalidAmount, "reversal amount is too small to cover its share of the fee").
+					WithDetails("fee: " + fee.String() + " " + original.Currency)
+			}
+		}
+
+		if err := store.Transaction().CreateTransaction(ctx, reversal); err != nil {
+			return err
+		}
+
+		// A declined reversal is committed as failed, exactly like a declined transfer
+		if sourceAccount.SpendableBalance().LessThan(reversal.Amount) ||
+			(feeAccount != nil && feeAccount.SpendableBalance().LessThan(fee)) {
+			reason := string(errors.InsufficientBalance)
+			if err := store.Transaction().MarkTransactionFailed(ctx, reversal.ID, reason); err != nil {
+				return err
+			}
+			reversal.Status = "failed"
+			reversal.FailureReason = &reason
+			declined = failedTransferError(reversal)
+			return enqueueTransferEvent(ctx, store, reversal)
+		}
+
+		if err := postLedgerEntries(ctx, store, reversal); err != nil {
+			return err
+		}
+
+		status := domain.StatusPartiallyReversed
+		if amount.Equal(remaining) {
+			status = domain.StatusReversed
+		}
+		if err := store.Transaction().RecordReversal(ctx, original.ID, amount, status); err != nil {
+			return err
+		}
+
+		reversal.Status = "completed"
+		if err := store.Transaction().UpdateTransactionStatus(ctx, reversal.ID, "completed"); err != nil {
+			return err
+		}
+		return enqueueTransferEvent(ctx, store, reversal)
+	})
+
+	if err != nil {
+		s.logger.Error("Reversal failed", "transaction_id", req.TransactionID, "error", err)
+		return nil, err
+	}
+
+	if declined != nil {
+		s.logger.Warn("Reversal declined", "transaction_id", reversal.ID, "failure_reason", declined.Code)
+		return nil, declined
+	}
+
+	s.logger.Info("Reversal completed successfully", "transaction_id", reversal.ID, "reversal_of", originalID)
+	return reversal, nil
+}
+
+// reversalFeeShare is the part of a transfer's fee covered by reversing total of its amount.
+// Shares are computed on the running reversed total, so the partial reversals of a transfer
+// refund exactly its fee.
+func reversalFeeShare(original *domain.Transaction, total decimal.Decimal) decimal.Decimal {
+	if !original.Fee.IsPositive() {
+		return decimal.Zero
+	}
+	return domain.RoundAmount(original.Fee.Mul(total).Div(original.Amount), original.Currency)
+}
+
+// convertReversal turns a reversal of an FX transfer into the opposite conversion at the original
+// rate. The reversal refunds its amount in the original source currency and takes back the share
+// of the converted amount it covers, which excludes the fee. Shares are computed on the running
+// reversed total, so the partial reversals of a transfer add up to exactly its converted amount.
+func convertReversal(original, reversal *domain.Transaction) error {
+	share := func(total decimal.Decimal) decimal.Decimal {
+		net := total.Sub(reversalFeeShare(original, total))
+		return domain.RoundAmount(net.Mul(*original.FXRate), *original.DestinationCurrency)
+	}
+
+	debit := share(original.ReversedAmount.Add(reversal.Amount)).Sub(share(original.ReversedAmount))
+	if !debit.IsPositive() {
+		return errors.NewAppError(errors.InvalidAmount, "reversal amount is too small to convert").
+			WithDetails("minimum: " + domain.MinorUnit(*original.DestinationCurrency).String() + " " + *original.DestinationCurrency)
+	}
+
+	refund, rate := reversal.Amount, decimal.NewFromInt(1).DivRound(*original.FXRate, fxRatePrecision)
+	reversal.Amount = debit
+	reversal.Currency = *original.DestinationCurrency
+	reversal.DestinationAmount = &refund
+	reversal.DestinationCurrency = &original.Currency
+	reversal.FXRate = &rate
+	return nil
+}
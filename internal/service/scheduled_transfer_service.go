@@ -0,0 +1,356 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/repository"
+)
+
+const (
+	scheduleBatchSize = 100
+
+	// scheduleLease is how long a claimed transfer may stay running before another worker
+	// claims it again. Execution is idempotent, so a re-run never moves money twice.
+	scheduleLease = 5 * time.Minute
+)
+
+// ScheduledTransferService stores future-dated transfers and runs them through
+// TransactionService.Transfer once they are due
+type ScheduledTransferService struct {
+	store        *repository.Store
+	logger       *slog.Logger
+	transactions *TransactionService
+}
+
+func NewScheduledTransferService(
+	store *repository.Store,
+	logger *slog.Logger,
+	transactions *TransactionService,
+) *ScheduledTransferService {
+	return &ScheduledTransferService{
+		store:        store,
+		logger:       logger,
+		transactions: transactions,
+	}
+}
+
+type ScheduleTransferRequest struct {
+	SourceAccountID      string
+	DestinationAccountID string
+	Amount               decimal.Decimal
+	ExecuteAt            time.Time
+	Currency             string  // Optional ISO 4217 code; checked against the accounts when scheduling
+	IdempotencyKey       *string // Optional, scoped to ClientID
+	ClientID             string
+}
+
+func (s *ScheduledTransferService) ScheduleTransfer(ctx context.Context, req *ScheduleTransferRequest) (*domain.ScheduledTransfer, error) {
+	s.logger.Info("Scheduling transfer",
+		"source_account_id", req.SourceAccountID,
+		"destination_account_id", req.DestinationAccountID,
+		"amount", req.Amount,
+		"execute_at", req.ExecuteAt,
+		"client_id", req.ClientID,
+		"idempotency_key", req.IdempotencyKey)
+
+	sourceID, destID, err := parseAccountIDs(req.SourceAccountID, req.DestinationAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateTransfer(sourceID, destID, req.Amount); err != nil {
+		return nil, err
+	}
+	if err := authorizeDebit(ctx, sourceID); err != nil {
+		return nil, err
+	}
+
+	requested, err := normalizeCurrency(req.Currency, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var requestHash string
+	if req.IdempotencyKey != nil {
+		requestHash = fingerprintScheduledTransfer(sourceID, destID, req.Amount, requested, req.ExecuteAt)
+	}
+
+	var scheduled *domain.ScheduledTransfer
+
+	err = s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		// Reset outcome in case this is a retry after a serialization failure
+		scheduled = nil
+
+		if req.IdempotencyKey != nil {
+			existing, err := store.ScheduledTransfer().GetScheduledTransferByIdempotencyKey(ctx, req.ClientID, *req.IdempotencyKey)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				if existing.RequestHash != requestHash {
+					s.logger.Warn("Idempotency key reused with a different payload",
+						"idempotency_key", req.IdempotencyKey,
+						"scheduled_transfer_id", existing.ID)
+					return errors.ErrIdempotencyKeyReused
+				}
+
+				existing.Replayed = true
+				scheduled = existing
+				return nil
+			}
+		}
+
+		// The time check sits after the replay lookup so a retried request is not rejected once execute_at has passed
+		if !req.ExecuteAt.After(time.Now()) {
+			return errors.NewAppError(errors.InvalidInput, "execute_at must be in the future")
+		}
+
+		// Reject unknown accounts and mismatched currencies now rather than when the transfer runs
+		source, err := store.Account().GetAccount(ctx, sourceID)
+		if err != nil {
+			return err
+		}
+		dest, err := store.Account().GetAccount(ctx, destID)
+		if err != nil {
+			return err
+		}
+		currency, err := checkTransferCurrency(source, dest, requested, req.Amount)
+		if err != nil {
+			return err
+		}
+
+		scheduled = &domain.ScheduledTransfer{
+			ID:                   uuid.New(),
+			SourceAccountID:      sourceID,
+			DestinationAccountID: destID,
+			Amount:               req.Amount,
+			Currency:             currency,
+			ExecuteAt:            req.ExecuteAt,
+			Status:               domain.ScheduledStatusScheduled,
+			ClientID:             req.ClientID,
+			IdempotencyKey:       req.IdempotencyKey,
+			InitiatedBy:          initiatedBy(ctx),
+			RequestHash:          requestHash,
+		}
+
+		return store.ScheduledTransfer().CreateScheduledTransfer(ctx, scheduled)
+	})
+
+	if err != nil {
+		s.logger.Error("Scheduling transfer failed", "error", err)
+		return nil, err
+	}
+
+	return scheduled, nil
+}
+
+func (s *ScheduledTransferService) GetScheduledTransfer(ctx context.Context, scheduledID string) (*domain.ScheduledTransfer, error) {
+	id, err := uuid.Parse(scheduledID)
+	if err != nil {
+		return nil, errors.ErrInvalidScheduledID
+	}
+
+	scheduled, err := s.store.ScheduledTransfer().GetScheduledTransfer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if scheduled == nil {
+		return nil, errors.ErrScheduledNotFound
+	}
+
+	return scheduled, nil
+}
+
+// CancelScheduledTransfer cancels a transfer that has not been picked up by the scheduler yet.
+// Cancelling an already cancelled transfer returns it unchanged.
+func (s *ScheduledTransferService) CancelScheduledTransfer(ctx context.Context, scheduledID string) (*domain.ScheduledTransfer, error) {
+	s.logger.Info("Cancelling scheduled transfer", "scheduled_transfer_id", scheduledID)
+
+	id, err := uuid.Parse(scheduledID)
+	if err != nil {
+		return nil, errors.ErrInvalidScheduledID
+	}
+
+	scheduled, err := s.store.ScheduledTransfer().GetScheduledTransfer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if scheduled == nil {
+		return nil, errors.ErrScheduledNotFound
+	}
+	if err := authorizeDebit(ctx, scheduled.SourceAccountID); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.store.ScheduledTransfer().CancelScheduledTransfer(ctx, id); err != nil {
+		return nil, err
+	}
+
+	scheduled, err = s.store.ScheduledTransfer().GetScheduledTransfer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if scheduled == nil {
+		return nil, errors.ErrScheduledNotFound
+	}
+	if scheduled.Status != domain.ScheduledStatusCancelled {
+		return nil, errors.NewAppError(errors.NotCancellable, errors.ErrNotCancellable.Message).
+			WithDetails("status: " + scheduled.Status)
+	}
+
+	return scheduled, nil
+}
+
+// RunDue claims and executes due transfers in batches and returns how many were executed
+func (s *ScheduledTransferService) RunDue(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		now := time.Now()
+		due, err := s.store.ScheduledTransfer().ClaimDueScheduledTransfers(ctx, now, now.Add(-scheduleLease), scheduleBatchSize)
+		if err != nil {
+			return total, err
+		}
+
+		for _, scheduled := range due {
+			if err := s.execute(ctx, scheduled); err != nil {
+				return total, err
+			}
+			total++
+		}
+
+		if len(due) < scheduleBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// execute runs one claimed transfer through the normal Transfer path, in the currency it was
+// scheduled in. The idempotency key is derived from the scheduled transfer, so a re-claimed
+// transfer returns the original outcome instead of moving money again.
+func (s *ScheduledTransferService) execute(ctx context.Context, scheduled *domain.ScheduledTransfer) error {
+	key := "scheduled-transfer:" + scheduled.ID.String()
+
+	transactionID, failureReason, err := s.transactions.runJobTransfer(ctx,
+		scheduled.SourceAccountID, scheduled.DestinationAccountID, scheduled.Amount, scheduled.Currency, scheduled.ClientID, key, scheduled.InitiatedBy)
+	if err != nil {
+		// Leave it running; it is claimed again once the lease runs out
+		s.logger.Error("Scheduled transfer could not run", "scheduled_transfer_id", scheduled.ID, "error", err)
+		return nil
+	}
+
+	if failureReason != nil {
+		s.logger.Warn("Scheduled transfer failed", "scheduled_transfer_id", scheduled.ID, "failure_reason", *failureReason)
+		return s.store.ScheduledTransfer().FinishScheduledTransfer(ctx, scheduled.ID, domain.ScheduledStatusFailed, transactionID, failureReason)
+	}
+
+	return s.store.ScheduledTransfer().FinishScheduledTransfer(ctx, scheduled.ID, domain.ScheduledStatusCompleted, transactionID, nil)
+}
+
+// runJobTransfer runs a transfer on behalf of a background job. A declined transfer returns
+// its failure reason along with the failed transaction, when one was recorded. An error means
+// the transfer could not run at all and the job should try again later with the same key.
+// An empty currency runs the transfer in whatever currency the accounts are in. A transfer
+// recorded by a run from before jobs passed their currency still replays under the same key.
+func (s *TransactionService) runJobTransfer(ctx context.Context, sourceID, destID int64, amount decimal.Decimal,
+	currency, clientID, key string, initiatedBy *string) (*uuid.UUID, *string, error) {
+	transaction, err := s.Transfer(ctx, &TransferRequest{
+		SourceAccountID:      strconv.FormatInt(sourceID, 10),
+		DestinationAccountID: strconv.FormatInt(destID, 10),
+		Amount:               amount,
+		Currency:             currency,
+		IdempotencyKey:       &key,
+		ClientID:             clientID,
+		InitiatedBy:          initiatedBy,
+		legacyHash:           fingerprintTransfer(sourceID, destID, amount, "", ""),
+	})
+	if err == nil {
+		return &transaction.ID, nil, nil
+	}
+
+	appErr, ok := err.(*errors.AppError)
+	if ctx.Err() != nil || !ok || appErr.Code == errors.InternalError || appErr.Code == errors.CannotBeginTransaction {
+		return nil, nil, err
+	}
+
+	// Declined transfers are persisted as failed transactions; link them when one exists
+	var transactionID *uuid.UUID
+	if failed, err := s.store.Transaction().GetTransactionByIDempotencyKey(ctx, clientID, key); err == nil && failed != nil {
+		transactionID = &failed.ID
+	}
+
+	reason := string(appErr.Code)
+	return transactionID, &reason, nil
+}
+
+// Scheduler periodically runs due scheduled transfers and standing orders. Claims use
+// FOR UPDATE SKIP LOCKED, so every replica can run a Scheduler against the same database.
+type Scheduler struct {
+	scheduled      *ScheduledTransferService
+	standingOrders *StandingOrderService
+	logger         *slog.Logger
+	interval       time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler(scheduled *ScheduledTransferService, standingOrders *StandingOrderService, logger *slog.Logger, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		scheduled:      scheduled,
+		standingOrders: standingOrders,
+		logger:         logger,
+		interval:       interval,
+	}
+}
+
+// Start launches the background scheduling loop. It is a no-op when interval is not positive.
+func (s *Scheduler) Start() {
+	if s.interval <= 0 {
+		s.logger.Info("Transfer scheduler disabled")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.scheduled.RunDue(ctx); err != nil && ctx.Err() == nil {
+					s.logger.Error("Scheduled transfer run failed", "error", err)
+				}
+				if _, err := s.standingOrders.RunDue(ctx); err != nil && ctx.Err() == nil {
+					s.logger.Error("Standing order run failed", "error", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	s.logger.Info("Transfer scheduler started", "interval", s.interval)
+}
+
+// Stop cancels the scheduling loop, including an in-flight run, and waits for it to exit
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
@@ -0,0 +1,522 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/repository"
+)
+
+// StandingOrderService manages recurring transfers. Due periods are run by the Scheduler,
+// each through TransactionService.Transfer with an idempotency key derived from the order
+// and the period, so a period can never be paid twice.
+type StandingOrderService struct {
+	store        *repository.Store
+	logger       *slog.Logger
+	transactions *TransactionService
+}
+
+func NewStandingOrderService(
+	store *repository.Store,
+	logger *slog.Logger,
+	transactions *TransactionService,
+) *StandingOrderService {
+	return &StandingOrderService{
+		store:        store,
+		logger:       logger,
+		transactions: transactions,
+	}
+}
+
+// orderSchedule yields the periods of a standing order
+type orderSchedule interface {
+	next(after time.Time) time.Time // First period strictly after the given time; zero when there is none
+}
+
+// intervalSchedule runs at start and every fixed interval after it
+type intervalSchedule struct {
+	start time.Time
+	every time.Duration
+}
+
+func (s intervalSchedule) next(after time.Time) time.Time {
+	if after.Before(s.start) {
+		return s.start
+	}
+	periods := after.Sub(s.start)/s.every + 1
+	return s.start.Add(periods * s.every)
+}
+
+func scheduleOf(order *domain.StandingOrder) (orderSchedule, error) {
+	if order.CronExpression != "" {
+		schedule, err := parseCron(order.CronExpression)
+		if err != nil {
+			return nil, errors.NewAppError(errors.InvalidInput, "invalid cron expression").WithDetails(err.Error())
+		}
+		return schedule, nil
+	}
+
+	return intervalSchedule{start: order.StartAt, every: order.Interval}, nil
+}
+
+// nextRun returns the first period of the order strictly after the given time, or nil when there is none
+func nextRun(schedule orderSchedule, after time.Time) *time.Time {
+	next := schedule.next(after)
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// settleStandingOrder completes an order once it has used up its runs or its next period
+// falls after the end date. Finished orders have no next run.
+func settleStandingOrder(order *domain.StandingOrder) {
+	if order.Status == domain.StandingOrderStatusCancelled || order.Status == domain.StandingOrderStatusCompleted {
+		order.NextRunAt = nil
+		return
+	}
+
+	if order.NextRunAt == nil ||
+		(order.MaxRuns != nil && order.RunCount >= *order.MaxRuns) ||
+		(order.EndAt != nil && order.NextRunAt.After(*order.EndAt)) {
+		order.Status = domain.StandingOrderStatusCompleted
+		order.NextRunAt = nil
+	}
+}
+
+func isFinished(order *domain.StandingOrder) bool {
+	return order.Status == domain.StandingOrderStatusCompleted || order.Status == domain.StandingOrderStatusCancelled
+}
+
+type CreateStandingOrderRequest struct {
+	SourceAccountID      string
+	DestinationAccountID string
+	Amount               decimal.Decimal
+	CronExpression       string        // Either a cron expression...
+	Interval             time.Duration // ...or a fixed interval of whole seconds
+	StartAt              *time.Time    // Defaults to now
+	EndAt                *time.Time    // Optional
+	MaxRuns              *int          // Optional
+	IdempotencyKey       *string       // Optional, scoped to ClientID
+	ClientID             string
+}
+
+func (s *StandingOrderService) CreateStandingOrder(ctx context.Context, req *CreateStandingOrderRequest) (*domain.StandingOrder, error) {
+	s.logger.Info("Creating standing order",
+		"source_account_id", req.SourceAccountID,
+		"destination_account_id", req.DestinationAccountID,
+		"amount", req.Amount,
+		"cron_expression", req.CronExpression,
+		"interval", req.Interval,
+		"client_id", req.ClientID,
+		"idempotency_key", req.IdempotencyKey)
+
+	sourceID, destID, err := parseAccountIDs(req.SourceAccountID, req.DestinationAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateTransfer(sourceID, destID, req.Amount); err != nil {
+		return nil, err
+	}
+	if err := authorizeDebit(ctx, sourceID); err != nil {
+		return nil, err
+	}
+
+	if (req.CronExpression == "") == (req.Interval == 0) {
+		return nil, errors.NewAppError(errors.InvalidInput, "exactly one of cron and interval is required")
+	}
+	if req.Interval < 0 || req.Interval%time.Second != 0 {
+		return nil, errors.NewAppError(errors.InvalidInput, "interval must be a positive whole number of seconds")
+	}
+	if req.MaxRuns != nil && *req.MaxRuns <= 0 {
+		return nil, errors.NewAppError(errors.InvalidInput, "max_runs must be positive")
+	}
+
+	startAt := time.Now()
+	if req.StartAt != nil {
+		startAt = *req.StartAt
+	}
+	if req.EndAt != nil && !req.EndAt.After(startAt) {
+		return nil, errors.NewAppError(errors.InvalidInput, "end_at must be after start_at")
+	}
+
+	order := &domain.StandingOrder{
+		ID:                   uuid.New(),
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               req.Amount,
+		CronExpression:       req.CronExpression,
+		Interval:             req.Interval,
+		StartAt:              startAt,
+		EndAt:                req.EndAt,
+		MaxRuns:              req.MaxRuns,
+		Status:               domain.StandingOrderStatusActive,
+		ClientID:             req.ClientID,
+		IdempotencyKey:       req.IdempotencyKey,
+		InitiatedBy:          initiatedBy(ctx),
+	}
+
+	schedule, err := scheduleOf(order)
+	if err != nil {
+		return nil, err
+	}
+	order.NextRunAt = nextRun(schedule, startAt.Add(-time.Nanosecond))
+	if order.NextRunAt == nil {
+		return nil, errors.NewAppError(errors.InvalidInput, "cron expression never matches")
+	}
+	if req.EndAt != nil && order.NextRunAt.After(*req.EndAt) {
+		return nil, errors.NewAppError(errors.InvalidInput, "schedule has no runs before end_at")
+	}
+
+	if req.IdempotencyKey != nil {
+		order.RequestHash = fingerprintStandingOrder(sourceID, destID, req.Amount, req.CronExpression, req.Interval,
+			req.StartAt, req.EndAt, req.MaxRuns)
+	}
+
+	var created *domain.StandingOrder
+
+	err = s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		// Reset outcome in case this is a retry after a serialization failure
+		created = nil
+
+		if req.IdempotencyKey != nil {
+			existing, err := store.StandingOrder().GetStandingOrderByIdempotencyKey(ctx, req.ClientID, *req.IdempotencyKey)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				if existing.RequestHash != order.RequestHash {
+					s.logger.Warn("Idempotency key reused with a different payload",
+						"idempotency_key", req.IdempotencyKey,
+						"standing_order_id", existing.ID)
+					return errors.ErrIdempotencyKeyReused
+				}
+
+				existing.Replayed = true
+				created = existing
+				return nil
+			}
+		}
+
+		// The time check sits after the replay lookup so a retried request is not rejected once start_at has passed
+		if req.StartAt != nil && req.StartAt.Before(time.Now()) {
+			return errors.NewAppError(errors.InvalidInput, "start_at must not be in the past")
+		}
+
+		// Reject unknown accounts and mismatched currencies now rather than on the first run
+		source, err := store.Account().GetAccount(ctx, sourceID)
+		if err != nil {
+			return err
+		}
+		dest, err := store.Account().GetAccount(ctx, destID)
+		if err != nil {
+			return err
+		}
+		if _, err := checkTransferCurrency(source, dest, "", req.Amount); err != nil {
+			return err
+		}
+
+		if err := store.StandingOrder().CreateStandingOrder(ctx, order); err != nil {
+			return err
+		}
+		created = order
+		return nil
+	})
+
+	if err != nil {
+		s.logger.Error("Creating standing order failed", "error", err)
+		return nil, err
+	}
+
+	return created, nil
+}
+
+func (s *StandingOrderService) GetStandingOrder(ctx context.Context, orderID string) (*domain.StandingOrder, error) {
+	id, err := uuid.Parse(orderID)
+	if err != nil {
+		return nil, errors.ErrInvalidStandingOrderID
+	}
+
+	order, err := s.store.StandingOrder().GetStandingOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, errors.ErrStandingOrderNotFound
+	}
+
+	return order, nil
+}
+
+// ListAccountStandingOrders returns the standing orders paying out of an account, oldest first
+func (s *StandingOrderService) ListAccountStandingOrders(ctx context.Context, accountIDStr string) ([]*domain.StandingOrder, error) {
+	accountID, err := strconv.ParseInt(accountIDStr, 10, 64)
+	if err != nil {
+		return nil, errors.ErrInvalidAccountID
+	}
+
+	if _, err := s.store.Account().GetAccount(ctx, accountID); err != nil {
+		return nil, err
+	}
+
+	return s.store.StandingOrder().ListStandingOrdersByAccount(ctx, accountID)
+}
+
+type UpdateStandingOrderRequest struct {
+	StandingOrderID string
+	Amount          *decimal.Decimal // Nil fields are left unchanged
+	EndAt           *time.Time
+	MaxRuns         *int
+}
+
+// UpdateStandingOrder changes the amount, end date or maximum run count of an active or paused
+// order. Lowering either limit below what has already run completes the order.
+func (s *StandingOrderService) UpdateStandingOrder(ctx context.Context, req *UpdateStandingOrderRequest) (*domain.StandingOrder, error) {
+	s.logger.Info("Updating standing order", "standing_order_id", req.StandingOrderID)
+
+	if req.MaxRuns != nil && *req.MaxRuns <= 0 {
+		return nil, errors.NewAppError(errors.InvalidInput, "max_runs must be positive")
+	}
+
+	return s.modify(ctx, req.StandingOrderID, func(store *repository.Store, order *domain.StandingOrder) error {
+		if isFinished(order) {
+			return finishedOrderError(order)
+		}
+
+		if req.Amount != nil {
+			if err := validateTransfer(order.SourceAccountID, order.DestinationAccountID, *req.Amount); err != nil {
+				return err
+			}
+			source, err := store.Account().GetAccount(ctx, order.SourceAccountID)
+			if err != nil {
+				return err
+			}
+			if err := validateAmountPrecision(*req.Amount, source.Currency); err != nil {
+				return err
+			}
+			order.Amount = *req.Amount
+		}
+		if req.EndAt != nil {
+			if !req.EndAt.After(order.StartAt) {
+				return errors.NewAppError(errors.InvalidInput, "end_at must be after start_at")
+			}
+			order.EndAt = req.EndAt
+		}
+		if req.MaxRuns != nil {
+			order.MaxRuns = req.MaxRuns
+		}
+
+		settleStandingOrder(order)
+		return nil
+	})
+}
+
+// PauseStandingOrder stops an active order from running. Pausing a paused order returns it unchanged.
+func (s *StandingOrderService) PauseStandingOrder(ctx context.Context, orderID string) (*domain.StandingOrder, error) {
+	s.logger.Info("Pausing standing order", "standing_order_id", orderID)
+
+	return s.modify(ctx, orderID, func(_ *repository.Store, order *domain.StandingOrder) error {
+		if isFinished(order) {
+			return finishedOrderError(order)
+		}
+
+		order.Status = domain.StandingOrderStatusPaused
+		return nil
+	})
+}
+
+// ResumeStandingOrder reactivates a paused order. Periods that fell due while it was paused are
+// skipped; the next run is the first period from now on. Resuming an active order returns it unchanged.
+func (s *StandingOrderService) ResumeStandingOrder(ctx context.Context, orderID string) (*domain.StandingOrder, error) {
+	s.logger.Info("Resuming standing order", "standing_order_id", orderID)
+
+	return s.modify(ctx, orderID, func(_ *repository.Store, order *domain.StandingOrder) error {
+		if isFinished(order) {
+			return finishedOrderError(order)
+		}
+		if order.Status == domain.StandingOrderStatusActive {
+			return nil
+		}
+
+		now := time.Now()
+		if order.NextRunAt == nil || !order.NextRunAt.After(now) {
+			schedule, err := scheduleOf(order)
+			if err != nil {
+				return err
+			}
+			order.NextRunAt = nextRun(schedule, now)
+		}
+
+		order.Status = domain.StandingOrderStatusActive
+		settleStandingOrder(order)
+		return nil
+	})
+}
+
+// CancelStandingOrder stops an order for good; its run history is kept. Cancelling a
+// cancelled order returns it unchanged.
+func (s *StandingOrderService) CancelStandingOrder(ctx context.Context, orderID string) (*domain.StandingOrder, error) {
+	s.logger.Info("Cancelling standing order", "standing_order_id", orderID)
+
+	return s.modify(ctx, orderID, func(_ *repository.Store, order *domain.StandingOrder) error {
+		if order.Status == domain.StandingOrderStatusCompleted {
+			return finishedOrderError(order)
+		}
+
+		order.Status = domain.StandingOrderStatusCancelled
+		settleStandingOrder(order)
+		return nil
+	})
+}
+
+// modify locks an order, applies change within the same database transaction and saves the result.
+// Only principals that may debit the order's source account may change it.
+func (s *StandingOrderService) modify(ctx context.Context, orderID string,
+	change func(*repository.Store, *domain.StandingOrder) error) (*domain.StandingOrder, error) {
+	id, err := uuid.Parse(orderID)
+	if err != nil {
+		return nil, errors.ErrInvalidStandingOrderID
+	}
+
+	var order *domain.StandingOrder
+
+	err = s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		var err error
+		order, err = store.StandingOrder().GetStandingOrderForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if order == nil {
+			return errors.ErrStandingOrderNotFound
+		}
+		if err := authorizeDebit(ctx, order.SourceAccountID); err != nil {
+			return err
+		}
+
+		if err := change(store, order); err != nil {
+			return err
+		}
+
+		return store.StandingOrder().UpdateStandingOrder(ctx, order)
+	})
+
+	if err != nil {
+		s.logger.Error("Standing order update failed", "standing_order_id", orderID, "error", err)
+		return nil, err
+	}
+
+	return order, nil
+}
+
+func finishedOrderError(order *domain.StandingOrder) *errors.AppError {
+	return errors.NewAppError(errors.StandingOrderFinished, errors.ErrStandingOrderFinished.Message).
+		WithDetails("status: " + order.Status)
+}
+
+// ListStandingOrderRuns returns the run history of an order, oldest period first
+func (s *StandingOrderService) ListStandingOrderRuns(ctx context.Context, orderID string) ([]*domain.StandingOrderRun, error) {
+	order, err := s.GetStandingOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.StandingOrder().ListStandingOrderRuns(ctx, order.ID)
+}
+
+// RunDue claims and runs due standing orders in batches and returns how many periods were run.
+// An order that is several periods behind runs one period per claim, oldest first.
+func (s *StandingOrderService) RunDue(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		now := time.Now()
+		due, err := s.store.StandingOrder().ClaimDueStandingOrders(ctx, now, now.Add(scheduleLease), scheduleBatchSize)
+		if err != nil {
+			return total, err
+		}
+
+		for _, order := range due {
+			ran, err := s.execute(ctx, order)
+			if err != nil {
+				return total, err
+			}
+			if ran {
+				total++
+			}
+		}
+
+		if len(due) < scheduleBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// execute runs the due period of a claimed order, records the run and advances the order to its
+// next period. It reports false when the transfer could not run; the order then stays leased and
+// the same period is retried once the lease runs out.
+func (s *StandingOrderService) execute(ctx context.Context, claimed *domain.StandingOrder) (bool, error) {
+	period := *claimed.NextRunAt
+	key := fmt.Sprintf("standing-order:%s:%s", claimed.ID, period.UTC().Format(time.RFC3339))
+
+	transactionID, failureReason, err := s.transactions.runJobTransfer(ctx,
+		claimed.SourceAccountID, claimed.DestinationAccountID, claimed.Amount, "", claimed.ClientID, key, claimed.InitiatedBy)
+	if err != nil {
+		s.logger.Error("Standing order could not run", "standing_order_id", claimed.ID, "period", period, "error", err)
+		return false, nil
+	}
+
+	run := &domain.StandingOrderRun{
+		ID:              uuid.New(),
+		StandingOrderID: claimed.ID,
+		Period:          period,
+		Status:          "completed",
+		TransactionID:   transactionID,
+		FailureReason:   failureReason,
+	}
+	if failureReason != nil {
+		run.Status = "failed"
+		s.logger.Warn("Standing order run failed", "standing_order_id", claimed.ID, "period", period, "failure_reason", *failureReason)
+	}
+
+	err = s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		// Re-read under lock; the order may have been edited, paused or cancelled during the run
+		order, err := store.StandingOrder().GetStandingOrderForUpdate(ctx, claimed.ID)
+		if err != nil {
+			return err
+		}
+		if order == nil {
+			return errors.ErrStandingOrderNotFound
+		}
+
+		if err := store.StandingOrder().CreateStandingOrderRun(ctx, run); err != nil {
+			return err
+		}
+
+		schedule, err := scheduleOf(order)
+		if err != nil {
+			return err
+		}
+
+		// Keep a later next run set by a resume during the run, so skipped periods stay skipped
+		next := nextRun(schedule, period)
+		if next != nil && order.NextRunAt != nil && order.NextRunAt.After(*next) {
+			next = order.NextRunAt
+		}
+
+		order.RunCount++
+		order.NextRunAt = next
+		settleStandingOrder(order)
+
+		return store.StandingOrder().AdvanceStandingOrder(ctx, order)
+	})
+
+	return true, err
+}
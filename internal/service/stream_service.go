@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/repository"
+)
+
+// StreamBatchSize is how many events ReadEvents returns at most
+const StreamBatchSize = 100
+
+// StreamEvent is an outbox event as seen from the stream of one of its accounts
+type StreamEvent struct {
+	Sequence  int64
+	Type      string
+	AccountID int64
+	Balance   *decimal.Decimal // The account's balance after the event; nil when it left it unchanged
+	Data      json.RawMessage  // The event's data: the account or the transaction
+	CreatedAt time.Time
+}
+
+// StreamSnapshot is an account along with the last of its events the account reflects
+type StreamSnapshot struct {
+	Account  *domain.Account
+	Sequence int64
+}
+
+// StreamSubscription wakes a stream when events of its account commit. Wakes are coalesced: the
+// stream reads every event after the last one it sent, so a stream busy writing to a slow client
+// misses nothing, and nothing waits on it.
+type StreamSubscription struct {
+	AccountID int64
+
+	service   *StreamService
+	wake      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Wake receives when events of the account may have committed since the stream last read
+func (s *StreamSubscription) Wake() <-chan struct{} {
+	return s.wake
+}
+
+// Done is closed when the service stops, and the stream should end
+func (s *StreamSubscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close unsubscribes
+func (s *StreamSubscription) Close() {
+	s.service.unsubscribe(s)
+}
+
+func (s *StreamSubscription) end() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// StreamService feeds account streams from the outbox. A listener relays the notifications
+// enqueueEvent sends after commit to the streams of the accounts concerned, which then read the
+// new events from the outbox.
+type StreamService struct {
+	store    *repository.Store
+	listener domain.EventListener
+	logger   *slog.Logger
+
+	mu          sync.Mutex
+	subscribers map[int64]map[*StreamSubscription]struct{}
+	stopped     bool
+	wg          sync.WaitGroup
+}
+
+func NewStreamService(store *repository.Store, listener domain.EventListener, logger *slog.Logger) *StreamService {
+	return &StreamService{
+		store:       store,
+		listener:    listener,
+		logger:      logger,
+		subscribers: make(map[int64]map[*StreamSubscription]struct{}),
+	}
+}
+
+// Start relays notifications to the subscribed streams in the background
+func (s *StreamService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		for payload := range s.listener.Notifications() {
+			if payload == "" {
+				// Notifications may have been missed while the listener reconnected
+				s.wakeAll()
+				continue
+			}
+
+			var notification domain.EventNotification
+			if err := json.Unmarshal([]byte(payload), &notification); err != nil {
+				s.logger.Error("Invalid event notification", "payload", payload, "error", err)
+				continue
+			}
+			s.wakeAccounts(notification.AccountIDs)
+		}
+	}()
+
+	s.logger.Info("Account streams started")
+}
+
+// Stop closes the listener and ends every stream
+func (s *StreamService) Stop() {
+	if err := s.listener.Close(); err != nil {
+		s.logger.Error("Failed to close event listener", "error", err)
+	}
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	for _, subscriptions := range s.subscribers {
+		for subscription := range subscriptions {
+			subscription.end()
+		}
+	}
+	s.subscribers = make(map[int64]map[*StreamSubscription]struct{})
+}
+
+// Subscribe registers a stream for an account. Subscribing before reading the outbox means no
+// event committed in between goes unnoticed.
+func (s *StreamService) Subscribe(ctx context.Context, accountID string) (*StreamSubscription, error) {
+	id, err := strconv.ParseInt(accountID, 10, 64)
+	if err != nil || id <= 0 {
+		return nil, errors.ErrInvalidAccountID
+	}
+
+	if _, err := s.store.Account().GetAccount(ctx, id); err != nil {
+		return nil, err
+	}
+
+	subscription := &StreamSubscription{
+		AccountID: id,
+		service:   s,
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		subscription.end()
+		return subscription, nil
+	}
+	if s.subscribers[id] == nil {
+		s.subscribers[id] = make(map[*StreamSubscription]struct{})
+	}
+	s.subscribers[id][subscription] = struct{}{}
+
+	return subscription, nil
+}
+
+func (s *StreamService) unsubscribe(subscription *StreamSubscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscriptions := s.subscribers[subscription.AccountID]
+	delete(subscriptions, subscription)
+	if len(subscriptions) == 0 {
+		delete(s.subscribers, subscription.AccountID)
+	}
+}
+
+func (s *StreamService) wakeAccounts(accountIDs []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range accountIDs {
+		for subscription := range s.subscribers[id] {
+			wake(subscription)
+		}
+	}
+}
+
+func (s *StreamService) wakeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, subscriptions := range s.subscribers {
+		for subscription := range subscriptions {
+			wake(subscription)
+		}
+	}
+}
+
+// wake never blocks: a stream with a wake pending reads the new events along with the others
+func wake(subscription *StreamSubscription) {
+	select {
+	case subscription.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Snapshot returns an account along with the sequence of its latest event, both read from one
+// snapshot so the stream can carry on from that sequence
+func (s *StreamService) Snapshot(ctx context.Context, accountID int64) (*StreamSnapshot, error) {
+	snapshot := &StreamSnapshot{}
+
+	err := s.store.WithTransactionOptions(ctx, repository.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	}, func(store *repository.Store) error {
+		account, err := store.Account().GetAccount(ctx, accountID)
+		if err != nil {
+			return err
+		}
+
+		sequence, err := store.Outbox().LatestAccountSequence(ctx, accountID)
+		if err != nil {
+			return err
+		}
+
+		snapshot.Account, snapshot.Sequence = account, sequence
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// ReadEvents returns up to StreamBatchSize events of an account after a sequence, in order, with
+// the balance each completed transfer or opening left the account with. Events of an account are
+// enqueued while it is locked, so one committing later never has a lower sequence, and reading on
+// from the last sequence sent misses none.
+func (s *StreamService) ReadEvents(ctx context.Context, accountID, afterSequence int64) ([]*StreamEvent, error) {
+	events, err := s.store.Outbox().ListAccountEvents(ctx, accountID, afterSequence, StreamBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	streamEvents := make([]*StreamEvent, 0, len(events))
+	transfers := make(map[*StreamEvent]uuid.UUID) // Completed transfers, whose balance is looked up
+	var transactionIDs []uuid.UUID
+	for _, event := range events {
+		streamEvent := &StreamEvent{
+			Sequence:  event.Sequence,
+			Type:      event.Type,
+			AccountID: accountID,
+			Data:      event.Data,
+			CreatedAt: event.CreatedAt,
+		}
+
+		switch event.Type {
+		case domain.EventAccountCreated:
+			var account domain.Account
+			if err := json.Unmarshal(event.Data, &account); err != nil {
+				return nil, errors.Wrap(err, errors.InternalError, "failed to decode event")
+			}
+			streamEvent.Balance = &account.Balance
+		case domain.EventTransferCompleted:
+			var transaction domain.Transaction
+			if err := json.Unmarshal(event.Data, &transaction); err != nil {
+				return nil, errors.Wrap(err, errors.InternalError, "failed to decode event")
+			}
+			transfers[streamEvent] = transaction.ID
+			transactionIDs = append(transactionIDs, transaction.ID)
+		}
+
+		streamEvents = append(streamEvents, streamEvent)
+	}
+
+	if len(transactionIDs) == 0 {
+		return streamEvents, nil
+	}
+
+	balances, err := s.store.Account().GetBalancesAfter(ctx, accountID, transactionIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for event, transactionID := range transfers {
+		if balance, ok := balances[transactionID]; ok {
+			event.Balance = &balance
+		}
+	}
+
+	return streamEvents, nil
+}
@@ -1,8 +1,13 @@
 package service
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
@@ -15,15 +20,24 @@ import (
 type TransactionService struct {
 	store  *repository.Store
 	logger *slog.Logger
+	rates  domain.RateProvider
+	limits *LimitService
+	fees   *FeeService
 }
 
 func NewTransactionService(
 	store *repository.Store,
 	logger *slog.Logger,
+	rates domain.RateProvider,
+	limits *LimitService,
+	fees *FeeService,
 ) *TransactionService {
 	return &TransactionService{
 		store:  store,
 		logger: logger,
+		rates:  rates,
+		limits: limits,
+		fees:   fees,
 	}
 }
 
@@ -31,74 +45,164 @@ type TransferRequest struct {
 	SourceAccountID      string
 	DestinationAccountID string
 	Amount               decimal.Decimal
-	IdempotencyKey       *uuid.UUID // Now optional
+	Currency             string  // Optional ISO 4217 code; must match the source account when set
+	Convert              bool    // Converts at the market rate when the accounts' currencies differ
+	QuoteID              string  // Optional FX quote to convert with; implies Convert
+	IdempotencyKey       *string // Optional, scoped to ClientID
+	ClientID             string
+	InitiatedBy          *string // Set by background jobs to the principal they run for; defaults to the request's
+
+	// legacyHash is also accepted as the fingerprint of an earlier request with the same key. Set
+	// by background jobs whose earlier runs were fingerprinted without their currency.
+	legacyHash string
 }
 
-func (s *TransactionService) Transfer(req *TransferRequest) (*domain.Transaction, error) {
+func (s *TransactionService) Transfer(ctx context.Context, req *TransferRequest) (*domain.Transaction, error) {
 	s.logger.Info("Processing transfer",
 		"source_account_id", req.SourceAccountID,
 		"destination_account_id", req.DestinationAccountID,
 		"amount", req.Amount,
+		"currency", req.Currency,
+		"convert", req.Convert,
+		"fx_quote_id", req.QuoteID,
+		"client_id", req.ClientID,
 		"idempotency_key", req.IdempotencyKey)
 
 	// Parse account IDs first
-	sourceID, destID, err := s.parseAccountIDs(req.SourceAccountID, req.DestinationAccountID)
+	sourceID, destID, err := parseAccountIDs(req.SourceAccountID, req.DestinationAccountID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Validate transfer
-	if err := s.validateTransfer(sourceID, destID, req.Amount); err != nil {
+	if err := validateTransfer(sourceID, destID, req.Amount); err != nil {
+		return nil, err
+	}
+	if err := authorizeDebit(ctx, sourceID); err != nil {
+		return nil, err
+	}
+	initiator := req.InitiatedBy
+	if initiator == nil {
+		initiator = initiatedBy(ctx)
+	}
+
+	requested, err := normalizeCurrency(req.Currency, "")
+	if err != nil {
 		return nil, err
 	}
 
+	var quoteID *uuid.UUID
+	if req.QuoteID != "" {
+		id, err := uuid.Parse(req.QuoteID)
+		if err != nil {
+			return nil, errors.ErrInvalidFXQuoteID
+		}
+		quoteID = &id
+	}
+
+	// Market rates are fetched before any row is locked, so a slow provider never holds locks
+	var marketRate *decimal.Decimal
+	if req.Convert && quoteID == nil {
+		marketRate, err = s.marketRate(ctx, sourceID, destID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var transaction *domain.Transaction
+	var declined *errors.AppError
+
+	// Fingerprint the payload so a reused key can be told apart from a genuine replay
+	var requestHash string
+	if req.IdempotencyKey != nil {
+		requestHash = fingerprintTransfer(sourceID, destID, req.Amount, requested, fxMode(req.Convert, req.QuoteID))
+	}
 
 	// Process everything in a single database transaction
-	err = s.store.WithTransaction(func(store *repository.Store) error {
+	err = s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		// Reset outcome in case this is a retry after a serialization failure
+		transaction, declined = nil, nil
+
 		// Check for existing transaction with same idempotency key ONLY if provided
 		if req.IdempotencyKey != nil {
-			existingTx, err := store.Transaction().GetTransactionByIDempotencyKey(*req.IdempotencyKey)
+			existingTx, err := store.Transaction().GetTransactionByIDempotencyKey(ctx, req.ClientID, *req.IdempotencyKey)
 			if err != nil {
 				return err
 			}
 			if existingTx != nil {
+				// Rows written before fingerprinting are compared on their stored fields
+				existingHash := existingTx.RequestHash
+				if existingHash == "" {
+					existingHash = fingerprintTransfer(existingTx.SourceAccountID, existingTx.DestinationAccountID, existingTx.Amount, "", "")
+				}
+				if existingHash != requestHash && (req.legacyHash == "" || existingHash != req.legacyHash) {
+					s.logger.Warn("Idempotency key reused with a different payload",
+						"idempotency_key", req.IdempotencyKey,
+						"transaction_id", existingTx.ID)
+					return errors.ErrIdempotencyKeyReused
+				}
+
 				s.logger.Info("Returning existing transaction for idempotency key",
 					"idempotency_key", req.IdempotencyKey,
 					"transaction_id", existingTx.ID)
+				existingTx.Replayed = true
 				transaction = existingTx
+				if existingTx.Status == "failed" {
+					declined = failedTransferError(existingTx)
+				}
 				return nil
 			}
 		}
 
-		// Determine deterministic order by comparing account IDs to avoid deadlocks
-		var firstID, secondID int64
-		if sourceID < destID {
-			firstID, secondID = sourceID, destID
-		} else {
-			firstID, secondID = destID, sourceID
+		// The fee decides which fee account is locked with the source and destination. It only
+		// depends on the source's ID and currency, which never change, so it is priced first.
+		unlockedSource, err := store.Account().GetAccount(ctx, sourceID)
+		if err != nil {
+			return err
 		}
-
-		// Lock first account
-		firstAccount, err := store.Account().GetAccountForUpdate(firstID)
+		fee, err := s.fees.price(ctx, store, unlockedSource, destID, req.Amount)
 		if err != nil {
 			return err
 		}
 
-		// Lock second account
-		secondAccount, err := store.Account().GetAccountForUpdate(secondID)
+		lockIDs := []int64{sourceID, destID}
+		if fee != nil {
+			lockIDs = append(lockIDs, fee.accountID)
+		}
+		locked, err := lockAccounts(ctx, store, lockIDs...)
 		if err != nil {
 			return err
 		}
+		sourceAccount, destAccount := locked[sourceID], locked[destID]
 
-		// Map locked rows back to source and destination
-		var sourceAccount, destAccount *domain.Account
-		if firstID == sourceID {
-			sourceAccount = firstAccount
-			destAccount = secondAccount
+		if err := checkAccountStatus(sourceAccount, destAccount); err != nil {
+			return err
+		}
+		if fee != nil {
+			if err := checkFeeAccount(sourceAccount, locked[fee.accountID]); err != nil {
+				return err
+			}
+		}
+		if err := s.limits.checkTransfer(ctx, store, sourceAccount, req.Amount, decimal.Zero); err != nil {
+			return err
+		}
+
+		// The destination receives the net amount, converted if need be
+		net := req.Amount
+		if fee != nil {
+			net = req.Amount.Sub(fee.amount)
+		}
+
+		// Without a requested conversion both accounts must share a currency, and the amount
+		// must fit its minor unit
+		var conversion *fxConversion
+		if quoteID != nil || (marketRate != nil && sourceAccount.Currency != destAccount.Currency) {
+			conversion, err = prepareConversion(ctx, store, sourceAccount, destAccount, requested, net, marketRate, quoteID, req.ClientID)
 		} else {
-			sourceAccount = secondAccount
-			destAccount = firstAccount
+			_, err = checkTransferCurrency(sourceAccount, destAccount, requested, req.Amount)
+		}
+		if err != nil {
+			return err
 		}
 
 		// Create transaction record as pending INSIDE transaction
@@ -107,39 +211,54 @@ func (s *TransactionService) Transfer(req *TransferRequest) (*domain.Transaction
 			SourceAccountID:      sourceID,
 			DestinationAccountID: destID,
 			Amount:               req.Amount,
+			Currency:             sourceAccount.Currency,
 			IdempotencyKey:       req.IdempotencyKey, // Can be nil
+			ClientID:             req.ClientID,
+			InitiatedBy:          initiator,
+			RequestHash:          requestHash,
 			Status:               "pending",
 		}
+		if fee != nil {
+			transaction.Fee, transaction.FeeAccountID = fee.amount, &fee.accountID
+		}
+		if conversion != nil {
+			conversion.apply(transaction, destAccount.Currency)
+		}
 
-		if err := store.Transaction().CreateTransaction(transaction); err != nil {
+		if err := store.Transaction().CreateTransaction(ctx, transaction); err != nil {
 			return err
 		}
 
-		// Check sufficient balance
-		if sourceAccount.Balance.LessThan(req.Amount) {
-			transaction.Status = "failed"
-			if updateErr := store.Transaction().UpdateTransactionStatus(transaction.ID, "failed"); updateErr != nil {
-				return updateErr
+		// Check sufficient balance, overdraft limit included. The failed attempt is committed
+		// rather than rolled back so the outcome is recorded and replays of the same key return it.
+		if sourceAccount.SpendableBalance().LessThan(req.Amount) {
+			reason := string(errors.InsufficientBalance)
+			if err := store.Transaction().MarkTransactionFailed(ctx, transaction.ID, reason); err != nil {
+				return err
 			}
-			return errors.ErrInsufficientBalance
+			transaction.Status = "failed"
+			transaction.FailureReason = &reason
+			declined = failedTransferError(transaction)
+			return enqueueTransferEvent(ctx, store, transaction)
 		}
 
-		// Perform the transfer
-		newSourceBalance := sourceAccount.Balance.Sub(req.Amount)
-		newDestBalance := destAccount.Balance.Add(req.Amount)
-
-		// Update accounts
-		if err := store.Account().UpdateAccountBalance(sourceID, newSourceBalance); err != nil {
+		if err := postLedgerEntries(ctx, store, transaction); err != nil {
 			return err
 		}
 
-		if err := store.Account().UpdateAccountBalance(destID, newDestBalance); err != nil {
-			return err
+		// A quote converts a single transfer
+		if conversion != nil && conversion.quote != nil {
+			if err := store.FXQuote().MarkQuoteUsed(ctx, conversion.quote.ID, transaction.ID); err != nil {
+				return err
+			}
 		}
 
 		// Mark transaction as completed
 		transaction.Status = "completed"
-		return store.Transaction().UpdateTransactionStatus(transaction.ID, "completed")
+		if err := store.Transaction().UpdateTransactionStatus(ctx, transaction.ID, "completed"); err != nil {
+			return err
+		}
+		return enqueueTransferEvent(ctx, store, transaction)
 	})
 
 	if err != nil {
@@ -147,11 +266,249 @@ func (s *TransactionService) Transfer(req *TransferRequest) (*domain.Transaction
 		return nil, err
 	}
 
+	if declined != nil {
+		s.logger.Warn("Transfer declined", "transaction_id", transaction.ID, "failure_reason", declined.Code)
+		return nil, declined
+	}
+
 	s.logger.Info("Transfer completed successfully", "transaction_id", transaction.ID)
 	return transaction, nil
 }
 
-func (s *TransactionService) parseAccountIDs(sourceIDStr, destIDStr string) (int64, int64, error) {
+// postTransfer records a transfer whose funds have already been checked, posts its ledger
+// entries, marks it completed and enqueues its event
+func postTransfer(ctx context.Context, store *repository.Store, transaction *domain.Transaction) error {
+	if err := store.Transaction().CreateTransaction(ctx, transaction); err != nil {
+		return err
+	}
+
+	if err := postLedgerEntries(ctx, store, transaction); err != nil {
+		return err
+	}
+
+	transaction.Status = "completed"
+	if err := store.Transaction().UpdateTransactionStatus(ctx, transaction.ID, "completed"); err != nil {
+		return err
+	}
+	return enqueueTransferEvent(ctx, store, transaction)
+}
+
+// postLedgerEntries applies a transaction to the balances of its accounts as balanced ledger
+// entries: the source is debited the amount and the destination credited the credited amount.
+// A transfer's fee is credited to the fee account; a reversal debits the fee it refunds from it.
+func postLedgerEntries(ctx context.Context, store *repository.Store, transaction *domain.Transaction) error {
+	if err := store.Account().PostLedgerEntry(ctx, &domain.LedgerEntry{
+		TransactionID: &transaction.ID,
+		AccountID:     transaction.SourceAccountID,
+		EntryType:     domain.LedgerEntryDebit,
+		Amount:        transaction.Amount,
+	}); err != nil {
+		return err
+	}
+
+	if err := store.Account().PostLedgerEntry(ctx, &domain.LedgerEntry{
+		TransactionID: &transaction.ID,
+		AccountID:     transaction.DestinationAccountID,
+		EntryType:     domain.LedgerEntryCredit,
+		Amount:        transaction.CreditedAmount(),
+	}); err != nil {
+		return err
+	}
+
+	if !transaction.Fee.IsPositive() {
+		return nil
+	}
+
+	entryType := domain.LedgerEntryCredit
+	if transaction.ReversalOf != nil {
+		entryType = domain.LedgerEntryDebit
+	}
+	return store.Account().PostLedgerEntry(ctx, &domain.LedgerEntry{
+		TransactionID: &transaction.ID,
+		AccountID:     *transaction.FeeAccountID,
+		EntryType:     entryType,
+		Amount:        transaction.Fee,
+	})
+}
+
+// lockAccounts locks accounts in ascending ID order, as every operation moving funds does, to
+// avoid deadlocks. An ID given twice is locked once.
+func lockAccounts(ctx context.Context, store *repository.Store, ids ...int64) (map[int64]*domain.Account, error) {
+	sorted := append([]int64(nil), ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	locked := make(map[int64]*domain.Account, len(sorted))
+	for _, id := range sorted {
+		if _, ok := locked[id]; ok {
+			continue
+		}
+		account, err := store.Account().GetAccountForUpdate(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		locked[id] = account
+	}
+
+	return locked, nil
+}
+
+// checkFeeAccount checks that a fee account can collect the fee of a transfer from source
+func checkFeeAccount(source, feeAccount *domain.Account) error {
+	if feeAccount.Status == domain.AccountStatusClosed {
+		return errors.NewAppError(errors.AccountClosed, "fee account is closed").
+			WithDetails(fmt.Sprintf("account_id: %d", feeAccount.ID))
+	}
+	if feeAccount.Currency != source.Currency {
+		return errors.NewAppError(errors.CurrencyMismatch, "fee account holds a different currency").
+			WithDetails(fmt.Sprintf("fee_account_id: %d, fee account: %s, source: %s", feeAccount.ID, feeAccount.Currency, source.Currency))
+	}
+	return nil
+}
+
+// marketRate returns the provider's rate between the currencies of two accounts, or nil when
+// they share a currency and nothing needs converting
+func (s *TransactionService) marketRate(ctx context.Context, sourceID, destID int64) (*decimal.Decimal, error) {
+	source, err := s.store.Account().GetAccount(ctx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	dest, err := s.store.Account().GetAccount(ctx, destID)
+	if err != nil {
+		return nil, err
+	}
+	if source.Currency == dest.Currency {
+		return nil, nil
+	}
+
+	rate, err := lookupRate(ctx, s.rates, source.Currency, dest.Currency)
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// failedTransferError rebuilds the error for a transaction persisted as failed,
+// so the first attempt and any idempotent replay report the same outcome.
+func failedTransferError(tx *domain.Transaction) *errors.AppError {
+	reason := string(errors.InsufficientBalance)
+	if tx.FailureReason != nil {
+		reason = *tx.FailureReason
+	}
+
+	return errors.NewAppError(errors.ErrorCode(reason), strings.ReplaceAll(reason, "_", " ")).
+		WithDetails("transaction_id: " + tx.ID.String())
+}
+
+func (s *TransactionService) GetTransaction(ctx context.Context, transactionID string) (*domain.Transaction, error) {
+	s.logger.Info("Getting transaction", "transaction_id", transactionID)
+
+	id, err := uuid.Parse(transactionID)
+	if err != nil {
+		return nil, errors.ErrInvalidTransactionID
+	}
+
+	transaction, err := s.store.Transaction().GetTransactionByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if transaction == nil {
+		return nil, errors.ErrTransactionNotFound
+	}
+
+	return transaction, nil
+}
+
+func (s *TransactionService) GetTransactionByIdempotencyKey(ctx context.Context, clientID, idempotencyKey string) (*domain.Transaction, error) {
+	s.logger.Info("Getting transaction by idempotency key", "client_id", clientID, "idempotency_key", idempotencyKey)
+
+	transaction, err := s.store.Transaction().GetTransactionByIDempotencyKey(ctx, clientID, idempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+	if transaction == nil {
+		return nil, errors.ErrTransactionNotFound
+	}
+
+	return transaction, nil
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+type ListTransactionsRequest struct {
+	AccountID string
+	Direction string
+	Status    string
+	From      *time.Time
+	To        *time.Time
+	After     *domain.TransactionCursor
+	Limit     int
+}
+
+type TransactionPage struct {
+	Transactions []*domain.Transaction
+	NextCursor   *domain.TransactionCursor // Nil on the last page
+}
+
+// ListAccountTransactions returns one page of an account statement, newest first
+func (s *TransactionService) ListAccountTransactions(ctx context.Context, req *ListTransactionsRequest) (*TransactionPage, error) {
+	s.logger.Info("Listing account transactions", "account_id", req.AccountID)
+
+	accountID, err := strconv.ParseInt(req.AccountID, 10, 64)
+	if err != nil || accountID <= 0 {
+		return nil, errors.ErrInvalidAccountID
+	}
+
+	switch req.Direction {
+	case "", domain.DirectionDebit, domain.DirectionCredit:
+	default:
+		return nil, errors.NewAppError(errors.InvalidInput, "direction must be debit or credit")
+	}
+
+	if req.From != nil && req.To != nil && !req.From.Before(*req.To) {
+		return nil, errors.NewAppError(errors.InvalidInput, "from must be before to")
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+	if limit < 0 || limit > maxPageSize {
+		return nil, errors.NewAppErrorf(errors.InvalidInput, "limit must be between 1 and %d", maxPageSize)
+	}
+
+	// Surface a 404 rather than an empty statement for unknown accounts
+	if _, err := s.store.Account().GetAccount(ctx, accountID); err != nil {
+		return nil, err
+	}
+
+	// Fetch one extra row to know whether another page exists
+	transactions, err := s.store.Transaction().ListTransactions(ctx, domain.TransactionFilter{
+		AccountID: accountID,
+		Direction: req.Direction,
+		Status:    req.Status,
+		From:      req.From,
+		To:        req.To,
+		After:     req.After,
+		Limit:     limit + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &TransactionPage{Transactions: transactions}
+	if len(transactions) > limit {
+		page.Transactions = transactions[:limit]
+		last := page.Transactions[limit-1]
+		page.NextCursor = &domain.TransactionCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+
+	return page, nil
+}
+
+func parseAccountIDs(sourceIDStr, destIDStr string) (int64, int64, error) {
 	sourceID, err := strconv.ParseInt(sourceIDStr, 10, 64)
 	if err != nil || sourceID <= 0 {
 		return 0, 0, errors.ErrInvalidAccountID
@@ -165,7 +522,7 @@ func (s *TransactionService) parseAccountIDs(sourceIDStr, destIDStr string) (int
 	return sourceID, destID, nil
 }
 
-func (s *TransactionService) validateTransfer(sourceID, destID int64, amount decimal.Decimal) error {
+func validateTransfer(sourceID, destID int64, amount decimal.Decimal) error {
 	if sourceID == destID {
 		return errors.ErrSameAccountTransfer
 	}
@@ -174,16 +531,8 @@ func (s *TransactionService) validateTransfer(sourceID, destID int64, amount dec
 		return errors.NewAppError(errors.InvalidAmount, "amount must be positive")
 	}
 
-	// Validate reasonable limits
-	maxAmount := decimal.NewFromInt(1_000_000_000) // 1 billion
-	if amount.GreaterThan(maxAmount) {
-		return errors.NewAppError(errors.InvalidAmount, "amount exceeds maximum limit")
-	}
-
-	minAmount := decimal.NewFromFloat(0.01)
-	if amount.LessThan(minAmount) {
-		return errors.NewAppError(errors.InvalidAmount, "amount below minimum limit")
-	}
+	// The minimum amount and precision depend on the currency; see validateAmountPrecision.
+	// The maximum depends on the source account; see LimitService.checkTransfer.
 
 	return nil
 }
@@ -0,0 +1,546 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"internal-transfers/internal/domain"
+	"internal-transfers/internal/errors"
+	"internal-transfers/internal/repository"
+)
+
+const (
+	// webhookSecretPrefix marks signing secrets so they are recognisable in configuration
+	webhookSecretPrefix = "whsec_"
+	// webhookLeaseMargin is added to the send timeout when claiming deliveries, so a lease
+	// only runs out on a sender that stopped
+	webhookLeaseMargin = time.Minute
+)
+
+// enqueueWebhookDeliveries queues the event for every subscription it matches. It runs in the
+// transaction that records the event, so deliveries exist exactly for committed events.
+func enqueueWebhookDeliveries(ctx context.Context, store *repository.Store, event *domain.Event) error {
+	subscriptions, err := store.Webhook().ListMatchingSubscriptions(ctx, event.Type, event.AccountIDs)
+	if err != nil || len(subscriptions) == 0 {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, errors.InternalError, "failed to encode event")
+	}
+
+	for _, subscription := range subscriptions {
+		err := store.Webhook().CreateDelivery(ctx, &domain.WebhookDelivery{
+			ID:             uuid.New(),
+			SubscriptionID: subscription.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        payload,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WebhookService manages webhook subscriptions and their delivery log
+type WebhookService struct {
+	store  *repository.Store
+	logger *slog.Logger
+}
+
+func NewWebhookService(store *repository.Store, logger *slog.Logger) *WebhookService {
+	return &WebhookService{
+		store:  store,
+		logger: logger,
+	}
+}
+
+type CreateWebhookRequest struct {
+	URL        string
+	EventTypes []string
+	AccountID  string // Optional; only events concerning the account are delivered
+}
+
+// CreateWebhook registers a subscription with a new signing secret. The secret is only returned
+// here; later reads leave it out.
+func (s *WebhookService) CreateWebhook(ctx context.Context, req *CreateWebhookRequest) (*domain.WebhookSubscription, error) {
+	s.logger.Info("Creating webhook", "url", req.URL, "event_types", req.EventTypes, "account_id", req.AccountID)
+
+	if err := validateWebhookURL(req.URL); err != nil {
+		return nil, err
+	}
+	eventTypes, err := validateEventTypes(req.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	subscription := &domain.WebhookSubscription{
+		ID:         uuid.New(),
+		URL:        req.URL,
+		Secret:     secret,
+		EventTypes: eventTypes,
+		Active:     true,
+	}
+
+	if req.AccountID != "" {
+		accountID, err := strconv.ParseInt(req.AccountID, 10, 64)
+		if err != nil || accountID <= 0 {
+			return nil, errors.ErrInvalidAccountID
+		}
+		subscription.AccountID = &accountID
+	}
+
+	if err := s.store.Webhook().CreateSubscription(ctx, subscription); err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+func (s *WebhookService) GetWebhook(ctx context.Context, webhookID string) (*domain.WebhookSubscription, error) {
+	id, err := uuid.Parse(webhookID)
+	if err != nil {
+		return nil, errors.ErrInvalidWebhookID
+	}
+
+	subscription, err := s.store.Webhook().GetSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription == nil {
+		return nil, errors.ErrWebhookNotFound
+	}
+
+	return subscription, nil
+}
+
+// ListWebhooks returns every subscription, or only those of one account when accountID is set
+func (s *WebhookService) ListWebhooks(ctx context.Context, accountID string) ([]*domain.WebhookSubscription, error) {
+	var filter *int64
+	if accountID != "" {
+		id, err := strconv.ParseInt(accountID, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, errors.ErrInvalidAccountID
+		}
+		filter = &id
+	}
+
+	return s.store.Webhook().ListSubscriptions(ctx, filter)
+}
+
+type UpdateWebhookRequest struct {
+	WebhookID  string
+	URL        *string // Nil fields are left unchanged
+	EventTypes []string
+	Active     *bool
+}
+
+// UpdateWebhook changes the URL, event types or active flag of a subscription. Deliveries already
+// queued keep their payload and are sent to the new URL.
+func (s *WebhookService) UpdateWebhook(ctx context.Context, req *UpdateWebhookRequest) (*domain.WebhookSubscription, error) {
+	s.logger.Info("Updating webhook", "webhook_id", req.WebhookID)
+
+	id, err := uuid.Parse(req.WebhookID)
+	if err != nil {
+		return nil, errors.ErrInvalidWebhookID
+	}
+
+	if req.URL != nil {
+		if err := validateWebhookURL(*req.URL); err != nil {
+			return nil, err
+		}
+	}
+	var eventTypes []string
+	if req.EventTypes != nil {
+		if eventTypes, err = validateEventTypes(req.EventTypes); err != nil {
+			return nil, err
+		}
+	}
+
+	var subscription *domain.WebhookSubscription
+	err = s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		var err error
+		subscription, err = store.Webhook().GetSubscriptionForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if subscription == nil {
+			return errors.ErrWebhookNotFound
+		}
+
+		if req.URL != nil {
+			subscription.URL = *req.URL
+		}
+		if eventTypes != nil {
+			subscription.EventTypes = eventTypes
+		}
+		if req.Active != nil {
+			subscription.Active = *req.Active
+		}
+
+		return store.Webhook().UpdateSubscription(ctx, subscription)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+// DeleteWebhook removes a subscription together with its deliveries and their log
+func (s *WebhookService) DeleteWebhook(ctx context.Context, webhookID string) (*domain.WebhookSubscription, error) {
+	id, err := uuid.Parse(webhookID)
+	if err != nil {
+		return nil, errors.ErrInvalidWebhookID
+	}
+
+	subscription, err := s.store.Webhook().DeleteSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription == nil {
+		return nil, errors.ErrWebhookNotFound
+	}
+
+	s.logger.Info("Webhook deleted", "webhook_id", id)
+	return subscription, nil
+}
+
+type ListWebhookDeliveriesRequest struct {
+	WebhookID string
+	Status    string // Optional; pending, succeeded or dead_letter
+	Limit     int
+}
+
+// ListDeliveries returns the most recent deliveries of a subscription with their attempts
+func (s *WebhookService) ListDeliveries(ctx context.Context, req *ListWebhookDeliveriesRequest) ([]*domain.WebhookDelivery, error) {
+	if req.Status != "" && !slices.Contains(domain.WebhookDeliveryStatuses, req.Status) {
+		return nil, errors.NewAppErrorf(errors.InvalidInput, "status must be one of %s", strings.Join(domain.WebhookDeliveryStatuses, ", "))
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+	if limit < 0 || limit > maxPageSize {
+		return nil, errors.NewAppErrorf(errors.InvalidInput, "limit must be between 1 and %d", maxPageSize)
+	}
+
+	subscription, err := s.GetWebhook(ctx, req.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.store.Webhook().ListDeliveries(ctx, subscription.ID, req.Status, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadAttempts(ctx, deliveries...); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (s *WebhookService) GetDelivery(ctx context.Context, webhookID, deliveryID string) (*domain.WebhookDelivery, error) {
+	delivery, err := s.getDelivery(ctx, s.store, webhookID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadAttempts(ctx, delivery); err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// Redeliver queues a delivery again, whatever its status, with a fresh set of attempts. The
+// dispatcher sends it on its next round; the payload, event ID and delivery ID are unchanged.
+func (s *WebhookService) Redeliver(ctx context.Context, webhookID, deliveryID string) (*domain.WebhookDelivery, error) {
+	s.logger.Info("Redelivering webhook delivery", "webhook_id", webhookID, "delivery_id", deliveryID)
+
+	var delivery *domain.WebhookDelivery
+	err := s.store.WithTransaction(ctx, func(store *repository.Store) error {
+		var err error
+		delivery, err = s.getDelivery(ctx, store, webhookID, deliveryID)
+		if err != nil {
+			return err
+		}
+		return store.Webhook().RequeueDelivery(ctx, delivery)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.loadAttempts(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func (s *WebhookService) getDelivery(ctx context.Context, store *repository.Store, webhookID, deliveryID string) (*domain.WebhookDelivery, error) {
+	subscriptionID, err := uuid.Parse(webhookID)
+	if err != nil {
+		return nil, errors.ErrInvalidWebhookID
+	}
+	id, err := uuid.Parse(deliveryID)
+	if err != nil {
+		return nil, errors.ErrInvalidWebhookDeliveryID
+	}
+
+	delivery, err := store.Webhook().GetDelivery(ctx, subscriptionID, id)
+	if err != nil {
+		return nil, err
+	}
+	if delivery == nil {
+		return nil, errors.ErrWebhookDeliveryNotFound
+	}
+
+	return delivery, nil
+}
+
+// loadAttempts fills in the attempt log of the deliveries
+func (s *WebhookService) loadAttempts(ctx context.Context, deliveries ...*domain.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(deliveries))
+	for i, delivery := range deliveries {
+		ids[i] = delivery.ID
+	}
+
+	attempts, err := s.store.Webhook().ListAttempts(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, delivery := range deliveries {
+		delivery.AttemptLog = attempts[delivery.ID]
+	}
+	return nil
+}
+
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.NewAppError(errors.InvalidInput, "url must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// validateEventTypes checks a subscription's event types and drops duplicates
+func validateEventTypes(eventTypes []string) ([]string, error) {
+	if len(eventTypes) == 0 {
+		return nil, errors.NewAppError(errors.InvalidInput, "event_types must list at least one event type")
+	}
+
+	var unique []string
+	for _, eventType := range eventTypes {
+		if !slices.Contains(domain.EventTypes, eventType) {
+			return nil, errors.NewAppErrorf(errors.InvalidInput, "unknown event type %q, expected one of %s", eventType, strings.Join(domain.EventTypes, ", "))
+		}
+		if !slices.Contains(unique, eventType) {
+			unique = append(unique, eventType)
+		}
+	}
+	return unique, nil
+}
+
+func newWebhookSecret() (string, error) {
+	key := make([]byte, 24)
+	if _, err := rand.Read(key); err != nil {
+		return "", errors.Wrap(err, errors.InternalError, "failed to generate webhook secret")
+	}
+	return webhookSecretPrefix + hex.EncodeToString(key), nil
+}
+
+type WebhookDispatcherConfig struct {
+	Interval        time.Duration // How often due deliveries are sent; zero disables the dispatcher
+	BatchSize       int           // Deliveries claimed per round
+	Timeout         time.Duration // Bounds each attempt; also sizes the claim lease
+	MaxAttempts     int           // Attempts before a delivery is dead-lettered
+	RetryBaseDelay  time.Duration // Wait after a first failed attempt, doubled per failure
+	RetryMaxBackoff time.Duration // Upper bound of the wait between attempts
+}
+
+// WebhookDispatcher periodically sends due webhook deliveries. A failed attempt is retried with
+// exponential backoff until MaxAttempts, after which the delivery is dead-lettered. Deliveries
+// are claimed with a lease, so several instances can dispatch side by side.
+type WebhookDispatcher struct {
+	store  *repository.Store
+	sender domain.WebhookSender
+	logger *slog.Logger
+	config WebhookDispatcherConfig
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewWebhookDispatcher(store *repository.Store, sender domain.WebhookSender, logger *slog.Logger, config WebhookDispatcherConfig) *WebhookDispatcher {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 50
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 1
+	}
+	return &WebhookDispatcher{
+		store:  store,
+		sender: sender,
+		logger: logger,
+		config: config,
+	}
+}
+
+// Start launches the background dispatch loop. It is a no-op when the interval is not positive;
+// deliveries then stay pending.
+func (d *WebhookDispatcher) Start() {
+	if d.config.Interval <= 0 {
+		d.logger.Info("Webhook dispatcher disabled")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		ticker := time.NewTicker(d.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := d.Dispatch(ctx); err != nil && ctx.Err() == nil {
+					d.logger.Error("Webhook dispatch failed", "error", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	d.logger.Info("Webhook dispatcher started", "interval", d.config.Interval, "batch_size", d.config.BatchSize)
+}
+
+// Stop cancels the dispatch loop, including in-flight attempts, and waits for it to exit
+func (d *WebhookDispatcher) Stop() {
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.wg.Wait()
+}
+
+// Dispatch sends due deliveries until none are left, and returns how many attempts were made
+func (d *WebhookDispatcher) Dispatch(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		now := time.Now()
+		due, err := d.store.Webhook().ClaimDueDeliveries(ctx, now, now.Add(d.config.Timeout+webhookLeaseMargin), d.config.BatchSize)
+		if err != nil {
+			return total, err
+		}
+
+		// Subscriptions are read once per round; most deliveries share a few of them
+		subscriptions := make(map[uuid.UUID]*domain.WebhookSubscription)
+		for _, delivery := range due {
+			subscription, ok := subscriptions[delivery.SubscriptionID]
+			if !ok {
+				if subscription, err = d.store.Webhook().GetSubscription(ctx, delivery.SubscriptionID); err != nil {
+					return total, err
+				}
+				subscriptions[delivery.SubscriptionID] = subscription
+			}
+			if subscription == nil {
+				continue // Deleted since the claim, taking its deliveries with it
+			}
+
+			if err := d.attempt(ctx, subscription, delivery); err != nil {
+				return total, err
+			}
+			total++
+		}
+
+		if len(due) < d.config.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+// attempt sends a claimed delivery once and records the outcome
+func (d *WebhookDispatcher) attempt(ctx context.Context, subscription *domain.WebhookSubscription, delivery *domain.WebhookDelivery) error {
+	started := time.Now()
+	statusCode, sendErr := d.sender.Send(ctx, subscription, delivery)
+	if ctx.Err() != nil {
+		// Shutting down; the lease runs out and the delivery is claimed again
+		return ctx.Err()
+	}
+
+	attempt := &domain.WebhookAttempt{
+		Duration:    time.Since(started),
+		AttemptedAt: started,
+	}
+	delivery.Attempts++
+	delivery.LastStatusCode = nil
+	if statusCode != 0 {
+		attempt.StatusCode = &statusCode
+		delivery.LastStatusCode = &statusCode
+	}
+
+	switch {
+	case sendErr == nil:
+		delivery.Status = domain.WebhookDeliverySucceeded
+		delivery.LastError = nil
+		delivery.DeliveredAt = &started
+	case delivery.Attempts >= d.config.MaxAttempts:
+		message := sendErr.Error()
+		attempt.Error, delivery.LastError = &message, &message
+		delivery.Status = domain.WebhookDeliveryDeadLetter
+		d.logger.Warn("Webhook delivery dead-lettered",
+			"webhook_id", subscription.ID,
+			"delivery_id", delivery.ID,
+			"event_id", delivery.EventID,
+			"attempts", delivery.Attempts,
+			"error", sendErr)
+	default:
+		message := sendErr.Error()
+		attempt.Error, delivery.LastError = &message, &message
+		delay := retryBackoff(d.config.RetryBaseDelay, d.config.RetryMaxBackoff, delivery.Attempts)
+		delivery.NextAttemptAt = time.Now().Add(delay)
+		d.logger.Warn("Webhook delivery failed",
+			"webhook_id", subscription.ID,
+			"delivery_id", delivery.ID,
+			"event_id", delivery.EventID,
+			"attempt", delivery.Attempts,
+			"retry_in", delay,
+			"error", sendErr)
+	}
+
+	err := d.store.WithTransaction(ctx, func(store *repository.Store) error {
+		return store.Webhook().RecordAttempt(ctx, delivery, attempt)
+	})
+	if err == errors.ErrWebhookDeliveryNotFound {
+		return nil // The subscription was deleted while the attempt was in flight
+	}
+	return err
+}
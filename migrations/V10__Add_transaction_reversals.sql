@@ -0,0 +1,5 @@
+-- Reversals are transfers in the opposite direction linked to the transaction they undo
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS reversal_of UUID REFERENCES transactions(id);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS reversed_amount DECIMAL(20, 8) NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD CONSTRAINT transactions_reversed_amount_check CHECK (reversed_amount >= 0 AND reversed_amount <= amount);
+CREATE INDEX IF NOT EXISTS idx_transactions_reversal_of ON transactions(reversal_of) WHERE reversal_of IS NOT NULL;
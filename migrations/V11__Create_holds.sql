@@ -0,0 +1,29 @@
+-- Funds reserved by holds stay in balance but are not available to transfers
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS held_balance DECIMAL(20, 8) NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD CONSTRAINT accounts_held_balance_check CHECK (held_balance >= 0 AND held_balance <= balance);
+
+-- Holds reserve an amount on a source account until captured into a transfer, voided or expired
+CREATE TABLE IF NOT EXISTS holds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source_account_id BIGINT NOT NULL REFERENCES accounts(id),
+    destination_account_id BIGINT NOT NULL REFERENCES accounts(id),
+    amount DECIMAL(20, 8) NOT NULL CHECK (amount > 0),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('active', 'captured', 'voided', 'expired')),
+    transaction_id UUID REFERENCES transactions(id),
+    client_id VARCHAR(255) NOT NULL DEFAULT '',
+    idempotency_key VARCHAR(255),
+    request_hash VARCHAR(64),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT different_hold_accounts CHECK (source_account_id != destination_account_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_holds_idempotency_key ON holds (client_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_holds_source_account_id ON holds(source_account_id);
+CREATE INDEX IF NOT EXISTS idx_holds_active_expires_at ON holds(expires_at) WHERE status = 'active';
+
+DROP TRIGGER IF EXISTS update_holds_updated_at ON holds;
+CREATE TRIGGER update_holds_updated_at 
+    BEFORE UPDATE ON holds
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
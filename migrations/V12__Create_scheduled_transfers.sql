@@ -0,0 +1,25 @@
+-- Transfers submitted with an execute_at timestamp, run by the scheduler once due
+CREATE TABLE IF NOT EXISTS scheduled_transfers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source_account_id BIGINT NOT NULL REFERENCES accounts(id),
+    destination_account_id BIGINT NOT NULL REFERENCES accounts(id),
+    amount DECIMAL(20, 8) NOT NULL CHECK (amount > 0),
+    execute_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('scheduled', 'running', 'completed', 'failed', 'cancelled')),
+    transaction_id UUID REFERENCES transactions(id),
+    failure_reason VARCHAR(255),
+    client_id VARCHAR(255) NOT NULL DEFAULT '',
+    idempotency_key VARCHAR(255),
+    request_hash VARCHAR(64),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT different_scheduled_accounts CHECK (source_account_id != destination_account_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_scheduled_transfers_idempotency_key ON scheduled_transfers (client_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_due ON scheduled_transfers(execute_at) WHERE status IN ('scheduled', 'running');
+
+DROP TRIGGER IF EXISTS update_scheduled_transfers_updated_at ON scheduled_transfers;
+CREATE TRIGGER update_scheduled_transfers_updated_at 
+    BEFORE UPDATE ON scheduled_transfers
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,44 @@
+-- Recurring transfers on a cron or fixed-interval schedule, run by the scheduler
+CREATE TABLE IF NOT EXISTS standing_orders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source_account_id BIGINT NOT NULL REFERENCES accounts(id),
+    destination_account_id BIGINT NOT NULL REFERENCES accounts(id),
+    amount DECIMAL(20, 8) NOT NULL CHECK (amount > 0),
+    cron_expression VARCHAR(100),
+    interval_seconds BIGINT CHECK (interval_seconds > 0),
+    start_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    end_at TIMESTAMP WITH TIME ZONE,
+    max_runs INTEGER CHECK (max_runs > 0),
+    run_count INTEGER NOT NULL DEFAULT 0,
+    next_run_at TIMESTAMP WITH TIME ZONE, -- NULL once the order has completed or been cancelled
+    status VARCHAR(20) NOT NULL CHECK (status IN ('active', 'paused', 'completed', 'cancelled')),
+    locked_until TIMESTAMP WITH TIME ZONE, -- Lease held by the scheduler worker running the order
+    client_id VARCHAR(255) NOT NULL DEFAULT '',
+    idempotency_key VARCHAR(255),
+    request_hash VARCHAR(64),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT different_standing_order_accounts CHECK (source_account_id != destination_account_id),
+    CONSTRAINT standing_order_schedule CHECK ((cron_expression IS NULL) != (interval_seconds IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_standing_orders_idempotency_key ON standing_orders (client_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_standing_orders_due ON standing_orders(next_run_at) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_standing_orders_source_account ON standing_orders(source_account_id);
+
+DROP TRIGGER IF EXISTS update_standing_orders_updated_at ON standing_orders;
+CREATE TRIGGER update_standing_orders_updated_at 
+    BEFORE UPDATE ON standing_orders
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- One row per executed period; the unique period keeps a run from being recorded twice
+CREATE TABLE IF NOT EXISTS standing_order_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    standing_order_id UUID NOT NULL REFERENCES standing_orders(id),
+    period TIMESTAMP WITH TIME ZONE NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('completed', 'failed')),
+    transaction_id UUID REFERENCES transactions(id),
+    failure_reason VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT standing_order_runs_period_key UNIQUE (standing_order_id, period)
+);
@@ -0,0 +1,19 @@
+-- Exchange rates locked by clients ahead of an FX transfer
+CREATE TABLE IF NOT EXISTS fx_quotes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source_currency CHAR(3) NOT NULL,
+    destination_currency CHAR(3) NOT NULL,
+    rate DECIMAL(24, 12) NOT NULL CHECK (rate > 0),
+    client_id VARCHAR(255) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    transaction_id UUID REFERENCES transactions(id), -- Set once a transfer has used the quote
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT different_fx_quote_currencies CHECK (source_currency != destination_currency)
+);
+
+-- Transfers between accounts of different currencies record the conversion they applied.
+-- amount stays in the source currency; destination_amount is what the destination was credited.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS destination_amount DECIMAL(20, 8) CHECK (destination_amount > 0);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS destination_currency CHAR(3);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fx_rate DECIMAL(24, 12) CHECK (fx_rate > 0);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fx_quote_id UUID REFERENCES fx_quotes(id);
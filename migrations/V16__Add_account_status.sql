@@ -0,0 +1,17 @@
+-- Accounts can be frozen by compliance or closed. Existing accounts are active.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'frozen', 'closed'));
+
+-- Audit trail of every status change, with the reason given for it
+CREATE TABLE IF NOT EXISTS account_status_changes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id BIGINT NOT NULL REFERENCES accounts(id),
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL CHECK (reason != ''),
+    client_id VARCHAR(255) NOT NULL DEFAULT '', -- Client that requested the change
+    sweep_transaction_id UUID REFERENCES transactions(id), -- Transfer that emptied a closed account
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_status_changes_account ON account_status_changes(account_id, created_at);
@@ -0,0 +1,31 @@
+-- Accounts belong to a tier that limits can be defined for. Existing accounts are standard.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS tier VARCHAR(50) NOT NULL DEFAULT 'standard';
+
+-- Transfer limits defined globally, per tier or per account. Amounts are in the currency of the
+-- source account; global and tier limits can be restricted to accounts of one currency.
+CREATE TABLE IF NOT EXISTS transfer_limits (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    scope VARCHAR(20) NOT NULL CHECK (scope IN ('global', 'tier', 'account')),
+    tier VARCHAR(50) NULL,
+    account_id BIGINT NULL REFERENCES accounts(id),
+    limit_type VARCHAR(20) NOT NULL CHECK (limit_type IN ('per_transfer', 'daily', 'monthly')),
+    currency CHAR(3) NULL, -- NULL applies to every currency
+    amount DECIMAL(20, 8) NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT transfer_limit_target CHECK (
+        (scope = 'global' AND tier IS NULL AND account_id IS NULL) OR
+        (scope = 'tier' AND tier IS NOT NULL AND account_id IS NULL) OR
+        (scope = 'account' AND tier IS NULL AND account_id IS NOT NULL AND currency IS NULL)
+    ),
+    -- One limit of each type per target and currency
+    CONSTRAINT unique_transfer_limit UNIQUE NULLS NOT DISTINCT (scope, tier, account_id, limit_type, currency)
+);
+
+DROP TRIGGER IF EXISTS update_transfer_limits_updated_at ON transfer_limits;
+CREATE TRIGGER update_transfer_limits_updated_at 
+    BEFORE UPDATE ON transfer_limits
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Daily and monthly usage sums an account's outgoing transfers since the start of the window
+CREATE INDEX IF NOT EXISTS idx_transactions_source_created_at ON transactions(source_account_id, created_at);
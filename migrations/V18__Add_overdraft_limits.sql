@@ -0,0 +1,11 @@
+-- Accounts with an overdraft limit can go negative down to minus the limit. Existing accounts get none.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS overdraft_limit DECIMAL(20, 8) NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD CONSTRAINT accounts_overdraft_limit_check CHECK (overdraft_limit >= 0);
+
+-- Replace the non-negative balance checks from V1 and V11 with ones that allow the overdraft
+ALTER TABLE accounts DROP CONSTRAINT accounts_balance_check;
+ALTER TABLE accounts ADD CONSTRAINT accounts_balance_check CHECK (balance >= -overdraft_limit);
+
+ALTER TABLE accounts DROP CONSTRAINT accounts_held_balance_check;
+ALTER TABLE accounts ADD CONSTRAINT accounts_held_balance_check
+    CHECK (held_balance >= 0 AND held_balance <= balance + overdraft_limit);
@@ -0,0 +1,57 @@
+-- Fee schedules price the transfers an account sends: globally, optionally for one currency, or
+-- per account. Every schedule is a list of amount tiers; flat and percentage schedules have one.
+CREATE TABLE IF NOT EXISTS fee_schedules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    scope VARCHAR(20) NOT NULL CHECK (scope IN ('global', 'account')),
+    account_id BIGINT NULL REFERENCES accounts(id),
+    currency CHAR(3) NULL, -- NULL applies to every currency
+    fee_type VARCHAR(20) NOT NULL CHECK (fee_type IN ('flat', 'percentage', 'tiered')),
+    min_fee DECIMAL(20, 8) NULL CHECK (min_fee >= 0),
+    max_fee DECIMAL(20, 8) NULL CHECK (max_fee >= 0),
+    fee_account_id BIGINT NULL REFERENCES accounts(id), -- NULL collects into the configured FEE_ACCOUNT_ID
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fee_schedule_target CHECK (
+        (scope = 'global' AND account_id IS NULL) OR
+        (scope = 'account' AND account_id IS NOT NULL AND currency IS NULL)
+    ),
+    CONSTRAINT fee_schedule_bounds CHECK (min_fee IS NULL OR max_fee IS NULL OR min_fee <= max_fee),
+    -- One schedule per target and currency
+    CONSTRAINT unique_fee_schedule UNIQUE NULLS NOT DISTINCT (scope, account_id, currency)
+);
+
+DROP TRIGGER IF EXISTS update_fee_schedules_updated_at ON fee_schedules;
+CREATE TRIGGER update_fee_schedules_updated_at
+    BEFORE UPDATE ON fee_schedules
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- A transfer uses the first tier whose up_to covers its amount; the last tier covers the rest
+CREATE TABLE IF NOT EXISTS fee_schedule_tiers (
+    schedule_id UUID NOT NULL REFERENCES fee_schedules(id) ON DELETE CASCADE,
+    position INT NOT NULL CHECK (position >= 0),
+    up_to DECIMAL(20, 8) NULL CHECK (up_to > 0), -- NULL on the last tier only
+    amount DECIMAL(20, 8) NOT NULL DEFAULT 0 CHECK (amount >= 0),
+    percentage DECIMAL(9, 6) NOT NULL DEFAULT 0 CHECK (percentage >= 0 AND percentage <= 100),
+    PRIMARY KEY (schedule_id, position)
+);
+
+-- The fee charged by a transfer, or refunded by a reversal, and the account it is paid into or
+-- refunded from. Fees are in the currency the original transfer was sent in.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fee_amount DECIMAL(20, 8) NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fee_account_id BIGINT NULL REFERENCES accounts(id);
+ALTER TABLE transactions ADD CONSTRAINT transactions_fee_check CHECK (
+    fee_amount >= 0 AND
+    (fee_amount = 0 OR fee_account_id IS NOT NULL) AND
+    (reversal_of IS NOT NULL OR fee_amount < amount)
+);
+
+-- The net amount is the gross amount less the fee: what reaches the destination before any
+-- conversion. A reversal takes its net amount back from the destination and the fee from the
+-- fee account.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS net_amount DECIMAL(20, 8) GENERATED ALWAYS AS (
+    CASE
+        WHEN reversal_of IS NULL THEN amount - fee_amount
+        WHEN destination_amount IS NOT NULL THEN destination_amount - fee_amount
+        ELSE amount
+    END
+) STORED;
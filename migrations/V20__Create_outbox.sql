@@ -0,0 +1,19 @@
+-- Events are written in the same database transaction as the change they describe and relayed to
+-- a publisher afterwards, at least once. The sequence orders events: events sharing an account
+-- are published in sequence order.
+CREATE TABLE IF NOT EXISTS outbox (
+    sequence BIGSERIAL PRIMARY KEY,
+    id UUID NOT NULL UNIQUE, -- Stable across redeliveries, for consumers to deduplicate on
+    event_type VARCHAR(100) NOT NULL,
+    account_ids BIGINT[] NOT NULL, -- Accounts the event concerns; ordering is kept per account
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    attempts INT NOT NULL DEFAULT 0, -- Failed deliveries so far
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT NULL,
+    published_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+-- The relay reads pending events in sequence order; the purge finds old published ones
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(sequence) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_published_at ON outbox(published_at) WHERE published_at IS NOT NULL;
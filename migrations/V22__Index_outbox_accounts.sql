@@ -0,0 +1,2 @@
+-- Account streams read the events concerning one account, after the sequence a client last saw
+CREATE INDEX IF NOT EXISTS idx_outbox_account_ids ON outbox USING GIN (account_ids);
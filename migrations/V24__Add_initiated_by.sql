@@ -0,0 +1,6 @@
+-- The authenticated principal that created a transaction: "api_key:<id>", "jwt:<subject>" or
+-- "admin". Scheduled transfers and standing orders keep theirs for the transactions they run.
+-- NULL for rows created before authentication or with it disabled.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS initiated_by VARCHAR(255) NULL;
+ALTER TABLE scheduled_transfers ADD COLUMN IF NOT EXISTS initiated_by VARCHAR(255) NULL;
+ALTER TABLE standing_orders ADD COLUMN IF NOT EXISTS initiated_by VARCHAR(255) NULL;
@@ -0,0 +1,7 @@
+-- Events that run out of delivery attempts are dead-lettered: the relay stops publishing them, and
+-- they no longer hold up the later events of their accounts
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS dead_lettered_at TIMESTAMP WITH TIME ZONE NULL;
+
+-- The relay reads the events neither published nor dead-lettered
+DROP INDEX IF EXISTS idx_outbox_pending;
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(sequence) WHERE published_at IS NULL AND dead_lettered_at IS NULL;
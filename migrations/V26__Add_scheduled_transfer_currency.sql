@@ -0,0 +1,4 @@
+-- Currency a scheduled transfer runs in, taken from its source account when it was scheduled
+ALTER TABLE scheduled_transfers ADD COLUMN IF NOT EXISTS currency CHAR(3);
+UPDATE scheduled_transfers s SET currency = a.currency FROM accounts a WHERE a.id = s.source_account_id AND s.currency IS NULL;
+ALTER TABLE scheduled_transfers ALTER COLUMN currency SET NOT NULL;
@@ -0,0 +1,2 @@
+-- Persist why a transfer was declined so failed attempts can be reported and replayed
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS failure_reason VARCHAR(255);
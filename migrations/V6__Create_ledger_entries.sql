@@ -0,0 +1,22 @@
+-- Double-entry ledger: every balance change is recorded as a debit or credit entry
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    transaction_id UUID NULL,
+    account_id BIGINT NOT NULL,
+    entry_type VARCHAR(10) NOT NULL CHECK (entry_type IN ('debit', 'credit')),
+    amount DECIMAL(20, 8) NOT NULL CHECK (amount > 0),
+    balance_after DECIMAL(20, 8) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id),
+    FOREIGN KEY (account_id) REFERENCES accounts(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_account_id ON ledger_entries(account_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_transaction_id ON ledger_entries(transaction_id);
+
+-- Opening entries so balances of existing accounts can be rebuilt from the ledger
+INSERT INTO ledger_entries (account_id, entry_type, amount, balance_after)
+SELECT id, 'credit', balance, balance
+FROM accounts
+WHERE balance > 0
+  AND NOT EXISTS (SELECT 1 FROM ledger_entries le WHERE le.account_id = accounts.id);
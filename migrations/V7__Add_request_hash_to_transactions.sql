@@ -0,0 +1,2 @@
+-- Fingerprint of the transfer payload stored with its idempotency key to detect key reuse
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS request_hash VARCHAR(64);
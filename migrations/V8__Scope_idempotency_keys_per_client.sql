@@ -0,0 +1,14 @@
+-- Idempotency keys become opaque strings scoped per client, for transfers and account creation
+
+-- Original UNIQUE constraint from V1 would make keys global across clients
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_idempotency_key_key;
+DROP INDEX IF EXISTS idx_transactions_idempotency_key;
+
+ALTER TABLE transactions ALTER COLUMN idempotency_key TYPE VARCHAR(255) USING idempotency_key::text;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS client_id VARCHAR(255) NOT NULL DEFAULT '';
+CREATE UNIQUE INDEX idx_transactions_idempotency_key ON transactions (client_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS client_id VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(255);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS request_hash VARCHAR(64);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_idempotency_key ON accounts (client_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
@@ -0,0 +1,26 @@
+-- Batches group transfers executed atomically (or best effort) under one idempotency key
+CREATE TABLE IF NOT EXISTS transaction_batches (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    client_id VARCHAR(255) NOT NULL DEFAULT '',
+    idempotency_key VARCHAR(255),
+    request_hash VARCHAR(64),
+    mode VARCHAR(20) NOT NULL CHECK (mode IN ('atomic', 'best_effort')),
+    status VARCHAR(50) NOT NULL,
+    item_count INT NOT NULL CHECK (item_count > 0),
+    failure_reason VARCHAR(255),
+    failed_item INT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transaction_batches_idempotency_key ON transaction_batches (client_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_transaction_batches_created_at ON transaction_batches(created_at);
+
+DROP TRIGGER IF EXISTS update_transaction_batches_updated_at ON transaction_batches;
+CREATE TRIGGER update_transaction_batches_updated_at 
+    BEFORE UPDATE ON transaction_batches
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS batch_id UUID REFERENCES transaction_batches(id);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS batch_item INT;
+CREATE INDEX IF NOT EXISTS idx_transactions_batch_id ON transactions(batch_id, batch_item);